@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/metar"
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+// MetarConfig configures where METAR reports for airport tasks come from
+type MetarConfig struct {
+	// Archive is a file of raw reports, or a directory of them
+	Archive string `json:"archive,omitempty"`
+	// URL is an HTTP source of raw reports, queried with ids, hours and date
+	URL     string        `json:"url,omitempty"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// Validate checks the METAR source settings
+func (c MetarConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("METAR_TIMEOUT must be positive")
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid METAR_URL %q", c.URL)
+		}
+	}
+	return nil
+}
+
+// MetarSource is a source of METAR and SPECI reports
+type MetarSource interface {
+	Name() string
+	// FetchMetars returns the station's reports made in [start, end)
+	FetchMetars(ctx context.Context, station string, start, end time.Time) ([]metar.Report, error)
+}
+
+// metarSources returns the configured sources in the order they are tried:
+// the local archive, then the HTTP source
+func metarSources(cfg MetarConfig) []MetarSource {
+	var sources []MetarSource
+	if cfg.Archive != "" {
+		sources = append(sources, NewMetarArchive(cfg.Archive))
+	}
+	if cfg.URL != "" {
+		sources = append(sources, NewMetarHTTPSource(cfg.URL, &http.Client{Timeout: cfg.Timeout}))
+	}
+	return sources
+}
+
+// MetarArchive reads raw reports from a file, or every file in a directory.
+// A report may be preceded by its time, either as a "YYYYMMDDHHMM " prefix
+// or on a "YYYY/MM/DD HH:MM" line of its own as in NOAA station files;
+// reports without one are placed by the end of the requested window.
+// Indented lines continue the report before them.
+type MetarArchive struct {
+	path string
+}
+
+// NewMetarArchive returns an archive reading path
+func NewMetarArchive(path string) *MetarArchive {
+	return &MetarArchive{path: path}
+}
+
+func (a *MetarArchive) Name() string { return "metar-archive" }
+
+var (
+	archivePrefixRe = regexp.MustCompile(`^(\d{12})\s+(.+)$`)
+	archiveStampRe  = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}$`)
+)
+
+func (a *MetarArchive) FetchMetars(ctx context.Context, station string, start, end time.Time) ([]metar.Report, error) {
+	files, err := archiveFiles(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read METAR archive: %w", err)
+	}
+	var reports []metar.Report
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read METAR archive: %w", err)
+		}
+		found, err := readMetars(f, station, start, end)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read METAR archive %s: %w", path, err)
+		}
+		reports = append(reports, found...)
+	}
+	return reports, nil
+}
+
+// archiveFiles returns path if it is a file, or the files in it if it is a directory
+func archiveFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// readMetars returns the station's reports in r made in [start, end).
+// Lines that are not reports, and NIL reports, are skipped.
+func readMetars(r io.Reader, station string, start, end time.Time) ([]metar.Report, error) {
+	var reports []metar.Report
+	var text string
+	var ref, stamp time.Time
+	flush := func() {
+		if text == "" || !strings.Contains(text, station) {
+			text = ""
+			return
+		}
+		if ref.IsZero() {
+			ref = end
+		}
+		report, err := metar.Parse(text, ref)
+		text = ""
+		if err != nil || report.Station != station || report.Time.Before(start) || !report.Time.Before(end) {
+			return
+		}
+		reports = append(reports, *report)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if text != "" && strings.TrimSpace(line) != "" && (line[0] == ' ' || line[0] == '\t') {
+			text += " " + strings.TrimSpace(line)
+			continue
+		}
+		flush()
+		line = strings.TrimSpace(line)
+		switch m := archivePrefixRe.FindStringSubmatch(line); {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case archiveStampRe.MatchString(line):
+			stamp, _ = time.Parse("2006/01/02 15:04", line)
+		case m != nil:
+			ref, _ = time.Parse("200601021504", m[1])
+			text, stamp = m[2], time.Time{}
+		default:
+			ref, text, stamp = stamp, line, time.Time{}
+		}
+	}
+	flush()
+	return reports, scanner.Err()
+}
+
+// MetarHTTPSource fetches raw reports over HTTP from an endpoint shaped
+// like the aviationweather.gov data API:
+// GET url?ids=KJFK&format=raw&hours=N&date=<end, RFC 3339>
+type MetarHTTPSource struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewMetarHTTPSource returns a source querying baseURL with httpClient
+func NewMetarHTTPSource(baseURL string, httpClient *http.Client) *MetarHTTPSource {
+	return &MetarHTTPSource{url: baseURL, httpClient: httpClient}
+}
+
+func (s *MetarHTTPSource) Name() string { return "metar-http" }
+
+func (s *MetarHTTPSource) FetchMetars(ctx context.Context, station string, start, end time.Time) ([]metar.Report, error) {
+	hours := int((end.Sub(start) + time.Hour - 1) / time.Hour)
+	query := url.Values{
+		"ids":    {station},
+		"format": {"raw"},
+		"hours":  {fmt.Sprint(hours)},
+		"date":   {end.UTC().Format(time.RFC3339)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch METARs: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("METAR source returned status %d", resp.StatusCode)
+	}
+	return readMetars(resp.Body, station, start, end)
+}
+
+// MetarRequirement asks for a station's reports over a window
+type MetarRequirement struct {
+	Station string    `json:"station"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// fetchMetars returns the reports of the first source that has any for
+// the window, and that source's name. Only the sources named in sources
+// are asked if any of them is configured.
+func (w *SunReWorker) fetchMetars(ctx context.Context, need *MetarRequirement, sources []string) ([]metar.Report, string, error) {
+	if len(w.metar) == 0 {
+		return nil, "", errors.New("no METAR source configured (METAR_ARCHIVE or METAR_URL)")
+	}
+	var errs []error
+	for _, source := range preferred(w.metar, sources) {
+		reports, err := source.FetchMetars(ctx, need.Station, need.Start, need.End)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+		if len(reports) > 0 {
+			return reports, source.Name(), nil
+		}
+	}
+	errs = append(errs, fmt.Errorf("no METARs for %s between %s and %s",
+		need.Station, need.Start.Format(time.RFC3339), need.End.Format(time.RFC3339)))
+	return nil, "", errors.Join(errs...)
+}
+
+// airportPerilName selects airport weather tasks
+const airportPerilName = "airport"
+
+// maxAirportWindow is the longest coverage period an airport task may summarise
+const maxAirportWindow = 31 * 24 * time.Hour
+
+// AirportTerms are the airport part of an airport task. The window is the
+// task's coverage period.
+type AirportTerms struct {
+	// Station is the ICAO identifier of the reporting airport
+	Station string `json:"station"`
+	// Minimums define the time below minimums; they default to IFR
+	Minimums *metar.Minimums `json:"minimums,omitempty"`
+	// RunwayHeading, in degrees, enables the crosswind index
+	RunwayHeading int `json:"runway_heading,omitempty"`
+	// MaxGapMinutes is how long a report stands without a newer one; default 90
+	MaxGapMinutes int `json:"max_gap_minutes,omitempty"`
+}
+
+// maxGap is how long each report stands for
+func (t *AirportTerms) maxGap() time.Duration {
+	if t.MaxGapMinutes == 0 {
+		return metar.DefaultMaxGap
+	}
+	return time.Duration(t.MaxGapMinutes) * time.Minute
+}
+
+var icaoRe = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}$`)
+
+// airportVariables are the indices a payout on an airport task can use
+var airportVariables = []string{
+	"hours_below_minimums", "thunderstorm_hours", "thunderstorm",
+	"min_visibility", "min_ceiling", "max_gust", "max_crosswind",
+}
+
+// airportSchema is the JSON Schema of airport task payloads
+const airportSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Airport weather task",
+  "type": "object",
+  "required": ["type", "policy_id", "location", "coverage", "airport"],
+  "properties": {
+    "type": {"const": "airport"},
+    "policy_id": {"type": "string", "minLength": 1},
+    "requester": {"type": "string", "description": "address that created the task; selects its tenant"},
+    "location": {"type": "object", "required": ["latitude", "longitude"]},
+    "coverage": {
+      "type": "object",
+      "anyOf": [{"required": ["start", "end"]}, {"required": ["local_start", "local_end"]}],
+      "properties": {
+        "start": {"type": "integer"}, "end": {"type": "integer"},
+        "local_start": {"type": "string", "description": "wall-clock time at the location, YYYY-MM-DDTHH:MM[:SS]"},
+        "local_end": {"type": "string"},
+        "time_zone": {"type": "string", "description": "IANA zone of the local times; resolved from the location when empty"}
+      }
+    },
+    "airport": {
+      "type": "object",
+      "required": ["station"],
+      "properties": {
+        "station": {"type": "string", "pattern": "^[A-Z][A-Z0-9]{3}$"},
+        "minimums": {
+          "type": "object",
+          "properties": {"ceiling_ft": {"type": "integer", "minimum": 0}, "visibility_m": {"type": "integer", "minimum": 0}}
+        },
+        "runway_heading": {"type": "integer", "minimum": 0, "maximum": 360},
+        "max_gap_minutes": {"type": "integer", "minimum": 0, "maximum": 360}
+      }
+    },
+    "payout": {
+      "type": "object",
+      "required": ["structure", "variable"],
+      "properties": {
+        "structure": {"type": "object"},
+        "variable": {"enum": ["hours_below_minimums", "thunderstorm_hours", "thunderstorm", "min_visibility", "min_ceiling", "max_gust", "max_crosswind"]}
+      }
+    }
+  }
+}`
+
+// AirportResult is the airport block of a result
+type AirportResult struct {
+	Station string `json:"station"`
+	*metar.Summary
+	HoursBelowMinimums payout.Decimal `json:"hours_below_minimums"`
+	ThunderstormHours  payout.Decimal `json:"thunderstorm_hours"`
+	MissingHours       payout.Decimal `json:"missing_hours"`
+}
+
+// airportPeril summarises the METARs of an airport over the coverage
+// period, for flight delay and airport disruption cover
+type airportPeril struct{}
+
+func (airportPeril) Spec() PerilSpec {
+	return PerilSpec{
+		Name:        airportPerilName,
+		Description: "METAR reports of an airport over the coverage period: time below ceiling and visibility minimums, thunderstorms, gusts and crosswind",
+		Variables:   airportVariables,
+		Schema:      json.RawMessage(airportSchema),
+	}
+}
+
+func (airportPeril) Validate(req *WeatherVerificationRequest) error {
+	terms := req.Airport
+	if terms == nil {
+		return fmt.Errorf("airport task requires airport terms")
+	}
+	if !icaoRe.MatchString(terms.Station) {
+		return fmt.Errorf("invalid ICAO station %q", terms.Station)
+	}
+	if req.Coverage == nil {
+		return fmt.Errorf("airport task requires a coverage period")
+	}
+	if time.Duration(req.Coverage.End-req.Coverage.Start)*time.Second > maxAirportWindow {
+		return fmt.Errorf("airport coverage period is longer than %s", maxAirportWindow)
+	}
+	if m := terms.Minimums; m != nil && (m.CeilingFt < 0 || m.VisibilityM < 0) {
+		return fmt.Errorf("airport minimums must not be negative")
+	}
+	if terms.RunwayHeading < 0 || terms.RunwayHeading > 360 {
+		return fmt.Errorf("invalid runway_heading %d", terms.RunwayHeading)
+	}
+	if terms.MaxGapMinutes < 0 || terms.MaxGapMinutes > 360 {
+		return fmt.Errorf("max_gap_minutes must be between 0 and 360")
+	}
+	return nil
+}
+
+// Requirements asks for the reports over the coverage period, and those
+// made early enough before it to still stand at its start
+func (airportPeril) Requirements(req *WeatherVerificationRequest) DataRequirements {
+	return DataRequirements{Metar: &MetarRequirement{
+		Station: req.Airport.Station,
+		Start:   time.Unix(req.Coverage.Start, 0).UTC().Add(-req.Airport.maxGap()),
+		End:     time.Unix(req.Coverage.End, 0).UTC(),
+	}}
+}
+
+func (airportPeril) Evaluate(env PerilEnv, req *WeatherVerificationRequest, data *PerilData) (map[string]interface{}, error) {
+	summary := summarizeAirport(req, data)
+	return map[string]interface{}{
+		"airport": &AirportResult{
+			Station:            req.Airport.Station,
+			Summary:            summary,
+			HoursBelowMinimums: secondsToHours(summary.BelowMinimumsSeconds),
+			ThunderstormHours:  secondsToHours(summary.ThunderstormSeconds),
+			MissingHours:       secondsToHours(summary.MissingSeconds),
+		},
+		"verified": summary.BelowMinimumsSeconds > 0 || summary.Thunderstorm,
+		"source":   data.MetarSource,
+	}, nil
+}
+
+func (airportPeril) Index(req *WeatherVerificationRequest, data *PerilData, variable string) (payout.Decimal, error) {
+	s := summarizeAirport(req, data)
+	switch variable {
+	case "hours_below_minimums":
+		return secondsToHours(s.BelowMinimumsSeconds), nil
+	case "thunderstorm_hours":
+		return secondsToHours(s.ThunderstormSeconds), nil
+	case "thunderstorm":
+		if s.Thunderstorm {
+			return payout.Scale, nil
+		}
+		return 0, nil
+	case "min_visibility":
+		if s.MinVisibilityM == nil {
+			return 0, fmt.Errorf("no visibility reported at %s", req.Airport.Station)
+		}
+		return payout.Decimal(*s.MinVisibilityM) * payout.Scale, nil
+	case "min_ceiling":
+		if s.MinCeilingFt == nil {
+			return 0, fmt.Errorf("no ceiling reported at %s", req.Airport.Station)
+		}
+		return payout.Decimal(*s.MinCeilingFt) * payout.Scale, nil
+	case "max_gust":
+		return payout.Decimal(s.MaxGustKt) * payout.Scale, nil
+	case "max_crosswind":
+		if req.Airport.RunwayHeading == 0 {
+			return 0, fmt.Errorf("max_crosswind needs a runway_heading")
+		}
+		return payout.Decimal(s.MaxCrosswindKt) * payout.Scale, nil
+	default:
+		return 0, fmt.Errorf("invalid payout index variable %q", variable)
+	}
+}
+
+func (airportPeril) Encode(result map[string]interface{}) ([]byte, error) {
+	return canonicalJSON(result)
+}
+
+// summarizeAirport summarises the fetched reports over req's coverage period
+func summarizeAirport(req *WeatherVerificationRequest, data *PerilData) *metar.Summary {
+	opts := metar.SummaryOptions{RunwayHeading: req.Airport.RunwayHeading, MaxGap: req.Airport.maxGap()}
+	if req.Airport.Minimums != nil {
+		opts.Minimums = *req.Airport.Minimums
+	}
+	return metar.Summarize(data.Metar, time.Unix(req.Coverage.Start, 0), time.Unix(req.Coverage.End, 0), opts)
+}
+
+// secondsToHours converts whole seconds to hours in fixed point, rounding down
+func secondsToHours(seconds int64) payout.Decimal {
+	return payout.Decimal(seconds * payout.Scale / 3600)
+}
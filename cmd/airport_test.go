@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+// jfkArchive mixes the archive formats MetarArchive reads
+const jfkArchive = `# KJFK, 12 July 2024
+202407120951 METAR KJFK 120951Z 18010KT 10SM BKN040 24/20 A2992
+202407121051 METAR KJFK 121051Z 18012KT 2SM BR OVC008 24/22 A2990
+202407121055 METAR EGLL 121050Z 27010KT 9999 FEW040 20/12 Q1015
+2024/07/12 11:20
+SPECI KJFK 121120Z 20018G30KT 1/2SM +TSRA
+    OVC003CB 22/21 A2988
+KJFK 121151Z 21010KT 6SM -RA BKN012 22/21 A2990 RETS
+KJFK 121251Z NIL
+not a report
+KJFK 121421Z 21008KT 10SM FEW050 23/20 A2992
+`
+
+var (
+	jfkStart = time.Date(2024, 7, 12, 10, 0, 0, 0, time.UTC)
+	jfkEnd   = time.Date(2024, 7, 12, 15, 0, 0, 0, time.UTC)
+)
+
+func TestMetarArchive(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "KJFK.txt"), []byte(jfkArchive), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := NewMetarArchive(dir)
+	reports, err := archive.FetchMetars(context.Background(), "KJFK", jfkStart.Add(-90*time.Minute), jfkEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 5 {
+		t.Fatalf("reports = %+v", reports)
+	}
+	// The continuation line belongs to the SPECI
+	if r := reports[2]; r.Kind != "SPECI" || len(r.Clouds) != 1 || r.Clouds[0].BaseFt != 300 || !r.Time.Equal(time.Date(2024, 7, 12, 11, 20, 0, 0, time.UTC)) {
+		t.Errorf("SPECI = %+v", r)
+	}
+
+	reports, err = archive.FetchMetars(context.Background(), "KJFK", jfkStart, jfkStart.Add(time.Hour))
+	if err != nil || len(reports) != 1 {
+		t.Errorf("10:00-11:00 = %+v, %v", reports, err)
+	}
+	if _, err := NewMetarArchive(filepath.Join(dir, "missing")).FetchMetars(context.Background(), "KJFK", jfkStart, jfkEnd); err == nil {
+		t.Error("read a missing archive")
+	}
+}
+
+func TestMetarHTTPSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("ids") != "KJFK" || q.Get("format") != "raw" || q.Get("hours") != "7" || q.Get("date") != "2024-07-12T15:00:00Z" {
+			http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(jfkArchive))
+	}))
+	defer srv.Close()
+
+	source := NewMetarHTTPSource(srv.URL, srv.Client())
+	reports, err := source.FetchMetars(context.Background(), "KJFK", jfkStart.Add(-90*time.Minute), jfkEnd)
+	if err != nil || len(reports) != 5 {
+		t.Fatalf("reports = %+v, %v", reports, err)
+	}
+	if _, err := source.FetchMetars(context.Background(), "KJFK", jfkStart, jfkEnd); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("bad query: %v", err)
+	}
+}
+
+func TestAirportPeril_HandleTask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metar.txt")
+	if err := os.WriteFile(path, []byte(jfkArchive), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return jfkEnd }
+	worker.metar = metarSources(MetarConfig{Archive: path})
+
+	payload := `{"type": "airport", "policy_id": "POL-JFK", "location": {"latitude": 40.64, "longitude": -73.78},
+		"coverage": {"start": 1720778400, "end": 1720796400},
+		"airport": {"station": "KJFK", "runway_heading": 40},
+		"payout": {"variable": "hours_below_minimums", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "2", "sum_insured": "1000"}}}`
+	task := &performerV1.TaskRequest{TaskId: []byte("task-jfk"), Payload: []byte(payload)}
+	if err := worker.ValidateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Type     string         `json:"type"`
+		Verified bool           `json:"verified"`
+		Source   string         `json:"source"`
+		Airport  *AirportResult `json:"airport"`
+		Payout   *payout.Result `json:"payout"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	a := result.Airport
+	if result.Type != TaskTypePayout || !result.Verified || result.Source != "metar-archive" || a == nil || a.Station != "KJFK" {
+		t.Fatalf("result = %s", resp.Result)
+	}
+	if a.HoursBelowMinimums.String() != "1" || a.MissingHours.String() != "1" || !a.Thunderstorm || a.MaxCrosswindKt != 10 {
+		t.Errorf("airport = %+v", a)
+	}
+	if result.Payout == nil || result.Payout.Payout.String() != "500" {
+		t.Errorf("payout = %+v", result.Payout)
+	}
+	records := worker.policies.List("POL-JFK")
+	if len(records) != 1 || records[0].Peril != "hours_below_minimums" || records[0].State != PolicySettled {
+		t.Errorf("records = %+v", records)
+	}
+
+	// Without a source the task fails rather than summarising nothing
+	worker.metar = nil
+	task = &performerV1.TaskRequest{TaskId: []byte("task-jfk-2"), Payload: []byte(strings.Replace(payload, "POL-JFK", "POL-JFK-2", 1))}
+	if _, err := worker.HandleTask(task); err == nil || !strings.Contains(err.Error(), "no METAR source") {
+		t.Errorf("HandleTask without a source: %v", err)
+	}
+}
+
+func TestAirportPeril_Validate(t *testing.T) {
+	for name, c := range map[string]struct {
+		payload string
+		err     string
+	}{
+		"no terms":    {`{"coverage": {"start": 1, "end": 2}}`, "requires airport terms"},
+		"bad station": {`{"coverage": {"start": 1, "end": 2}, "airport": {"station": "jfk"}}`, "invalid ICAO station"},
+		"no coverage": {`{"airport": {"station": "KJFK"}}`, "requires a coverage period"},
+		"long window": {`{"coverage": {"start": 0, "end": 5000000}, "airport": {"station": "KJFK"}}`, "longer than"},
+		"runway":      {`{"coverage": {"start": 1, "end": 2}, "airport": {"station": "KJFK", "runway_heading": 400}}`, "invalid runway_heading"},
+		"variable": {`{"coverage": {"start": 1, "end": 2}, "airport": {"station": "KJFK"},
+			"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "1", "sum_insured": "1"}}}`, "invalid payout index variable"},
+	} {
+		var req WeatherVerificationRequest
+		if err := json.Unmarshal([]byte(c.payload), &req); err != nil {
+			t.Fatal(err)
+		}
+		req.Type, req.PolicyID = airportPerilName, "P"
+		if err := req.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: Validate() = %v", name, err)
+		}
+	}
+}
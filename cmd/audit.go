@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditConfig configures the verification audit log
+type AuditConfig struct {
+	// Dir holds the audit files; empty disables auditing
+	Dir string `json:"dir,omitempty"`
+	// MaxBytes starts a new file once the current one reaches this size; zero disables
+	MaxBytes int64 `json:"max_bytes"`
+	// Daily starts a new file on the first record of each UTC day
+	Daily bool `json:"daily"`
+}
+
+// Validate checks the audit settings
+func (c AuditConfig) Validate() error {
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("AUDIT_MAX_BYTES must not be negative")
+	}
+	return nil
+}
+
+// auditGenesisHash is the previous hash of the first record
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// AuditRecord is one verification in the audit log. Hash covers every other
+// field, including PrevHash, so editing, removing or reordering records
+// breaks the chain.
+type AuditRecord struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	TaskID string    `json:"task_id"`
+	// PayloadHash is the SHA-256 of the task payload as received
+	PayloadHash string `json:"payload_hash"`
+	// Tenant is the tenant that submitted the task
+	Tenant string `json:"tenant,omitempty"`
+	// Policy is the request the result was computed for
+	Policy *WeatherVerificationRequest `json:"policy,omitempty"`
+	// Sources lists every provider and station the weather was derived from
+	Sources []string `json:"sources,omitempty"`
+	// Provenance is the weather data the result was built from; it can be
+	// replayed with verify -provenance
+	Provenance *WeatherData `json:"provenance,omitempty"`
+	// OutputHash is the SHA-256 of the canonical result the operator signs
+	OutputHash string `json:"output_hash,omitempty"`
+	// Replayed marks a task answered with a previously computed result, for
+	// a retry or a settled policy period
+	Replayed bool `json:"replayed,omitempty"`
+	// FetchError explains why fallback data was used
+	FetchError string `json:"fetch_error,omitempty"`
+	Error      string `json:"error,omitempty"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// computeHash returns the hash of the record with its Hash field cleared
+func (r AuditRecord) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditSources lists the sources behind data: the provider, each station
+// and each corroborating provider
+func auditSources(data *WeatherData) []string {
+	sources := []string{data.Source}
+	for _, s := range data.Stations {
+		sources = append(sources, data.Source+"/"+s.ID)
+	}
+	for _, c := range data.Corroborating {
+		sources = append(sources, c.Source)
+	}
+	return sources
+}
+
+// AuditLog appends hash-chained records to rotating JSONL files
+type AuditLog struct {
+	cfg      AuditConfig
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	day      string
+	seq      uint64
+	lastHash string
+	now      func() time.Time
+}
+
+// auditFileName names a file after the first sequence number it holds, so
+// names sort in chain order
+func auditFileName(seq uint64) string {
+	return fmt.Sprintf("audit-%012d.jsonl", seq)
+}
+
+// auditFiles lists the audit files in dir in chain order
+func auditFiles(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// OpenAuditLog opens the log in cfg.Dir, continuing the chain from its last record
+func OpenAuditLog(cfg AuditConfig) (*AuditLog, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	l := &AuditLog{cfg: cfg, lastHash: auditGenesisHash, now: time.Now}
+
+	paths, err := auditFiles(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	// A crash right after rotation leaves an empty file; the chain then
+	// continues from the last record of the file before it
+	var last string
+	var record *AuditRecord
+	for len(paths) > 0 {
+		last, paths = paths[len(paths)-1], paths[:len(paths)-1]
+		if record, err = lastAuditRecord(last); err != nil {
+			return nil, err
+		}
+		if record != nil {
+			break
+		}
+		if err := os.Remove(last); err != nil {
+			return nil, fmt.Errorf("failed to remove empty audit file: %w", err)
+		}
+	}
+	if record == nil {
+		return l, nil
+	}
+	l.seq, l.lastHash = record.Seq, record.Hash
+
+	f, err := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.file, l.size, l.day = f, info.Size(), record.Time.UTC().Format("2006-01-02")
+	return l, nil
+}
+
+// lastAuditRecord returns the last record in path, or nil if it has none
+func lastAuditRecord(path string) (*AuditRecord, error) {
+	var last *AuditRecord
+	err := scanAuditFile(path, func(line int, r *AuditRecord, raw []byte) error {
+		last = r
+		return nil
+	})
+	return last, err
+}
+
+// scanAuditFile calls fn for each record in path
+func scanAuditFile(path string, fn func(line int, r *AuditRecord, raw []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read audit file: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(raw)) > 0 {
+			var r AuditRecord
+			if jsonErr := json.Unmarshal(raw, &r); jsonErr != nil {
+				return fmt.Errorf("%s:%d: invalid record: %w", filepath.Base(path), line, jsonErr)
+			}
+			if fnErr := fn(line, &r, raw); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit file: %w", err)
+		}
+	}
+}
+
+// Append chains r to the previous record and writes it. A nil log discards it.
+func (l *AuditLog) Append(r AuditRecord) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.Time = l.now().UTC()
+	r.PrevHash = l.lastHash
+	hash, err := r.computeHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash audit record: %w", err)
+	}
+	r.Hash = hash
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if err := l.rotate(r.Seq, r.Time.Format("2006-01-02"), int64(len(line))); err != nil {
+		return err
+	}
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	// The record must survive a crash right after the operator signs
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit file: %w", err)
+	}
+	l.size += int64(len(line))
+	l.seq, l.lastHash = r.Seq, r.Hash
+	return nil
+}
+
+// rotate starts a new file if there is none, the day changed or the next
+// record would exceed the size limit
+func (l *AuditLog) rotate(seq uint64, day string, next int64) error {
+	if l.file != nil {
+		full := l.cfg.MaxBytes > 0 && l.size > 0 && l.size+next > l.cfg.MaxBytes
+		if !full && (!l.cfg.Daily || day == l.day) {
+			return nil
+		}
+		if err := l.file.Close(); err != nil {
+			return fmt.Errorf("failed to close audit file: %w", err)
+		}
+		l.file = nil
+	}
+	f, err := os.OpenFile(filepath.Join(l.cfg.Dir, auditFileName(seq)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create audit file: %w", err)
+	}
+	l.file, l.size, l.day = f, 0, day
+	return nil
+}
+
+// Close closes the current file. A nil log has nothing to close.
+func (l *AuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// ErrAuditChainBroken is returned when the audit log fails verification
+var ErrAuditChainBroken = errors.New("audit chain broken")
+
+// AuditVerifyReport summarises a verified audit log
+type AuditVerifyReport struct {
+	Files    int    `json:"files"`
+	Records  uint64 `json:"records"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+	LastHash string `json:"last_hash"`
+}
+
+// VerifyAuditLog checks every record's hash and its link to the previous
+// one. With allowTruncated the chain may start after the genesis record, as
+// it does once old files have been archived.
+func VerifyAuditLog(dir string, allowTruncated bool) (*AuditVerifyReport, error) {
+	paths, err := auditFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &AuditVerifyReport{Files: len(paths)}
+	var prev *AuditRecord
+	for _, path := range paths {
+		name := filepath.Base(path)
+		err := scanAuditFile(path, func(line int, r *AuditRecord, raw []byte) error {
+			at := fmt.Sprintf("%s:%d (seq %d)", name, line, r.Seq)
+			hash, err := r.computeHash()
+			if err != nil {
+				return err
+			}
+			if hash != r.Hash {
+				return fmt.Errorf("%w at %s: record hash does not match its contents", ErrAuditChainBroken, at)
+			}
+			switch {
+			case prev == nil && !allowTruncated && (r.Seq != 1 || r.PrevHash != auditGenesisHash):
+				return fmt.Errorf("%w at %s: chain does not start at the genesis record", ErrAuditChainBroken, at)
+			case prev != nil && r.Seq != prev.Seq+1:
+				return fmt.Errorf("%w at %s: expected seq %d", ErrAuditChainBroken, at, prev.Seq+1)
+			case prev != nil && r.PrevHash != prev.Hash:
+				return fmt.Errorf("%w at %s: previous hash does not match seq %d", ErrAuditChainBroken, at, prev.Seq)
+			}
+			if prev == nil {
+				report.FirstSeq = r.Seq
+			}
+			prev = r
+			report.Records++
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	if prev != nil {
+		report.LastSeq, report.LastHash = prev.Seq, prev.Hash
+	}
+	return report, nil
+}
+
+// FindAuditRecords returns every record for taskID, oldest first
+func FindAuditRecords(dir, taskID string) ([]AuditRecord, error) {
+	paths, err := auditFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var found []AuditRecord
+	needle := []byte(taskID)
+	for _, path := range paths {
+		err := scanAuditFile(path, func(line int, r *AuditRecord, raw []byte) error {
+			if bytes.Contains(raw, needle) && r.TaskID == taskID {
+				found = append(found, *r)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
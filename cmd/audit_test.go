@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// appendTestRecords appends one record per task ID
+func appendTestRecords(t *testing.T, log *AuditLog, taskIDs ...string) {
+	t.Helper()
+	for _, id := range taskIDs {
+		if err := log.Append(AuditRecord{TaskID: id, PayloadHash: sha256Hex([]byte(id))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditLog_ChainsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	log, err := OpenAuditLog(AuditConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestRecords(t, log, "task-1", "task-2")
+	log.Close()
+
+	log, err = OpenAuditLog(AuditConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestRecords(t, log, "task-3")
+	log.Close()
+
+	report, err := VerifyAuditLog(dir, false)
+	if err != nil {
+		t.Fatalf("VerifyAuditLog() = %v", err)
+	}
+	if report.Records != 3 || report.Files != 1 || report.LastSeq != 3 {
+		t.Errorf("report = %+v, want 3 records in 1 file", report)
+	}
+}
+
+func TestAuditLog_RecoversFromEmptyRotatedFile(t *testing.T) {
+	dir := t.TempDir()
+	log, err := OpenAuditLog(AuditConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestRecords(t, log, "task-1", "task-2")
+	log.Close()
+	// A crash after rotation created the next file but before anything was written to it
+	if err := os.WriteFile(filepath.Join(dir, auditFileName(3)), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err = OpenAuditLog(AuditConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestRecords(t, log, "task-3")
+	log.Close()
+
+	report, err := VerifyAuditLog(dir, false)
+	if err != nil {
+		t.Fatalf("VerifyAuditLog() = %v", err)
+	}
+	if report.Records != 3 || report.LastSeq != 3 {
+		t.Errorf("report = %+v, want the chain continued to 3", report)
+	}
+}
+
+func TestAuditLog_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	log, err := OpenAuditLog(AuditConfig{Dir: dir, MaxBytes: 1, Daily: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestRecords(t, log, "task-1", "task-2")
+
+	// A new day rotates even when the size limit is not reached
+	log.cfg.MaxBytes = 0
+	log.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	appendTestRecords(t, log, "task-3", "task-4")
+	log.Close()
+
+	paths, _ := auditFiles(dir)
+	var names []string
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	want := []string{auditFileName(1), auditFileName(2), auditFileName(3)}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+	if _, err := VerifyAuditLog(dir, false); err != nil {
+		t.Errorf("VerifyAuditLog() = %v", err)
+	}
+
+	// Archiving the first file is only accepted as a truncated chain
+	os.Remove(paths[0])
+	if _, err := VerifyAuditLog(dir, false); !errors.Is(err, ErrAuditChainBroken) {
+		t.Errorf("VerifyAuditLog() without the first file = %v", err)
+	}
+	if report, err := VerifyAuditLog(dir, true); err != nil || report.FirstSeq != 2 {
+		t.Errorf("VerifyAuditLog(allowTruncated) = %+v, %v", report, err)
+	}
+}
+
+func TestVerifyAuditLog_DetectsTampering(t *testing.T) {
+	tamper := map[string]func(lines []string) []string{
+		"edited field": func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "task-2", "task-X", 1)
+			return lines
+		},
+		"removed record": func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		},
+		"reordered records": func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+	}
+	for name, fn := range tamper {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			log, err := OpenAuditLog(AuditConfig{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			appendTestRecords(t, log, "task-1", "task-2", "task-3")
+			log.Close()
+
+			path := filepath.Join(dir, auditFileName(1))
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			writeFile(t, path, strings.Join(fn(lines), "\n")+"\n")
+
+			if _, err := VerifyAuditLog(dir, false); !errors.Is(err, ErrAuditChainBroken) {
+				t.Errorf("VerifyAuditLog() = %v, want ErrAuditChainBroken", err)
+			}
+		})
+	}
+}
+
+func TestSunReWorker_HandleTaskAudits(t *testing.T) {
+	dir := t.TempDir()
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(&staticProvider{data: WeatherData{Temperature: 21, Source: "static", Timestamp: time.Now()}})
+	var err error
+	if worker.audit, err = OpenAuditLog(AuditConfig{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer worker.audit.Close()
+
+	payload := []byte(`{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-AUDIT"}`)
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-ok"), Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-bad"), Payload: []byte("{")}); err == nil {
+		t.Fatal("HandleTask accepted an invalid payload")
+	}
+
+	records, err := FindAuditRecords(dir, "task-ok")
+	if err != nil || len(records) != 1 {
+		t.Fatalf("FindAuditRecords() = %v, %v", records, err)
+	}
+	r := records[0]
+	if r.OutputHash != sha256Hex(resp.Result) || r.PayloadHash != sha256Hex(payload) {
+		t.Error("record hashes do not match the task")
+	}
+	if r.Policy == nil || r.Policy.PolicyID != "POL-AUDIT" || r.Provenance == nil || r.Provenance.Source != "static" {
+		t.Errorf("record = %+v, want the policy and provenance", r)
+	}
+	if r.Error != "" {
+		t.Errorf("Error = %q", r.Error)
+	}
+
+	records, _ = FindAuditRecords(dir, "task-bad")
+	if len(records) != 1 || !strings.Contains(records[0].Error, "invalid task payload") || records[0].OutputHash != "" {
+		t.Errorf("failed task records = %+v", records)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"audit", "verify", "-dir", dir}, &stdout, &stderr); code != exitOK || !strings.HasPrefix(stdout.String(), "OK: 2 records") {
+		t.Errorf("audit verify = %d, %q %s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"audit", "find", "-dir", dir, "-task", "task-ok"}, &stdout, &stderr); code != exitOK || !strings.Contains(stdout.String(), "POL-AUDIT") {
+		t.Errorf("audit find = %d, %q %s", code, stdout.String(), stderr.String())
+	}
+	if code := run([]string{"audit", "find", "-dir", dir, "-task", "missing"}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("audit find for an unknown task = %d, want %d", code, exitFailure)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a provider circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// ResilienceConfig configures provider circuit breakers and retries
+type ResilienceConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens a breaker
+	FailureThreshold int `json:"failure_threshold"`
+	// OpenTimeout is how long a breaker stays open before letting probes through
+	OpenTimeout time.Duration `json:"open_timeout"`
+	// HalfOpenProbes is the number of probe requests, and successes needed to close
+	HalfOpenProbes int `json:"half_open_probes"`
+	// MaxAttempts bounds the calls made per fetch, including the first
+	MaxAttempts int `json:"max_attempts"`
+	// BaseDelay is the backoff before the first retry; it doubles per retry up to MaxDelay
+	BaseDelay time.Duration `json:"base_delay"`
+	MaxDelay  time.Duration `json:"max_delay"`
+}
+
+// DefaultResilienceConfig returns the breaker and retry settings used by the performer
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenProbes:   1,
+		MaxAttempts:      3,
+		BaseDelay:        200 * time.Millisecond,
+		MaxDelay:         2 * time.Second,
+	}
+}
+
+// Validate checks the breaker and retry settings
+func (c ResilienceConfig) Validate() error {
+	if c.FailureThreshold <= 0 {
+		return fmt.Errorf("BREAKER_FAILURE_THRESHOLD must be positive")
+	}
+	if c.OpenTimeout <= 0 {
+		return fmt.Errorf("BREAKER_OPEN_TIMEOUT must be positive")
+	}
+	if c.HalfOpenProbes <= 0 {
+		return fmt.Errorf("BREAKER_HALF_OPEN_PROBES must be positive")
+	}
+	if c.MaxAttempts <= 0 {
+		return fmt.Errorf("RETRY_MAX_ATTEMPTS must be positive")
+	}
+	if c.BaseDelay < 0 || c.MaxDelay < c.BaseDelay {
+		return fmt.Errorf("RETRY_MAX_DELAY must be at least RETRY_BASE_DELAY")
+	}
+	return nil
+}
+
+// BreakerStatus is a snapshot of a provider's breaker for readiness and metrics
+type BreakerStatus struct {
+	Provider            string       `json:"provider"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	Trips               uint64       `json:"trips"`
+	Retries             uint64       `json:"retries"`
+	Rejected            uint64       `json:"rejected"`
+}
+
+// CircuitBreaker stops calls to a failing provider and probes it before resuming
+type CircuitBreaker struct {
+	mu             sync.Mutex
+	cfg            ResilienceConfig
+	state          BreakerState
+	failures       int
+	openedAt       time.Time
+	probesInFlight int
+	probeSuccesses int
+	trips          uint64
+	rejected       uint64
+	now            func() time.Time
+}
+
+// NewCircuitBreaker creates a closed breaker
+func NewCircuitBreaker(cfg ResilienceConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:   cfg,
+		state: BreakerClosed,
+		now:   time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by exactly one Record or Release.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.state = BreakerHalfOpen
+		b.probesInFlight = 0
+		b.probeSuccesses = 0
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		b.rejected++
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.probesInFlight+b.probeSuccesses >= b.cfg.HalfOpenProbes {
+			b.rejected++
+			return ErrCircuitOpen
+		}
+		b.probesInFlight++
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call
+func (b *CircuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.endProbe()
+		if !success {
+			b.trip()
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.cfg.HalfOpenProbes {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+	case BreakerClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.trip()
+		}
+	}
+}
+
+// Release ends an allowed call without counting it as a success or failure
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.endProbe()
+	}
+}
+
+// endProbe frees a probe slot; calls allowed before the breaker opened hold none
+func (b *CircuitBreaker) endProbe() {
+	if b.probesInFlight > 0 {
+		b.probesInFlight--
+	}
+}
+
+func (b *CircuitBreaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	b.trips++
+}
+
+// State returns the current breaker state
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// ResilientProvider wraps a provider with a circuit breaker and bounded retries
+type ResilientProvider struct {
+	inner   WeatherProvider
+	breaker *CircuitBreaker
+	cfg     ResilienceConfig
+	logger  *zap.Logger
+	retries uint64
+	mu      sync.Mutex
+	sleep   func(ctx context.Context, d time.Duration) error
+	jitter  func(d time.Duration) time.Duration
+}
+
+// NewResilientProvider wraps inner with a breaker and retry policy
+func NewResilientProvider(inner WeatherProvider, cfg ResilienceConfig, logger *zap.Logger) *ResilientProvider {
+	return &ResilientProvider{
+		inner:   inner,
+		breaker: NewCircuitBreaker(cfg),
+		cfg:     cfg,
+		logger:  logger,
+		sleep:   sleepContext,
+		jitter:  equalJitter,
+	}
+}
+
+// withResilience wraps inner as NewResilientProvider does, keeping FetchAt
+// when inner is a historical provider
+func withResilience(inner WeatherProvider, cfg ResilienceConfig, logger *zap.Logger) WeatherProvider {
+	p := NewResilientProvider(inner, cfg, logger)
+	if hp, ok := inner.(HistoricalProvider); ok {
+		return &historicalResilientProvider{ResilientProvider: p, inner: hp}
+	}
+	return p
+}
+
+// historicalResilientProvider is a ResilientProvider around a historical
+// provider; past and current fetches share the breaker
+type historicalResilientProvider struct {
+	*ResilientProvider
+	inner HistoricalProvider
+}
+
+// FetchAt asks the wrapped provider for at, with the breaker and retries
+// FetchCurrent has
+func (p *historicalResilientProvider) FetchAt(ctx context.Context, location Location, at time.Time) (*WeatherData, error) {
+	return p.call(ctx, func(ctx context.Context) (*WeatherData, error) {
+		return p.inner.FetchAt(ctx, location, at)
+	})
+}
+
+// Name returns the wrapped provider's name
+func (p *ResilientProvider) Name() string {
+	return p.inner.Name()
+}
+
+// FetchCurrent calls the wrapped provider, retrying failures with exponential
+// backoff. Retries stop when the breaker opens, attempts run out, or the next
+// backoff would not finish before the context deadline. A dry run makes a
+// single attempt, refused unless the breaker is closed, and records nothing.
+func (p *ResilientProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	return p.call(ctx, func(ctx context.Context) (*WeatherData, error) {
+		return p.inner.FetchCurrent(ctx, location)
+	})
+}
+
+// call makes a call through fetch with the breaker and retry policy
+func (p *ResilientProvider) call(ctx context.Context, fetch func(context.Context) (*WeatherData, error)) (*WeatherData, error) {
+	if isDryRun(ctx) {
+		if p.breaker.State() != BreakerClosed {
+			return nil, ErrCircuitOpen
+		}
+		return fetch(ctx)
+	}
+	var lastErr error
+	for attempt := 1; attempt <= p.cfg.MaxAttempts; attempt++ {
+		if err := p.breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w (after: %v)", err, lastErr)
+			}
+			return nil, err
+		}
+
+		data, err := fetch(ctx)
+		if err == nil {
+			p.breaker.Record(true)
+			return data, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			// A call abandoned because the task ran out of time says nothing
+			// about the provider's health
+			p.breaker.Release()
+			break
+		}
+		p.breaker.Record(false)
+		if attempt == p.cfg.MaxAttempts {
+			break
+		}
+
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			break
+		}
+		p.mu.Lock()
+		p.retries++
+		p.mu.Unlock()
+		p.logger.Debug("Retrying weather provider",
+			zap.String("provider", p.inner.Name()),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay),
+			zap.Error(err),
+		)
+		if err := p.sleep(ctx, delay); err != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// backoff returns the jittered delay before retry number attempt
+func (p *ResilientProvider) backoff(attempt int) time.Duration {
+	delay := p.cfg.BaseDelay << (attempt - 1)
+	if delay > p.cfg.MaxDelay || delay <= 0 {
+		delay = p.cfg.MaxDelay
+	}
+	return p.jitter(delay)
+}
+
+// Status returns a snapshot of the provider's breaker
+func (p *ResilientProvider) Status() BreakerStatus {
+	b := p.breaker
+	state := b.State()
+
+	b.mu.Lock()
+	status := BreakerStatus{
+		Provider:            p.inner.Name(),
+		State:               state,
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips,
+		Rejected:            b.rejected,
+	}
+	if state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	b.mu.Unlock()
+
+	p.mu.Lock()
+	status.Retries = p.retries
+	p.mu.Unlock()
+	return status
+}
+
+// equalJitter returns a random delay between d/2 and d
+func equalJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
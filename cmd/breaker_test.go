@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// flakyProvider fails while down is set
+type flakyProvider struct {
+	down  bool
+	calls int
+}
+
+func (p *flakyProvider) Name() string { return "flaky" }
+
+func (p *flakyProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	p.calls++
+	if p.down {
+		return nil, errors.New("API returned status 503")
+	}
+	return &WeatherData{Temperature: 21, Source: "flaky"}, nil
+}
+
+func newTestResilientProvider(inner WeatherProvider, cfg ResilienceConfig) (*ResilientProvider, *time.Time, *[]time.Duration) {
+	now := time.Unix(1704067200, 0)
+	var slept []time.Duration
+	p := NewResilientProvider(inner, cfg, zap.NewNop())
+	p.breaker.now = func() time.Time { return now }
+	p.jitter = func(d time.Duration) time.Duration { return d }
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return p, &now, &slept
+}
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	cfg := DefaultResilienceConfig()
+	cfg.FailureThreshold = 3
+	cfg.HalfOpenProbes = 2
+	b := NewCircuitBreaker(cfg)
+	now := time.Unix(1704067200, 0)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("closed breaker rejected call %d", i)
+		}
+		b.Record(false)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after threshold failures, want open", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("open breaker allowed a call")
+	}
+
+	now = now.Add(cfg.OpenTimeout)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state = %s after open timeout, want half-open", b.State())
+	}
+	if b.Allow() != nil || b.Allow() != nil {
+		t.Fatal("half-open breaker rejected its probes")
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Error("half-open breaker allowed more than its probes")
+	}
+	b.Record(true)
+	if b.State() != BreakerHalfOpen {
+		t.Errorf("state = %s after one of two probes succeeded, want half-open", b.State())
+	}
+	b.Record(true)
+	if b.State() != BreakerClosed {
+		t.Errorf("state = %s after probes succeeded, want closed", b.State())
+	}
+
+	for i := 0; i < 3; i++ {
+		b.Allow()
+		b.Record(false)
+	}
+	now = now.Add(cfg.OpenTimeout)
+	b.Allow()
+	b.Record(false)
+	if b.State() != BreakerOpen {
+		t.Errorf("state = %s after failed probe, want open", b.State())
+	}
+	if b.trips != 3 {
+		t.Errorf("trips = %d, want 3", b.trips)
+	}
+}
+
+func TestResilientProvider_RetriesWithBackoff(t *testing.T) {
+	cfg := DefaultResilienceConfig()
+	cfg.MaxAttempts = 4
+	cfg.BaseDelay = 100 * time.Millisecond
+	cfg.MaxDelay = 250 * time.Millisecond
+	inner := &flakyProvider{down: true}
+	p, _, slept := newTestResilientProvider(inner, cfg)
+
+	if _, err := p.FetchCurrent(context.Background(), Location{}); err == nil {
+		t.Fatal("expected error from a provider that is down")
+	}
+	if inner.calls != 4 {
+		t.Errorf("calls = %d, want 4", inner.calls)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond}
+	if len(*slept) != len(want) {
+		t.Fatalf("backoffs = %v, want %v", *slept, want)
+	}
+	for i := range want {
+		if (*slept)[i] != want[i] {
+			t.Errorf("backoff %d = %v, want %v", i, (*slept)[i], want[i])
+		}
+	}
+	if s := p.Status(); s.Retries != 3 || s.ConsecutiveFailures != 4 {
+		t.Errorf("unexpected status: %+v", s)
+	}
+}
+
+func TestResilientProvider_RespectsDeadline(t *testing.T) {
+	cfg := DefaultResilienceConfig()
+	cfg.BaseDelay = time.Second
+	inner := &flakyProvider{down: true}
+	p, _, slept := newTestResilientProvider(inner, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := p.FetchCurrent(ctx, Location{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if inner.calls != 1 || len(*slept) != 0 {
+		t.Errorf("retried past the deadline: calls=%d backoffs=%v", inner.calls, *slept)
+	}
+}
+
+func TestResilientProvider_StopsCallingOpenProvider(t *testing.T) {
+	cfg := DefaultResilienceConfig()
+	cfg.FailureThreshold = 2
+	cfg.MaxAttempts = 3
+	inner := &flakyProvider{down: true}
+	p, now, _ := newTestResilientProvider(inner, cfg)
+
+	_, err := p.FetchCurrent(context.Background(), Location{})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v, want circuit open", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("calls = %d, want breaker to stop the third attempt", inner.calls)
+	}
+	p.FetchCurrent(context.Background(), Location{})
+	if inner.calls != 2 {
+		t.Error("open breaker let a call through")
+	}
+
+	inner.down = false
+	*now = now.Add(cfg.OpenTimeout)
+	if _, err := p.FetchCurrent(context.Background(), Location{}); err != nil {
+		t.Fatalf("probe after recovery failed: %v", err)
+	}
+	if s := p.Status(); s.State != BreakerClosed || s.Trips != 1 || s.Rejected != 2 {
+		t.Errorf("unexpected status after recovery: %+v", s)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	cfg := DefaultResilienceConfig()
+	cfg.FailureThreshold = 1
+	cfg.MaxAttempts = 1
+	worker := NewSunReWorker(zap.NewNop())
+	provider := NewResilientProvider(&flakyProvider{down: true}, cfg, zap.NewNop())
+	worker.weatherClient.SetProviders(provider)
+
+	check := func(wantCode int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		worker.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		if rec.Code != wantCode {
+			t.Errorf("/ready = %d, want %d: %s", rec.Code, wantCode, rec.Body.String())
+		}
+	}
+
+	check(http.StatusOK)
+	provider.FetchCurrent(context.Background(), Location{})
+	check(http.StatusServiceUnavailable)
+
+	rec := httptest.NewRecorder()
+	worker.metricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics struct {
+		TasksProcessed uint64
+		Providers      []BreakerStatus `json:"providers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics.Providers) != 1 || metrics.Providers[0].State != BreakerOpen {
+		t.Errorf("metrics providers = %+v, want one open breaker", metrics.Providers)
+	}
+}
+
+func TestResilientProvider_KeepsHistory(t *testing.T) {
+	cfg := DefaultResilienceConfig()
+	cfg.FailureThreshold = 1
+	cfg.MaxAttempts = 1
+	if _, ok := withResilience(&flakyProvider{}, cfg, zap.NewNop()).(HistoricalProvider); ok {
+		t.Error("wrapping made a current-only provider historical")
+	}
+	p := withResilience(&archiveProvider{rain: map[string]float64{"2024-04-01": 12}}, cfg, zap.NewNop())
+	hp, ok := p.(HistoricalProvider)
+	if !ok {
+		t.Fatal("wrapping dropped FetchAt")
+	}
+
+	// A failed replay trips the breaker that guards current conditions too
+	if _, err := hp.FetchAt(context.Background(), Location{}, time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Fatal("FetchAt of a missing day succeeded")
+	}
+	if _, err := hp.FetchAt(context.Background(), Location{}, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("FetchAt through an open breaker = %v, want circuit open", err)
+	}
+	if s := hp.(interface{ Status() BreakerStatus }).Status(); s.State != BreakerOpen || s.Trips != 1 {
+		t.Errorf("status = %+v", s)
+	}
+}
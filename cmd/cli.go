@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+const cliUsage = `Usage: sunre-avs <command> [flags]
+
+Commands:
+  serve                 Start the performer (default when no command is given)
+  verify                Recompute a task result and diff it against a claimed one
+  task build            Construct a weather verification task payload
+  task submit           Push a task payload to an aggregator /events endpoint or the task mailbox
+  cache inspect         List the weather cache of a running performer
+  cache purge           Purge the weather cache of a running performer
+  config validate       Validate the configuration from the environment
+  config print          Print the effective configuration
+  providers test        Fetch weather from each configured provider
+  performer health      Call the gRPC health check of a performer, optionally over TLS
+  audit verify          Verify the hash chain of an audit log
+  audit find            Print the audit records of a task
+  policy list           List the policy periods tracked by a running performer
+  policy cancel         Cancel a policy on a running performer
+  monitor               Publish verification tasks for registered policies on a schedule
+  quote                 Price a proposed payout policy against archived data
+  tls-proxy             Put TLS in front of, or behind, a plaintext ponos gRPC connection
+
+Run 'sunre-avs <command> -h' for command flags.
+`
+
+// Exit codes shared by the CLI commands
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// run dispatches a CLI invocation and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return cmdServe(nil, stdout, stderr)
+	}
+
+	name, rest := args[0], args[1:]
+	switch name {
+	case "serve":
+		return cmdServe(rest, stdout, stderr)
+	case "verify":
+		return runVerify(rest, stdout, stderr)
+	case "monitor":
+		return cmdMonitor(rest, stdout, stderr)
+	case "quote":
+		return cmdQuote(rest, stdout, stderr)
+	case "tls-proxy":
+		return cmdTLSProxy(rest, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, cliUsage)
+		return exitOK
+	}
+
+	if len(rest) == 0 {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, cliUsage)
+		return exitUsage
+	}
+	sub, rest := rest[0], rest[1:]
+	switch name + " " + sub {
+	case "task build":
+		return cmdTaskBuild(rest, stdout, stderr)
+	case "task submit":
+		return cmdTaskSubmit(rest, stdout, stderr)
+	case "cache inspect":
+		return cmdCacheInspect(rest, stdout, stderr)
+	case "cache purge":
+		return cmdCachePurge(rest, stdout, stderr)
+	case "config validate":
+		return cmdConfigValidate(rest, stdout, stderr)
+	case "config print":
+		return cmdConfigPrint(rest, stdout, stderr)
+	case "providers test":
+		return cmdProvidersTest(rest, stdout, stderr)
+	case "performer health":
+		return cmdPerformerHealth(rest, stdout, stderr)
+	case "audit verify":
+		return cmdAuditVerify(rest, stdout, stderr)
+	case "audit find":
+		return cmdAuditFind(rest, stdout, stderr)
+	case "policy list":
+		return cmdPolicyList(rest, stdout, stderr)
+	case "policy cancel":
+		return cmdPolicyCancel(rest, stdout, stderr)
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", name+" "+sub, cliUsage)
+	return exitUsage
+}
+
+// loadCLIConfig loads and validates the shared configuration, reporting errors to stderr
+func loadCLIConfig(stderr io.Writer) (*Config, bool) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return nil, false
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+func cmdServe(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(stderr, "serve takes no arguments; configure it through the environment\n")
+		return exitUsage
+	}
+	cfg, ok := loadCLIConfig(stderr)
+	if !ok {
+		return exitUsage
+	}
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create logger: %v\n", err)
+		return exitFailure
+	}
+	defer logger.Sync()
+
+	if err := runServe(cfg, logger); err != nil {
+		logger.Error("Performer exited with error", zap.Error(err))
+		return exitFailure
+	}
+	return exitOK
+}
+
+func cmdConfigValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if _, ok := loadCLIConfig(stderr); !ok {
+		return exitUsage
+	}
+	fmt.Fprintln(stdout, "configuration is valid")
+	return exitOK
+}
+
+func cmdConfigPrint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return exitUsage
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(cfg.Redacted())
+	return exitOK
+}
+
+// healthAddrFlag registers the -addr flag pointing at a running performer's health server
+func healthAddrFlag(fs *flag.FlagSet) *string {
+	def := "http://localhost:8081"
+	if cfg, err := LoadConfig(); err == nil {
+		def = fmt.Sprintf("http://localhost:%d", cfg.HealthPort)
+	}
+	return fs.String("addr", def, "base URL of the performer health server")
+}
+
+// apiKeyFlag registers -api-key, the operator API key that guards changes
+// made through the health server and reads of its policies. A tenant's key
+// also lists that tenant's policies.
+func apiKeyFlag(fs *flag.FlagSet) *string {
+	return fs.String("api-key", os.Getenv("DRY_RUN_API_KEY"), "operator API key (default $DRY_RUN_API_KEY)")
+}
+
+func cmdCacheInspect(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cache inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := healthAddrFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(*addr + "/cache")
+	if err != nil {
+		fmt.Fprintf(stderr, "cache inspect: %v\n", err)
+		return exitFailure
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "cache inspect: performer returned status %d\n", resp.StatusCode)
+		return exitFailure
+	}
+
+	var entries map[string]CachedWeatherData
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		fmt.Fprintf(stderr, "cache inspect: invalid response: %v\n", err)
+		return exitFailure
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSOURCE\tTEMPERATURE\tCONDITIONS\tEXPIRES")
+	for _, key := range keys {
+		entry := entries[key]
+		if entry.Data == nil {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f\t%s\t%s\n",
+			key, entry.Data.Source, entry.Data.Temperature, entry.Data.Conditions,
+			entry.ExpiresAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+	fmt.Fprintf(stdout, "%d entries\n", len(entries))
+	return exitOK
+}
+
+func cmdCachePurge(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cache purge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := healthAddrFlag(fs)
+	apiKey := apiKeyFlag(fs)
+	key := fs.String("key", "", "purge a single lat,lon key instead of the whole cache")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	target := *addr + "/cache"
+	if *key != "" {
+		target += "?key=" + url.QueryEscape(*key)
+	}
+	req, err := http.NewRequest(http.MethodDelete, target, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "cache purge: %v\n", err)
+		return exitUsage
+	}
+	req.Header.Set("X-API-Key", *apiKey)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(stderr, "cache purge: %v\n", err)
+		return exitFailure
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "cache purge: performer returned status %d\n", resp.StatusCode)
+		return exitFailure
+	}
+
+	var result struct {
+		Purged int `json:"purged"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(stderr, "cache purge: invalid response: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "purged %d entries\n", result.Purged)
+	return exitOK
+}
+
+func cmdProvidersTest(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("providers test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	lat := fs.Float64("lat", 40.7128, "latitude to query")
+	lon := fs.Float64("lon", -74.0060, "longitude to query")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	cfg, ok := loadCLIConfig(stderr)
+	if !ok {
+		return exitUsage
+	}
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create logger: %v\n", err)
+		return exitFailure
+	}
+	defer logger.Sync()
+
+	location := Location{Latitude: *lat, Longitude: *lon}
+	providers, _, err := weatherProviders(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "providers test: %v\n", err)
+		return exitFailure
+	}
+
+	failed := false
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tSTATUS\tLATENCY\tOBSERVED\tTEMPERATURE\tHUMIDITY\tWIND\tPRESSURE\tPRECIPITATION")
+	for _, provider := range providers {
+		start := time.Now()
+		data, err := provider.FetchCurrent(context.Background(), location)
+		latency := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			failed = true
+			fmt.Fprintf(tw, "%s\tFAIL: %v\t%s\t-\t-\t-\t-\t-\t-\n", provider.Name(), err, latency)
+			continue
+		}
+		precipitation := "-"
+		if data.Precipitation != nil {
+			precipitation = fmt.Sprintf("%.1f", *data.Precipitation)
+		}
+		fmt.Fprintf(tw, "%s\tOK\t%s\t%s\t%.1f\t%.0f\t%.1f\t%.1f\t%s\n", provider.Name(), latency,
+			data.Timestamp.Format(time.RFC3339), data.Temperature, data.Humidity, data.WindSpeed, data.Pressure, precipitation)
+	}
+	tw.Flush()
+
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func cmdPolicyList(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("policy list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := healthAddrFlag(fs)
+	apiKey := apiKeyFlag(fs)
+	policyID := fs.String("policy", "", "only list the periods of this policy")
+	tenant := fs.String("tenant", "", "only list the periods of this tenant")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	req, err := http.NewRequest(http.MethodGet, *addr+"/policies?policy="+url.QueryEscape(*policyID)+"&tenant="+url.QueryEscape(*tenant), nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "policy list: %v\n", err)
+		return exitUsage
+	}
+	req.Header.Set("X-API-Key", *apiKey)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(stderr, "policy list: %v\n", err)
+		return exitFailure
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "policy list: performer returned status %d\n", resp.StatusCode)
+		return exitFailure
+	}
+
+	var records []PolicyRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		fmt.Fprintf(stderr, "policy list: invalid response: %v\n", err)
+		return exitFailure
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POLICY\tPERIL\tSTART\tEND\tSTATE\tTASK")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.policy(), r.Peril,
+			time.Unix(r.Start, 0).UTC().Format(time.RFC3339), time.Unix(r.End, 0).UTC().Format(time.RFC3339),
+			r.State, r.TaskID)
+	}
+	tw.Flush()
+	fmt.Fprintf(stdout, "%d periods\n", len(records))
+	return exitOK
+}
+
+func cmdPolicyCancel(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("policy cancel", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := healthAddrFlag(fs)
+	apiKey := apiKeyFlag(fs)
+	policyID := fs.String("policy", "", "policy ID to cancel (required)")
+	tenant := fs.String("tenant", "", "tenant the policy belongs to")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *policyID == "" {
+		fmt.Fprintln(stderr, "policy cancel: -policy is required")
+		return exitUsage
+	}
+
+	req, err := http.NewRequest(http.MethodDelete,
+		*addr+"/policies?policy="+url.QueryEscape(*policyID)+"&tenant="+url.QueryEscape(*tenant), nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "policy cancel: %v\n", err)
+		return exitUsage
+	}
+	req.Header.Set("X-API-Key", *apiKey)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(stderr, "policy cancel: %v\n", err)
+		return exitFailure
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "policy cancel: performer returned status %d\n", resp.StatusCode)
+		return exitFailure
+	}
+	var out struct {
+		Cancelled int `json:"cancelled"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		fmt.Fprintf(stderr, "policy cancel: invalid response: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "cancelled %s (%d open periods)\n", tenantPolicy(*tenant, *policyID), out.Cancelled)
+	return exitOK
+}
+
+func cmdPerformerHealth(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("performer health", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:8080", "performer gRPC address")
+	useTLS := fs.Bool("tls", false, "connect over TLS")
+	var tlsCfg TLSConfig
+	fs.StringVar(&tlsCfg.CAFile, "tls-ca", "", "CA verifying the performer certificate (default: system roots)")
+	fs.StringVar(&tlsCfg.CertFile, "tls-cert", "", "client certificate for mTLS")
+	fs.StringVar(&tlsCfg.KeyFile, "tls-key", "", "client key for mTLS")
+	fs.StringVar(&tlsCfg.ServerName, "tls-server-name", "", "name to verify on the performer certificate")
+	timeout := fs.Duration("timeout", 5*time.Second, "call timeout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if (tlsCfg.CertFile == "") != (tlsCfg.KeyFile == "") {
+		fmt.Fprintln(stderr, "performer health: -tls-cert and -tls-key must be set together")
+		return exitUsage
+	}
+
+	var clientTLS *tls.Config
+	if *useTLS || tlsCfg.CAFile != "" || tlsCfg.CertFile != "" {
+		tlsCfg.ReloadInterval = time.Hour
+		certs, err := newCertReloader(tlsCfg, zap.NewNop())
+		if err != nil {
+			fmt.Fprintf(stderr, "performer health: %v\n", err)
+			return exitUsage
+		}
+		clientTLS = certs.ClientTLS()
+	}
+	client, err := NewPerformerClient(*addr, clientTLS)
+	if err != nil {
+		fmt.Fprintf(stderr, "performer health: %v\n", err)
+		return exitFailure
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	resp, err := client.HealthCheck(ctx, &performerV1.HealthCheckRequest{})
+	if err != nil {
+		fmt.Fprintf(stderr, "performer health: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintln(stdout, resp.Status.String())
+	return exitOK
+}
+
+// auditDirFlag registers -dir, defaulting to AUDIT_DIR
+func auditDirFlag(fs *flag.FlagSet) *string {
+	return fs.String("dir", os.Getenv("AUDIT_DIR"), "audit log directory")
+}
+
+func cmdAuditVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := auditDirFlag(fs)
+	truncated := fs.Bool("allow-truncated", false, "accept a chain whose earliest files were archived")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *dir == "" {
+		fmt.Fprintln(stderr, "audit verify: -dir or AUDIT_DIR is required")
+		return exitUsage
+	}
+
+	report, err := VerifyAuditLog(*dir, *truncated)
+	if err != nil {
+		fmt.Fprintf(stderr, "audit verify: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "OK: %d records in %d files (seq %d-%d), head %s\n",
+		report.Records, report.Files, report.FirstSeq, report.LastSeq, report.LastHash)
+	return exitOK
+}
+
+func cmdAuditFind(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("audit find", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := auditDirFlag(fs)
+	taskID := fs.String("task", "", "task ID to look up")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *dir == "" || *taskID == "" {
+		fmt.Fprintln(stderr, "audit find: -dir (or AUDIT_DIR) and -task are required")
+		return exitUsage
+	}
+
+	records, err := FindAuditRecords(*dir, *taskID)
+	if err != nil {
+		fmt.Fprintf(stderr, "audit find: %v\n", err)
+		return exitFailure
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(stderr, "audit find: no records for task %q\n", *taskID)
+		return exitFailure
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			fmt.Fprintf(stderr, "audit find: %v\n", err)
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+// readPayloadFile reads a task payload from path, or stdin when path is "-"
+func readPayloadFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/chainPoller"
+	"go.uber.org/zap"
+)
+
+func TestRun_Dispatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "help", args: []string{"help"}, wantCode: exitOK, wantOut: "task submit"},
+		{name: "unknown command", args: []string{"bogus"}, wantCode: exitUsage},
+		{name: "unknown subcommand", args: []string{"cache", "bogus"}, wantCode: exitUsage},
+		{name: "serve rejects arguments", args: []string{"serve", "extra"}, wantCode: exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("run(%v) = %d, want %d\nstderr: %s", tt.args, code, tt.wantCode, stderr.String())
+			}
+			if tt.wantOut != "" && !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("stdout missing %q:\n%s", tt.wantOut, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_TaskBuild(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"task", "build", "-lat", "25.7617", "-lon", "-80.1918", "-city", "Miami", "-policy", "POL-MIA-1", "-timestamp", "1693526400"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("task build = %d, stderr: %s", code, stderr.String())
+	}
+
+	var req WeatherVerificationRequest
+	if err := json.Unmarshal(stdout.Bytes(), &req); err != nil {
+		t.Fatalf("task build output is not a payload: %v", err)
+	}
+	if req.PolicyID != "POL-MIA-1" || req.Timestamp != 1693526400 || req.Location.City != "Miami" {
+		t.Errorf("unexpected payload: %+v", req)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"task", "build", "-lat", "95", "-policy", "POL-1"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("task build with invalid latitude = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_TaskSubmitEvents(t *testing.T) {
+	var received *chainPoller.LogWithBlock
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("task event enqueued"))
+	}))
+	defer srv.Close()
+
+	payloadPath := filepath.Join(t.TempDir(), "task.json")
+	if err := os.WriteFile(payloadPath, []byte(verifyTestPayload), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"task", "submit",
+		"-payload", payloadPath,
+		"-events-url", srv.URL + "/events",
+		"-avs-address", "0xAVS",
+		"-mailbox-address", "0xMAILBOX",
+		"-operator-set-id", "2",
+	}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("task submit = %d, stderr: %s", code, stderr.String())
+	}
+
+	if received == nil || received.Log == nil || received.Block == nil {
+		t.Fatal("aggregator did not receive a task event")
+	}
+	if received.Log.EventName != "TaskCreated" || received.Log.Address != "0xmailbox" {
+		t.Errorf("unexpected log: %+v", received.Log)
+	}
+	if len(received.Log.Arguments) != 3 || received.Log.Arguments[2].Value != "0xavs" {
+		t.Errorf("unexpected arguments: %+v", received.Log.Arguments)
+	}
+	if got := received.Log.OutputData["executorOperatorSetId"]; got != float64(2) {
+		t.Errorf("executorOperatorSetId = %v, want 2", got)
+	}
+}
+
+func TestRun_TaskSubmitRejectsInvalidPayload(t *testing.T) {
+	payloadPath := filepath.Join(t.TempDir(), "task.json")
+	os.WriteFile(payloadPath, []byte(`{"location": {"latitude": 120}}`), 0o644)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"task", "submit", "-payload", payloadPath, "-events-url", "http://127.0.0.1:1/events", "-avs-address", "0xAVS"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Errorf("task submit = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_Config(t *testing.T) {
+	t.Setenv("ENV", "production")
+	t.Setenv("PERFORMER_PORT", "9090")
+	t.Setenv("OPERATOR_KEY", "0xdeadbeef")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config", "validate"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("config validate = %d, stderr: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"config", "print"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("config print = %d, stderr: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "deadbeef") {
+		t.Error("config print leaked the private key")
+	}
+	var cfg Config
+	if err := json.Unmarshal(stdout.Bytes(), &cfg); err != nil {
+		t.Fatalf("config print output is not JSON: %v", err)
+	}
+	if cfg.PerformerPort != 9090 || cfg.Env != "production" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	// Any environment name is accepted
+	t.Setenv("ENV", "staging")
+	if code := run([]string{"config", "validate"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("config validate with ENV=staging = %d, stderr: %s", code, stderr.String())
+	}
+
+	t.Setenv("HEALTH_PORT", "9090")
+	if code := run([]string{"config", "validate"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("config validate with clashing ports = %d, want %d", code, exitUsage)
+	}
+
+	t.Setenv("PERFORMER_TIMEOUT", "soon")
+	if code := run([]string{"config", "validate"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("config validate with bad timeout = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestWeatherProviders_FromConfig(t *testing.T) {
+	t.Setenv("GRID_DIR", writeGridDir(t))
+	t.Setenv("GRID_NAME", "chirps")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// providers test lists what serve fetches from, in the same order
+	providers, faults, err := weatherProviders(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	if strings.Join(names, ",") != "chirps,open-meteo" || faults != nil {
+		t.Errorf("providers = %v, faults = %v", names, faults)
+	}
+}
+
+func TestRun_Cache(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.cache["40.7128,-74.0060"] = &CachedWeatherData{
+		Data:      &WeatherData{Temperature: 3.4, Source: "open-meteo", Conditions: "Rainy"},
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	worker.weatherClient.cache["25.7617,-80.1918"] = &CachedWeatherData{
+		Data:      &WeatherData{Temperature: 29.1, Source: "open-meteo", Conditions: "Clear"},
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cache", requireAPIKey(dryRunKey, worker.cacheHandler, http.MethodGet))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"cache", "inspect", "-addr", srv.URL}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("cache inspect = %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "40.7128,-74.0060") || !strings.Contains(stdout.String(), "2 entries") {
+		t.Errorf("unexpected cache inspect output:\n%s", stdout.String())
+	}
+
+	// Purging needs the operator API key
+	if code := run([]string{"cache", "purge", "-addr", srv.URL, "-api-key", ""}, &stdout, &stderr); code != exitFailure ||
+		len(worker.weatherClient.CacheEntries()) != 2 {
+		t.Errorf("cache purge without an API key = %d", code)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"cache", "purge", "-addr", srv.URL, "-api-key", dryRunKey, "-key", "25.7617,-80.1918"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("cache purge = %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "purged 1 entries") {
+		t.Errorf("unexpected cache purge output: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	t.Setenv("DRY_RUN_API_KEY", dryRunKey)
+	run([]string{"cache", "purge", "-addr", srv.URL}, &stdout, &stderr)
+	if len(worker.weatherClient.CacheEntries()) != 0 {
+		t.Error("cache purge left entries behind")
+	}
+}
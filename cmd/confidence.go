@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfidenceConfig configures the confidence model. It feeds the signed
+// result, so every operator must run with the same settings.
+type ConfidenceConfig struct {
+	// Reliability is each provider's historical reliability in [0, 1], keyed by source name
+	Reliability map[string]float64 `json:"reliability"`
+	// DefaultReliability applies to sources missing from Reliability
+	DefaultReliability float64 `json:"default_reliability"`
+	// Corroborate asks the providers after the one that answered for the same
+	// variable, so inter-source agreement can be measured
+	Corroborate bool `json:"corroborate"`
+}
+
+// DefaultConfidenceConfig returns the reliability of the built-in sources.
+// Generated fallback data is not an observation and scores zero.
+func DefaultConfidenceConfig() ConfidenceConfig {
+	return ConfidenceConfig{
+		Reliability: map[string]float64{
+			"open-meteo":   0.9,
+			fallbackSource: 0,
+		},
+		DefaultReliability: 0.8,
+	}
+}
+
+// Validate checks the confidence settings
+func (c ConfidenceConfig) Validate() error {
+	if c.DefaultReliability < 0 || c.DefaultReliability > 1 {
+		return fmt.Errorf("DEFAULT_PROVIDER_RELIABILITY must be between 0 and 1")
+	}
+	for source, r := range c.Reliability {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("PROVIDER_RELIABILITY for %s must be between 0 and 1", source)
+		}
+	}
+	return nil
+}
+
+// parseReliability parses source=reliability pairs separated by commas
+func parseReliability(value string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		source, raw, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(source) == "" {
+			return nil, fmt.Errorf("expected source=reliability, got %q", pair)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reliability for %s: %w", source, err)
+		}
+		out[strings.TrimSpace(source)] = r
+	}
+	return out, nil
+}
+
+func (c ConfidenceConfig) reliability(source string) float64 {
+	if r, ok := c.Reliability[source]; ok {
+		return r
+	}
+	return c.DefaultReliability
+}
+
+// Confidence model constants. Each factor is in [0, 1]; see README.md.
+const (
+	// freshnessHalfLife is the observation age at which freshness halves
+	freshnessHalfLife = 3 * time.Hour
+	// proximityScaleKm is the distance to the data at which proximity halves
+	proximityScaleKm = 25.0
+	// outlierZ is the modified z-score above which a value is an outlier
+	outlierZ = 3.5
+	// temperatureScale is the temperature spread (°C) at which agreement halves
+	temperatureScale = 1.5
+)
+
+// confidenceWeights are the exponents of the weighted geometric mean; they sum to 1
+var confidenceWeights = ConfidenceFactors{
+	Agreement:   0.3,
+	Freshness:   0.2,
+	Proximity:   0.15,
+	Coverage:    0.15,
+	Reliability: 0.2,
+}
+
+// SourceValue is one value of the assessed variable
+type SourceValue struct {
+	Source  string  `json:"source"`
+	Value   float64 `json:"value"`
+	Outlier bool    `json:"outlier,omitempty"`
+}
+
+// ConfidenceInputs records everything the score was computed from
+type ConfidenceInputs struct {
+	// Variable is "precipitation" or "temperature"
+	Variable string        `json:"variable"`
+	Values   []SourceValue `json:"values"`
+	Sources  int           `json:"sources"`
+	Outliers int           `json:"outliers"`
+	// Dispersion is the mean absolute deviation from the median after outlier removal
+	Dispersion float64 `json:"dispersion"`
+	// AgeSeconds is the gap between the requested time and the observed period
+	AgeSeconds int64 `json:"age_seconds"`
+	// DistanceKm is the weighted station distance, or half the grid spacing
+	DistanceKm  float64 `json:"distance_km"`
+	Reliability float64 `json:"reliability"`
+}
+
+// ConfidenceFactors are the per-input scores combined into the confidence
+type ConfidenceFactors struct {
+	Agreement   float64 `json:"agreement"`
+	Freshness   float64 `json:"freshness"`
+	Proximity   float64 `json:"proximity"`
+	Coverage    float64 `json:"coverage"`
+	Reliability float64 `json:"reliability"`
+}
+
+// ConfidenceReport is a confidence score with its inputs
+type ConfidenceReport struct {
+	Score   float64           `json:"score"`
+	Inputs  ConfidenceInputs  `json:"inputs"`
+	Factors ConfidenceFactors `json:"factors"`
+}
+
+// Assess scores data for a request at requestedAt. The score only depends on
+// the data and the configuration, and is rounded, so operators agree on it.
+func (c ConfidenceConfig) Assess(data *WeatherData, requestedAt time.Time) ConfidenceReport {
+	in := ConfidenceInputs{
+		Variable:    "temperature",
+		Reliability: c.reliability(data.Source),
+	}
+	if data.Precipitation != nil {
+		in.Variable = "precipitation"
+	}
+
+	// Every station counts as a source; other providers contribute one value
+	if len(data.Stations) > 0 {
+		for _, s := range data.Stations {
+			in.Values = append(in.Values, SourceValue{Source: data.Source + "/" + s.ID, Value: s.Value})
+			in.DistanceKm += s.Weight * s.DistanceKm
+		}
+	} else {
+		in.Values = append(in.Values, SourceValue{Source: data.Source, Value: primaryValue(data)})
+		in.DistanceKm = data.ResolutionKm / 2
+	}
+	in.Values = append(in.Values, data.Corroborating...)
+	in.DistanceKm = roundTo(in.DistanceKm, 3)
+
+	median, dispersion := markOutliers(in.Values)
+	for _, v := range in.Values {
+		if v.Outlier {
+			in.Outliers++
+		}
+	}
+	in.Sources = len(in.Values) - in.Outliers
+	in.Dispersion = roundTo(dispersion, 4)
+	in.AgeSeconds = observationAge(data, requestedAt)
+
+	scale := agreementScale(in.Variable, median)
+	f := ConfidenceFactors{
+		Agreement:   scale / (scale + in.Dispersion),
+		Freshness:   math.Pow(0.5, float64(in.AgeSeconds)/freshnessHalfLife.Seconds()),
+		Proximity:   proximityScaleKm / (proximityScaleKm + in.DistanceKm),
+		Coverage:    1 - math.Pow(0.5, float64(in.Sources+1)),
+		Reliability: in.Reliability,
+	}
+	score := math.Pow(f.Agreement, confidenceWeights.Agreement) *
+		math.Pow(f.Freshness, confidenceWeights.Freshness) *
+		math.Pow(f.Proximity, confidenceWeights.Proximity) *
+		math.Pow(f.Coverage, confidenceWeights.Coverage) *
+		math.Pow(f.Reliability, confidenceWeights.Reliability)
+
+	return ConfidenceReport{
+		Score:  roundTo(score, 3),
+		Inputs: in,
+		Factors: ConfidenceFactors{
+			Agreement:   roundTo(f.Agreement, 4),
+			Freshness:   roundTo(f.Freshness, 4),
+			Proximity:   roundTo(f.Proximity, 4),
+			Coverage:    roundTo(f.Coverage, 4),
+			Reliability: roundTo(f.Reliability, 4),
+		},
+	}
+}
+
+// agreementScale is the spread of variable around median at which agreement halves
+func agreementScale(variable string, median float64) float64 {
+	if variable == "precipitation" {
+		return math.Max(1, 0.2*math.Abs(median))
+	}
+	return temperatureScale
+}
+
+// primaryValue returns the value of the variable the reading is assessed on
+func primaryValue(data *WeatherData) float64 {
+	if data.Precipitation != nil {
+		return *data.Precipitation
+	}
+	return data.Temperature
+}
+
+// sameVariable reports whether other measures the variable data is assessed on
+func sameVariable(data, other *WeatherData) bool {
+	return (data.Precipitation != nil) == (other.Precipitation != nil)
+}
+
+// markOutliers flags values whose modified z-score exceeds outlierZ, or that
+// differ from an exact majority when the median absolute deviation is zero,
+// and returns the median and mean absolute deviation of the rest
+func markOutliers(values []SourceValue) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	raw := make([]float64, len(values))
+	for i, v := range values {
+		raw[i] = v.Value
+	}
+	m := median(raw)
+	if len(values) >= 3 {
+		deviations := make([]float64, len(raw))
+		for i, v := range raw {
+			deviations[i] = math.Abs(v - m)
+		}
+		mad := median(deviations)
+		for i := range values {
+			if mad == 0 {
+				values[i].Outlier = deviations[i] > 0
+			} else {
+				values[i].Outlier = 0.6745*deviations[i]/mad > outlierZ
+			}
+		}
+	}
+
+	var kept []float64
+	for _, v := range values {
+		if !v.Outlier {
+			kept = append(kept, v.Value)
+		}
+	}
+	m = median(kept)
+	var sum float64
+	for _, v := range kept {
+		sum += math.Abs(v - m)
+	}
+	return m, sum / float64(len(kept))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// observationAge returns how far requestedAt lies outside the period the
+// observation covers, in whole seconds
+func observationAge(data *WeatherData, requestedAt time.Time) int64 {
+	start := data.Timestamp.Unix()
+	end := start + data.PeriodSeconds
+	at := requestedAt.Unix()
+	switch {
+	case at < start:
+		return start - at
+	case at > end:
+		return at - end
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+func TestConfidence_SingleSource(t *testing.T) {
+	observed := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	data := &WeatherData{Temperature: 21.5, Source: "open-meteo", Timestamp: observed, ResolutionKm: 11}
+
+	report := DefaultConfidenceConfig().Assess(data, observed)
+	want := ConfidenceFactors{Agreement: 1, Freshness: 1, Proximity: 0.8197, Coverage: 0.75, Reliability: 0.9}
+	if report.Factors != want {
+		t.Errorf("factors = %+v, want %+v", report.Factors, want)
+	}
+	if report.Score != 0.91 {
+		t.Errorf("score = %v, want 0.91", report.Score)
+	}
+	in := report.Inputs
+	if in.Variable != "temperature" || in.Sources != 1 || in.DistanceKm != 5.5 || in.AgeSeconds != 0 || in.Reliability != 0.9 {
+		t.Errorf("unexpected inputs: %+v", in)
+	}
+
+	// Three hours off halves freshness and lowers the score
+	stale := DefaultConfidenceConfig().Assess(data, observed.Add(3*time.Hour))
+	if stale.Inputs.AgeSeconds != 10800 || stale.Factors.Freshness != 0.5 || stale.Score >= report.Score {
+		t.Errorf("stale reading: %+v", stale)
+	}
+}
+
+func TestConfidence_DailyPeriod(t *testing.T) {
+	precip := 12.0
+	day := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	data := &WeatherData{Precipitation: &precip, Source: "chirps", Timestamp: day, PeriodSeconds: 86400}
+
+	report := DefaultConfidenceConfig().Assess(data, day.Add(15*time.Hour))
+	if report.Inputs.AgeSeconds != 0 || report.Inputs.Variable != "precipitation" {
+		t.Errorf("a time inside the day should have no age: %+v", report.Inputs)
+	}
+	if report.Inputs.Reliability != 0.8 {
+		t.Errorf("unlisted source reliability = %v, want the default", report.Inputs.Reliability)
+	}
+}
+
+func TestConfidence_OutlierRemoval(t *testing.T) {
+	precip := 10.0
+	data := &WeatherData{
+		Precipitation: &precip,
+		Source:        "ghcnd",
+		Stations: []StationReading{
+			{ID: "A", DistanceKm: 4, Value: 10, Weight: 0.5},
+			{ID: "B", DistanceKm: 6, Value: 10.5, Weight: 0.3},
+			{ID: "C", DistanceKm: 8, Value: 9.5, Weight: 0.2},
+		},
+		Corroborating: []SourceValue{{Source: "chirps", Value: 40}},
+	}
+
+	report := DefaultConfidenceConfig().Assess(data, time.Time{})
+	in := report.Inputs
+	if len(in.Values) != 4 || !in.Values[3].Outlier || in.Outliers != 1 || in.Sources != 3 {
+		t.Fatalf("the 40 mm value should be the only outlier: %+v", in.Values)
+	}
+	if in.Values[0].Source != "ghcnd/A" || in.DistanceKm != 5.4 {
+		t.Errorf("unexpected station inputs: %+v", in)
+	}
+	if in.Dispersion != 0.3333 {
+		t.Errorf("dispersion = %v, want 0.3333", in.Dispersion)
+	}
+
+	// Without the outlier the three stations agree closely
+	if report.Factors.Agreement < 0.7 || report.Factors.Coverage != 0.9375 {
+		t.Errorf("unexpected factors: %+v", report.Factors)
+	}
+}
+
+func TestConfidence_FallbackScoresZero(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	data := worker.generateFallbackWeatherData(Location{Latitude: 40.7, Longitude: -74})
+	if report := worker.confidence.Assess(data, data.Timestamp); report.Score != 0 {
+		t.Errorf("fallback score = %v, want 0", report.Score)
+	}
+}
+
+func TestParseReliability(t *testing.T) {
+	got, err := parseReliability("open-meteo=0.95, chirps=0.85,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["open-meteo"] != 0.95 || got["chirps"] != 0.85 {
+		t.Errorf("parseReliability() = %v", got)
+	}
+	for _, bad := range []string{"open-meteo", "=0.5", "chirps=high"} {
+		if _, err := parseReliability(bad); err == nil {
+			t.Errorf("parseReliability(%q) succeeded", bad)
+		}
+	}
+	cfg := DefaultConfidenceConfig()
+	cfg.Reliability["chirps"] = 1.5
+	if err := cfg.Validate(); err == nil {
+		t.Error("reliability above 1 was accepted")
+	}
+}
+
+// namedProvider is a staticProvider reporting under its own name
+type namedProvider struct {
+	staticProvider
+	name string
+}
+
+func (p *namedProvider) Name() string { return p.name }
+
+func TestFetchWeather_Corroborate(t *testing.T) {
+	now := time.Now().UTC()
+	primary := &namedProvider{name: "a", staticProvider: staticProvider{data: WeatherData{Temperature: 20, Source: "a", Timestamp: now}}}
+	second := &namedProvider{name: "b", staticProvider: staticProvider{data: WeatherData{Temperature: 21, Source: "b", Timestamp: now}}}
+	precip := 3.0
+	rain := &namedProvider{name: "c", staticProvider: staticProvider{data: WeatherData{Precipitation: &precip, Source: "c", Timestamp: now}}}
+
+	client := NewWeatherClientWithProviders(zap.NewNop(), primary, second, rain)
+	data, err := client.FetchWeather(context.Background(), Location{Latitude: 1, Longitude: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Corroborating) != 0 || second.calls != 0 {
+		t.Fatal("providers were corroborated without corroboration enabled")
+	}
+
+	client.PurgeCache("")
+	client.corroborate = true
+	data, err = client.FetchWeather(context.Background(), Location{Latitude: 1, Longitude: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Corroborating) != 1 || data.Corroborating[0] != (SourceValue{Source: "b", Value: 21}) {
+		t.Errorf("corroborating = %+v, want only the other temperature", data.Corroborating)
+	}
+	if rain.calls != 1 {
+		t.Errorf("rain provider called %d times, want 1", rain.calls)
+	}
+}
+
+func TestHandleTask_MinConfidence(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(newTestStationProvider(t))
+
+	run := func(minConfidence float64) map[string]json.RawMessage {
+		t.Helper()
+		req := WeatherVerificationRequest{
+			Location:      Location{Latitude: -1.29, Longitude: 36.82},
+			Timestamp:     1711983600,
+			PolicyID:      "POL-KE-3",
+			MinConfidence: minConfidence,
+		}
+		payload, _ := json.Marshal(req)
+		taskID := fmt.Sprintf("task-confidence-%v", minConfidence)
+		resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte(taskID), Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]json.RawMessage
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := run(0.5)
+	var report ConfidenceReport
+	if err := json.Unmarshal(result["confidence_inputs"], &report); err != nil {
+		t.Fatal(err)
+	}
+	var confidence float64
+	json.Unmarshal(result["confidence"], &confidence)
+	if report.Score != confidence || report.Inputs.Sources != 2 || report.Inputs.Variable != "precipitation" {
+		t.Errorf("unexpected confidence report: %s", result["confidence_inputs"])
+	}
+	if string(result["verified"]) != "true" {
+		t.Errorf("verified = %s with confidence %v >= 0.5", result["verified"], confidence)
+	}
+	if result := run(0.99); string(result["verified"]) != "false" {
+		t.Errorf("verified = %s with confidence %v < 0.99", result["verified"], confidence)
+	}
+
+	bad := &WeatherVerificationRequest{PolicyID: "POL", MinConfidence: 1.5}
+	if err := bad.Validate(); err == nil {
+		t.Error("min_confidence above 1 was accepted")
+	}
+}
+
+func TestHandleTask_FallbackNeverVerifies(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(&flakyProvider{down: true})
+
+	payload := []byte(`{"type": "payout", "policy_id": "POL-FB", "location": {"latitude": 1, "longitude": 2},
+		"payout": {"variable": "temperature", "structure": {"kind": "linear", "exhaustion": "1", "sum_insured": "1000"}}}`)
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-fallback"), Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if string(result["source"]) != `"Fallback"` {
+		t.Fatalf("source = %s, want fallback data", result["source"])
+	}
+	// min_confidence defaults to 0, which fallback data still does not meet
+	if string(result["verified"]) != "false" || result["payout"] != nil {
+		t.Errorf("fallback data verified a claim: %s", resp.Result)
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config holds the performer settings shared by every subcommand
+type Config struct {
+	Env              string           `json:"env"`
+	LogLevel         string           `json:"log_level"`
+	PerformerPort    int              `json:"performer_port"`
+	PerformerTimeout time.Duration    `json:"performer_timeout"`
+	HealthPort       int              `json:"health_port"`
+	OperatorID       string           `json:"operator_id"`
+	Chain            ChainConfig      `json:"chain"`
+	Resilience       ResilienceConfig `json:"resilience"`
+	// TimeBucket is the granularity requested times are snapped to
+	TimeBucket time.Duration `json:"time_bucket"`
+	// MaxObservationAge is the oldest provider observation accepted
+	MaxObservationAge time.Duration    `json:"max_observation_age"`
+	Grid              GridConfig       `json:"grid"`
+	Stations          StationConfig    `json:"stations"`
+	Confidence        ConfidenceConfig `json:"confidence"`
+	Reputation        ReputationConfig `json:"reputation"`
+	// FaultInjection enables fault injection into weather providers: "on",
+	// or a path to a fault configuration applied at start. Empty disables
+	// it; it is never allowed in production.
+	FaultInjection string `json:"fault_injection,omitempty"`
+	// ShutdownGracePeriod bounds the wait for in-flight tasks on shutdown
+	ShutdownGracePeriod time.Duration `json:"shutdown_grace_period"`
+	// PerformerTLS serves the performer gRPC API over TLS, or mTLS with a client CA
+	PerformerTLS TLSConfig `json:"performer_tls"`
+	// Audit records every verification in a hash-chained log
+	Audit AuditConfig `json:"audit"`
+	// Policy tracks policy periods so none is settled twice
+	Policy PolicyConfig `json:"policy"`
+	// Results keeps computed results so task retries get identical bytes
+	Results ResultStoreConfig `json:"results"`
+	// Monitor publishes verification tasks for registered policies
+	Monitor MonitorConfig `json:"monitor"`
+	// Quote prices proposed policies against archived data
+	Quote QuoteConfig `json:"quote"`
+	// Webhooks notifies policyholder systems of triggered policies
+	Webhooks WebhookConfig `json:"webhooks"`
+	// DryRunAPIKey enables the /v1/verify and /v1/validate dry-run API
+	DryRunAPIKey string `json:"dry_run_api_key,omitempty"`
+	// TenantRegistry is the JSON file listing the tenants; empty serves
+	// every requester
+	TenantRegistry string `json:"tenant_registry,omitempty"`
+	// Metar is where airport tasks read METAR reports from
+	Metar MetarConfig `json:"metar"`
+	// Energy is where energy tasks read hourly irradiance and wind from
+	Energy EnergyConfig `json:"energy"`
+}
+
+// ChainConfig holds the settings used to submit tasks on-chain
+type ChainConfig struct {
+	RPCURL              string `json:"rpc_url,omitempty"`
+	PrivateKey          string `json:"private_key,omitempty"`
+	AVSAddress          string `json:"avs_address,omitempty"`
+	AVSRegistrarAddress string `json:"avs_registrar_address,omitempty"`
+	TaskMailboxAddress  string `json:"task_mailbox_address,omitempty"`
+}
+
+// LoadConfig reads the configuration from the environment, applying defaults
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		Env:               os.Getenv("ENV"),
+		LogLevel:          os.Getenv("LOG_LEVEL"),
+		PerformerPort:     8080,
+		PerformerTimeout:  5 * time.Second,
+		HealthPort:        8081,
+		OperatorID:        os.Getenv("OPERATOR_ID"),
+		FaultInjection:    os.Getenv("FAULT_INJECTION"),
+		Resilience:        DefaultResilienceConfig(),
+		Confidence:        DefaultConfidenceConfig(),
+		Reputation:        DefaultReputationConfig(),
+		TimeBucket:        time.Hour,
+		MaxObservationAge: time.Hour,
+		Grid: GridConfig{
+			Dir:           os.Getenv("GRID_DIR"),
+			Name:          os.Getenv("GRID_NAME"),
+			Variable:      os.Getenv("GRID_VARIABLE"),
+			Interpolation: os.Getenv("GRID_INTERPOLATION"),
+		},
+		Stations: StationConfig{
+			Catalogue:    os.Getenv("STATION_CATALOGUE"),
+			Observations: os.Getenv("STATION_OBSERVATIONS"),
+			Name:         os.Getenv("STATION_NAME"),
+			Element:      os.Getenv("STATION_ELEMENT"),
+			K:            5,
+			MaxRadiusKm:  50,
+			MinStations:  2,
+		},
+		Chain: ChainConfig{
+			RPCURL:              os.Getenv("RPC_URL"),
+			PrivateKey:          os.Getenv("OPERATOR_KEY"),
+			AVSAddress:          os.Getenv("AVS_ADDRESS"),
+			AVSRegistrarAddress: os.Getenv("AVS_REGISTRAR_ADDRESS"),
+			TaskMailboxAddress:  os.Getenv("TASK_MAILBOX_ADDRESS"),
+		},
+	}
+	if cfg.Env == "" {
+		cfg.Env = "development"
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if cfg.OperatorID == "" {
+		cfg.OperatorID = "sunre-operator-default"
+	}
+	if cfg.Grid.Name == "" {
+		cfg.Grid.Name = "gridded"
+	}
+	if cfg.Grid.Variable == "" {
+		cfg.Grid.Variable = "precip"
+	}
+	if cfg.Grid.Interpolation == "" {
+		cfg.Grid.Interpolation = "bilinear"
+	}
+	if cfg.Stations.Name == "" {
+		cfg.Stations.Name = "ghcnd"
+	}
+	if cfg.Stations.Element == "" {
+		cfg.Stations.Element = "PRCP"
+	}
+
+	var err error
+	if cfg.PerformerPort, err = envInt("PERFORMER_PORT", cfg.PerformerPort); err != nil {
+		return nil, err
+	}
+	if cfg.HealthPort, err = envInt("HEALTH_PORT", cfg.HealthPort); err != nil {
+		return nil, err
+	}
+	if cfg.PerformerTimeout, err = envDuration("PERFORMER_TIMEOUT", cfg.PerformerTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownGracePeriod, err = envDuration("SHUTDOWN_GRACE_PERIOD", 15*time.Second); err != nil {
+		return nil, err
+	}
+	cfg.PerformerTLS = TLSConfig{
+		CertFile: os.Getenv("PERFORMER_TLS_CERT"),
+		KeyFile:  os.Getenv("PERFORMER_TLS_KEY"),
+		CAFile:   os.Getenv("PERFORMER_TLS_CLIENT_CA"),
+	}
+	if cfg.PerformerTLS.ReloadInterval, err = envDuration("TLS_RELOAD_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	cfg.Audit = AuditConfig{Dir: os.Getenv("AUDIT_DIR"), Daily: true}
+	maxBytes, err := envInt("AUDIT_MAX_BYTES", 64<<20)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Audit.MaxBytes = int64(maxBytes)
+	if v := os.Getenv("AUDIT_ROTATE_DAILY"); v != "" {
+		if cfg.Audit.Daily, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid AUDIT_ROTATE_DAILY: %q", v)
+		}
+	}
+	cfg.Results = DefaultResultStoreConfig()
+	cfg.Results.Path = os.Getenv("RESULT_STORE_FILE")
+	if cfg.Results.Capacity, err = envInt("RESULT_STORE_SIZE", cfg.Results.Capacity); err != nil {
+		return nil, err
+	}
+	cfg.Policy.Path = os.Getenv("POLICY_STATE_FILE")
+	if cfg.Policy.ClaimWindow, err = envDuration("POLICY_CLAIM_WINDOW", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.Monitor = MonitorConfig{
+		Registry:  os.Getenv("MONITOR_REGISTRY"),
+		StatePath: os.Getenv("MONITOR_STATE_FILE"),
+	}
+	if cfg.Monitor.Interval, err = envDuration("MONITOR_INTERVAL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Monitor.Budget, err = envInt("MONITOR_BUDGET", 10); err != nil {
+		return nil, err
+	}
+	if cfg.Monitor.OperatorSetID, err = envUint32("MONITOR_OPERATOR_SET_ID", 1); err != nil {
+		return nil, err
+	}
+	if v := os.Getenv("MONITOR_DRY_RUN"); v != "" {
+		if cfg.Monitor.DryRun, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid MONITOR_DRY_RUN: %q", v)
+		}
+	}
+	cfg.DryRunAPIKey = os.Getenv("DRY_RUN_API_KEY")
+	cfg.TenantRegistry = os.Getenv("TENANT_REGISTRY")
+	cfg.Webhooks = DefaultWebhookConfig()
+	cfg.Webhooks.Endpoints = os.Getenv("WEBHOOK_ENDPOINTS")
+	cfg.Webhooks.Outbox = os.Getenv("WEBHOOK_OUTBOX_FILE")
+	if cfg.Webhooks.MaxAttempts, err = envInt("WEBHOOK_MAX_ATTEMPTS", cfg.Webhooks.MaxAttempts); err != nil {
+		return nil, err
+	}
+	if cfg.Webhooks.BaseDelay, err = envDuration("WEBHOOK_BASE_DELAY", cfg.Webhooks.BaseDelay); err != nil {
+		return nil, err
+	}
+	if cfg.Webhooks.MaxDelay, err = envDuration("WEBHOOK_MAX_DELAY", cfg.Webhooks.MaxDelay); err != nil {
+		return nil, err
+	}
+	if cfg.Webhooks.Timeout, err = envDuration("WEBHOOK_TIMEOUT", cfg.Webhooks.Timeout); err != nil {
+		return nil, err
+	}
+	cfg.Quote = DefaultQuoteConfig()
+	if cfg.Quote.Years, err = envInt("QUOTE_YEARS", cfg.Quote.Years); err != nil {
+		return nil, err
+	}
+	if cfg.Quote.VaRLevelBps, err = envUint32("QUOTE_VAR_LEVEL_BPS", cfg.Quote.VaRLevelBps); err != nil {
+		return nil, err
+	}
+	if cfg.Quote.Loading.ExpenseBps, err = envUint32("QUOTE_EXPENSE_LOADING_BPS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Quote.Loading.RiskBps, err = envUint32("QUOTE_RISK_LOADING_BPS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Quote.Loading.MinRateBps, err = envUint32("QUOTE_MIN_RATE_BPS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Quote.PerMinute, err = envInt("QUOTE_RATE_PER_MINUTE", cfg.Quote.PerMinute); err != nil {
+		return nil, err
+	}
+	if cfg.Quote.MaxConcurrent, err = envInt("QUOTE_MAX_CONCURRENT", cfg.Quote.MaxConcurrent); err != nil {
+		return nil, err
+	}
+	cfg.Metar = MetarConfig{
+		Archive: os.Getenv("METAR_ARCHIVE"),
+		URL:     os.Getenv("METAR_URL"),
+	}
+	if cfg.Metar.Timeout, err = envDuration("METAR_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	cfg.Energy = EnergyConfig{
+		Series: os.Getenv("ENERGY_SERIES"),
+		URL:    os.Getenv("ENERGY_URL"),
+	}
+	if cfg.Energy.Timeout, err = envDuration("ENERGY_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.TimeBucket, err = envDuration("TIME_BUCKET", cfg.TimeBucket); err != nil {
+		return nil, err
+	}
+	if cfg.MaxObservationAge, err = envDuration("MAX_OBSERVATION_AGE", cfg.MaxObservationAge); err != nil {
+		return nil, err
+	}
+	if cfg.Stations.K, err = envInt("STATION_K", cfg.Stations.K); err != nil {
+		return nil, err
+	}
+	if cfg.Stations.MinStations, err = envInt("STATION_MIN_STATIONS", cfg.Stations.MinStations); err != nil {
+		return nil, err
+	}
+	if v := os.Getenv("STATION_MAX_RADIUS_KM"); v != "" {
+		if cfg.Stations.MaxRadiusKm, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid STATION_MAX_RADIUS_KM: %q", v)
+		}
+	}
+	if v := os.Getenv("PROVIDER_RELIABILITY"); v != "" {
+		reliability, err := parseReliability(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROVIDER_RELIABILITY: %w", err)
+		}
+		for source, r := range reliability {
+			cfg.Confidence.Reliability[source] = r
+		}
+	}
+	if v := os.Getenv("DEFAULT_PROVIDER_RELIABILITY"); v != "" {
+		if cfg.Confidence.DefaultReliability, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT_PROVIDER_RELIABILITY: %q", v)
+		}
+	}
+	if v := os.Getenv("CONFIDENCE_CORROBORATE"); v != "" {
+		if cfg.Confidence.Corroborate, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid CONFIDENCE_CORROBORATE: %q", v)
+		}
+	}
+	cfg.Reputation.Path = os.Getenv("REPUTATION_FILE")
+	if cfg.Reputation.MinSamples, err = envInt("REPUTATION_MIN_SAMPLES", cfg.Reputation.MinSamples); err != nil {
+		return nil, err
+	}
+	if v := os.Getenv("REPUTATION_DROP_BELOW"); v != "" {
+		if cfg.Reputation.DropBelow, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid REPUTATION_DROP_BELOW: %q", v)
+		}
+	}
+	r := &cfg.Resilience
+	if r.FailureThreshold, err = envInt("BREAKER_FAILURE_THRESHOLD", r.FailureThreshold); err != nil {
+		return nil, err
+	}
+	if r.OpenTimeout, err = envDuration("BREAKER_OPEN_TIMEOUT", r.OpenTimeout); err != nil {
+		return nil, err
+	}
+	if r.HalfOpenProbes, err = envInt("BREAKER_HALF_OPEN_PROBES", r.HalfOpenProbes); err != nil {
+		return nil, err
+	}
+	if r.MaxAttempts, err = envInt("RETRY_MAX_ATTEMPTS", r.MaxAttempts); err != nil {
+		return nil, err
+	}
+	if r.BaseDelay, err = envDuration("RETRY_BASE_DELAY", r.BaseDelay); err != nil {
+		return nil, err
+	}
+	if r.MaxDelay, err = envDuration("RETRY_MAX_DELAY", r.MaxDelay); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration for values the performer cannot run with
+func (c *Config) Validate() error {
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	if c.PerformerPort <= 0 || c.PerformerPort > 65535 {
+		return fmt.Errorf("invalid PERFORMER_PORT: %d", c.PerformerPort)
+	}
+	if c.HealthPort <= 0 || c.HealthPort > 65535 {
+		return fmt.Errorf("invalid HEALTH_PORT: %d", c.HealthPort)
+	}
+	if c.HealthPort == c.PerformerPort {
+		return fmt.Errorf("HEALTH_PORT and PERFORMER_PORT must differ")
+	}
+	if c.PerformerTimeout <= 0 {
+		return fmt.Errorf("PERFORMER_TIMEOUT must be positive")
+	}
+	if c.ShutdownGracePeriod <= 0 {
+		return fmt.Errorf("SHUTDOWN_GRACE_PERIOD must be positive")
+	}
+	if c.TimeBucket <= 0 || c.TimeBucket > 24*time.Hour || (24*time.Hour)%c.TimeBucket != 0 {
+		return fmt.Errorf("TIME_BUCKET must divide a day evenly, got %s", c.TimeBucket)
+	}
+	if c.MaxObservationAge <= 0 {
+		return fmt.Errorf("MAX_OBSERVATION_AGE must be positive")
+	}
+	if err := c.Resilience.Validate(); err != nil {
+		return err
+	}
+	if err := c.Grid.Validate(); err != nil {
+		return err
+	}
+	if err := c.Stations.Validate(); err != nil {
+		return err
+	}
+	if err := c.PerformerTLS.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return err
+	}
+	if err := c.Policy.Validate(); err != nil {
+		return err
+	}
+	if err := c.Results.Validate(); err != nil {
+		return err
+	}
+	if err := c.Monitor.Validate(); err != nil {
+		return err
+	}
+	if err := c.Quote.Validate(); err != nil {
+		return err
+	}
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+	if err := c.Metar.Validate(); err != nil {
+		return err
+	}
+	if err := c.Energy.Validate(); err != nil {
+		return err
+	}
+	if c.DryRunAPIKey != "" && len(c.DryRunAPIKey) < 16 {
+		return fmt.Errorf("DRY_RUN_API_KEY must be at least 16 characters")
+	}
+	if err := c.Confidence.Validate(); err != nil {
+		return err
+	}
+	if err := c.Reputation.Validate(); err != nil {
+		return err
+	}
+	if c.FaultInjection != "" && c.Env == "production" {
+		return fmt.Errorf("FAULT_INJECTION is not allowed in production")
+	}
+	if c.FaultInjection != "" && c.DryRunAPIKey == "" {
+		return fmt.Errorf("FAULT_INJECTION requires DRY_RUN_API_KEY, which guards /faults")
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration that is safe to print
+func (c *Config) Redacted() *Config {
+	out := *c
+	if out.Chain.PrivateKey != "" {
+		out.Chain.PrivateKey = "<redacted>"
+	}
+	if out.DryRunAPIKey != "" {
+		out.DryRunAPIKey = "<redacted>"
+	}
+	return &out
+}
+
+// NewLogger builds the process logger for the configured environment
+func NewLogger(cfg *Config) (*zap.Logger, error) {
+	var zc zap.Config
+	if cfg.Env == "production" {
+		zc = zap.NewProductionConfig()
+	} else {
+		zc = zap.NewDevelopmentConfig()
+	}
+	level, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	zc.Level = level
+	return zc.Build()
+}
+
+func envInt(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return i, nil
+}
+
+func envUint32(name string, def uint32) (uint32, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	u, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return uint32(u), nil
+}
+
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/signer/inMemorySigner"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/signing/aggregation"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/signing/bn254"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/types"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/util"
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// mockOpenMeteo serves a fixed Open-Meteo current conditions response
+type mockOpenMeteo struct {
+	temperature float64
+	humidity    float64
+	windSpeed   float64
+	pressure    float64
+	weatherCode int
+	observedAt  time.Time
+	down        bool
+}
+
+func (m *mockOpenMeteo) RoundTrip(req *http.Request) (*http.Response, error) {
+	if m.down {
+		return nil, fmt.Errorf("provider unavailable")
+	}
+	body := fmt.Sprintf(`{"current":{"time":%q,"temperature_2m":%g,"relative_humidity_2m":%g,"wind_speed_10m":%g,"surface_pressure":%g,"weather_code":%d}}`,
+		m.observedAt.Format(openMeteoTimeLayout), m.temperature, m.humidity, m.windSpeed, m.pressure, m.weatherCode)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+		Request:    req,
+	}, nil
+}
+
+// consensusObservedAt is the observation time of the provider snapshot every
+// operator samples, in the time bucket of verifyTestPayload
+var consensusObservedAt = time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)
+
+// baselineReading is the weather every honest operator's provider reports
+func baselineReading() *mockOpenMeteo {
+	return &mockOpenMeteo{temperature: 31.2, humidity: 78, windSpeed: 22.5, pressure: 1009.4, weatherCode: 63, observedAt: consensusObservedAt}
+}
+
+// consensusOperator is a SunRe performer paired with an executor-style BLS signer
+type consensusOperator struct {
+	address string
+	worker  *SunReWorker
+	signer  *inMemorySigner.InMemorySigner
+	pubKey  *bn254.PublicKey
+}
+
+// newConsensusOperator creates an operator whose performer reads weather from provider.
+// Operator clocks run a few minutes after consensusObservedAt so the snapshot is fresh.
+func newConsensusOperator(t *testing.T, index int, provider http.RoundTripper) *consensusOperator {
+	t.Helper()
+	privKey, pubKey, err := bn254.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate BLS key pair: %v", err)
+	}
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(NewOpenMeteoProvider(&http.Client{Transport: provider}))
+	worker.weatherClient.now = func() time.Time { return consensusObservedAt.Add(time.Duration(index+1) * time.Minute) }
+	return &consensusOperator{
+		address: fmt.Sprintf("0x%040x", index+1),
+		worker:  worker,
+		signer:  inMemorySigner.NewInMemorySigner(privKey),
+		pubKey:  pubKey,
+	}
+}
+
+// execute runs the task through the performer and signs the keccak256 digest of the
+// output, mirroring the ponos executor's handleReceivedTask
+func (op *consensusOperator) execute(taskID string, payload []byte) (*types.TaskResult, error) {
+	task := &performerV1.TaskRequest{TaskId: []byte(taskID), Payload: payload}
+	if err := op.worker.ValidateTask(task); err != nil {
+		return nil, err
+	}
+	resp, err := op.worker.HandleTask(task)
+	if err != nil {
+		return nil, err
+	}
+	digest := util.GetKeccak256Digest(resp.Result)
+	sig, err := op.signer.SignMessage(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return &types.TaskResult{
+		TaskId:          taskID,
+		Output:          resp.Result,
+		OperatorAddress: op.address,
+		Signature:       sig,
+	}, nil
+}
+
+// simulatedAggregator fans a task out to every operator and aggregates the
+// signatures of the largest group of byte-identical outputs
+type simulatedAggregator struct {
+	operators []*consensusOperator
+	threshold uint8
+}
+
+// consensusOutcome is what the simulated aggregator observed for one task
+type consensusOutcome struct {
+	results     map[string]*types.TaskResult
+	divergent   []string
+	certificate *aggregation.AggregatedCertificate
+}
+
+func (a *simulatedAggregator) run(taskID string, payload []byte) (*consensusOutcome, error) {
+	operators := make([]*aggregation.Operator, 0, len(a.operators))
+	for _, op := range a.operators {
+		operators = append(operators, &aggregation.Operator{Address: op.address, PublicKey: op.pubKey})
+	}
+	deadline := time.Now().Add(time.Minute)
+	agg, err := aggregation.NewTaskResultAggregator(context.Background(), taskID, 1, 1, a.threshold, payload, &deadline, operators)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &consensusOutcome{results: make(map[string]*types.TaskResult)}
+	groups := make(map[[32]byte][]*types.TaskResult)
+	var majority [32]byte
+	for _, op := range a.operators {
+		res, err := op.execute(taskID, payload)
+		if err != nil {
+			continue
+		}
+		outcome.results[op.address] = res
+		digest := util.GetKeccak256Digest(res.Output)
+		groups[digest] = append(groups[digest], res)
+		if len(groups[digest]) > len(groups[majority]) {
+			majority = digest
+		}
+	}
+
+	for digest, group := range groups {
+		if digest != majority {
+			for _, res := range group {
+				outcome.divergent = append(outcome.divergent, res.OperatorAddress)
+			}
+			continue
+		}
+		for _, res := range group {
+			if err := agg.ProcessNewSignature(context.Background(), taskID, res); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !agg.SigningThresholdMet() {
+		return outcome, fmt.Errorf("signing threshold not met")
+	}
+	outcome.certificate, err = agg.GenerateFinalCertificate()
+	return outcome, err
+}
+
+func newConsensusTaskID(t *testing.T) string {
+	t.Helper()
+	id, err := newTaskID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// verifyCertificate checks the aggregate signature over the certified response
+func verifyCertificate(t *testing.T, cert *aggregation.AggregatedCertificate) {
+	t.Helper()
+	signersKey, err := bn254.NewPublicKeyFromBytes(cert.SignersPublicKey.Marshal())
+	if err != nil {
+		t.Fatalf("invalid signers public key: %v", err)
+	}
+	digest := util.GetKeccak256Digest(cert.TaskResponse)
+	if !bytes.Equal(cert.TaskResponseDigest, digest[:]) {
+		t.Fatal("certificate digest does not match the certified response")
+	}
+	ok, err := cert.SignersSignature.Verify(signersKey, cert.TaskResponseDigest)
+	if err != nil || !ok {
+		t.Fatalf("aggregate signature does not verify: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestConsensus_OperatorsReachCertificate(t *testing.T) {
+	var ops []*consensusOperator
+	for i := 0; i < 4; i++ {
+		ops = append(ops, newConsensusOperator(t, i, baselineReading()))
+	}
+	aggregator := &simulatedAggregator{operators: ops, threshold: 67}
+
+	taskID := newConsensusTaskID(t)
+	outcome, err := aggregator.run(taskID, []byte(verifyTestPayload))
+	if err != nil {
+		t.Fatalf("consensus failed: %v", err)
+	}
+	cert := outcome.certificate
+	verifyCertificate(t, cert)
+
+	if len(outcome.divergent) != 0 {
+		t.Errorf("divergent operators = %v, want none", outcome.divergent)
+	}
+	if len(cert.NonSignersPubKeys) != 0 {
+		t.Errorf("non-signers = %d, want 0", len(cert.NonSignersPubKeys))
+	}
+	if len(cert.AllOperatorsPubKeys) != len(ops) {
+		t.Errorf("all operators = %d, want %d", len(cert.AllOperatorsPubKeys), len(ops))
+	}
+	if got := "0x" + hex.EncodeToString(cert.TaskId); got != taskID {
+		t.Errorf("certificate task ID = %s, want %s", got, taskID)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(cert.TaskResponse, &result); err != nil {
+		t.Fatalf("certified response is not JSON: %v", err)
+	}
+	weather := result["weather"].(map[string]interface{})
+	if weather["temperature"] != 31.2 || weather["conditions"] != "Rainy" || result["source"] != "open-meteo" {
+		t.Errorf("unexpected certified result: %s", cert.TaskResponse)
+	}
+	if result["policy_id"] != "POL-001" || result["task_id"] != taskID {
+		t.Errorf("certified result does not match the task: %s", cert.TaskResponse)
+	}
+}
+
+func TestConsensus_DivergentOperators(t *testing.T) {
+	biased := baselineReading()
+	biased.temperature += 4.5
+	down := baselineReading()
+	down.down = true
+
+	tests := []struct {
+		name          string
+		providers     []*mockOpenMeteo
+		threshold     uint8
+		wantErr       bool
+		wantDivergent []int
+	}{
+		{
+			name:          "biased provider is a non-signer",
+			providers:     []*mockOpenMeteo{baselineReading(), baselineReading(), baselineReading(), biased},
+			threshold:     67,
+			wantDivergent: []int{3},
+		},
+		{
+			name:          "provider outage falls back and is a non-signer",
+			providers:     []*mockOpenMeteo{baselineReading(), down, baselineReading(), baselineReading(), baselineReading()},
+			threshold:     67,
+			wantDivergent: []int{1},
+		},
+		{
+			name:          "too many divergent operators",
+			providers:     []*mockOpenMeteo{baselineReading(), baselineReading(), biased, down},
+			threshold:     75,
+			wantErr:       true,
+			wantDivergent: []int{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []*consensusOperator
+			for i, p := range tt.providers {
+				ops = append(ops, newConsensusOperator(t, i, p))
+			}
+			aggregator := &simulatedAggregator{operators: ops, threshold: tt.threshold}
+
+			outcome, err := aggregator.run(newConsensusTaskID(t), []byte(verifyTestPayload))
+			if len(outcome.divergent) != len(tt.wantDivergent) {
+				t.Fatalf("divergent operators = %v, want %d", outcome.divergent, len(tt.wantDivergent))
+			}
+			for _, i := range tt.wantDivergent {
+				found := false
+				for _, addr := range outcome.divergent {
+					found = found || addr == ops[i].address
+				}
+				if !found {
+					t.Errorf("operator %d (%s) not reported as divergent", i, ops[i].address)
+				}
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected consensus to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("consensus failed: %v", err)
+			}
+
+			cert := outcome.certificate
+			verifyCertificate(t, cert)
+			if len(cert.NonSignersPubKeys) != len(tt.wantDivergent) {
+				t.Fatalf("non-signers = %d, want %d", len(cert.NonSignersPubKeys), len(tt.wantDivergent))
+			}
+			for i, idx := range tt.wantDivergent {
+				if !bytes.Equal(cert.NonSignersPubKeys[i].Bytes(), ops[idx].pubKey.Bytes()) {
+					t.Errorf("non-signer %d is not operator %d", i, idx)
+				}
+			}
+			honest := outcome.results[ops[0].address]
+			if !bytes.Equal(cert.TaskResponse, honest.Output) {
+				t.Error("certificate does not carry the majority output")
+			}
+		})
+	}
+}
+
+func TestConsensus_MixedOutputsInvalidateAggregate(t *testing.T) {
+	biased := baselineReading()
+	biased.temperature -= 3
+
+	ops := []*consensusOperator{
+		newConsensusOperator(t, 0, baselineReading()),
+		newConsensusOperator(t, 1, baselineReading()),
+		newConsensusOperator(t, 2, biased),
+	}
+	var operators []*aggregation.Operator
+	for _, op := range ops {
+		operators = append(operators, &aggregation.Operator{Address: op.address, PublicKey: op.pubKey})
+	}
+
+	taskID := newConsensusTaskID(t)
+	deadline := time.Now().Add(time.Minute)
+	agg, err := aggregation.NewTaskResultAggregator(context.Background(), taskID, 1, 1, 100, nil, &deadline, operators)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ponos aggregator does not compare outputs, so a divergent signature is
+	// accepted and the resulting certificate no longer verifies.
+	for _, op := range ops {
+		res, err := op.execute(taskID, []byte(verifyTestPayload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := agg.ProcessNewSignature(context.Background(), taskID, res); err != nil {
+			t.Fatalf("ProcessNewSignature() error = %v", err)
+		}
+	}
+	cert, err := agg.GenerateFinalCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signersKey, err := bn254.NewPublicKeyFromBytes(cert.SignersPublicKey.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := cert.SignersSignature.Verify(signersKey, cert.TaskResponseDigest); ok {
+		t.Error("aggregate over mixed outputs unexpectedly verified")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrDraining is returned for tasks that arrive after shutdown has begun
+var ErrDraining = errors.New("performer is shutting down")
+
+// taskGate counts in-flight tasks and refuses new ones once draining
+type taskGate struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	// idle is closed once draining with no task in flight
+	idle chan struct{}
+}
+
+// enter admits a task, or reports false while draining
+func (g *taskGate) enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.draining {
+		return false
+	}
+	g.active++
+	return true
+}
+
+func (g *taskGate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	if g.draining && g.active == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
+}
+
+// Draining reports whether new tasks are refused
+func (g *taskGate) Draining() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.draining
+}
+
+// InFlight returns the number of tasks being handled
+func (g *taskGate) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.active
+}
+
+// drain refuses new tasks and waits for the in-flight ones until ctx is done
+func (g *taskGate) drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.draining = true
+	if g.active == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	if g.idle == nil {
+		g.idle = make(chan struct{})
+	}
+	idle := g.idle
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d tasks still in flight: %w", g.InFlight(), ctx.Err())
+	}
+}
+
+// grpcStopper is the part of *grpc.Server the shutdown needs
+type grpcStopper interface {
+	GracefulStop()
+	Stop()
+}
+
+// shutdown tears the performer down in order: refuse tasks and report not
+// ready, wait out in-flight tasks, flush local state, then close the servers
+type shutdown struct {
+	logger *zap.Logger
+	worker *SunReWorker
+	// grace bounds the wait for in-flight tasks and for each server to close
+	grace time.Duration
+	grpc  grpcStopper
+	http  *http.Server
+	// flush persists local state once no task can change it
+	flush []namedFlush
+}
+
+// namedFlush is a shutdown step that persists state
+type namedFlush struct {
+	name string
+	fn   func() error
+}
+
+// run performs the shutdown and returns every step that failed
+func (s *shutdown) run() error {
+	var errs []error
+
+	s.logger.Info("Draining in-flight tasks",
+		zap.Int("inFlight", s.worker.gate.InFlight()),
+		zap.Duration("grace", s.grace),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), s.grace)
+	err := s.worker.gate.drain(ctx)
+	cancel()
+	drained := err == nil
+	if err != nil {
+		s.logger.Warn("Grace period expired before tasks finished", zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	for _, f := range s.flush {
+		if err := f.fn(); err != nil {
+			s.logger.Warn("Failed to flush state", zap.String("step", f.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("flush %s: %w", f.name, err))
+		}
+	}
+
+	if s.grpc != nil {
+		s.stopGRPC(drained)
+	}
+	if s.http != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.grace)
+		if err := s.http.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("health server: %w", err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// stopGRPC stops the gRPC server, gracefully if the tasks drained and
+// forcibly if they did not or if closing takes longer than the grace period
+func (s *shutdown) stopGRPC(drained bool) {
+	if !drained {
+		s.grpc.Stop()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.grace):
+		s.logger.Warn("gRPC server did not stop in time, closing connections")
+		s.grpc.Stop()
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// blockingProvider answers once release is closed
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingProvider() *blockingProvider {
+	return &blockingProvider{started: make(chan struct{}, 8), release: make(chan struct{})}
+}
+
+func (p *blockingProvider) Name() string { return "blocking" }
+
+func (p *blockingProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return &WeatherData{Temperature: 20, Source: "blocking", Timestamp: time.Now()}, nil
+}
+
+// fakeGRPC records how the gRPC server was stopped
+type fakeGRPC struct {
+	graceful atomic.Bool
+	forced   atomic.Bool
+}
+
+func (g *fakeGRPC) GracefulStop() { g.graceful.Store(true) }
+func (g *fakeGRPC) Stop()         { g.forced.Store(true) }
+
+func startBlockedTask(t *testing.T, worker *SunReWorker) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := worker.HandleTask(&performerV1.TaskRequest{
+			TaskId:  []byte("task-inflight"),
+			Payload: []byte(`{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-1"}`),
+		})
+		done <- err
+	}()
+	return done
+}
+
+func TestShutdown_WaitsForInFlightTasks(t *testing.T) {
+	provider := newBlockingProvider()
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(provider)
+	done := startBlockedTask(t, worker)
+	<-provider.started
+
+	var flushed []string
+	grpc := &fakeGRPC{}
+	healthServer := httptest.NewUnstartedServer(http.HandlerFunc(worker.readyHandler))
+	healthServer.Start()
+	defer healthServer.Close()
+
+	down := &shutdown{
+		logger: zap.NewNop(),
+		worker: worker,
+		grace:  5 * time.Second,
+		grpc:   grpc,
+		http:   healthServer.Config,
+		flush: []namedFlush{{"test", func() error {
+			if worker.gate.InFlight() != 0 {
+				t.Error("state flushed while a task was in flight")
+			}
+			flushed = append(flushed, "test")
+			return nil
+		}}},
+	}
+	result := make(chan error, 1)
+	go func() { result <- down.run() }()
+
+	// New tasks are refused and readiness fails while draining
+	for !worker.gate.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := worker.ValidateTask(&performerV1.TaskRequest{Payload: []byte(`{"policy_id": "POL-2"}`)}); !errors.Is(err, ErrDraining) {
+		t.Errorf("ValidateTask() while draining = %v", err)
+	}
+	if _, err := worker.HandleTask(&performerV1.TaskRequest{Payload: []byte(`{"policy_id": "POL-2"}`)}); !errors.Is(err, ErrDraining) {
+		t.Errorf("HandleTask() while draining = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	worker.readyHandler(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while draining = %d", rec.Code)
+	}
+
+	close(provider.release)
+	if err := <-done; err != nil {
+		t.Errorf("in-flight task failed: %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("shutdown = %v", err)
+	}
+	if len(flushed) != 1 || !grpc.graceful.Load() || grpc.forced.Load() {
+		t.Errorf("flushed %v, graceful stop %v, forced stop %v", flushed, grpc.graceful.Load(), grpc.forced.Load())
+	}
+	if _, err := http.Get(healthServer.URL); err == nil {
+		t.Error("health server still serving after shutdown")
+	}
+}
+
+func TestShutdown_GracePeriodExpires(t *testing.T) {
+	provider := newBlockingProvider()
+	defer close(provider.release)
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(provider)
+	startBlockedTask(t, worker)
+	<-provider.started
+
+	grpc := &fakeGRPC{}
+	flushErr := errors.New("disk full")
+	down := &shutdown{
+		logger: zap.NewNop(),
+		worker: worker,
+		grace:  20 * time.Millisecond,
+		grpc:   grpc,
+		flush:  []namedFlush{{"reputation", func() error { return flushErr }}},
+	}
+	err := down.run()
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, flushErr) {
+		t.Errorf("shutdown = %v, want the expired grace period and the flush error", err)
+	}
+	if !grpc.forced.Load() || grpc.graceful.Load() {
+		t.Error("gRPC server should be stopped forcibly when tasks are still running")
+	}
+}
+
+func TestTaskGate_DrainWhenIdle(t *testing.T) {
+	var g taskGate
+	if !g.enter() {
+		t.Fatal("enter() refused before draining")
+	}
+	g.leave()
+	if err := g.drain(context.Background()); err != nil {
+		t.Errorf("drain() with nothing in flight = %v", err)
+	}
+	if g.enter() {
+		t.Error("enter() admitted a task after draining")
+	}
+}
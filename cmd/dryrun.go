@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxDryRunPayload bounds the task payloads the dry-run API accepts
+const maxDryRunPayload = 1 << 20
+
+// dryRunCtxKey marks a context whose fetches must leave no trace
+type dryRunCtxKey struct{}
+
+// withDryRun returns ctx marked as a dry run
+func withDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunCtxKey{}, true)
+}
+
+// isDryRun reports whether ctx is marked as a dry run
+func isDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunCtxKey{}).(bool)
+	return dryRun
+}
+
+// DryRunAPI serves POST /v1/validate and POST /v1/verify, which run a JSON
+// task payload through the performer without signing anything. Nothing is
+// recorded: the policy lifecycle, result store, audit log, metrics, tenant
+// quotas, webhooks, provider reputation, weather cache and circuit breakers
+// are left untouched. Requests need the operator's API
+// key, or a tenant's, in an X-API-Key header or as a bearer token. A
+// tenant's key only runs the tenant's own tasks, within its plan.
+type DryRunAPI struct {
+	worker     *SunReWorker
+	apiKey     string
+	operatorID string
+	now        func() time.Time
+}
+
+// NewDryRunAPI returns the dry-run API of worker, guarded by apiKey and
+// the worker's tenant keys
+func NewDryRunAPI(worker *SunReWorker, apiKey, operatorID string) *DryRunAPI {
+	if operatorID == "" {
+		operatorID = "sunre-operator-default"
+	}
+	return &DryRunAPI{worker: worker, apiKey: apiKey, operatorID: operatorID, now: time.Now}
+}
+
+// Register adds the API's routes to mux
+func (a *DryRunAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/validate", a.guard(a.validateHandler))
+	mux.HandleFunc("/v1/verify", a.guard(a.verifyHandler))
+}
+
+// DryRunDiagnostics is the unsigned envelope returned beside a dry-run result
+type DryRunDiagnostics struct {
+	// Signed is always false: no operator signs a dry run
+	Signed      bool   `json:"signed"`
+	OperatorID  string `json:"operator_id"`
+	TaskID      string `json:"task_id"`
+	PayloadHash string `json:"payload_hash"`
+	ResultHash  string `json:"result_hash,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+	// Sources are the providers the weather came from
+	Sources []string `json:"sources,omitempty"`
+	// FetchError is set when every provider failed and fallback data was used
+	FetchError string `json:"fetch_error,omitempty"`
+	// PolicyPeriod and PolicyState are what a real task would find
+	PolicyPeriod string      `json:"policy_period"`
+	PolicyState  PolicyState `json:"policy_state"`
+	Warnings     []string    `json:"warnings,omitempty"`
+}
+
+// DryRunResponse is the body of a dry-run answer
+type DryRunResponse struct {
+	DryRun bool   `json:"dry_run"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+	// Task is the decoded payload
+	Task *WeatherVerificationRequest `json:"task,omitempty"`
+	// Result is the canonical result bytes operators would sign
+	Result      json.RawMessage    `json:"result,omitempty"`
+	Diagnostics *DryRunDiagnostics `json:"diagnostics,omitempty"`
+}
+
+// dryRunHandler serves a request made with the key of tenant, or of the
+// operator when tenant is nil
+type dryRunHandler func(w http.ResponseWriter, r *http.Request, tenant *Tenant)
+
+// requestAPIKey returns the API key r carries in an X-API-Key header or as
+// a bearer token
+func requestAPIKey(r *http.Request) string {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return bearer
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// requireAPIKey refuses requests to next without apiKey, except for the
+// open methods. With no key configured every guarded request is refused.
+func requireAPIKey(apiKey string, next http.HandlerFunc, open ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range open {
+			if r.Method == method {
+				next(w, r)
+				return
+			}
+		}
+		key := requestAPIKey(r)
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sunre-operator"`)
+			http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireTenantKey refuses requests to next without the operator API key or,
+// for the tenant methods, a tenant's key. A tenant's requests are scoped to
+// it: their tenant query parameter is replaced with its ID.
+func requireTenantKey(apiKey string, tenants *TenantRegistry, next http.HandlerFunc, tenantMethods ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		if apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			next(w, r)
+			return
+		}
+		tenant := tenants.ForAPIKey(key)
+		if tenant == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sunre-operator"`)
+			http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		if !slices.Contains(tenantMethods, r.Method) {
+			http.Error(w, "operator API key required", http.StatusForbidden)
+			return
+		}
+		r = r.Clone(r.Context())
+		query := r.URL.Query()
+		query.Set("tenant", tenant.ID)
+		r.URL.RawQuery = query.Encode()
+		next(w, r)
+	}
+}
+
+// guard refuses requests without a valid API key, and anything but POST
+func (a *DryRunAPI) guard(next dryRunHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		operator := a.apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.apiKey)) == 1
+		tenant := a.worker.tenants.ForAPIKey(key)
+		if key == "" || (!operator && tenant == nil) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sunre-dry-run"`)
+			http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r, tenant)
+	}
+}
+
+// decode reads the task payload of r, returning the response to send if it
+// is invalid or, for a tenant's key, not the tenant's to run
+func (a *DryRunAPI) decode(r *http.Request, tenant *Tenant) ([]byte, *WeatherVerificationRequest, *DryRunResponse) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxDryRunPayload+1))
+	if err != nil {
+		return nil, nil, &DryRunResponse{DryRun: true, Error: fmt.Sprintf("failed to read payload: %v", err)}
+	}
+	if len(payload) > maxDryRunPayload {
+		return nil, nil, &DryRunResponse{DryRun: true, Error: "payload is too large"}
+	}
+	var req WeatherVerificationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, nil, &DryRunResponse{DryRun: true, Error: fmt.Sprintf("invalid task payload: %v", err)}
+	}
+	if err := req.Validate(); err != nil {
+		return nil, nil, &DryRunResponse{DryRun: true, Error: err.Error(), Task: &req}
+	}
+	if tenant != nil && !tenant.Owns(req.Requester) {
+		return nil, nil, &DryRunResponse{DryRun: true, Error: fmt.Sprintf("requester %q is not a creator of tenant %s", req.Requester, tenant.ID), Task: &req}
+	}
+	if _, err := a.worker.tenants.Authorize(&req, payload); err != nil {
+		return nil, nil, &DryRunResponse{DryRun: true, Error: err.Error(), Task: &req}
+	}
+	return payload, &req, nil
+}
+
+// validateHandler checks a payload as ValidateTask would
+func (a *DryRunAPI) validateHandler(w http.ResponseWriter, r *http.Request, tenant *Tenant) {
+	_, req, invalid := a.decode(r, tenant)
+	if invalid != nil {
+		writeDryRun(w, http.StatusUnprocessableEntity, invalid)
+		return
+	}
+	writeDryRun(w, http.StatusOK, &DryRunResponse{DryRun: true, Valid: true, Task: req})
+}
+
+// verifyHandler computes the result a payload would get. The task ID is
+// taken from ?task_id=, defaulting to one derived from the payload.
+func (a *DryRunAPI) verifyHandler(w http.ResponseWriter, r *http.Request, tenant *Tenant) {
+	start := a.now()
+	payload, req, invalid := a.decode(r, tenant)
+	if invalid != nil {
+		writeDryRun(w, http.StatusUnprocessableEntity, invalid)
+		return
+	}
+	payloadHash := sha256Hex(payload)
+	taskID := r.URL.Query().Get("task_id")
+	if taskID == "" {
+		taskID = "dry-run-" + payloadHash[:16]
+	}
+
+	worker := a.worker
+	// A real task uses the namespace and sources of the tenant that created it
+	owner, _ := worker.tenants.Authorize(req, payload)
+	key := req.policyKey(worker.timeBucket, start)
+	key.Tenant = owner.Label()
+	diag := &DryRunDiagnostics{
+		OperatorID:   a.operatorID,
+		TaskID:       taskID,
+		PayloadHash:  payloadHash,
+		PolicyPeriod: key.String(),
+	}
+	var settledBy string
+	diag.PolicyState, settledBy = worker.policies.Peek(key, req.Coverage != nil)
+	switch diag.PolicyState {
+	case PolicySettled:
+		diag.Warnings = append(diag.Warnings, fmt.Sprintf("period is settled: a real task gets the result of task %s back", settledBy))
+	case PolicyCancelled, PolicyExpired:
+		diag.Warnings = append(diag.Warnings, fmt.Sprintf("period is %s: a real task fails", diag.PolicyState))
+	}
+
+	data, err := worker.fetchTaskData(withDryRun(context.Background()), req, owner.Sources())
+	var result []byte
+	if data != nil {
+		if err != nil {
+			diag.FetchError = err.Error()
+			diag.Warnings = append(diag.Warnings, "every provider failed: the result uses generated fallback data")
+		}
+		diag.Sources = data.sources()
+		result, err = worker.buildResult([]byte(taskID), *req, data)
+	}
+	diag.DurationMs = a.now().Sub(start).Milliseconds()
+	if err != nil {
+		writeDryRun(w, http.StatusUnprocessableEntity, &DryRunResponse{DryRun: true, Error: err.Error(), Task: req, Diagnostics: diag})
+		return
+	}
+	diag.ResultHash = sha256Hex(result)
+
+	worker.logger.Info("Dry-run verification",
+		zap.String("taskId", taskID),
+		zap.String("payloadHash", payloadHash),
+		zap.Int64("durationMs", diag.DurationMs),
+	)
+	writeDryRun(w, http.StatusOK, &DryRunResponse{DryRun: true, Valid: true, Task: req, Result: result, Diagnostics: diag})
+}
+
+// writeDryRun writes a dry-run response, marked as such in a header too
+func writeDryRun(w http.ResponseWriter, status int, resp *DryRunResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-SunRe-Dry-Run", "true")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+)
+
+const dryRunKey = "test-key-0123456789"
+
+// dryRunPost posts payload to path on srv with key
+func dryRunPost(t *testing.T, srv *httptest.Server, path, key, payload string) (*http.Response, DryRunResponse) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(payload))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out DryRunResponse
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp, out
+}
+
+func newDryRunServer(t *testing.T, worker *SunReWorker) *httptest.Server {
+	mux := http.NewServeMux()
+	NewDryRunAPI(worker, dryRunKey, "op-1").Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDryRunAPI_RequiresKey(t *testing.T) {
+	srv := newDryRunServer(t, newPolicyWorker(t, ""))
+	payload := `{"location": {"latitude": 1, "longitude": 2}, "policy_id": "P"}`
+	for _, key := range []string{"", "wrong-key-0123456789"} {
+		if resp, _ := dryRunPost(t, srv, "/v1/validate", key, payload); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("key %q: status %d", key, resp.StatusCode)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/validate", strings.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer "+dryRunKey)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("X-SunRe-Dry-Run") != "true" {
+		t.Errorf("bearer token: %v, %v", resp.StatusCode, err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/v1/verify", nil)
+	req.Header.Set("X-API-Key", dryRunKey)
+	if resp, _ := http.DefaultClient.Do(req); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET = %d", resp.StatusCode)
+	}
+}
+
+func TestDryRunAPI_Validate(t *testing.T) {
+	srv := newDryRunServer(t, newPolicyWorker(t, ""))
+	resp, out := dryRunPost(t, srv, "/v1/validate", dryRunKey, `{"location": {"latitude": 1, "longitude": 2}, "policy_id": "P"}`)
+	if resp.StatusCode != http.StatusOK || !out.Valid || !out.DryRun || out.Task.PolicyID != "P" {
+		t.Errorf("valid payload: %d %+v", resp.StatusCode, out)
+	}
+	resp, out = dryRunPost(t, srv, "/v1/validate", dryRunKey, `{"location": {"latitude": 91, "longitude": 2}, "policy_id": "P"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity || out.Valid || out.Error == "" {
+		t.Errorf("invalid payload: %d %+v", resp.StatusCode, out)
+	}
+}
+
+func TestDryRunAPI_VerifyMatchesTaskAndRecordsNothing(t *testing.T) {
+	worker := newPolicyWorker(t, "")
+	srv := newDryRunServer(t, worker)
+	// The static provider reports current conditions, so the task asks for now
+	payload := fmt.Sprintf(`{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-D", "timestamp": %d}`, time.Now().Unix())
+
+	resp, out := dryRunPost(t, srv, "/v1/verify?task_id=task-d", dryRunKey, payload)
+	if resp.StatusCode != http.StatusOK || !out.DryRun || len(out.Result) == 0 {
+		t.Fatalf("verify = %d %+v", resp.StatusCode, out)
+	}
+	diag := out.Diagnostics
+	if diag.Signed || diag.OperatorID != "op-1" || diag.TaskID != "task-d" || diag.ResultHash != sha256Hex(out.Result) ||
+		len(diag.Sources) == 0 || diag.PolicyState != PolicyActive {
+		t.Errorf("diagnostics = %+v", diag)
+	}
+	if worker.results.Len() != 0 || len(worker.policies.List("")) != 0 || worker.metrics.TasksProcessed != 0 {
+		t.Error("dry run changed the performer's state")
+	}
+
+	// The real task signs the same canonical bytes
+	real, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-d"), Payload: []byte(payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(real.Result, out.Result) {
+		t.Errorf("dry run and task differ:\n%s\n%s", out.Result, real.Result)
+	}
+}
+
+func TestDryRunAPI_VerifyWarnsOfSettledPeriod(t *testing.T) {
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+	task := payoutTask("task-1", "40")
+	if _, err := worker.HandleTask(task); err != nil {
+		t.Fatal(err)
+	}
+	srv := newDryRunServer(t, worker)
+	_, out := dryRunPost(t, srv, "/v1/verify", dryRunKey, string(task.Payload))
+	if out.Diagnostics == nil || out.Diagnostics.PolicyState != PolicySettled || len(out.Diagnostics.Warnings) != 1 ||
+		!strings.Contains(out.Diagnostics.Warnings[0], "task-1") {
+		t.Errorf("diagnostics = %+v", out.Diagnostics)
+	}
+}
+
+func TestDryRunAPI_VerifyLeavesProvidersUntouched(t *testing.T) {
+	worker := newPolicyWorker(t, "")
+	cfg := DefaultResilienceConfig()
+	cfg.FailureThreshold = 1
+	flaky := &flakyProvider{down: true}
+	down, _, _ := newTestResilientProvider(flaky, cfg)
+	now := time.Now().UTC()
+	a := &namedProvider{name: "a", staticProvider: staticProvider{data: WeatherData{Temperature: 20, Source: "a", Timestamp: now}}}
+	b := &namedProvider{name: "b", staticProvider: staticProvider{data: WeatherData{Temperature: 20.5, Source: "b", Timestamp: now}}}
+	c := &namedProvider{name: "c", staticProvider: staticProvider{data: WeatherData{Temperature: 30, Source: "c", Timestamp: now}}}
+	client := worker.weatherClient
+	client.SetProviders(down, a, b, c)
+	client.corroborate = true
+	client.reputation = newTestReputation(t, ReputationConfig{MinSamples: 1, DropBelow: 0.5})
+	srv := newDryRunServer(t, worker)
+
+	payload := `{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-D"}`
+	resp, out := dryRunPost(t, srv, "/v1/verify?task_id=task-d", dryRunKey, payload)
+	if resp.StatusCode != http.StatusOK || len(out.Diagnostics.Sources) == 0 || out.Diagnostics.Sources[0] != "a" {
+		t.Fatalf("verify = %d %+v", resp.StatusCode, out.Diagnostics)
+	}
+	if flaky.calls != 1 {
+		t.Errorf("failing provider called %d times, want a single attempt", flaky.calls)
+	}
+	if status := down.Status(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("breaker = %+v, want it untouched", status)
+	}
+	if scores := client.reputation.Scorecard(); len(scores) != 0 {
+		t.Errorf("reputation = %+v, want nothing recorded", scores)
+	}
+	if len(client.cache) != 0 {
+		t.Errorf("cache holds %d entries, want none", len(client.cache))
+	}
+
+	// The same payload as a real task is recorded everywhere
+	if _, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-d"), Payload: []byte(payload)}); err != nil {
+		t.Fatal(err)
+	}
+	if down.Status().ConsecutiveFailures == 0 || len(client.reputation.Scorecard()) == 0 || len(client.cache) == 0 {
+		t.Error("real task left no trace")
+	}
+}
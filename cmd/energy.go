@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/energy"
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+// EnergyConfig configures where hourly irradiance and wind for energy
+// tasks come from
+type EnergyConfig struct {
+	// Series is a CSV file of hourly weather, or a directory of them
+	Series string `json:"series,omitempty"`
+	// URL is an Open-Meteo style hourly API
+	URL     string        `json:"url,omitempty"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// Validate checks the energy source settings
+func (c EnergyConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("ENERGY_TIMEOUT must be positive")
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid ENERGY_URL %q", c.URL)
+		}
+	}
+	return nil
+}
+
+// EnergySource is a source of hourly weather for energy production
+type EnergySource interface {
+	Name() string
+	// FetchSamples returns the hourly samples at location starting in [start, end)
+	FetchSamples(ctx context.Context, location Location, start, end time.Time) ([]energy.Sample, error)
+}
+
+// energySources returns the configured sources in the order they are
+// tried: the local series, then the HTTP API
+func energySources(cfg EnergyConfig) []EnergySource {
+	var sources []EnergySource
+	if cfg.Series != "" {
+		sources = append(sources, NewEnergySeries(cfg.Series))
+	}
+	if cfg.URL != "" {
+		sources = append(sources, NewOpenMeteoEnergySource(cfg.URL, &http.Client{Timeout: cfg.Timeout}))
+	}
+	return sources
+}
+
+// energyStep is the interval each sample covers
+const energyStep = time.Hour
+
+// seriesTolerance is how far, in degrees, a series row's coordinates may
+// be from the task location
+const seriesTolerance = 0.01
+
+// EnergySeries reads hourly weather from CSV files with a header row. The
+// time column (RFC 3339, or YYYY-MM-DDTHH:MM in UTC) is the start of the
+// hour a row covers; ghi, dni, dhi (W/m²), temperature (°C) and
+// wind_speed_<height>m (m/s) columns are optional, as is any empty cell.
+// Rows with latitude and longitude only apply at that point.
+type EnergySeries struct {
+	path string
+}
+
+// NewEnergySeries returns a series reading path
+func NewEnergySeries(path string) *EnergySeries {
+	return &EnergySeries{path: path}
+}
+
+func (s *EnergySeries) Name() string { return "energy-series" }
+
+func (s *EnergySeries) FetchSamples(ctx context.Context, location Location, start, end time.Time) ([]energy.Sample, error) {
+	files, err := archiveFiles(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read energy series: %w", err)
+	}
+	var samples []energy.Sample
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read energy series: %w", err)
+		}
+		found, err := readSeries(f, location, start, end)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read energy series %s: %w", path, err)
+		}
+		samples = append(samples, found...)
+	}
+	return samples, nil
+}
+
+var windColumnRe = regexp.MustCompile(`^wind_speed_(\d+)m$`)
+
+// readSeries returns the samples in a CSV series at location starting in [start, end)
+func readSeries(r io.Reader, location Location, start, end time.Time) ([]energy.Sample, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := map[string]int{}
+	winds := map[int]string{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		columns[name] = i
+		if m := windColumnRe.FindStringSubmatch(name); m != nil {
+			height, _ := strconv.Atoi(m[1])
+			winds[height] = name
+		}
+	}
+	if _, ok := columns["time"]; !ok {
+		return nil, fmt.Errorf("series has no time column")
+	}
+
+	var samples []energy.Sample
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(column string) (*float64, error) {
+			i, ok := columns[column]
+			if !ok || i >= len(row) || strings.TrimSpace(row[i]) == "" {
+				return nil, nil
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("line %d: invalid %s %q", line, column, row[i])
+			}
+			return &v, nil
+		}
+
+		lat, err := value("latitude")
+		if err != nil {
+			return nil, err
+		}
+		lon, err := value("longitude")
+		if err != nil {
+			return nil, err
+		}
+		if lat != nil && lon != nil &&
+			(math.Abs(*lat-location.Latitude) > seriesTolerance || math.Abs(*lon-location.Longitude) > seriesTolerance) {
+			continue
+		}
+		at, err := parseSeriesTime(row[columns["time"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if at.Before(start) || !at.Before(end) {
+			continue
+		}
+
+		sample := energy.Sample{Time: at}
+		for column, dst := range map[string]**float64{
+			"ghi": &sample.GHI, "dni": &sample.DNI, "dhi": &sample.DHI, "temperature": &sample.TemperatureC,
+		} {
+			if *dst, err = value(column); err != nil {
+				return nil, err
+			}
+		}
+		for height, column := range winds {
+			v, err := value(column)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				if sample.Wind == nil {
+					sample.Wind = map[int]float64{}
+				}
+				sample.Wind[height] = *v
+			}
+		}
+		samples = append(samples, sample)
+	}
+}
+
+// parseSeriesTime parses an RFC 3339 time, or a UTC time in Open-Meteo's layout
+func parseSeriesTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	return parseOpenMeteoTime(s)
+}
+
+// OpenMeteoEnergySource fetches hourly irradiance, temperature and 10 m
+// and 100 m wind from an Open-Meteo style API, such as the historical
+// forecast API
+type OpenMeteoEnergySource struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewOpenMeteoEnergySource returns a source querying baseURL with httpClient
+func NewOpenMeteoEnergySource(baseURL string, httpClient *http.Client) *OpenMeteoEnergySource {
+	return &OpenMeteoEnergySource{url: baseURL, httpClient: httpClient}
+}
+
+func (s *OpenMeteoEnergySource) Name() string { return "open-meteo-energy" }
+
+func (s *OpenMeteoEnergySource) FetchSamples(ctx context.Context, location Location, start, end time.Time) ([]energy.Sample, error) {
+	// Values are means over the hour before their time, so the last hour
+	// of the window is labelled with the window's end
+	query := url.Values{
+		"latitude":        {fmt.Sprintf("%.4f", location.Latitude)},
+		"longitude":       {fmt.Sprintf("%.4f", location.Longitude)},
+		"hourly":          {"shortwave_radiation,direct_normal_irradiance,diffuse_radiation,temperature_2m,wind_speed_10m,wind_speed_100m"},
+		"wind_speed_unit": {"ms"},
+		"timezone":        {"GMT"},
+		"start_date":      {start.UTC().Format("2006-01-02")},
+		"end_date":        {end.UTC().Format("2006-01-02")},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch hourly weather: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Hourly struct {
+			Time        []string   `json:"time"`
+			GHI         []*float64 `json:"shortwave_radiation"`
+			DNI         []*float64 `json:"direct_normal_irradiance"`
+			DHI         []*float64 `json:"diffuse_radiation"`
+			Temperature []*float64 `json:"temperature_2m"`
+			Wind10      []*float64 `json:"wind_speed_10m"`
+			Wind100     []*float64 `json:"wind_speed_100m"`
+		} `json:"hourly"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode hourly weather: %w", err)
+	}
+	h := result.Hourly
+	at := func(values []*float64, i int) *float64 {
+		if i < len(values) {
+			return values[i]
+		}
+		return nil
+	}
+	var samples []energy.Sample
+	for i, label := range h.Time {
+		t, err := parseOpenMeteoTime(label)
+		if err != nil {
+			return nil, err
+		}
+		sample := energy.Sample{
+			Time:         t.Add(-energyStep),
+			GHI:          at(h.GHI, i),
+			DNI:          at(h.DNI, i),
+			DHI:          at(h.DHI, i),
+			TemperatureC: at(h.Temperature, i),
+		}
+		if sample.Time.Before(start) || !sample.Time.Before(end) {
+			continue
+		}
+		for height, values := range map[int][]*float64{10: h.Wind10, 100: h.Wind100} {
+			if v := at(values, i); v != nil {
+				if sample.Wind == nil {
+					sample.Wind = map[int]float64{}
+				}
+				sample.Wind[height] = *v
+			}
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// EnergyRequirement asks for hourly weather at a location over a window
+type EnergyRequirement struct {
+	Location Location  `json:"location"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// fetchEnergy returns the samples of the first source that has any for
+// the window, and that source's name. Only the sources named in sources
+// are asked if any of them is configured.
+func (w *SunReWorker) fetchEnergy(ctx context.Context, need *EnergyRequirement, sources []string) ([]energy.Sample, string, error) {
+	if len(w.energy) == 0 {
+		return nil, "", errors.New("no energy weather source configured (ENERGY_SERIES or ENERGY_URL)")
+	}
+	var errs []error
+	for _, source := range preferred(w.energy, sources) {
+		samples, err := source.FetchSamples(ctx, need.Location, need.Start, need.End)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+		if len(samples) > 0 {
+			return samples, source.Name(), nil
+		}
+	}
+	errs = append(errs, fmt.Errorf("no hourly weather at %.4f,%.4f between %s and %s",
+		need.Location.Latitude, need.Location.Longitude, need.Start.Format(time.RFC3339), need.End.Format(time.RFC3339)))
+	return nil, "", errors.Join(errs...)
+}
+
+// energyPerilName selects energy production tasks
+const energyPerilName = "energy"
+
+// maxEnergyWindow is the longest coverage period an energy task may cover
+const maxEnergyWindow = 92 * 24 * time.Hour
+
+// maxEnergyMissing is the share of the coverage period the data may miss
+// before production is no longer estimated from the rest
+const maxEnergyMissing = 0.1
+
+// EnergyTerms are the energy part of an energy task: exactly one plant, and
+// the production it is contracted to deliver over the coverage period
+type EnergyTerms struct {
+	Solar *energy.PVSystem `json:"solar,omitempty"`
+	Wind  *energy.Turbine  `json:"wind,omitempty"`
+	// BaselineMWh is the contracted production over the coverage period
+	BaselineMWh payout.Decimal `json:"baseline_mwh"`
+	ElevationM  float64        `json:"elevation_m,omitempty"`
+	// LinkeTurbidity is the site's clear-sky haze; zero uses the default of 3
+	LinkeTurbidity float64 `json:"linke_turbidity,omitempty"`
+}
+
+// plant returns the terms' plant and what kind it is
+func (t *EnergyTerms) plant() (energy.Plant, string) {
+	if t.Solar != nil {
+		return t.Solar, "solar"
+	}
+	return t.Wind, "wind"
+}
+
+// energyVariables are the indices a payout on an energy task can use
+var energyVariables = []string{
+	"shortfall_mwh", "shortfall_bps", "expected_mwh", "capacity_factor",
+	"ghi", "dni", "clearness_index", "hub_wind",
+}
+
+// energySchema is the JSON Schema of energy task payloads
+const energySchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Energy production task",
+  "type": "object",
+  "required": ["type", "policy_id", "location", "coverage", "energy"],
+  "properties": {
+    "type": {"const": "energy"},
+    "policy_id": {"type": "string", "minLength": 1},
+    "requester": {"type": "string", "description": "address that created the task; selects its tenant"},
+    "location": {"type": "object", "required": ["latitude", "longitude"]},
+    "coverage": {
+      "type": "object",
+      "anyOf": [{"required": ["start", "end"]}, {"required": ["local_start", "local_end"]}],
+      "properties": {
+        "start": {"type": "integer"}, "end": {"type": "integer"},
+        "local_start": {"type": "string", "description": "wall-clock time at the location, YYYY-MM-DDTHH:MM[:SS]"},
+        "local_end": {"type": "string"},
+        "time_zone": {"type": "string", "description": "IANA zone of the local times; resolved from the location when empty"}
+      }
+    },
+    "energy": {
+      "type": "object",
+      "required": ["baseline_mwh"],
+      "oneOf": [{"required": ["solar"]}, {"required": ["wind"]}],
+      "properties": {
+        "baseline_mwh": {"type": "string", "description": "contracted production over the coverage period"},
+        "elevation_m": {"type": "number"},
+        "linke_turbidity": {"type": "number", "minimum": 0, "maximum": 10},
+        "solar": {
+          "type": "object",
+          "required": ["capacity_kw"],
+          "properties": {
+            "capacity_kw": {"type": "number", "exclusiveMinimum": 0},
+            "inverter_kw": {"type": "number", "minimum": 0},
+            "tilt_deg": {"type": "number", "minimum": 0, "maximum": 90},
+            "azimuth_deg": {"type": "number", "minimum": 0, "exclusiveMaximum": 360},
+            "temperature_coefficient_pct": {"type": "number", "minimum": -2, "maximum": 0},
+            "losses_pct": {"type": "number", "minimum": 0, "exclusiveMaximum": 100},
+            "albedo": {"type": "number", "minimum": 0, "maximum": 1}
+          }
+        },
+        "wind": {
+          "type": "object",
+          "required": ["hub_height_m", "power_curve"],
+          "properties": {
+            "hub_height_m": {"type": "number", "exclusiveMinimum": 0, "maximum": 300},
+            "power_curve": {
+              "type": "array",
+              "minItems": 2,
+              "items": {"type": "object", "required": ["speed_ms", "power_kw"], "properties": {"speed_ms": {"type": "number"}, "power_kw": {"type": "number"}}}
+            },
+            "count": {"type": "integer", "minimum": 0},
+            "losses_pct": {"type": "number", "minimum": 0, "exclusiveMaximum": 100},
+            "shear_exponent": {"type": "number", "minimum": 0, "maximum": 1}
+          }
+        }
+      }
+    },
+    "payout": {
+      "type": "object",
+      "required": ["structure", "variable"],
+      "properties": {
+        "structure": {"type": "object"},
+        "variable": {"enum": ["shortfall_mwh", "shortfall_bps", "expected_mwh", "capacity_factor", "ghi", "dni", "clearness_index", "hub_wind"]}
+      }
+    }
+  }
+}`
+
+// EnergyResult is the energy block of a result. Energy is in MWh,
+// irradiation in kWh/m² and wind in m/s.
+type EnergyResult struct {
+	Plant       string         `json:"plant"`
+	Start       time.Time      `json:"start"`
+	End         time.Time      `json:"end"`
+	ExpectedMWh payout.Decimal `json:"expected_mwh"`
+	BaselineMWh payout.Decimal `json:"baseline_mwh"`
+	// ShortfallMWh is how far expected production falls short of the baseline
+	ShortfallMWh payout.Decimal `json:"shortfall_mwh"`
+	ShortfallBps uint32         `json:"shortfall_bps"`
+	// ClearSkyMWh is what a PV system would have produced under a cloudless sky
+	ClearSkyMWh    payout.Decimal `json:"clear_sky_mwh,omitempty"`
+	CapacityKW     payout.Decimal `json:"capacity_kw"`
+	PeakKW         payout.Decimal `json:"peak_kw"`
+	CapacityFactor payout.Decimal `json:"capacity_factor"`
+	GHI            payout.Decimal `json:"ghi_kwh_m2,omitempty"`
+	DNI            payout.Decimal `json:"dni_kwh_m2,omitempty"`
+	ClearnessIndex payout.Decimal `json:"clearness_index,omitempty"`
+	MeanHubWindMS  payout.Decimal `json:"mean_hub_wind_ms,omitempty"`
+	MissingHours   payout.Decimal `json:"missing_hours"`
+}
+
+// energyPeril estimates what a solar or wind plant produced over the
+// coverage period, for hedges against low irradiance or wind
+type energyPeril struct{}
+
+func (energyPeril) Spec() PerilSpec {
+	return PerilSpec{
+		Name:        energyPerilName,
+		Description: "Expected production of a PV system or wind turbines over the coverage period from hourly irradiance and hub-height wind, and its shortfall against a contracted baseline",
+		Variables:   energyVariables,
+		Schema:      json.RawMessage(energySchema),
+	}
+}
+
+func (energyPeril) Validate(req *WeatherVerificationRequest) error {
+	terms := req.Energy
+	if terms == nil {
+		return fmt.Errorf("energy task requires energy terms")
+	}
+	if (terms.Solar == nil) == (terms.Wind == nil) {
+		return fmt.Errorf("energy task requires exactly one of a solar or wind plant")
+	}
+	if terms.Solar != nil {
+		if err := terms.Solar.Validate(); err != nil {
+			return err
+		}
+	}
+	if terms.Wind != nil {
+		if err := terms.Wind.Validate(); err != nil {
+			return err
+		}
+	}
+	if terms.BaselineMWh <= 0 {
+		return fmt.Errorf("energy baseline_mwh must be positive")
+	}
+	if terms.ElevationM < -500 || terms.ElevationM > 9000 {
+		return fmt.Errorf("invalid elevation_m %v", terms.ElevationM)
+	}
+	if terms.LinkeTurbidity < 0 || terms.LinkeTurbidity > 10 {
+		return fmt.Errorf("linke_turbidity must be between 0 and 10")
+	}
+	if req.Coverage == nil {
+		return fmt.Errorf("energy task requires a coverage period")
+	}
+	if time.Duration(req.Coverage.End-req.Coverage.Start)*time.Second > maxEnergyWindow {
+		return fmt.Errorf("energy coverage period is longer than %s", maxEnergyWindow)
+	}
+	return nil
+}
+
+func (energyPeril) Requirements(req *WeatherVerificationRequest) DataRequirements {
+	return DataRequirements{Energy: &EnergyRequirement{
+		Location: req.Location,
+		Start:    time.Unix(req.Coverage.Start, 0).UTC(),
+		End:      time.Unix(req.Coverage.End, 0).UTC(),
+	}}
+}
+
+func (energyPeril) Evaluate(env PerilEnv, req *WeatherVerificationRequest, data *PerilData) (map[string]interface{}, error) {
+	result, err := energyResult(req, data)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"energy":   result,
+		"verified": result.ShortfallMWh > 0,
+		"source":   data.EnergySource,
+	}, nil
+}
+
+func (energyPeril) Index(req *WeatherVerificationRequest, data *PerilData, variable string) (payout.Decimal, error) {
+	result, err := energyResult(req, data)
+	if err != nil {
+		return 0, err
+	}
+	switch variable {
+	case "shortfall_mwh":
+		return result.ShortfallMWh, nil
+	case "shortfall_bps":
+		return payout.Decimal(result.ShortfallBps) * payout.Scale, nil
+	case "expected_mwh":
+		return result.ExpectedMWh, nil
+	case "capacity_factor":
+		return result.CapacityFactor, nil
+	case "ghi", "dni", "clearness_index":
+		if req.Energy.Solar == nil {
+			return 0, fmt.Errorf("%s needs a solar plant", variable)
+		}
+		return map[string]payout.Decimal{"ghi": result.GHI, "dni": result.DNI, "clearness_index": result.ClearnessIndex}[variable], nil
+	case "hub_wind":
+		if req.Energy.Wind == nil {
+			return 0, fmt.Errorf("hub_wind needs a wind plant")
+		}
+		return result.MeanHubWindMS, nil
+	default:
+		return 0, fmt.Errorf("invalid payout index variable %q", variable)
+	}
+}
+
+func (energyPeril) Encode(result map[string]interface{}) ([]byte, error) {
+	return canonicalJSON(result)
+}
+
+// energyResult simulates req's plant over its coverage period
+func energyResult(req *WeatherVerificationRequest, data *PerilData) (*EnergyResult, error) {
+	terms := req.Energy
+	plant, kind := terms.plant()
+	site := energy.Site{
+		Latitude:       req.Location.Latitude,
+		Longitude:      req.Location.Longitude,
+		ElevationM:     terms.ElevationM,
+		LinkeTurbidity: terms.LinkeTurbidity,
+	}
+	start, end := time.Unix(req.Coverage.Start, 0), time.Unix(req.Coverage.End, 0)
+	p := energy.Simulate(plant, site, data.Energy, start, end, energyStep)
+	if window := end.Sub(start).Seconds(); float64(p.MissingSeconds) > maxEnergyMissing*window {
+		return nil, fmt.Errorf("hourly weather covers only %d of %d hours of the coverage period",
+			p.CoveredSeconds/3600, int64(window)/3600)
+	}
+
+	result := &EnergyResult{
+		Plant:        kind,
+		Start:        p.Start,
+		End:          p.End,
+		BaselineMWh:  terms.BaselineMWh,
+		MissingHours: secondsToHours(p.MissingSeconds),
+	}
+	for _, f := range []struct {
+		dst   *payout.Decimal
+		value float64
+	}{
+		{&result.ExpectedMWh, p.ExpectedMWh},
+		{&result.ClearSkyMWh, p.ClearSkyMWh},
+		{&result.CapacityKW, p.CapacityKW},
+		{&result.PeakKW, p.PeakKW},
+		{&result.CapacityFactor, p.CapacityFactor},
+		{&result.GHI, p.GHI},
+		{&result.DNI, p.DNI},
+		{&result.ClearnessIndex, p.ClearnessIndex()},
+		{&result.MeanHubWindMS, p.MeanHubWindMS},
+	} {
+		value, err := payout.FromFloat(f.value)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = value
+	}
+	if shortfall := result.BaselineMWh - result.ExpectedMWh; shortfall > 0 {
+		result.ShortfallMWh = shortfall
+		result.ShortfallBps = uint32(int64(shortfall) * payout.MaxBps / int64(result.BaselineMWh))
+	}
+	return result, nil
+}
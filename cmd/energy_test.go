@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+var (
+	windDay  = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	windSite = Location{Latitude: 55.5, Longitude: 7.9}
+)
+
+// windSeries is a day of 7.5 m/s hub-height wind at windSite, with hours
+// listed in skip missing
+func windSeries(skip ...int) string {
+	var b strings.Builder
+	b.WriteString("# Horns Rev met mast\ntime,latitude,longitude,temperature,wind_speed_10m,wind_speed_100m\n")
+	for h := 0; h < 24; h++ {
+		if containsInt(skip, h) {
+			continue
+		}
+		at := windDay.Add(time.Duration(h) * time.Hour).Format(time.RFC3339)
+		fmt.Fprintf(&b, "%s,55.5,7.9,,,7.5\n", at)
+		// Another site in the same file
+		fmt.Fprintf(&b, "%s,56.1,8.2,,,20\n", at)
+	}
+	return b.String()
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReadSeries(t *testing.T) {
+	series := "time,ghi,dni,temperature,wind_speed_10m\n" +
+		"2024-03-01T10:00,420,,8.5,6\n" +
+		"2024-03-01T11:00:00Z,510,610,,\n" +
+		"2024-03-01T12:00,,,,\n"
+	samples, err := readSeries(strings.NewReader(series), windSite, windDay, windDay.Add(12*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("samples = %+v", samples)
+	}
+	s := samples[0]
+	if !s.Time.Equal(windDay.Add(10*time.Hour)) || *s.GHI != 420 || s.DNI != nil || *s.TemperatureC != 8.5 || s.Wind[10] != 6 {
+		t.Errorf("10:00 = %+v", s)
+	}
+	if s := samples[1]; *s.DNI != 610 || s.TemperatureC != nil || s.Wind != nil {
+		t.Errorf("11:00 = %+v", s)
+	}
+
+	samples, err = readSeries(strings.NewReader(windSeries()), windSite, windDay, windDay.Add(24*time.Hour))
+	if err != nil || len(samples) != 24 || samples[0].Wind[100] != 7.5 {
+		t.Errorf("nearby rows = %d, %v", len(samples), err)
+	}
+
+	for _, bad := range []string{"ghi\n1\n", "time,ghi\n2024-03-01T10:00,x\n", "time\nyesterday\n"} {
+		if _, err := readSeries(strings.NewReader(bad), windSite, windDay, windDay.Add(24*time.Hour)); err == nil {
+			t.Errorf("readSeries(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestOpenMeteoEnergySource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("start_date") != "2024-03-01" || q.Get("end_date") != "2024-03-01" || q.Get("wind_speed_unit") != "ms" ||
+			!strings.Contains(q.Get("hourly"), "wind_speed_100m") {
+			http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"hourly": {
+			"time": ["2024-03-01T10:00", "2024-03-01T11:00", "2024-03-01T12:00"],
+			"shortwave_radiation": [300, 400, null],
+			"temperature_2m": [7, 8, 9],
+			"wind_speed_10m": [5, 6, 7],
+			"wind_speed_100m": [8, 9, null]
+		}}`))
+	}))
+	defer srv.Close()
+
+	source := NewOpenMeteoEnergySource(srv.URL, srv.Client())
+	samples, err := source.FetchSamples(context.Background(), windSite, windDay.Add(10*time.Hour), windDay.Add(12*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each value is the mean of the hour before its label
+	if len(samples) != 2 || !samples[0].Time.Equal(windDay.Add(10*time.Hour)) || *samples[0].GHI != 400 {
+		t.Fatalf("samples = %+v", samples)
+	}
+	if s := samples[1]; s.GHI != nil || *s.TemperatureC != 9 || len(s.Wind) != 1 || s.Wind[10] != 7 {
+		t.Errorf("11:00 = %+v", s)
+	}
+	if _, err := source.FetchSamples(context.Background(), windSite, windDay, windDay.Add(48*time.Hour)); err == nil {
+		t.Error("bad query succeeded")
+	}
+}
+
+// energyPayload is a wind task over windDay contracted for 30 MWh, paying
+// out in full at a 12 MWh shortfall
+const energyPayload = `{"type": "energy", "policy_id": "POL-WIND", "location": {"latitude": 55.5, "longitude": 7.9},
+	"coverage": {"start": 1709251200, "end": 1709337600},
+	"energy": {"baseline_mwh": "30", "wind": {"hub_height_m": 100,
+		"power_curve": [{"speed_ms": 3, "power_kw": 0}, {"speed_ms": 12, "power_kw": 2000}, {"speed_ms": 25, "power_kw": 2000}]}},
+	"payout": {"variable": "shortfall_mwh", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "12", "sum_insured": "1000"}}}`
+
+func TestEnergyPeril_HandleTask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "series.csv")
+	if err := os.WriteFile(path, []byte(windSeries(3)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return windDay.Add(24 * time.Hour) }
+	worker.energy = energySources(EnergyConfig{Series: path})
+
+	task := &performerV1.TaskRequest{TaskId: []byte("task-wind"), Payload: []byte(energyPayload)}
+	if err := worker.ValidateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Type     string         `json:"type"`
+		Verified bool           `json:"verified"`
+		Source   string         `json:"source"`
+		Energy   *EnergyResult  `json:"energy"`
+		Payout   *payout.Result `json:"payout"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	e := result.Energy
+	if result.Type != TaskTypePayout || !result.Verified || result.Source != "energy-series" || e == nil || e.Plant != "wind" {
+		t.Fatalf("result = %s", resp.Result)
+	}
+	// 23 hours at 1 MW, with the missing hour produced at the same rate
+	if e.ExpectedMWh.String() != "24" || e.ShortfallMWh.String() != "6" || e.ShortfallBps != 2000 ||
+		e.MissingHours.String() != "1" || e.CapacityFactor.String() != "0.5" || e.MeanHubWindMS.String() != "7.5" {
+		t.Errorf("energy = %s", resp.Result)
+	}
+	if result.Payout == nil || result.Payout.Payout.String() != "500" {
+		t.Errorf("payout = %+v", result.Payout)
+	}
+
+	// Too much of the period missing to estimate
+	if err := os.WriteFile(path, []byte(windSeries(1, 2, 3, 4, 5)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	task = &performerV1.TaskRequest{TaskId: []byte("task-wind-2"), Payload: []byte(strings.Replace(energyPayload, "POL-WIND", "POL-WIND-2", 1))}
+	if _, err := worker.HandleTask(task); err == nil || !strings.Contains(err.Error(), "covers only 19 of 24 hours") {
+		t.Errorf("HandleTask with gaps: %v", err)
+	}
+}
+
+func TestEnergyPeril_Validate(t *testing.T) {
+	pv := `"solar": {"capacity_kw": 1000, "tilt_deg": 30, "azimuth_deg": 180}`
+	for name, c := range map[string]struct {
+		payload string
+		err     string
+	}{
+		"no terms":    {`{"coverage": {"start": 1, "end": 2}}`, "requires energy terms"},
+		"no plant":    {`{"coverage": {"start": 1, "end": 2}, "energy": {"baseline_mwh": "1"}}`, "exactly one"},
+		"bad plant":   {`{"coverage": {"start": 1, "end": 2}, "energy": {"baseline_mwh": "1", "solar": {"capacity_kw": 0}}}`, "capacity_kw"},
+		"no baseline": {`{"coverage": {"start": 1, "end": 2}, "energy": {` + pv + `}}`, "baseline_mwh must be positive"},
+		"no coverage": {`{"energy": {"baseline_mwh": "1", ` + pv + `}}`, "requires a coverage period"},
+		"long window": {`{"coverage": {"start": 0, "end": 9000000}, "energy": {"baseline_mwh": "1", ` + pv + `}}`, "longer than"},
+		"variable": {`{"coverage": {"start": 1, "end": 2}, "energy": {"baseline_mwh": "1", ` + pv + `},
+			"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "1", "sum_insured": "1"}}}`, "invalid payout index variable"},
+	} {
+		var req WeatherVerificationRequest
+		if err := json.Unmarshal([]byte(c.payload), &req); err != nil {
+			t.Fatal(err)
+		}
+		req.Type, req.PolicyID = energyPerilName, "P"
+		if err := req.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: Validate() = %v", name, err)
+		}
+	}
+}
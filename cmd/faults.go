@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// FaultType names a provider failure mode the fault injector can simulate
+type FaultType string
+
+const (
+	FaultLatency       FaultType = "latency"
+	FaultTimeout       FaultType = "timeout"
+	FaultHTTP5xx       FaultType = "http_5xx"
+	FaultTruncatedJSON FaultType = "truncated_json"
+	FaultStale         FaultType = "stale"
+	FaultBias          FaultType = "bias"
+)
+
+// Duration is a time.Duration that reads and writes JSON as a Go duration string
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1.5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// WeatherBias is an additive offset applied to provider readings
+type WeatherBias struct {
+	Temperature float64 `json:"temperature,omitempty"`
+	Humidity    float64 `json:"humidity,omitempty"`
+	WindSpeed   float64 `json:"wind_speed,omitempty"`
+	Pressure    float64 `json:"pressure,omitempty"`
+}
+
+// FaultRule describes one fault and when it fires.
+// A rule is active from After until After+For (For of zero means forever) measured
+// from when the configuration was applied. While active it fires on every Every-th
+// call, or with Probability when Every is zero.
+type FaultRule struct {
+	Type        FaultType   `json:"type"`
+	Probability float64     `json:"probability,omitempty"`
+	Every       int         `json:"every,omitempty"`
+	After       Duration    `json:"after,omitempty"`
+	For         Duration    `json:"for,omitempty"`
+	Latency     Duration    `json:"latency,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	StaleBy     Duration    `json:"stale_by,omitempty"`
+	Bias        WeatherBias `json:"bias,omitempty"`
+}
+
+// FaultConfig configures the fault injector
+type FaultConfig struct {
+	// Seed makes fault decisions reproducible; zero seeds from the clock
+	Seed int64 `json:"seed,omitempty"`
+	// Providers limits injection to the named providers; empty means all
+	Providers []string    `json:"providers,omitempty"`
+	Faults    []FaultRule `json:"faults"`
+}
+
+// Validate checks the fault rules
+func (c *FaultConfig) Validate() error {
+	for i, rule := range c.Faults {
+		if rule.Probability < 0 || rule.Probability > 1 {
+			return fmt.Errorf("fault %d: probability must be between 0 and 1", i)
+		}
+		if rule.Every < 0 {
+			return fmt.Errorf("fault %d: every must not be negative", i)
+		}
+		if rule.Probability == 0 && rule.Every == 0 {
+			return fmt.Errorf("fault %d: set probability or every", i)
+		}
+		switch rule.Type {
+		case FaultLatency:
+			if rule.Latency <= 0 {
+				return fmt.Errorf("fault %d: latency requires a positive latency", i)
+			}
+		case FaultHTTP5xx:
+			if rule.Status != 0 && (rule.Status < 500 || rule.Status > 599) {
+				return fmt.Errorf("fault %d: status must be 5xx, got %d", i, rule.Status)
+			}
+		case FaultStale:
+			if rule.StaleBy <= 0 {
+				return fmt.Errorf("fault %d: stale requires a positive stale_by", i)
+			}
+		case FaultTimeout, FaultTruncatedJSON, FaultBias:
+		default:
+			return fmt.Errorf("fault %d: unknown type %q", i, rule.Type)
+		}
+	}
+	return nil
+}
+
+// faultInjectionOn enables fault injection with no faults applied at start
+const faultInjectionOn = "on"
+
+// LoadFaultConfig reads a fault configuration file
+func LoadFaultConfig(path string) (*FaultConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fault config: %w", err)
+	}
+	var cfg FaultConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid fault config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// FaultInjector decides which faults fire for each provider call.
+// Its configuration can be replaced at runtime.
+type FaultInjector struct {
+	mu      sync.Mutex
+	config  FaultConfig
+	rng     *rand.Rand
+	applied time.Time
+	calls   map[int]int
+	counts  map[FaultType]uint64
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewFaultInjector creates an injector with no active faults
+func NewFaultInjector() *FaultInjector {
+	fi := &FaultInjector{
+		counts: make(map[FaultType]uint64),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+	fi.SetConfig(FaultConfig{})
+	return fi
+}
+
+// SetConfig replaces the active configuration and restarts rule schedules
+func (fi *FaultInjector) SetConfig(cfg FaultConfig) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fi.config = cfg
+	fi.rng = rand.New(rand.NewSource(seed))
+	fi.applied = fi.now()
+	fi.calls = make(map[int]int)
+}
+
+// Config returns the active configuration
+func (fi *FaultInjector) Config() FaultConfig {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.config
+}
+
+// Counts returns how many times each fault type has fired
+func (fi *FaultInjector) Counts() map[FaultType]uint64 {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	out := make(map[FaultType]uint64, len(fi.counts))
+	for k, v := range fi.counts {
+		out[k] = v
+	}
+	return out
+}
+
+// Wrap returns providers with fault injection applied to those the configuration targets
+func (fi *FaultInjector) Wrap(providers ...WeatherProvider) []WeatherProvider {
+	wrapped := make([]WeatherProvider, len(providers))
+	for i, p := range providers {
+		faulty := &FaultInjectingProvider{inner: p, injector: fi}
+		wrapped[i] = faulty
+		if hp, ok := p.(HistoricalProvider); ok {
+			wrapped[i] = &historicalFaultProvider{FaultInjectingProvider: faulty, inner: hp}
+		}
+	}
+	return wrapped
+}
+
+// firing returns the rules that fire for a call to provider
+func (fi *FaultInjector) firing(provider string) []FaultRule {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if len(fi.config.Providers) > 0 {
+		targeted := false
+		for _, name := range fi.config.Providers {
+			targeted = targeted || name == provider
+		}
+		if !targeted {
+			return nil
+		}
+	}
+
+	elapsed := fi.now().Sub(fi.applied)
+	var fired []FaultRule
+	for i, rule := range fi.config.Faults {
+		if elapsed < time.Duration(rule.After) {
+			continue
+		}
+		if rule.For > 0 && elapsed >= time.Duration(rule.After+rule.For) {
+			continue
+		}
+		fi.calls[i]++
+		var fire bool
+		if rule.Every > 0 {
+			fire = fi.calls[i]%rule.Every == 0
+		} else {
+			fire = fi.rng.Float64() < rule.Probability
+		}
+		if fire {
+			fi.counts[rule.Type]++
+			fired = append(fired, rule)
+		}
+	}
+	return fired
+}
+
+// FaultInjectingProvider wraps a provider and applies the injector's faults to its calls
+type FaultInjectingProvider struct {
+	inner    WeatherProvider
+	injector *FaultInjector
+}
+
+// Name returns the wrapped provider's name so results are attributed unchanged
+func (p *FaultInjectingProvider) Name() string {
+	return p.inner.Name()
+}
+
+// FetchCurrent fetches from the wrapped provider, injecting any faults that fire
+func (p *FaultInjectingProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	return p.inject(ctx, func(ctx context.Context) (*WeatherData, error) {
+		return p.inner.FetchCurrent(ctx, location)
+	})
+}
+
+// historicalFaultProvider is a FaultInjectingProvider around a historical
+// provider, so wrapping keeps it answering for past times
+type historicalFaultProvider struct {
+	*FaultInjectingProvider
+	inner HistoricalProvider
+}
+
+// FetchAt fetches at from the wrapped provider, injecting any faults that fire
+func (p *historicalFaultProvider) FetchAt(ctx context.Context, location Location, at time.Time) (*WeatherData, error) {
+	return p.inject(ctx, func(ctx context.Context) (*WeatherData, error) {
+		return p.inner.FetchAt(ctx, location, at)
+	})
+}
+
+// inject makes a call through fetch, applying any faults that fire to it
+func (p *FaultInjectingProvider) inject(ctx context.Context, fetch func(context.Context) (*WeatherData, error)) (*WeatherData, error) {
+	rules := p.injector.firing(p.inner.Name())
+
+	// Latency and failures happen before the provider is reached, the way a slow
+	// or broken upstream would look to the caller.
+	for _, rule := range rules {
+		switch rule.Type {
+		case FaultLatency:
+			if err := p.injector.sleep(ctx, time.Duration(rule.Latency)); err != nil {
+				return nil, fmt.Errorf("failed to fetch weather data: %w", err)
+			}
+		case FaultTimeout:
+			wait := time.Duration(rule.Latency)
+			if wait == 0 {
+				wait = 10 * time.Second
+			}
+			if err := p.injector.sleep(ctx, wait); err != nil {
+				return nil, fmt.Errorf("failed to fetch weather data: %w", err)
+			}
+			return nil, fmt.Errorf("failed to fetch weather data: %w", context.DeadlineExceeded)
+		case FaultHTTP5xx:
+			status := rule.Status
+			if status == 0 {
+				status = http.StatusServiceUnavailable
+			}
+			return nil, fmt.Errorf("API returned status %d", status)
+		case FaultTruncatedJSON:
+			return nil, fmt.Errorf("failed to decode weather data: %w", io.ErrUnexpectedEOF)
+		}
+	}
+
+	data, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var corrupted *WeatherData
+	for _, rule := range rules {
+		switch rule.Type {
+		case FaultStale:
+			if corrupted == nil {
+				copied := *data
+				corrupted = &copied
+			}
+			corrupted.Timestamp = corrupted.Timestamp.Add(-time.Duration(rule.StaleBy))
+		case FaultBias:
+			if corrupted == nil {
+				copied := *data
+				corrupted = &copied
+			}
+			corrupted.Temperature += rule.Bias.Temperature
+			corrupted.Humidity += rule.Bias.Humidity
+			corrupted.WindSpeed += rule.Bias.WindSpeed
+			corrupted.Pressure += rule.Bias.Pressure
+		}
+	}
+	if corrupted != nil {
+		return corrupted, nil
+	}
+	return data, nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Faults endpoint: GET shows the active configuration and counts, PUT replaces it,
+// DELETE clears every fault
+func (fi *FaultInjector) faultsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg FaultConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, fmt.Sprintf("invalid fault config: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := cfg.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fi.SetConfig(cfg)
+	case http.MethodDelete:
+		fi.SetConfig(FaultConfig{})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config": fi.Config(),
+		"counts": fi.Counts(),
+	})
+}
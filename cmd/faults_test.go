@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// staticProvider returns the same reading on every call
+type staticProvider struct {
+	data  WeatherData
+	calls int
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	p.calls++
+	data := p.data
+	return &data, nil
+}
+
+func newTestInjector(cfg FaultConfig) *FaultInjector {
+	fi := NewFaultInjector()
+	fi.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	fi.SetConfig(cfg)
+	return fi
+}
+
+func TestFaultInjectingProvider(t *testing.T) {
+	reading := WeatherData{Temperature: 20, Humidity: 50, WindSpeed: 10, Pressure: 1013, Timestamp: time.Unix(1704067200, 0)}
+
+	tests := []struct {
+		name    string
+		rule    FaultRule
+		wantErr error
+		errText string
+		check   func(t *testing.T, got *WeatherData)
+	}{
+		{
+			name:    "http 5xx",
+			rule:    FaultRule{Type: FaultHTTP5xx, Every: 1, Status: 502},
+			errText: "API returned status 502",
+		},
+		{
+			name:    "truncated json",
+			rule:    FaultRule{Type: FaultTruncatedJSON, Every: 1},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "timeout",
+			rule:    FaultRule{Type: FaultTimeout, Every: 1},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "stale",
+			rule: FaultRule{Type: FaultStale, Every: 1, StaleBy: Duration(3 * time.Hour)},
+			check: func(t *testing.T, got *WeatherData) {
+				if want := reading.Timestamp.Add(-3 * time.Hour); !got.Timestamp.Equal(want) {
+					t.Errorf("timestamp = %v, want %v", got.Timestamp, want)
+				}
+			},
+		},
+		{
+			name: "bias",
+			rule: FaultRule{Type: FaultBias, Every: 1, Bias: WeatherBias{Temperature: 5, Pressure: -10}},
+			check: func(t *testing.T, got *WeatherData) {
+				if got.Temperature != 25 || got.Pressure != 1003 || got.Humidity != 50 {
+					t.Errorf("unexpected biased reading: %+v", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &staticProvider{data: reading}
+			fi := newTestInjector(FaultConfig{Faults: []FaultRule{tt.rule}})
+			provider := fi.Wrap(inner)[0]
+			if provider.Name() != "static" {
+				t.Errorf("Name() = %q, want wrapped provider name", provider.Name())
+			}
+
+			got, err := provider.FetchCurrent(context.Background(), Location{})
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("error = %v, want %q", err, tt.errText)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				tt.check(t, got)
+				if !reflect.DeepEqual(inner.data, reading) {
+					t.Error("fault mutated the provider's reading")
+				}
+			}
+			if fi.Counts()[tt.rule.Type] != 1 {
+				t.Errorf("counts = %v, want one %s", fi.Counts(), tt.rule.Type)
+			}
+		})
+	}
+}
+
+func TestFaultInjector_Schedule(t *testing.T) {
+	now := time.Unix(1704067200, 0)
+	fi := NewFaultInjector()
+	fi.now = func() time.Time { return now }
+	fi.SetConfig(FaultConfig{Faults: []FaultRule{
+		{Type: FaultHTTP5xx, Every: 2, After: Duration(time.Minute), For: Duration(time.Minute)},
+	}})
+	provider := fi.Wrap(&staticProvider{})[0]
+
+	fails := func() bool {
+		_, err := provider.FetchCurrent(context.Background(), Location{})
+		return err != nil
+	}
+
+	if fails() || fails() {
+		t.Error("fault fired before its window opened")
+	}
+	now = now.Add(90 * time.Second)
+	if fails() || !fails() || fails() || !fails() {
+		t.Error("fault did not fire on every second call inside its window")
+	}
+	now = now.Add(time.Minute)
+	if fails() || fails() {
+		t.Error("fault fired after its window closed")
+	}
+}
+
+func TestFaultInjector_ProbabilityIsSeeded(t *testing.T) {
+	cfg := FaultConfig{Seed: 42, Faults: []FaultRule{{Type: FaultHTTP5xx, Probability: 0.3}}}
+	pattern := func() string {
+		provider := newTestInjector(cfg).Wrap(&staticProvider{})[0]
+		var sb strings.Builder
+		for i := 0; i < 200; i++ {
+			if _, err := provider.FetchCurrent(context.Background(), Location{}); err != nil {
+				sb.WriteByte('x')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		return sb.String()
+	}
+
+	first := pattern()
+	if first != pattern() {
+		t.Error("same seed produced different fault sequences")
+	}
+	if n := strings.Count(first, "x"); n < 30 || n > 90 {
+		t.Errorf("%d of 200 calls failed, want roughly 60", n)
+	}
+}
+
+func TestFaultInjector_ProviderFilter(t *testing.T) {
+	fi := newTestInjector(FaultConfig{
+		Providers: []string{"open-meteo"},
+		Faults:    []FaultRule{{Type: FaultHTTP5xx, Every: 1}},
+	})
+	if _, err := fi.Wrap(&staticProvider{})[0].FetchCurrent(context.Background(), Location{}); err != nil {
+		t.Errorf("untargeted provider failed: %v", err)
+	}
+}
+
+func TestFaultConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FaultRule
+	}{
+		{name: "unknown type", rule: FaultRule{Type: "meteor", Every: 1}},
+		{name: "no trigger", rule: FaultRule{Type: FaultHTTP5xx}},
+		{name: "probability above one", rule: FaultRule{Type: FaultHTTP5xx, Probability: 1.5}},
+		{name: "latency without duration", rule: FaultRule{Type: FaultLatency, Every: 1}},
+		{name: "non-5xx status", rule: FaultRule{Type: FaultHTTP5xx, Every: 1, Status: 404}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := FaultConfig{Faults: []FaultRule{tt.rule}}
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate() accepted an invalid rule")
+			}
+		})
+	}
+}
+
+func TestFaultsHandler(t *testing.T) {
+	fi := NewFaultInjector()
+	srv := httptest.NewServer(requireAPIKey(dryRunKey, fi.faultsHandler))
+	defer srv.Close()
+	do := func(method, key, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	latency := `{"faults":[{"type":"latency","probability":0.5,"latency":"250ms"}]}`
+	for _, key := range []string{"", "wrong-key-0123456789"} {
+		if status := do(http.MethodPut, key, latency); status != http.StatusUnauthorized {
+			t.Errorf("PUT with key %q = %d, want 401", key, status)
+		}
+	}
+	if len(fi.Config().Faults) != 0 {
+		t.Fatal("unauthenticated PUT applied faults")
+	}
+
+	if status := do(http.MethodPut, dryRunKey, latency); status != http.StatusOK {
+		t.Fatalf("PUT status = %d", status)
+	}
+	if rules := fi.Config().Faults; len(rules) != 1 || time.Duration(rules[0].Latency) != 250*time.Millisecond {
+		t.Errorf("unexpected config after PUT: %+v", fi.Config())
+	}
+
+	if status := do(http.MethodPut, dryRunKey, `{"faults":[{"type":"meteor","every":1}]}`); status != http.StatusBadRequest {
+		t.Errorf("PUT invalid config status = %d, want 400", status)
+	}
+
+	do(http.MethodDelete, dryRunKey, "")
+	if len(fi.Config().Faults) != 0 {
+		t.Error("DELETE did not clear faults")
+	}
+
+	// Without a configured key nothing gets through
+	closed := httptest.NewServer(requireAPIKey("", fi.faultsHandler))
+	defer closed.Close()
+	if resp, err := http.Get(closed.URL); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET with no key configured: %v, %v", resp.StatusCode, err)
+	}
+}
+
+func TestConfig_FaultInjection(t *testing.T) {
+	t.Setenv("FAULT_INJECTION", faultInjectionOn)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "DRY_RUN_API_KEY") {
+		t.Errorf("FAULT_INJECTION without an API key: %v", err)
+	}
+	cfg.DryRunAPIKey = dryRunKey
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("FAULT_INJECTION with an API key: %v", err)
+	}
+	cfg.Env = "production"
+	if err := cfg.Validate(); err == nil {
+		t.Error("FAULT_INJECTION allowed in production")
+	}
+}
+
+func TestFaultInjector_WrapKeepsHistory(t *testing.T) {
+	fi := newTestInjector(FaultConfig{Faults: []FaultRule{{Type: FaultHTTP5xx, Every: 2}}})
+	wrapped := fi.Wrap(&archiveProvider{rain: map[string]float64{"2024-04-01": 12}}, &staticProvider{})
+	if _, ok := wrapped[1].(HistoricalProvider); ok {
+		t.Error("wrapping made a current-only provider historical")
+	}
+	hp, ok := wrapped[0].(HistoricalProvider)
+	if !ok {
+		t.Fatal("wrapping dropped FetchAt")
+	}
+
+	at := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	data, err := hp.FetchAt(context.Background(), Location{}, at)
+	if err != nil || *data.Precipitation != 12 {
+		t.Fatalf("FetchAt = %+v, %v", data, err)
+	}
+	if _, err := hp.FetchAt(context.Background(), Location{}, at); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("FetchAt with a fault firing = %v, want status 503", err)
+	}
+}
+
+func TestWeatherProviders_FaultsReachArchives(t *testing.T) {
+	t.Setenv("FAULT_INJECTION", faultInjectionOn)
+	t.Setenv("GRID_DIR", writeGridDir(t))
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers, faults, err := weatherProviders(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	faults.SetConfig(FaultConfig{Faults: []FaultRule{{Type: FaultHTTP5xx, Every: 1}}})
+	hp, ok := providers[0].(HistoricalProvider)
+	if !ok {
+		t.Fatalf("gridded provider %T lost FetchAt", providers[0])
+	}
+	at := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := hp.FetchAt(context.Background(), Location{Latitude: -0.875, Longitude: 36.625}, at); err == nil {
+		t.Error("fault did not reach a past fetch from the rasters")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GridConfig configures the offline gridded precipitation provider
+type GridConfig struct {
+	// Dir holds one raster per day, with the date in the file name
+	// (e.g. chirps-v2.0.2024.01.01.tif or imerg_20240101.nc)
+	Dir  string `json:"dir,omitempty"`
+	Name string `json:"name"`
+	// Variable is the NetCDF variable holding daily precipitation in mm
+	Variable string `json:"variable"`
+	// Interpolation is "bilinear" or "nearest"
+	Interpolation string `json:"interpolation"`
+}
+
+// Validate checks the gridded provider settings
+func (c GridConfig) Validate() error {
+	if c.Interpolation != "bilinear" && c.Interpolation != "nearest" {
+		return fmt.Errorf("GRID_INTERPOLATION must be bilinear or nearest, got %q", c.Interpolation)
+	}
+	if c.Dir == "" {
+		return nil
+	}
+	info, err := os.Stat(c.Dir)
+	if err != nil {
+		return fmt.Errorf("invalid GRID_DIR: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("GRID_DIR %s is not a directory", c.Dir)
+	}
+	return nil
+}
+
+// rasterDatePattern finds a YYYY?MM?DD date in a raster file name
+var rasterDatePattern = regexp.MustCompile(`(\d{4})[._-]?(\d{2})[._-]?(\d{2})`)
+
+// gridCacheSize bounds the decoded rasters kept in memory; global daily grids
+// are tens of megabytes each
+const gridCacheSize = 4
+
+// GriddedProvider reads daily precipitation from rasters on disk, so claims can
+// be verified with no network dependency
+type GriddedProvider struct {
+	cfg   GridConfig
+	mu    sync.Mutex
+	index map[string]string
+	grids map[string]*gridEntry
+	order []string
+	now   func() time.Time
+}
+
+// gridEntry is a raster decoded once, outside the provider's lock, however
+// many fetches wait for it
+type gridEntry struct {
+	once sync.Once
+	path string
+	grid *Grid
+	err  error
+}
+
+// NewGriddedProvider indexes the rasters in cfg.Dir
+func NewGriddedProvider(cfg GridConfig) (*GriddedProvider, error) {
+	p := &GriddedProvider{cfg: cfg, now: time.Now}
+	if err := p.Reindex(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reindex rescans the raster directory and drops decoded rasters
+func (p *GriddedProvider) Reindex() error {
+	entries, err := os.ReadDir(p.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read grid directory: %w", err)
+	}
+
+	index := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !isRasterFile(entry.Name()) {
+			continue
+		}
+		m := rasterDatePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		date := fmt.Sprintf("%s-%s-%s", m[1], m[2], m[3])
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			continue
+		}
+		if existing, ok := index[date]; ok {
+			return fmt.Errorf("rasters %s and %s both cover %s", filepath.Base(existing), entry.Name(), date)
+		}
+		index[date] = filepath.Join(p.cfg.Dir, entry.Name())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.index = index
+	p.grids = make(map[string]*gridEntry)
+	p.order = nil
+	return nil
+}
+
+func isRasterFile(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".gz")
+	for _, ext := range []string{".tif", ".tiff", ".nc"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dates returns the indexed dates in order
+func (p *GriddedProvider) Dates() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dates := make([]string, 0, len(p.index))
+	for date := range p.index {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// Name returns the dataset name recorded as the data source
+func (p *GriddedProvider) Name() string {
+	return p.cfg.Name
+}
+
+// FetchCurrent returns today's precipitation (UTC), if today's raster is present
+func (p *GriddedProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	return p.FetchAt(ctx, location, p.now())
+}
+
+// FetchAt returns the precipitation of the UTC day containing at, sampled at
+// the location or averaged over its region
+func (p *GriddedProvider) FetchAt(ctx context.Context, location Location, at time.Time) (*WeatherData, error) {
+	day := at.UTC().Truncate(24 * time.Hour)
+	grid, err := p.grid(day.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	var precip float64
+	switch {
+	case location.Region != nil:
+		precip, _, err = grid.RegionMean(*location.Region)
+	case p.cfg.Interpolation == "nearest":
+		precip, err = grid.Nearest(location.Latitude, location.Longitude)
+	default:
+		precip, err = grid.Bilinear(location.Latitude, location.Longitude)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := "Dry"
+	if precip >= 1 {
+		conditions = "Rainy"
+	}
+	// The cell's larger side, shrinking in longitude towards the poles
+	kmPerDegree := math.Pi / 180 * 6371.0088
+	resolution := math.Max(math.Abs(grid.DLat), math.Abs(grid.DLon)*math.Cos(location.Latitude*math.Pi/180)) * kmPerDegree
+	return &WeatherData{
+		Precipitation: &precip,
+		Conditions:    conditions,
+		Source:        p.Name(),
+		Timestamp:     day,
+		ResolutionKm:  roundTo(resolution, 3),
+		PeriodSeconds: int64((24 * time.Hour).Seconds()),
+	}, nil
+}
+
+// grid returns the decoded raster for date, decoding it on first use.
+// Fetches of other dates do not wait for the decode.
+func (p *GriddedProvider) grid(date string) (*Grid, error) {
+	p.mu.Lock()
+	entry, ok := p.grids[date]
+	if !ok {
+		path, indexed := p.index[date]
+		if !indexed {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("no raster for %s", date)
+		}
+		if len(p.order) >= gridCacheSize {
+			delete(p.grids, p.order[0])
+			p.order = p.order[1:]
+		}
+		entry = &gridEntry{path: path}
+		p.grids[date] = entry
+		p.order = append(p.order, date)
+	}
+	p.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.grid, entry.err = ReadRaster(entry.path, p.cfg.Variable)
+	})
+	if entry.err != nil {
+		// Forget the failure so a later fetch reads the file again
+		p.mu.Lock()
+		if p.grids[date] == entry {
+			delete(p.grids, date)
+			for i, d := range p.order {
+				if d == date {
+					p.order = append(p.order[:i], p.order[i+1:]...)
+					break
+				}
+			}
+		}
+		p.mu.Unlock()
+		return nil, entry.err
+	}
+	return entry.grid, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// writeGridDir writes two daily rasters around Nairobi: 2024-04-01 as GeoTIFF
+// (value 10*row + col) and 2024-04-02 as NetCDF (all 2.5 mm)
+func writeGridDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestGeoTIFF(t, filepath.Join(dir, "chirps-v2.0.2024.04.01.tif"), testTIFF{
+		order: binary.LittleEndian, width: 4, height: 4, values: testGridValues(),
+		lon: 36, lat: -0.5, scale: 0.25,
+	})
+	writeTestNetCDF(t, filepath.Join(dir, "chirps_20240402.nc"), "precip",
+		[]float64{-1.5, -1, -0.5}, []float64{36, 36.5, 37},
+		[]int16{25, 25, 25, 25, 25, 25, 25, 25, 25}, 0.1, -1)
+	return dir
+}
+
+func newTestGriddedProvider(t *testing.T, dir string) *GriddedProvider {
+	t.Helper()
+	p, err := NewGriddedProvider(GridConfig{Dir: dir, Name: "chirps", Variable: "precip", Interpolation: "bilinear"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestGriddedProvider_FetchAt(t *testing.T) {
+	p := newTestGriddedProvider(t, writeGridDir(t))
+	if dates := p.Dates(); len(dates) != 2 || dates[0] != "2024-04-01" || dates[1] != "2024-04-02" {
+		t.Fatalf("Dates() = %v", dates)
+	}
+
+	// Centre of row 1, col 2 in the GeoTIFF
+	point := Location{Latitude: -0.875, Longitude: 36.625}
+	data, err := p.FetchAt(context.Background(), point, time.Date(2024, 4, 1, 15, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Precipitation == nil || *data.Precipitation != 12 || data.Conditions != "Rainy" || data.Source != "chirps" {
+		t.Errorf("unexpected reading: %+v", data)
+	}
+	if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); !data.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want the start of the day", data.Timestamp)
+	}
+
+	region := Location{Latitude: -1, Longitude: 36.5, Region: &BoundingBox{
+		MinLatitude: -1.5, MaxLatitude: -0.5, MinLongitude: 36, MaxLongitude: 37,
+	}}
+	data, err = p.FetchAt(context.Background(), region, time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(*data.Precipitation-2.5) > 1e-9 {
+		t.Errorf("region mean = %v, want 2.5", *data.Precipitation)
+	}
+
+	if _, err := p.FetchAt(context.Background(), point, time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("FetchAt() for a day with no raster succeeded")
+	}
+}
+
+func TestGriddedProvider_RejectsDuplicateDates(t *testing.T) {
+	dir := writeGridDir(t)
+	writeTestGeoTIFF(t, filepath.Join(dir, "imerg-2024-04-01.tif"), testTIFF{
+		order: binary.LittleEndian, width: 4, height: 4, values: testGridValues(), lon: 36, lat: -0.5, scale: 0.25,
+	})
+	if _, err := NewGriddedProvider(GridConfig{Dir: dir, Name: "chirps", Variable: "precip", Interpolation: "bilinear"}); err == nil {
+		t.Error("two rasters for the same day were accepted")
+	}
+}
+
+func TestHandleTask_GriddedPrecipitation(t *testing.T) {
+	dir := writeGridDir(t)
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(newTestGriddedProvider(t, dir))
+
+	payload := `{"location": {"latitude": -0.875, "longitude": 36.625}, "timestamp": 1711983600, "policy_id": "POL-KE-1"}`
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-grid"), Payload: []byte(payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Source  string      `json:"source"`
+		Weather WeatherData `json:"weather"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != "chirps" || result.Weather.Precipitation == nil || *result.Weather.Precipitation != 12 {
+		t.Fatalf("unexpected result: %s", resp.Result)
+	}
+
+	// The same rasters reproduce the claim offline
+	taskPath := filepath.Join(t.TempDir(), "task.json")
+	resultPath := filepath.Join(t.TempDir(), "result.json")
+	writeJSONFile(t, taskPath, json.RawMessage(payload))
+	writeJSONFile(t, resultPath, json.RawMessage(resp.Result))
+	t.Setenv("GRID_NAME", "chirps")
+	var stdout, stderr bytes.Buffer
+	if code := runVerify([]string{"-task", taskPath, "-result", resultPath, "-grid", dir}, &stdout, &stderr); code != verifyExitMatch {
+		t.Errorf("verify -grid = %d\n%s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestGriddedProvider_CurrentDayStaysFresh(t *testing.T) {
+	p := newTestGriddedProvider(t, writeGridDir(t))
+	// Late in the day the reading began long before the hour-old limit
+	now := time.Date(2024, 4, 1, 22, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	client := NewWeatherClientWithProviders(zap.NewNop(), p)
+	client.now = func() time.Time { return now }
+	if _, err := client.FetchWeather(context.Background(), Location{Latitude: -0.875, Longitude: 36.625}); err != nil {
+		t.Fatalf("today's raster rejected: %v", err)
+	}
+
+	// Yesterday's total is stale once its day is over by more than the maximum age
+	client.PurgeCache("")
+	now = time.Date(2024, 4, 2, 1, 30, 0, 0, time.UTC)
+	p.now = func() time.Time { return time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC) }
+	if _, err := client.FetchWeather(context.Background(), Location{Latitude: -0.875, Longitude: 36.625}); !errors.Is(err, ErrStaleObservation) {
+		t.Errorf("error = %v, want stale observation", err)
+	}
+}
+
+func TestGriddedProvider_ConcurrentFetches(t *testing.T) {
+	p := newTestGriddedProvider(t, writeGridDir(t))
+	point := Location{Latitude: -0.875, Longitude: 36.625}
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(day int) {
+			defer wg.Done()
+			if _, err := p.FetchAt(context.Background(), point, time.Date(2024, 4, day, 0, 0, 0, 0, time.UTC)); err != nil {
+				errs <- err
+			}
+		}(1 + i%2)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(p.grids) != 2 || len(p.order) != 2 {
+		t.Errorf("cached %d rasters in %v, want each date decoded once", len(p.grids), p.order)
+	}
+
+	// A date with no raster leaves nothing behind
+	if _, err := p.FetchAt(context.Background(), point, time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)); err == nil || len(p.grids) != 2 {
+		t.Errorf("missing raster: %v, %d cached", err, len(p.grids))
+	}
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,7 +14,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
 	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/performer/server"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/rpcServer"
 	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
@@ -24,6 +28,17 @@ type SunReWorker struct {
 	weatherClient *WeatherClient
 	metrics       *WorkerMetrics
 	rateLimiter   *rate.Limiter
+	taskTimeout   time.Duration
+	timeBucket    time.Duration
+	confidence    ConfidenceConfig
+	gate          taskGate
+	audit         *AuditLog
+	policies      *PolicyBook
+	results       *ResultStore
+	notifier      *Notifier
+	metar         []MetarSource
+	energy        []EnergySource
+	tenants       *TenantRegistry
 	mu            sync.RWMutex
 }
 
@@ -41,6 +56,28 @@ type WeatherVerificationRequest struct {
 	Location  Location `json:"location"`
 	Timestamp int64    `json:"timestamp"`
 	PolicyID  string   `json:"policy_id"`
+	// MinConfidence is the confidence the policy requires to verify the claim
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+	// Type selects the task's peril: a weather verification by default, a
+	// weather payout, or any registered peril
+	Type string `json:"type,omitempty"`
+	// Payout holds the terms of a payout task, or of a payout on another peril's index
+	Payout *PayoutTerms `json:"payout,omitempty"`
+	// Peril names the covered risk; it defaults to the payout variable, or the task type
+	Peril string `json:"peril,omitempty"`
+	// Coverage is the policy's coverage period. Without it each time bucket
+	// is its own period, and periods never expire.
+	Coverage *CoveragePeriod `json:"coverage,omitempty"`
+	// Airport holds the terms of an airport task
+	Airport *AirportTerms `json:"airport,omitempty"`
+	// Energy holds the terms of an energy task
+	Energy *EnergyTerms `json:"energy,omitempty"`
+	// Requester is the on-chain address that created the task, which
+	// selects its tenant when tenants are configured
+	Requester string `json:"requester,omitempty"`
+	// RequesterSignature is the requester's signature of the payload,
+	// which tenants must send (see SignTaskPayload)
+	RequesterSignature string `json:"requester_signature,omitempty"`
 }
 
 // Location represents geographic coordinates
@@ -48,32 +85,75 @@ type Location struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	City      string  `json:"city,omitempty"`
+	// Region, when set, asks gridded providers for an area average instead of a point
+	Region *BoundingBox `json:"region,omitempty"`
 }
 
 // WeatherData represents weather verification result
 type WeatherData struct {
-	Temperature float64   `json:"temperature"`
-	Humidity    float64   `json:"humidity"`
-	WindSpeed   float64   `json:"wind_speed"`
-	Pressure    float64   `json:"pressure"`
-	Conditions  string    `json:"conditions"`
-	Source      string    `json:"source"`
-	Timestamp   time.Time `json:"timestamp"`
-	Confidence  float64   `json:"confidence"`
+	Temperature float64 `json:"temperature"`
+	Humidity    float64 `json:"humidity"`
+	WindSpeed   float64 `json:"wind_speed"`
+	Pressure    float64 `json:"pressure"`
+	// Precipitation is the daily total in mm, reported by providers that measure it
+	Precipitation *float64  `json:"precipitation,omitempty"`
+	Conditions    string    `json:"conditions"`
+	Source        string    `json:"source"`
+	Timestamp     time.Time `json:"timestamp"`
+	Confidence    float64   `json:"confidence"`
+	// Stations lists the stations an interpolated value was derived from
+	Stations []StationReading `json:"stations,omitempty"`
+	// ResolutionKm is the grid or model spacing the value represents
+	ResolutionKm float64 `json:"resolution_km,omitempty"`
+	// PeriodSeconds is the length of the period an accumulated value covers,
+	// starting at Timestamp; zero for instantaneous readings
+	PeriodSeconds int64 `json:"period_seconds,omitempty"`
+	// Corroborating holds the same variable as reported by other providers
+	Corroborating []SourceValue `json:"corroborating,omitempty"`
+}
+
+// WeatherProvider is a source of current weather observations
+type WeatherProvider interface {
+	Name() string
+	FetchCurrent(ctx context.Context, location Location) (*WeatherData, error)
+}
+
+// HistoricalProvider is a provider that can also answer for a past time
+type HistoricalProvider interface {
+	WeatherProvider
+	FetchAt(ctx context.Context, location Location, at time.Time) (*WeatherData, error)
 }
 
 // WeatherClient handles weather data fetching
 type WeatherClient struct {
+	providers   []WeatherProvider
+	providersMu sync.RWMutex
+	logger      *zap.Logger
+	cache       map[string]*CachedWeatherData
+	cacheMu     sync.RWMutex
+	// maxAge is the oldest observation FetchWeather returns; zero disables the check
+	maxAge time.Duration
+	// timeBucket is how far a reading may be from the requested time
+	timeBucket time.Duration
+	// corroborate asks every remaining provider for the same variable too
+	corroborate bool
+	// reputation scores providers against consensus and drops the worst
+	reputation *ReputationTracker
+	now        func() time.Time
+}
+
+// openMeteoResolutionKm is the spacing of the global models behind Open-Meteo's forecasts
+const openMeteoResolutionKm = 11
+
+// OpenMeteoProvider fetches current conditions from the Open-Meteo API (free, no key required)
+type OpenMeteoProvider struct {
 	httpClient *http.Client
-	logger     *zap.Logger
-	cache      map[string]*CachedWeatherData
-	cacheMu    sync.RWMutex
 }
 
 // CachedWeatherData represents cached weather data
 type CachedWeatherData struct {
-	Data      *WeatherData
-	ExpiresAt time.Time
+	Data      *WeatherData `json:"data"`
+	ExpiresAt time.Time    `json:"expires_at"`
 }
 
 // NewSunReWorker creates a new SunRe worker
@@ -83,15 +163,38 @@ func NewSunReWorker(logger *zap.Logger) *SunReWorker {
 		weatherClient: NewWeatherClient(logger),
 		metrics:       &WorkerMetrics{},
 		rateLimiter:   rate.NewLimiter(rate.Every(time.Second), 10), // 10 requests per second
+		taskTimeout:   5 * time.Second,
+		timeBucket:    time.Hour,
+		confidence:    DefaultConfidenceConfig(),
+		results:       newResultStore(DefaultResultStoreConfig()),
 	}
 }
 
-// NewWeatherClient creates a new weather client
+// NewWeatherClient creates a new weather client backed by Open-Meteo
 func NewWeatherClient(logger *zap.Logger) *WeatherClient {
+	return NewWeatherClientWithProviders(logger, NewResilientProvider(
+		NewOpenMeteoProvider(&http.Client{Timeout: 10 * time.Second}),
+		DefaultResilienceConfig(),
+		logger,
+	))
+}
+
+// NewWeatherClientWithProviders creates a weather client that tries providers in order
+func NewWeatherClientWithProviders(logger *zap.Logger, providers ...WeatherProvider) *WeatherClient {
 	return &WeatherClient{
-		httpClient: &http.Client{Timeout: 10 * time.Second},
-		logger:     logger,
-		cache:      make(map[string]*CachedWeatherData),
+		providers: providers,
+		logger:    logger,
+		cache:     make(map[string]*CachedWeatherData),
+		maxAge:     time.Hour,
+		timeBucket: time.Hour,
+		now:        time.Now,
+	}
+}
+
+// NewOpenMeteoProvider creates an Open-Meteo provider using httpClient
+func NewOpenMeteoProvider(httpClient *http.Client) *OpenMeteoProvider {
+	return &OpenMeteoProvider{
+		httpClient: httpClient,
 	}
 }
 
@@ -102,6 +205,13 @@ func (w *SunReWorker) ValidateTask(t *performerV1.TaskRequest) error {
 		zap.Int("payloadSize", len(t.Payload)),
 	)
 
+	if w.gate.Draining() {
+		return ErrDraining
+	}
+	if _, err := w.results.Lookup(string(t.TaskId), sha256Hex(t.Payload)); err != nil {
+		return err
+	}
+
 	var req WeatherVerificationRequest
 	if err := json.Unmarshal(t.Payload, &req); err != nil {
 		w.logger.Error("Failed to unmarshal task payload",
@@ -111,6 +221,22 @@ func (w *SunReWorker) ValidateTask(t *performerV1.TaskRequest) error {
 		return fmt.Errorf("invalid task payload: %w", err)
 	}
 
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if _, err := w.tenants.Authorize(&req, t.Payload); err != nil {
+		return err
+	}
+	if req.Timestamp == 0 {
+		req.Timestamp = time.Now().Unix()
+	}
+
+	return nil
+}
+
+// Validate checks the request fields, resolving a coverage period declared
+// in local time to UTC
+func (req *WeatherVerificationRequest) Validate() error {
 	// Comprehensive validation
 	if req.Location.Latitude < -90 || req.Location.Latitude > 90 {
 		return fmt.Errorf("invalid latitude: %f", req.Location.Latitude)
@@ -121,77 +247,153 @@ func (w *SunReWorker) ValidateTask(t *performerV1.TaskRequest) error {
 	if req.PolicyID == "" {
 		return fmt.Errorf("policy ID is required")
 	}
-	if req.Timestamp == 0 {
-		req.Timestamp = time.Now().Unix()
+	if req.MinConfidence < 0 || req.MinConfidence > 1 {
+		return fmt.Errorf("invalid min_confidence: %f", req.MinConfidence)
 	}
-
-	return nil
+	if req.Location.Region != nil {
+		if err := req.Location.Region.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := req.Coverage.Resolve(req.Location); err != nil {
+		return err
+	}
+	peril, err := req.peril()
+	if err != nil {
+		return err
+	}
+	if req.Type == TaskTypePayout && req.Payout == nil {
+		return fmt.Errorf("payout task requires payout terms")
+	}
+	if req.paysOut() {
+		if err := req.Payout.Validate(peril.Spec().Variables); err != nil {
+			return err
+		}
+	}
+	return peril.Validate(req)
 }
 
 // HandleTask processes weather verification tasks
 func (w *SunReWorker) HandleTask(t *performerV1.TaskRequest) (*performerV1.TaskResponse, error) {
 	start := time.Now()
 
+	if !w.gate.enter() {
+		return nil, ErrDraining
+	}
+	defer w.gate.leave()
+
+	record := AuditRecord{TaskID: string(t.TaskId), PayloadHash: sha256Hex(t.Payload)}
+	var req WeatherVerificationRequest
+	decodeErr := json.Unmarshal(t.Payload, &req)
+	tenant, tenantErr := w.tenants.Authorize(&req, t.Payload)
+	record.Tenant = tenant.Label()
+
+	// Retries get the bytes computed the first time, so signatures aggregate
+	stored, err := w.results.Lookup(record.TaskID, record.PayloadHash)
+	if err != nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, w.recordAudit(record, err)
+	}
+	if stored != nil {
+		w.logger.Info("Task already computed, returning the stored result", zap.String("taskId", record.TaskID))
+		return w.replay(t, record, stored, start)
+	}
+
 	// Rate limiting
 	if !w.rateLimiter.Allow() {
-		return nil, fmt.Errorf("rate limit exceeded")
+		return nil, w.recordAudit(record, fmt.Errorf("rate limit exceeded"))
 	}
 
 	w.logger.Info("Processing weather verification task",
 		zap.String("taskId", string(t.TaskId)),
 	)
 
-	var req WeatherVerificationRequest
-	if err := json.Unmarshal(t.Payload, &req); err != nil {
+	if decodeErr != nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, w.recordAudit(record, fmt.Errorf("invalid task payload: %w", decodeErr))
+	}
+	if err := req.Coverage.Resolve(req.Location); err != nil {
 		w.updateMetrics(false, time.Since(start))
-		return nil, fmt.Errorf("invalid task payload: %w", err)
+		return nil, w.recordAudit(record, err)
 	}
+	record.Policy = &req
 
-	// Fetch weather data
-	weatherData, err := w.weatherClient.FetchWeather(req.Location)
+	if tenantErr != nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, w.recordAudit(record, tenantErr)
+	}
+
+	// A settled period is answered with its original result, never recomputed
+	key := req.policyKey(w.timeBucket, start)
+	key.Tenant = tenant.Label()
+	settled, err := w.policies.Begin(key, req.Coverage != nil, string(t.TaskId))
 	if err != nil {
-		w.logger.Warn("Failed to fetch weather data, using fallback",
-			zap.Error(err),
-			zap.Float64("lat", req.Location.Latitude),
-			zap.Float64("lon", req.Location.Longitude),
+		w.updateMetrics(false, time.Since(start))
+		return nil, w.recordAudit(record, err)
+	}
+	if settled != nil {
+		w.logger.Info("Policy period already settled, returning its original result",
+			zap.String("taskId", string(t.TaskId)),
+			zap.String("period", key.String()),
 		)
-		weatherData = w.generateFallbackWeatherData(req.Location)
+		return w.replay(t, record, settled, start)
 	}
 
-	// Create response with enhanced metadata
-	operatorID := os.Getenv("OPERATOR_ID")
-	if operatorID == "" {
-		operatorID = "sunre-operator-default"
+	// Quota is only used by tasks that are neither retries nor replays
+	if err := w.tenants.Admit(tenant); err != nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, w.recordAudit(record, err)
 	}
 
-	response := map[string]interface{}{
-		"task_id":      string(t.TaskId),
-		"policy_id":    req.PolicyID,
-		"location":     req.Location,
-		"weather":      weatherData,
-		"verified":     true,
-		"timestamp":    time.Now().Unix(),
-		"operator_id":  operatorID,
-		"confidence":   weatherData.Confidence,
-		"source":       weatherData.Source,
-		"version":      "1.0.0",
-		"latency_ms":   time.Since(start).Milliseconds(),
+	data, err := w.fetchTaskData(context.Background(), &req, tenant.Sources())
+	if err != nil && data == nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, w.recordAudit(record, err)
+	}
+	if err != nil {
+		record.FetchError = err.Error()
 	}
+	record.Sources = data.sources()
+	record.Provenance = data.Weather
 
-	resultBytes, err := json.Marshal(response)
+	resultBytes, err := w.buildResult(t.TaskId, req, data)
+	if err == nil {
+		resultBytes, err = w.policies.Complete(key, string(t.TaskId), resultBytes)
+	}
+	if err == nil {
+		resultBytes, err = w.results.Store(record.TaskID, record.PayloadHash, resultBytes)
+	}
 	if err != nil {
 		w.updateMetrics(false, time.Since(start))
-		return nil, fmt.Errorf("failed to encode response: %w", err)
+		return nil, w.recordAudit(record, err)
+	}
+	record.OutputHash = sha256Hex(resultBytes)
+	// A result that could not be audited is not returned for signing
+	if err := w.recordAudit(record, nil); err != nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, err
 	}
 
 	// Update metrics
 	w.updateMetrics(true, time.Since(start))
 
-	w.logger.Info("Task completed successfully",
+	operatorID := os.Getenv("OPERATOR_ID")
+	if operatorID == "" {
+		operatorID = "sunre-operator-default"
+	}
+	fields := []zap.Field{
 		zap.String("taskId", string(t.TaskId)),
+		zap.String("operatorId", operatorID),
 		zap.Duration("duration", time.Since(start)),
-		zap.String("source", weatherData.Source),
-	)
+	}
+	if data.Weather != nil {
+		fields = append(fields, zap.String("source", data.Weather.Source))
+	}
+	w.logger.Info("Task completed successfully", fields...)
+	// The outbox retries delivery, so a notification failure does not fail the task
+	if err := w.notifier.Notify(record.TaskID, resultBytes); err != nil {
+		w.logger.Error("Failed to queue webhooks", zap.String("taskId", record.TaskID), zap.Error(err))
+	}
 
 	return &performerV1.TaskResponse{
 		TaskId: t.TaskId,
@@ -199,28 +401,320 @@ func (w *SunReWorker) HandleTask(t *performerV1.TaskRequest) (*performerV1.TaskR
 	}, nil
 }
 
-// FetchWeather fetches weather data from API or cache
-func (c *WeatherClient) FetchWeather(location Location) (*WeatherData, error) {
-	// Check cache first
-	cacheKey := fmt.Sprintf("%.4f,%.4f", location.Latitude, location.Longitude)
-	
-	c.cacheMu.RLock()
-	if cached, ok := c.cache[cacheKey]; ok {
-		if time.Now().Before(cached.ExpiresAt) {
-			c.cacheMu.RUnlock()
-			c.logger.Debug("Weather data served from cache", zap.String("key", cacheKey))
-			return cached.Data, nil
+// fetchTaskData fetches the data req's peril needs from the preferred
+// sources, leaving retries no more time than the task has. When every
+// weather provider fails it returns generated fallback data along with the
+// fetch error; any other error comes with nil data.
+func (w *SunReWorker) fetchTaskData(parent context.Context, req *WeatherVerificationRequest, sources []string) (*PerilData, error) {
+	peril, err := req.peril()
+	if err != nil {
+		return nil, err
+	}
+	need := peril.Requirements(req)
+	data := &PerilData{}
+	ctx, cancel := context.WithTimeout(parent, w.taskTimeout)
+	defer cancel()
+	if need.Metar != nil {
+		// Reports have no fallback: a summary of nothing would read as fair weather
+		if data.Metar, data.MetarSource, err = w.fetchMetars(ctx, need.Metar, sources); err != nil {
+			return nil, err
+		}
+	}
+	if need.Energy != nil {
+		if data.Energy, data.EnergySource, err = w.fetchEnergy(ctx, need.Energy, sources); err != nil {
+			return nil, err
+		}
+	}
+	if !need.Weather {
+		return data, nil
+	}
+	var at time.Time
+	if req.Timestamp != 0 {
+		at = time.Unix(canonicalTime(req.Timestamp, w.timeBucket), 0).UTC()
+	}
+	data.Weather, err = w.weatherClient.FetchWeatherFrom(ctx, req.Location, at, sources)
+	if err != nil {
+		w.logger.Warn("Failed to fetch weather data, using fallback",
+			zap.Error(err),
+			zap.Float64("lat", req.Location.Latitude),
+			zap.Float64("lon", req.Location.Longitude),
+		)
+		data.Weather = w.generateFallbackWeatherData(req.Location)
+		return data, err
+	}
+	return data, nil
+}
+
+// replay answers t with a previously computed result
+func (w *SunReWorker) replay(t *performerV1.TaskRequest, record AuditRecord, result []byte, start time.Time) (*performerV1.TaskResponse, error) {
+	record.OutputHash, record.Replayed = sha256Hex(result), true
+	if err := w.recordAudit(record, nil); err != nil {
+		w.updateMetrics(false, time.Since(start))
+		return nil, err
+	}
+	w.updateMetrics(true, time.Since(start))
+	return &performerV1.TaskResponse{TaskId: t.TaskId, Result: result}, nil
+}
+
+// recordAudit appends the task's audit record with taskErr and counts the
+// outcome for its tenant. It returns taskErr, or the audit error if the task
+// had otherwise succeeded.
+func (w *SunReWorker) recordAudit(record AuditRecord, taskErr error) error {
+	if taskErr != nil {
+		record.Error = taskErr.Error()
+	}
+	defer func() { w.tenants.Record(record.Tenant, taskErr) }()
+	if err := w.audit.Append(record); err != nil {
+		w.logger.Error("Failed to write audit record", zap.String("taskId", record.TaskID), zap.Error(err))
+		if taskErr == nil {
+			return fmt.Errorf("failed to audit task: %w", err)
 		}
 	}
-	c.cacheMu.RUnlock()
+	return taskErr
+}
 
-	// Try to fetch from Open-Meteo API (free, no key required)
-	url := fmt.Sprintf(
+// buildResult encodes the task result for req from the fetched data
+func (w *SunReWorker) buildResult(taskID []byte, req WeatherVerificationRequest, data *PerilData) ([]byte, error) {
+	peril, err := req.peril()
+	if err != nil {
+		return nil, err
+	}
+	// The result is signed by every operator and aggregated, so it must only
+	// depend on the request and the observed data: operator identity and
+	// latency are logged instead of encoded. The requested time is snapped to
+	// a bucket so operators sampling at slightly different moments agree.
+	timestamp := req.Timestamp
+	if timestamp == 0 && data.Weather != nil {
+		timestamp = data.Weather.Timestamp.Unix()
+	}
+	timestamp = canonicalTime(timestamp, w.timeBucket)
+
+	response, err := peril.Evaluate(PerilEnv{Timestamp: timestamp, Confidence: w.confidence}, &req, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s task: %w", peril.Spec().Name, err)
+	}
+	response["task_id"] = string(taskID)
+	response["policy_id"] = req.PolicyID
+	response["location"] = req.Location
+	response["timestamp"] = timestamp
+	response["version"] = "1.0.0"
+	if window := req.Coverage.Window(); window != nil {
+		response["coverage_window"] = window
+	}
+	if req.paysOut() {
+		response["type"] = TaskTypePayout
+		// Nothing is paid on a result the operators could not verify
+		if verified, _ := response["verified"].(bool); !verified {
+			response["payout_withheld"] = "result is not verified"
+		} else {
+			index, err := peril.Index(&req, data, req.Payout.Variable)
+			if err != nil {
+				return nil, fmt.Errorf("failed to calculate payout: %w", err)
+			}
+			result, err := payout.Calculate(req.Payout.Structure, index)
+			if err != nil {
+				return nil, fmt.Errorf("failed to calculate payout: %w", err)
+			}
+			response["payout"] = result
+		}
+	}
+
+	resultBytes, err := peril.Encode(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+	return resultBytes, nil
+}
+
+// openMeteoURL builds the Open-Meteo current conditions URL for a location
+func openMeteoURL(location Location) string {
+	return fmt.Sprintf(
 		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&current=temperature_2m,relative_humidity_2m,wind_speed_10m,surface_pressure,weather_code",
 		location.Latitude, location.Longitude,
 	)
+}
+
+// FetchWeather fetches current weather data from cache or the first provider that answers
+func (c *WeatherClient) FetchWeather(ctx context.Context, location Location) (*WeatherData, error) {
+	return c.FetchWeatherAt(ctx, location, time.Time{})
+}
+
+// FetchWeatherAt fetches weather data for time at from the first provider that
+// answers. Historical providers are asked for at; the others, and every
+// provider when at is zero, report current conditions, which are cached.
+// Readings further than the time bucket from a non-zero at are rejected.
+func (c *WeatherClient) FetchWeatherAt(ctx context.Context, location Location, at time.Time) (*WeatherData, error) {
+	return c.FetchWeatherFrom(ctx, location, at, nil)
+}
+
+// FetchWeatherFrom is FetchWeatherAt using only the providers named in
+// sources, in that order, if any of them is configured. Cached data from
+// another provider is not served. A dry-run ctx may read the cache but
+// neither fills it nor records reputation.
+func (c *WeatherClient) FetchWeatherFrom(ctx context.Context, location Location, at time.Time, sources []string) (*WeatherData, error) {
+	cacheKey := fmt.Sprintf("%.4f,%.4f", location.Latitude, location.Longitude)
+	cacheChecked := false
+
+	// Every operator asks the same providers in the same order, whatever
+	// its local reputation scorecard says, so their results can agree
+	providers := preferred(c.Providers(), sources)
+	allowed := make(map[string]bool, len(providers))
+	for _, provider := range providers {
+		allowed[provider.Name()] = true
+	}
+	var errs []error
+	for i, provider := range providers {
+		_, historical := provider.(HistoricalProvider)
+		historical = historical && !at.IsZero()
 
-	resp, err := c.httpClient.Get(url)
+		// Check cache before the first current-conditions provider
+		if !historical && !cacheChecked {
+			cacheChecked = true
+			c.cacheMu.RLock()
+			cached, ok := c.cache[cacheKey]
+			c.cacheMu.RUnlock()
+			if ok && c.now().Before(cached.ExpiresAt) && c.checkFresh(cached.Data) == nil && c.checkMatches(cached.Data, at) == nil &&
+				(len(sources) == 0 || allowed[cached.Data.Source]) {
+				c.logger.Debug("Weather data served from cache", zap.String("key", cacheKey))
+				return cached.Data, nil
+			}
+		}
+
+		weatherData, err := c.fetchFrom(ctx, provider, location, at)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		if c.corroborate {
+			weatherData = c.corroborateWith(ctx, weatherData, providers[i+1:], location, at)
+			if !isDryRun(ctx) {
+				c.reputation.Record(weatherData)
+			}
+		}
+		if historical || isDryRun(ctx) {
+			return weatherData, nil
+		}
+
+		// Cache the result
+		c.cacheMu.Lock()
+		c.cache[cacheKey] = &CachedWeatherData{
+			Data:      weatherData,
+			ExpiresAt: c.now().Add(5 * time.Minute),
+		}
+		c.cacheMu.Unlock()
+
+		return weatherData, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no weather providers configured")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetchFrom asks a single provider for at, or for current conditions
+func (c *WeatherClient) fetchFrom(ctx context.Context, provider WeatherProvider, location Location, at time.Time) (*WeatherData, error) {
+	if hp, ok := provider.(HistoricalProvider); ok && !at.IsZero() {
+		// Historical readings are matched to the requested day by the
+		// provider itself, so the age check does not apply
+		weatherData, err := hp.FetchAt(ctx, location, at)
+		if err != nil {
+			c.logger.Debug("Weather provider failed",
+				zap.String("provider", provider.Name()),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		if err := c.checkMatches(weatherData, at); err != nil {
+			c.logger.Warn("Rejected weather observation for another time",
+				zap.String("provider", provider.Name()),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		return weatherData, nil
+	}
+
+	weatherData, err := provider.FetchCurrent(ctx, location)
+	if err != nil {
+		c.logger.Debug("Weather provider failed",
+			zap.String("provider", provider.Name()),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	if err := c.checkFresh(weatherData); err != nil {
+		c.logger.Warn("Rejected stale weather observation",
+			zap.String("provider", provider.Name()),
+			zap.Time("observedAt", weatherData.Timestamp),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	if err := c.checkMatches(weatherData, at); err != nil {
+		c.logger.Warn("Rejected weather observation for another time",
+			zap.String("provider", provider.Name()),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return weatherData, nil
+}
+
+// corroborateWith returns a copy of data listing the value of the same
+// variable from each of the other providers that answers
+func (c *WeatherClient) corroborateWith(ctx context.Context, data *WeatherData, others []WeatherProvider, location Location, at time.Time) *WeatherData {
+	corroborated := *data
+	corroborated.Corroborating = nil
+	for _, provider := range others {
+		other, err := c.fetchFrom(ctx, provider, location, at)
+		if err != nil || !sameVariable(data, other) {
+			continue
+		}
+		corroborated.Corroborating = append(corroborated.Corroborating, SourceValue{
+			Source: other.Source,
+			Value:  primaryValue(other),
+		})
+	}
+	return &corroborated
+}
+
+// Providers returns the configured providers in fetch order
+func (c *WeatherClient) Providers() []WeatherProvider {
+	c.providersMu.RLock()
+	defer c.providersMu.RUnlock()
+	return append([]WeatherProvider(nil), c.providers...)
+}
+
+// SetProviders replaces the configured providers
+func (c *WeatherClient) SetProviders(providers ...WeatherProvider) {
+	c.providersMu.Lock()
+	defer c.providersMu.Unlock()
+	c.providers = providers
+}
+
+// ProviderStatus returns the breaker status of every provider that has one
+func (c *WeatherClient) ProviderStatus() []BreakerStatus {
+	var statuses []BreakerStatus
+	for _, provider := range c.Providers() {
+		if rp, ok := provider.(interface{ Status() BreakerStatus }); ok {
+			statuses = append(statuses, rp.Status())
+		}
+	}
+	return statuses
+}
+
+// Name returns the provider name recorded as the data source
+func (p *OpenMeteoProvider) Name() string {
+	return "open-meteo"
+}
+
+// FetchCurrent fetches current conditions for a location
+func (p *OpenMeteoProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, openMeteoURL(location), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch weather data: %w", err)
 	}
@@ -232,38 +726,63 @@ func (c *WeatherClient) FetchWeather(location Location) (*WeatherData, error) {
 
 	var result struct {
 		Current struct {
-			Temperature  float64 `json:"temperature_2m"`
-			Humidity     float64 `json:"relative_humidity_2m"`
-			WindSpeed    float64 `json:"wind_speed_10m"`
-			Pressure     float64 `json:"surface_pressure"`
-			WeatherCode  int     `json:"weather_code"`
+			Time        string  `json:"time"`
+			Temperature float64 `json:"temperature_2m"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			WindSpeed   float64 `json:"wind_speed_10m"`
+			Pressure    float64 `json:"surface_pressure"`
+			WeatherCode int     `json:"weather_code"`
 		} `json:"current"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode weather data: %w", err)
 	}
+	observedAt, err := parseOpenMeteoTime(result.Current.Time)
+	if err != nil {
+		return nil, err
+	}
 
-	weatherData := &WeatherData{
-		Temperature: result.Current.Temperature,
-		Humidity:    result.Current.Humidity,
-		WindSpeed:   result.Current.WindSpeed,
-		Pressure:    result.Current.Pressure,
-		Conditions:  getWeatherCondition(result.Current.WeatherCode),
-		Source:      "open-meteo",
-		Timestamp:   time.Now(),
-		Confidence:  0.9,
+	return &WeatherData{
+		Temperature:  result.Current.Temperature,
+		Humidity:     result.Current.Humidity,
+		WindSpeed:    result.Current.WindSpeed,
+		Pressure:     result.Current.Pressure,
+		Conditions:   getWeatherCondition(result.Current.WeatherCode),
+		Source:       p.Name(),
+		Timestamp:    observedAt,
+		ResolutionKm: openMeteoResolutionKm,
+	}, nil
+}
+
+// CacheEntries returns a snapshot of the cached weather data keyed by location
+func (c *WeatherClient) CacheEntries() map[string]CachedWeatherData {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+
+	entries := make(map[string]CachedWeatherData, len(c.cache))
+	for k, v := range c.cache {
+		entries[k] = *v
 	}
+	return entries
+}
 
-	// Cache the result
+// PurgeCache removes the entry for key, or every entry when key is empty,
+// and returns the number of entries removed
+func (c *WeatherClient) PurgeCache(key string) int {
 	c.cacheMu.Lock()
-	c.cache[cacheKey] = &CachedWeatherData{
-		Data:      weatherData,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-	}
-	c.cacheMu.Unlock()
+	defer c.cacheMu.Unlock()
 
-	return weatherData, nil
+	if key == "" {
+		n := len(c.cache)
+		c.cache = make(map[string]*CachedWeatherData)
+		return n
+	}
+	if _, ok := c.cache[key]; !ok {
+		return 0
+	}
+	delete(c.cache, key)
+	return 1
 }
 
 // getWeatherCondition converts weather code to condition string
@@ -286,15 +805,19 @@ func getWeatherCondition(code int) string {
 	}
 }
 
+// fallbackSource is the source of generated fallback weather data
+const fallbackSource = "Fallback"
+
 // generateFallbackWeatherData generates fallback weather data for resilience
 func (w *SunReWorker) generateFallbackWeatherData(location Location) *WeatherData {
-	// Sophisticated simulation based on location and time
+	// Sophisticated simulation based on location and its local time
 	baseTemp := 20.0 + (location.Latitude / 10)
-	hour := time.Now().Hour()
+	now := time.Now().In(zoneAt(location))
+	hour := now.Hour()
 	tempVariance := 5.0 * math.Sin(float64(hour) * math.Pi / 12)
 	
 	// Add seasonal variation
-	month := time.Now().Month()
+	month := now.Month()
 	seasonalAdjustment := 0.0
 	switch {
 	case month >= 12 || month <= 2: // Winter
@@ -309,9 +832,8 @@ func (w *SunReWorker) generateFallbackWeatherData(location Location) *WeatherDat
 		WindSpeed:   10.0 + math.Abs(location.Latitude / 20),
 		Pressure:    1013.25 + (location.Latitude / 100),
 		Conditions:  "Clear",
-		Source:      "Fallback",
+		Source:      fallbackSource,
 		Timestamp:   time.Now(),
-		Confidence:  0.5, // Lower confidence for fallback data
 	}
 }
 
@@ -355,102 +877,307 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Readiness endpoint: not ready while shutting down, or while every provider
+// breaker is open, since tasks would then only be answered from cache or
+// fallback data
+func (worker *SunReWorker) readyHandler(w http.ResponseWriter, r *http.Request) {
+	providers := worker.weatherClient.ProviderStatus()
+	ready := len(providers) == 0
+	for _, p := range providers {
+		ready = ready || p.State != BreakerOpen
+	}
+
+	status, code := "ready", http.StatusOK
+	switch {
+	case worker.gate.Draining():
+		status, code = "draining", http.StatusServiceUnavailable
+	case !ready:
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    status,
+		"providers": providers,
+		"timestamp": time.Now(),
+	})
+}
+
 // Metrics endpoint
 func (worker *SunReWorker) metricsHandler(w http.ResponseWriter, r *http.Request) {
-	metrics := worker.GetMetrics()
+	metrics := struct {
+		WorkerMetrics
+		Providers  []BreakerStatus `json:"providers"`
+		Reputation []ProviderScore `json:"reputation,omitempty"`
+		Tenants    []TenantUsage   `json:"tenants,omitempty"`
+	}{worker.GetMetrics(), worker.weatherClient.ProviderStatus(), worker.weatherClient.reputation.Scorecard(), worker.tenants.Usage()}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(metrics)
 }
 
+// Cache endpoint: GET lists cached entries, DELETE purges them (optionally ?key=lat,lon)
+func (worker *SunReWorker) cacheHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(worker.weatherClient.CacheEntries())
+	case http.MethodDelete:
+		purged := worker.weatherClient.PurgeCache(r.URL.Query().Get("key"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	
-	// Create logger based on environment
-	var logger *zap.Logger
-	var err error
-	if os.Getenv("ENV") == "production" {
-		logger, err = zap.NewProduction()
-	} else {
-		logger, err = zap.NewDevelopment()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// weatherProviders builds the weather providers cfg configures, in fetch
+// order, and the fault injector wrapping them when FAULT_INJECTION is set
+func weatherProviders(cfg *Config, logger *zap.Logger) ([]WeatherProvider, *FaultInjector, error) {
+	// With FAULT_INJECTION set every provider is wrapped so faults can be
+	// injected at runtime through the /faults endpoint, on current and past
+	// fetches alike. Faults sit beneath the breakers so they look like
+	// upstream failures.
+	providers := []WeatherProvider{NewOpenMeteoProvider(&http.Client{Timeout: 10 * time.Second})}
+	var faults *FaultInjector
+	if cfg.FaultInjection != "" {
+		faults = NewFaultInjector()
+		if cfg.FaultInjection != faultInjectionOn {
+			faultCfg, err := LoadFaultConfig(cfg.FaultInjection)
+			if err != nil {
+				return nil, nil, err
+			}
+			faults.SetConfig(*faultCfg)
+			logger.Warn("Fault injection enabled",
+				zap.String("config", cfg.FaultInjection),
+				zap.Int("faults", len(faultCfg.Faults)),
+			)
+		}
+		providers = faults.Wrap(providers...)
 	}
-	if err != nil {
-		panic(fmt.Sprintf("Failed to create logger: %v", err))
+	for i, p := range providers {
+		providers[i] = withResilience(p, cfg.Resilience, logger)
 	}
-	defer logger.Sync()
-
-	// Get port from environment or use default
-	port := 8080
-	if envPort := os.Getenv("PERFORMER_PORT"); envPort != "" {
-		fmt.Sscanf(envPort, "%d", &port)
+	// Local datasets need no breakers and are tried first: station
+	// observations, then gridded rasters
+	var local []WeatherProvider
+	if cfg.Grid.Dir != "" {
+		gridded, err := NewGriddedProvider(cfg.Grid)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Info("Gridded precipitation provider enabled",
+			zap.String("dir", cfg.Grid.Dir),
+			zap.Int("days", len(gridded.Dates())),
+		)
+		local = append([]WeatherProvider{gridded}, local...)
 	}
-
-	// Get timeout from environment or use default
-	timeout := 5 * time.Second
-	if envTimeout := os.Getenv("PERFORMER_TIMEOUT"); envTimeout != "" {
-		if d, err := time.ParseDuration(envTimeout); err == nil {
-			timeout = d
+	if cfg.Stations.Catalogue != "" {
+		stations, err := NewStationProvider(cfg.Stations)
+		if err != nil {
+			return nil, nil, err
 		}
+		logger.Info("Station observation provider enabled",
+			zap.String("catalogue", cfg.Stations.Catalogue),
+			zap.Int("stations", stations.catalogue.Stations()),
+			zap.String("element", cfg.Stations.Element),
+		)
+		local = append([]WeatherProvider{stations}, local...)
+	}
+	if faults != nil {
+		local = faults.Wrap(local...)
 	}
+	return append(local, providers...), faults, nil
+}
+
+// runServe starts the performer gRPC server and the health endpoints
+func runServe(cfg *Config, logger *zap.Logger) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Create SunRe worker
 	worker := NewSunReWorker(logger)
+	worker.taskTimeout = cfg.PerformerTimeout
+	worker.timeBucket = cfg.TimeBucket
+	worker.weatherClient.maxAge = cfg.MaxObservationAge
+	worker.weatherClient.timeBucket = cfg.TimeBucket
+	worker.confidence = cfg.Confidence
+	worker.weatherClient.corroborate = cfg.Confidence.Corroborate
+	reputation, err := NewReputationTracker(cfg.Reputation, logger)
+	if err != nil {
+		return err
+	}
+	worker.weatherClient.reputation = reputation
+	if worker.policies, err = NewPolicyBook(cfg.Policy, logger); err != nil {
+		return err
+	}
+	if worker.results, err = NewResultStore(cfg.Results); err != nil {
+		return err
+	}
+	if cfg.Audit.Dir != "" {
+		if worker.audit, err = OpenAuditLog(cfg.Audit); err != nil {
+			return err
+		}
+		logger.Info("Auditing verifications", zap.String("dir", cfg.Audit.Dir))
+	}
+
+	providers, faults, err := weatherProviders(cfg, logger)
+	if err != nil {
+		return err
+	}
+	worker.weatherClient.SetProviders(providers...)
+	if worker.metar = metarSources(cfg.Metar); len(worker.metar) > 0 {
+		logger.Info("METAR sources enabled",
+			zap.String("archive", cfg.Metar.Archive),
+			zap.String("url", cfg.Metar.URL),
+		)
+	}
+	if worker.energy = energySources(cfg.Energy); len(worker.energy) > 0 {
+		logger.Info("Energy weather sources enabled",
+			zap.String("series", cfg.Energy.Series),
+			zap.String("url", cfg.Energy.URL),
+		)
+	}
+	if cfg.TenantRegistry != "" {
+		if worker.tenants, err = LoadTenantRegistry(cfg.TenantRegistry); err != nil {
+			return err
+		}
+		logger.Info("Tenants enabled", zap.String("registry", cfg.TenantRegistry), zap.Int("tenants", len(worker.tenants.tenants)))
+	}
+	if cfg.Webhooks.Endpoints != "" {
+		endpoints, err := LoadWebhookEndpoints(cfg.Webhooks.Endpoints)
+		if err != nil {
+			return err
+		}
+		if worker.notifier, err = NewNotifier(cfg.Webhooks, endpoints, logger); err != nil {
+			return err
+		}
+		go worker.notifier.Run(ctx)
+		logger.Info("Webhook notifications enabled", zap.Int("endpoints", len(endpoints)))
+	}
 
 	// Start health and metrics endpoints
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/ready", worker.readyHandler)
+	mux.HandleFunc("/metrics", worker.metricsHandler)
+	// Reading is open; changes need the operator API key
+	mux.HandleFunc("/cache", requireAPIKey(cfg.DryRunAPIKey, worker.cacheHandler, http.MethodGet))
+	mux.HandleFunc("/reputation", requireAPIKey(cfg.DryRunAPIKey, reputation.reputationHandler, http.MethodGet))
+	// Policies and tenants name customers, so reading them needs the operator
+	// key or a tenant's key, which only sees that tenant
+	mux.HandleFunc("/policies", requireTenantKey(cfg.DryRunAPIKey, worker.tenants, worker.policies.policiesHandler, http.MethodGet))
+	// Quotes read years of archives, so they need a key and are rate limited
+	mux.HandleFunc("/quote", requireTenantKey(cfg.DryRunAPIKey, worker.tenants, NewQuoter(cfg.Quote, providers, logger).quoteHandler, http.MethodPost))
+	mux.HandleFunc("/perils", perils.perilsHandler)
+	if worker.notifier != nil {
+		mux.HandleFunc("/webhooks", requireAPIKey(cfg.DryRunAPIKey, worker.notifier.webhooksHandler, http.MethodGet))
+	}
+	if worker.tenants != nil {
+		mux.HandleFunc("/tenants", requireTenantKey(cfg.DryRunAPIKey, worker.tenants, worker.tenants.tenantsHandler, http.MethodGet))
+	}
+	if cfg.DryRunAPIKey != "" || worker.tenants.HasAPIKeys() {
+		NewDryRunAPI(worker, cfg.DryRunAPIKey, cfg.OperatorID).Register(mux)
+		logger.Info("Dry-run API enabled at /v1/verify and /v1/validate")
+	}
+	if faults != nil {
+		mux.HandleFunc("/faults", requireAPIKey(cfg.DryRunAPIKey, faults.faultsHandler))
+	}
+	healthServer := &http.Server{Addr: fmt.Sprintf(":%d", cfg.HealthPort), Handler: mux}
 	go func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/health", healthHandler)
-		mux.HandleFunc("/metrics", worker.metricsHandler)
-		
-		healthPort := 8081
-		if envPort := os.Getenv("HEALTH_PORT"); envPort != "" {
-			fmt.Sscanf(envPort, "%d", &healthPort)
-		}
-		
-		logger.Info("Starting health endpoints", zap.Int("port", healthPort))
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", healthPort), mux); err != nil {
+		logger.Info("Starting health endpoints", zap.Int("port", cfg.HealthPort))
+		if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error("Health endpoint error", zap.Error(err))
 		}
 	}()
 
-	// Create performer server using DevKit's server package
-	performerServer, err := server.NewPonosPerformerWithRpcServer(&server.PonosPerformerConfig{
-		Port:    port,
-		Timeout: timeout,
-	}, worker, logger)
-	
-	if err != nil {
-		logger.Fatal("Failed to create performer server", zap.Error(err))
+	// Create performer server using DevKit's server package. The RPC server
+	// is built here so shutdown can stop it once tasks have drained. ponos's
+	// RPC server only listens on plain TCP, so TLS uses our own gRPC server.
+	var grpcServer grpcStopper
+	var startServer func(ctx context.Context) error
+	if cfg.PerformerTLS.Enabled() {
+		certs, err := newCertReloader(cfg.PerformerTLS, logger)
+		if err != nil {
+			return err
+		}
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.PerformerPort))
+		if err != nil {
+			return fmt.Errorf("failed to create performer server: %w", err)
+		}
+		srv := newTLSPerformerServer(worker, certs.ServerTLS(), cfg.PerformerTimeout, logger)
+		grpcServer = srv
+		startServer = func(ctx context.Context) error { return srv.Serve(lis) }
+		logger.Info("Performer gRPC server uses TLS",
+			zap.String("cert", cfg.PerformerTLS.CertFile),
+			zap.Bool("mtls", cfg.PerformerTLS.CAFile != ""),
+		)
+	} else {
+		rpc, err := rpcServer.NewRpcServer(&rpcServer.RpcServerConfig{GrpcPort: cfg.PerformerPort}, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create performer server: %w", err)
+		}
+		performerServer := server.NewPonosPerformer(&server.PonosPerformerConfig{
+			Port:    cfg.PerformerPort,
+			Timeout: cfg.PerformerTimeout,
+		}, rpc, worker, logger)
+		grpcServer = rpc.GetGrpcServer()
+		startServer = performerServer.Start
 	}
 
-	logger.Info("Starting SunRe AVS - Parametric Weather Insurance Platform", 
-		zap.Int("port", port),
-		zap.Duration("timeout", timeout),
+	logger.Info("Starting SunRe AVS - Parametric Weather Insurance Platform",
+		zap.Int("port", cfg.PerformerPort),
+		zap.Duration("timeout", cfg.PerformerTimeout),
 		zap.String("version", "1.0.0"),
-		zap.String("environment", os.Getenv("ENV")),
+		zap.String("environment", cfg.Env),
 	)
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
+
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
 	go func() {
-		if err := performerServer.Start(ctx); err != nil {
+		if err := startServer(ctx); err != nil {
 			serverErr <- err
 		}
 	}()
-	
+
 	// Wait for shutdown signal or error
 	select {
 	case sig := <-sigChan:
 		logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
+		down := &shutdown{
+			logger: logger,
+			worker: worker,
+			grace:  cfg.ShutdownGracePeriod,
+			grpc:   grpcServer,
+			http:   healthServer,
+			flush: []namedFlush{
+				{"reputation", reputation.Save},
+				{"results", worker.results.Close},
+				{"audit", worker.audit.Close},
+				{"webhooks", worker.notifier.Close},
+				{"cache", func() error {
+					logger.Info("Purged weather cache", zap.Int("entries", worker.weatherClient.PurgeCache("")))
+					return nil
+				}},
+			},
+		}
+		err := down.run()
 		cancel()
+		if err != nil {
+			return fmt.Errorf("unclean shutdown: %w", err)
+		}
 		logger.Info("SunRe AVS shutdown complete")
-		
+		return nil
+
 	case err := <-serverErr:
-		logger.Fatal("Server error", zap.Error(err))
+		return fmt.Errorf("server error: %w", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/contractCaller"
+	"go.uber.org/zap"
+)
+
+// MonitorConfig configures the policy monitor
+type MonitorConfig struct {
+	// Registry is the JSON file listing the monitored policies
+	Registry string `json:"registry,omitempty"`
+	// StatePath persists which tasks were published; empty keeps it in memory
+	StatePath string `json:"state_path,omitempty"`
+	// Interval is the time between runs
+	Interval time.Duration `json:"interval"`
+	// Budget is the most tasks published in one run; the rest wait for the next
+	Budget int `json:"budget"`
+	// OperatorSetID is the executor operator set tasks are published to
+	OperatorSetID uint32 `json:"operator_set_id"`
+	// DryRun reports what would be published without publishing it
+	DryRun bool `json:"dry_run"`
+}
+
+// Validate checks the monitor settings
+func (c MonitorConfig) Validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("MONITOR_INTERVAL must be positive")
+	}
+	if c.Budget <= 0 {
+		return fmt.Errorf("MONITOR_BUDGET must be positive")
+	}
+	return nil
+}
+
+// Reasons a monitored policy's task is published
+const (
+	monitorTriggerLikely  = "trigger_likely"
+	monitorCoverageClosed = "coverage_closed"
+)
+
+// Statuses of a monitored policy
+const (
+	monitorPolicyActive    = "active"
+	monitorPolicyCancelled = "cancelled"
+)
+
+// MonitorTrigger is the preliminary index level at which a claim is likely
+type MonitorTrigger struct {
+	Variable  string           `json:"variable"`
+	Threshold payout.Decimal   `json:"threshold"`
+	Direction payout.Direction `json:"direction,omitempty"`
+}
+
+// reached reports whether index is at or beyond the threshold
+func (t *MonitorTrigger) reached(index payout.Decimal) bool {
+	if t.Direction == payout.Below {
+		return index <= t.Threshold
+	}
+	return index >= t.Threshold
+}
+
+// MonitoredPolicy is a policy in the monitor's registry
+type MonitoredPolicy struct {
+	// Task is the task published for the policy; it needs a coverage period
+	Task WeatherVerificationRequest `json:"task"`
+	// Trigger defaults to the attachment point, or first tier, of a payout task
+	Trigger *MonitorTrigger `json:"trigger,omitempty"`
+	// Status is active (default) or cancelled
+	Status string `json:"status,omitempty"`
+}
+
+// trigger returns the policy's trigger, or nil if only the close of its
+// coverage window publishes a task
+func (p *MonitoredPolicy) trigger() *MonitorTrigger {
+	if p.Trigger != nil {
+		return p.Trigger
+	}
+	terms := p.Task.Payout
+	if terms == nil || terms.Variable == "" {
+		return nil
+	}
+	t := &MonitorTrigger{Variable: terms.Variable, Threshold: terms.Structure.Attachment, Direction: terms.Structure.Direction}
+	if terms.Structure.Kind == payout.Step {
+		t.Threshold = terms.Structure.Tiers[0].Threshold
+	}
+	return t
+}
+
+// LoadPolicyRegistry reads and validates the monitored policies in path
+func LoadPolicyRegistry(path string) ([]MonitoredPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy registry: %w", err)
+	}
+	var policies []MonitoredPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("invalid policy registry %s: %w", path, err)
+	}
+	for i := range policies {
+		p := &policies[i]
+		if err := p.Task.Validate(); err != nil {
+			return nil, fmt.Errorf("policy %d (%s): %w", i, p.Task.PolicyID, err)
+		}
+		if p.Task.Coverage == nil {
+			return nil, fmt.Errorf("policy %s: a coverage period is required", p.Task.PolicyID)
+		}
+		if p.Status != "" && p.Status != monitorPolicyActive && p.Status != monitorPolicyCancelled {
+			return nil, fmt.Errorf("policy %s: invalid status %q", p.Task.PolicyID, p.Status)
+		}
+		if t := p.trigger(); t != nil {
+			if _, err := weatherIndex(&WeatherData{Precipitation: new(float64)}, t.Variable); err != nil {
+				return nil, fmt.Errorf("policy %s: %w", p.Task.PolicyID, err)
+			}
+		}
+	}
+	return policies, nil
+}
+
+// MonitorPublication is a task the monitor published
+type MonitorPublication struct {
+	Key         string    `json:"key"`
+	Reason      string    `json:"reason"`
+	PublishedAt time.Time `json:"published_at"`
+	TxHash      string    `json:"tx_hash,omitempty"`
+}
+
+// MonitorDecision is what one run decided for one policy
+type MonitorDecision struct {
+	PolicyID string `json:"policy_id"`
+	Reason   string `json:"reason"`
+	// Index is the preliminary index that looked like a trigger
+	Index   string `json:"index,omitempty"`
+	Payload []byte `json:"-"`
+	TxHash  string `json:"tx_hash,omitempty"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+}
+
+// MonitorReport summarises one run
+type MonitorReport struct {
+	Evaluated int               `json:"evaluated"`
+	Published []MonitorDecision `json:"published"`
+	// Deferred counts tasks left for the next run once the budget was spent
+	Deferred int      `json:"deferred"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Monitor walks the policy registry on a schedule and publishes a
+// verification task to the TaskMailbox when a trigger looks likely from
+// preliminary data, or when a coverage window closes. Each policy period is
+// published at most once per reason.
+type Monitor struct {
+	cfg        MonitorConfig
+	avsAddress string
+	caller     contractCaller.IContractCaller
+	weather    *WeatherClient
+	timeBucket time.Duration
+	logger     *zap.Logger
+	published  map[string]MonitorPublication
+	now        func() time.Time
+}
+
+// NewMonitor returns a monitor, loading what was already published from
+// cfg.StatePath. The caller may be nil for dry runs.
+func NewMonitor(cfg MonitorConfig, avsAddress string, caller contractCaller.IContractCaller, weather *WeatherClient, logger *zap.Logger) (*Monitor, error) {
+	if caller == nil && !cfg.DryRun {
+		return nil, errors.New("a contract caller is required unless dry-running")
+	}
+	m := &Monitor{
+		cfg:        cfg,
+		avsAddress: avsAddress,
+		caller:     caller,
+		weather:    weather,
+		timeBucket: time.Hour,
+		logger:     logger,
+		published:  make(map[string]MonitorPublication),
+		now:        time.Now,
+	}
+	if cfg.StatePath == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read monitor state: %w", err)
+	}
+	var published []MonitorPublication
+	if err := json.Unmarshal(data, &published); err != nil {
+		return nil, fmt.Errorf("invalid monitor state %s: %w", cfg.StatePath, err)
+	}
+	for _, p := range published {
+		m.published[p.Key] = p
+	}
+	return m, nil
+}
+
+// Run runs the monitor every interval until ctx is done
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.RunOnce(ctx); err != nil {
+			m.logger.Error("Policy monitor run failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce evaluates every active policy in the registry once
+func (m *Monitor) RunOnce(ctx context.Context) (*MonitorReport, error) {
+	policies, err := LoadPolicyRegistry(m.cfg.Registry)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(policies, func(i, j int) bool { return policies[i].Task.PolicyID < policies[j].Task.PolicyID })
+
+	report := &MonitorReport{}
+	now := m.now()
+	for i := range policies {
+		p := &policies[i]
+		if p.Status == monitorPolicyCancelled || now.Unix() < p.Task.Coverage.Start {
+			continue
+		}
+		report.Evaluated++
+
+		decision, err := m.evaluate(ctx, p, now)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", p.Task.PolicyID, err))
+			continue
+		}
+		if decision == nil {
+			continue
+		}
+		if len(report.Published) >= m.cfg.Budget {
+			report.Deferred++
+			continue
+		}
+		if err := m.publish(ctx, p, decision, now); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", p.Task.PolicyID, err))
+			continue
+		}
+		report.Published = append(report.Published, *decision)
+	}
+
+	m.logger.Info("Policy monitor run complete",
+		zap.Int("evaluated", report.Evaluated),
+		zap.Int("published", len(report.Published)),
+		zap.Int("deferred", report.Deferred),
+		zap.Int("errors", len(report.Errors)),
+		zap.Bool("dryRun", m.cfg.DryRun),
+	)
+	return report, nil
+}
+
+// publicationKey identifies a policy period and the reason its task was published
+func publicationKey(req *WeatherVerificationRequest, reason string) string {
+	key := req.policyKey(time.Hour, time.Time{})
+	return key.String() + "#" + reason
+}
+
+// evaluate decides whether p needs a task now, returning nil if not
+func (m *Monitor) evaluate(ctx context.Context, p *MonitoredPolicy, now time.Time) (*MonitorDecision, error) {
+	decision := &MonitorDecision{PolicyID: p.Task.PolicyID}
+	task := p.Task
+	if now.Unix() >= task.Coverage.End {
+		decision.Reason = monitorCoverageClosed
+		// The final task looks at the last moment of the coverage period
+		task.Timestamp = task.Coverage.End - 1
+	} else {
+		trigger := p.trigger()
+		if trigger == nil {
+			return nil, nil
+		}
+		if _, done := m.published[publicationKey(&task, monitorTriggerLikely)]; done {
+			return nil, nil
+		}
+		data, err := m.weather.FetchWeather(ctx, task.Location)
+		if err != nil {
+			return nil, err
+		}
+		index, err := weatherIndex(data, trigger.Variable)
+		if err != nil {
+			return nil, err
+		}
+		if !trigger.reached(index) {
+			return nil, nil
+		}
+		decision.Reason, decision.Index = monitorTriggerLikely, index.String()
+		task.Timestamp = canonicalTime(now.Unix(), m.timeBucket)
+	}
+	if _, done := m.published[publicationKey(&task, decision.Reason)]; done {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	decision.Payload = payload
+	return decision, nil
+}
+
+// publish sends the decided task to the TaskMailbox, or only logs it on a dry run
+func (m *Monitor) publish(ctx context.Context, p *MonitoredPolicy, d *MonitorDecision, now time.Time) error {
+	fields := []zap.Field{
+		zap.String("policyId", d.PolicyID),
+		zap.String("reason", d.Reason),
+		zap.String("index", d.Index),
+		zap.ByteString("payload", d.Payload),
+	}
+	if m.cfg.DryRun {
+		d.DryRun = true
+		m.logger.Info("Would publish verification task (dry run)", fields...)
+		return nil
+	}
+
+	receipt, err := m.caller.PublishMessageToInbox(ctx, m.avsAddress, m.cfg.OperatorSetID, d.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to publish to mailbox: %w", err)
+	}
+	d.TxHash = receipt.TxHash.Hex()
+	m.logger.Info("Published verification task", append(fields, zap.String("tx", d.TxHash))...)
+
+	key := publicationKey(&p.Task, d.Reason)
+	m.published[key] = MonitorPublication{Key: key, Reason: d.Reason, PublishedAt: now.UTC(), TxHash: d.TxHash}
+	return m.save()
+}
+
+// save persists the published tasks
+func (m *Monitor) save() error {
+	if m.cfg.StatePath == "" {
+		return nil
+	}
+	published := make([]MonitorPublication, 0, len(m.published))
+	for _, p := range m.published {
+		published = append(published, p)
+	}
+	sort.Slice(published, func(i, j int) bool { return published[i].Key < published[j].Key })
+	data, err := json.MarshalIndent(published, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(m.cfg.StatePath, data); err != nil {
+		return fmt.Errorf("failed to save monitor state: %w", err)
+	}
+	return nil
+}
+
+func cmdMonitor(args []string, stdout, stderr io.Writer) int {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	once := fs.Bool("once", false, "run once, print the report and exit")
+	fs.BoolVar(&cfg.Monitor.DryRun, "dry-run", cfg.Monitor.DryRun, "report what would be published without publishing (MONITOR_DRY_RUN)")
+	fs.StringVar(&cfg.Monitor.Registry, "registry", cfg.Monitor.Registry, "policy registry JSON file (MONITOR_REGISTRY)")
+	fs.DurationVar(&cfg.Monitor.Interval, "interval", cfg.Monitor.Interval, "time between runs (MONITOR_INTERVAL)")
+	fs.IntVar(&cfg.Monitor.Budget, "budget", cfg.Monitor.Budget, "most tasks published per run (MONITOR_BUDGET)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return exitUsage
+	}
+	if cfg.Monitor.Registry == "" {
+		fmt.Fprintln(stderr, "monitor: -registry is required")
+		return exitUsage
+	}
+	if !cfg.Monitor.DryRun && (cfg.Chain.RPCURL == "" || cfg.Chain.TaskMailboxAddress == "" || cfg.Chain.AVSAddress == "" || cfg.Chain.PrivateKey == "") {
+		fmt.Fprintln(stderr, "monitor: publishing requires RPC_URL, TASK_MAILBOX_ADDRESS, AVS_ADDRESS and OPERATOR_KEY; use -dry-run otherwise")
+		return exitUsage
+	}
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create logger: %v\n", err)
+		return exitFailure
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var cc contractCaller.IContractCaller
+	if !cfg.Monitor.DryRun {
+		var closeClient func()
+		if cc, closeClient, err = dialContractCaller(ctx, cfg.Chain.RPCURL, cfg.Chain, logger); err != nil {
+			fmt.Fprintf(stderr, "monitor: %v\n", err)
+			return exitFailure
+		}
+		defer closeClient()
+	}
+	weather := NewWeatherClient(logger)
+	weather.maxAge = cfg.MaxObservationAge
+	monitor, err := NewMonitor(cfg.Monitor, cfg.Chain.AVSAddress, cc, weather, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "monitor: %v\n", err)
+		return exitFailure
+	}
+	monitor.timeBucket = cfg.TimeBucket
+
+	if !*once {
+		logger.Info("Starting policy monitor",
+			zap.String("registry", cfg.Monitor.Registry),
+			zap.Duration("interval", cfg.Monitor.Interval),
+			zap.Int("budget", cfg.Monitor.Budget),
+			zap.Bool("dryRun", cfg.Monitor.DryRun),
+		)
+		if err := monitor.Run(ctx); err != nil {
+			fmt.Fprintf(stderr, "monitor: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	report, err := monitor.RunOnce(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "monitor: %v\n", err)
+		return exitFailure
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if len(report.Errors) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/contractCaller"
+	"github.com/ethereum/go-ethereum/common"
+	ethereumTypes "github.com/ethereum/go-ethereum/core/types"
+	"go.uber.org/zap"
+)
+
+// simulatedCaller records the messages published to the mailbox
+type simulatedCaller struct {
+	contractCaller.IContractCaller
+	published [][]byte
+}
+
+func (c *simulatedCaller) PublishMessageToInbox(ctx context.Context, avsAddress string, operatorSetId uint32, payload []byte) (*ethereumTypes.Receipt, error) {
+	c.published = append(c.published, payload)
+	return &ethereumTypes.Receipt{TxHash: common.BigToHash(common.Big1)}, nil
+}
+
+// monitorRegistry is a registry with a policy in each situation the monitor handles
+const monitorRegistry = `[
+  {"task": {"type": "payout", "policy_id": "POL-OPEN", "location": {"latitude": 1, "longitude": 2},
+    "coverage": {"start": 1704067200, "end": 1706745600},
+    "payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "50", "exhaustion": "150", "sum_insured": "1000"}}}},
+  {"task": {"policy_id": "POL-CLOSED", "location": {"latitude": 1, "longitude": 2},
+    "coverage": {"start": 1701388800, "end": 1704067200}}},
+  {"task": {"policy_id": "POL-CANCELLED", "location": {"latitude": 1, "longitude": 2},
+    "coverage": {"start": 1701388800, "end": 1704067200}}, "status": "cancelled"},
+  {"task": {"policy_id": "POL-FUTURE", "location": {"latitude": 1, "longitude": 2},
+    "coverage": {"start": 1709251200, "end": 1711929600}}}
+]`
+
+// newTestMonitor returns a monitor over monitorRegistry at a time inside
+// POL-OPEN's coverage, with precipitation reported at rain
+func newTestMonitor(t *testing.T, dir string, cfg MonitorConfig, caller contractCaller.IContractCaller, rain float64) *Monitor {
+	t.Helper()
+	cfg.Registry = filepath.Join(dir, "registry.json")
+	if err := os.WriteFile(cfg.Registry, []byte(monitorRegistry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	weather := NewWeatherClientWithProviders(zap.NewNop(), &staticProvider{data: WeatherData{Precipitation: &rain, Source: "static", Timestamp: time.Now()}})
+	m, err := NewMonitor(cfg, "0xavs", caller, weather, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return time.Unix(1705000000, 0) }
+	return m
+}
+
+func TestMonitor_PublishesWithinBudgetAndDedupes(t *testing.T) {
+	dir := t.TempDir()
+	caller := &simulatedCaller{}
+	cfg := MonitorConfig{StatePath: filepath.Join(dir, "state.json"), Budget: 1, OperatorSetID: 1}
+	m := newTestMonitor(t, dir, cfg, caller, 80)
+
+	report, err := m.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Evaluated != 2 || len(report.Published) != 1 || report.Deferred != 1 {
+		t.Fatalf("first run = %+v", report)
+	}
+	closed := report.Published[0]
+	if closed.PolicyID != "POL-CLOSED" || closed.Reason != monitorCoverageClosed || closed.TxHash == "" {
+		t.Errorf("first publication = %+v", closed)
+	}
+	var task WeatherVerificationRequest
+	if err := json.Unmarshal(caller.published[0], &task); err != nil || task.Timestamp != 1704067200-1 {
+		t.Errorf("closing task timestamp = %d, %v", task.Timestamp, err)
+	}
+
+	// The deferred policy goes out on the next run, and nothing is sent twice
+	report, err = m.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Published) != 1 || report.Published[0].PolicyID != "POL-OPEN" ||
+		report.Published[0].Reason != monitorTriggerLikely || report.Published[0].Index != "80" {
+		t.Fatalf("second run = %+v", report)
+	}
+	if report, _ = m.RunOnce(context.Background()); len(report.Published) != 0 {
+		t.Errorf("third run published %+v", report.Published)
+	}
+
+	// What was published survives a restart
+	restarted := newTestMonitor(t, dir, cfg, caller, 80)
+	if report, _ = restarted.RunOnce(context.Background()); len(report.Published) != 0 {
+		t.Errorf("after restart published %+v", report.Published)
+	}
+	if len(caller.published) != 2 {
+		t.Errorf("caller got %d messages, want 2", len(caller.published))
+	}
+}
+
+func TestMonitor_TriggerNotReached(t *testing.T) {
+	caller := &simulatedCaller{}
+	m := newTestMonitor(t, t.TempDir(), MonitorConfig{Budget: 10}, caller, 20)
+	report, err := m.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Published) != 1 || report.Published[0].PolicyID != "POL-CLOSED" {
+		t.Errorf("published %+v", report.Published)
+	}
+}
+
+func TestMonitor_DryRun(t *testing.T) {
+	if _, err := NewMonitor(MonitorConfig{Budget: 1}, "0xavs", nil, nil, zap.NewNop()); err == nil {
+		t.Error("NewMonitor() without a caller succeeded")
+	}
+	m := newTestMonitor(t, t.TempDir(), MonitorConfig{Budget: 10, DryRun: true}, nil, 80)
+	for run := 1; run <= 2; run++ {
+		report, err := m.RunOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Nothing is marked as published, so every run reports the same tasks
+		if len(report.Published) != 2 || !report.Published[0].DryRun || report.Published[0].TxHash != "" {
+			t.Errorf("run %d = %+v", run, report)
+		}
+	}
+}
+
+func TestLoadPolicyRegistry_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, registry := range map[string]string{
+		"no coverage":    `[{"task": {"policy_id": "P", "location": {"latitude": 1, "longitude": 2}}}]`,
+		"bad status":     `[{"task": {"policy_id": "P", "location": {"latitude": 1, "longitude": 2}, "coverage": {"start": 1, "end": 2}}, "status": "paused"}]`,
+		"bad variable":   `[{"task": {"policy_id": "P", "location": {"latitude": 1, "longitude": 2}, "coverage": {"start": 1, "end": 2}}, "trigger": {"variable": "snow", "threshold": "1"}}]`,
+		"invalid task":   `[{"task": {"policy_id": "", "location": {"latitude": 1, "longitude": 2}, "coverage": {"start": 1, "end": 2}}}]`,
+		"not a registry": `{}`,
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".json")
+		os.WriteFile(path, []byte(registry), 0o644)
+		if _, err := LoadPolicyRegistry(path); err == nil {
+			t.Errorf("%s: LoadPolicyRegistry() succeeded", name)
+		}
+	}
+}
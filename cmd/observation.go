@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStaleObservation is returned for readings older than the configured maximum age
+var ErrStaleObservation = errors.New("stale observation")
+
+// ErrMismatchedObservation is returned for readings too far from the requested time
+var ErrMismatchedObservation = errors.New("observation does not match the requested time")
+
+// openMeteoTimeLayout is the format of Open-Meteo's current.time (UTC, minute precision)
+const openMeteoTimeLayout = "2006-01-02T15:04"
+
+// parseOpenMeteoTime parses the observation time of an Open-Meteo current block
+func parseOpenMeteoTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("weather data has no observation time")
+	}
+	t, err := time.ParseInLocation(openMeteoTimeLayout, s, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid observation time %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// canonicalTime snaps a Unix timestamp down to the start of its bucket in UTC,
+// so every operator reports the same requested time for a task
+func canonicalTime(ts int64, bucket time.Duration) int64 {
+	if bucket <= 0 {
+		return ts
+	}
+	return time.Unix(ts, 0).UTC().Truncate(bucket).Unix()
+}
+
+// checkFresh rejects observations older than the client's maximum age. The
+// age of an accumulated reading counts from the end of its period, so a day's
+// total in progress stays fresh all day.
+func (c *WeatherClient) checkFresh(data *WeatherData) error {
+	if c.maxAge <= 0 {
+		return nil
+	}
+	end := data.Timestamp.Add(time.Duration(data.PeriodSeconds) * time.Second)
+	if age := c.now().Sub(end); age > c.maxAge {
+		return fmt.Errorf("%w: observed %s ago at %s, maximum age is %s",
+			ErrStaleObservation, age.Round(time.Second), data.Timestamp.Format(time.RFC3339), c.maxAge)
+	}
+	return nil
+}
+
+// checkMatches rejects a reading whose period is further than the time
+// bucket from at, the requested time, so a past task is never answered with
+// current conditions. A zero at or bucket disables the check.
+func (c *WeatherClient) checkMatches(data *WeatherData, at time.Time) error {
+	if at.IsZero() || c.timeBucket <= 0 {
+		return nil
+	}
+	start := data.Timestamp
+	end := start.Add(time.Duration(data.PeriodSeconds) * time.Second)
+	var gap time.Duration
+	switch {
+	case at.Before(start):
+		gap = start.Sub(at)
+	case at.After(end):
+		gap = at.Sub(end)
+	}
+	if gap > c.timeBucket {
+		return fmt.Errorf("%w: observed at %s, requested %s, tolerance is %s",
+			ErrMismatchedObservation, start.Format(time.RFC3339), at.Format(time.RFC3339), c.timeBucket)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+func TestCanonicalTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		ts     int64
+		bucket time.Duration
+		want   int64
+	}{
+		{name: "top of hour unchanged", ts: 1704067200, bucket: time.Hour, want: 1704067200},
+		{name: "mid hour snaps down", ts: 1704067200 + 59*60 + 59, bucket: time.Hour, want: 1704067200},
+		{name: "fifteen minute bucket", ts: 1704067200 + 20*60, bucket: 15 * time.Minute, want: 1704067200 + 15*60},
+		{name: "daily bucket", ts: 1704067200 + 13*3600, bucket: 24 * time.Hour, want: 1704067200},
+		{name: "no bucket", ts: 1704067211, bucket: 0, want: 1704067211},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonicalTime(tt.ts, tt.bucket); got != tt.want {
+				t.Errorf("canonicalTime(%d, %s) = %d, want %d", tt.ts, tt.bucket, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOpenMeteoTime(t *testing.T) {
+	got, err := parseOpenMeteoTime("2024-09-01T12:15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 9, 1, 12, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("parseOpenMeteoTime() = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"", "yesterday", "2024-09-01 12:15"} {
+		if _, err := parseOpenMeteoTime(bad); err == nil {
+			t.Errorf("parseOpenMeteoTime(%q) accepted an invalid time", bad)
+		}
+	}
+}
+
+func TestWeatherClient_RejectsStaleObservations(t *testing.T) {
+	observedAt := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	stale := &staticProvider{data: WeatherData{Temperature: 10, Source: "static", Timestamp: observedAt}}
+	client := NewWeatherClientWithProviders(zap.NewNop(), stale)
+	client.maxAge = 30 * time.Minute
+
+	client.now = func() time.Time { return observedAt.Add(45 * time.Minute) }
+	if _, err := client.FetchWeather(context.Background(), Location{}); !errors.Is(err, ErrStaleObservation) {
+		t.Fatalf("error = %v, want stale observation", err)
+	}
+
+	client.now = func() time.Time { return observedAt.Add(10 * time.Minute) }
+	if _, err := client.FetchWeather(context.Background(), Location{}); err != nil {
+		t.Fatalf("fresh observation rejected: %v", err)
+	}
+
+	// The cached reading ages too and must not be served once stale
+	client.now = func() time.Time { return observedAt.Add(35 * time.Minute) }
+	client.cache["0.0000,0.0000"].ExpiresAt = observedAt.Add(time.Hour)
+	if _, err := client.FetchWeather(context.Background(), Location{}); !errors.Is(err, ErrStaleObservation) {
+		t.Errorf("stale cached observation served: %v", err)
+	}
+}
+
+func TestHandleTask_ReportsCanonicalAndObservedTimes(t *testing.T) {
+	observedAt := time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(&staticProvider{data: WeatherData{Temperature: 3, Source: "static", Timestamp: observedAt}})
+	worker.weatherClient.now = func() time.Time { return observedAt.Add(5 * time.Minute) }
+
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{
+		TaskId:  []byte("task-times"),
+		Payload: []byte(`{"location": {"latitude": 40.7128, "longitude": -74.0060}, "timestamp": 1704068123, "policy_id": "POL-001"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Timestamp  int64 `json:"timestamp"`
+		ObservedAt int64 `json:"observed_at"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Timestamp != 1704067200 {
+		t.Errorf("timestamp = %d, want requested time snapped to 1704067200", result.Timestamp)
+	}
+	if result.ObservedAt != observedAt.Unix() {
+		t.Errorf("observed_at = %d, want %d", result.ObservedAt, observedAt.Unix())
+	}
+}
+
+func TestWeatherClient_RejectsObservationsForAnotherTime(t *testing.T) {
+	observedAt := time.Date(2024, 9, 1, 12, 10, 0, 0, time.UTC)
+	current := &staticProvider{data: WeatherData{Temperature: 10, Source: "static", Timestamp: observedAt}}
+	client := NewWeatherClientWithProviders(zap.NewNop(), current)
+	client.now = func() time.Time { return observedAt.Add(5 * time.Minute) }
+
+	// A task for last week is not answered with this morning's reading,
+	// fetched or cached
+	lastWeek := observedAt.AddDate(0, 0, -7).Truncate(time.Hour)
+	if _, err := client.FetchWeatherAt(context.Background(), Location{}, lastWeek); !errors.Is(err, ErrMismatchedObservation) {
+		t.Fatalf("error = %v, want mismatched observation", err)
+	}
+	if _, err := client.FetchWeather(context.Background(), Location{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.FetchWeatherAt(context.Background(), Location{}, lastWeek); !errors.Is(err, ErrMismatchedObservation) {
+		t.Errorf("cached reading served for another time: %v", err)
+	}
+
+	// Within a bucket of the requested time the reading stands
+	for _, at := range []time.Time{observedAt.Truncate(time.Hour), observedAt.Add(-40 * time.Minute)} {
+		if _, err := client.FetchWeatherAt(context.Background(), Location{}, at); err != nil {
+			t.Errorf("reading rejected for %s: %v", at, err)
+		}
+	}
+
+	// A daily reading matches any time of its day
+	rain := 4.0
+	daily := &staticProvider{data: WeatherData{Precipitation: &rain, Source: "static", Timestamp: observedAt.Truncate(24 * time.Hour), PeriodSeconds: 86400}}
+	client = NewWeatherClientWithProviders(zap.NewNop(), daily)
+	client.maxAge = 0
+	if _, err := client.FetchWeatherAt(context.Background(), Location{}, observedAt.Add(10*time.Hour).Truncate(time.Hour)); err != nil {
+		t.Errorf("daily reading rejected within its day: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+// TaskTypePayout asks for the payout under a policy's payout structure
+// rather than a plain verification
+const TaskTypePayout = "payout"
+
+// PayoutTerms is the payout part of a payout task. The index is always
+// computed by the operators from the data they fetch, never taken from the
+// requester.
+type PayoutTerms struct {
+	Structure payout.Structure `json:"structure"`
+	// Variable is the peril's variable the index is read from
+	Variable string `json:"variable,omitempty"`
+}
+
+// Validate checks the payout structure and that the index is read from one
+// of the peril's variables
+func (p *PayoutTerms) Validate(variables []string) error {
+	if err := p.Structure.Validate(); err != nil {
+		return fmt.Errorf("invalid payout structure: %w", err)
+	}
+	if !containsString(variables, p.Variable) {
+		return fmt.Errorf("invalid payout index variable %q", p.Variable)
+	}
+	return nil
+}
+
+// weatherIndex reads the index variable from data in fixed point
+func weatherIndex(data *WeatherData, variable string) (payout.Decimal, error) {
+	var v float64
+	switch variable {
+	case "precipitation":
+		if data.Precipitation == nil {
+			return 0, fmt.Errorf("source %s does not report precipitation", data.Source)
+		}
+		v = *data.Precipitation
+	case "temperature":
+		v = data.Temperature
+	case "wind_speed":
+		v = data.WindSpeed
+	case "humidity":
+		v = data.Humidity
+	case "pressure":
+		v = data.Pressure
+	default:
+		return 0, fmt.Errorf("invalid payout index variable %q", variable)
+	}
+	return payout.FromFloat(v)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// payoutResult decodes the payout block of a task result
+func payoutResult(t *testing.T, result []byte) map[string]interface{} {
+	t.Helper()
+	var doc struct {
+		Type   string                 `json:"type"`
+		Payout map[string]interface{} `json:"payout"`
+	}
+	if err := json.Unmarshal(result, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Type != TaskTypePayout || doc.Payout == nil {
+		t.Fatalf("result has no payout: %s", result)
+	}
+	return doc.Payout
+}
+
+func TestSunReWorker_PayoutFromWeather(t *testing.T) {
+	// 100 mm observed on the task's day, fresh enough to verify
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(&archiveProvider{rain: map[string]float64{"2023-08-31": 100}})
+
+	payload, err := os.ReadFile("../examples/task-payout-miami.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &performerV1.TaskRequest{TaskId: []byte("task-payout"), Payload: payload}
+	if err := worker.ValidateTask(task); err != nil {
+		t.Fatalf("ValidateTask() = %v", err)
+	}
+	resp, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 mm is halfway from 50 to 150: 5 of 10 ether, less a 0.5 ether
+	// deductible, within the 5 ether period limit
+	p := payoutResult(t, resp.Result)
+	if p["index"] != "100" || p["gross_bps"] != 5000.0 || p["payout"] != "4500000000000000000" || p["payout_bps"] != 4500.0 {
+		t.Errorf("payout = %v", p)
+	}
+	if !strings.Contains(string(resp.Result), `"weather"`) {
+		t.Error("payout computed from weather should carry the weather")
+	}
+}
+
+func TestSunReWorker_StepPayout(t *testing.T) {
+	provider := &staticProvider{data: WeatherData{Temperature: 180, Source: "static", Timestamp: time.Now()}}
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(provider)
+
+	payload := []byte(`{"type": "payout", "policy_id": "POL-STEP", "location": {"latitude": 1, "longitude": 2},
+		"payout": {"variable": "temperature", "structure": {"kind": "step", "sum_insured": "1000",
+			"tiers": [{"threshold": "100", "payout_bps": 2500}, {"threshold": "150", "payout_bps": 5000}]}}}`)
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-step"), Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := payoutResult(t, resp.Result); p["payout"] != "500" || p["triggered"] != true {
+		t.Errorf("payout = %v", p)
+	}
+
+	// The result is identical however often it is computed
+	again, _ := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-step"), Payload: payload})
+	if string(again.Result) != string(resp.Result) {
+		t.Errorf("results differ:\n%s\n%s", resp.Result, again.Result)
+	}
+
+	// The index is never taken from the requester
+	asserted := []byte(`{"type": "payout", "policy_id": "POL-STEP", "location": {"latitude": 1, "longitude": 2},
+		"payout": {"index": "180", "structure": {"kind": "linear", "exhaustion": "100", "sum_insured": "1000"}}}`)
+	if err := worker.ValidateTask(&performerV1.TaskRequest{TaskId: []byte("task-asserted"), Payload: asserted}); err == nil {
+		t.Error("a payout task with only an index was accepted")
+	}
+}
+
+func TestSunReWorker_UnverifiedPayoutWithheld(t *testing.T) {
+	provider := &staticProvider{data: WeatherData{Temperature: 180, Source: "static", Timestamp: time.Now()}}
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(provider)
+
+	payload := []byte(`{"type": "payout", "policy_id": "POL-LOW", "location": {"latitude": 1, "longitude": 2},
+		"min_confidence": 0.99,
+		"payout": {"variable": "temperature", "structure": {"kind": "linear", "exhaustion": "100", "sum_insured": "1000"}}}`)
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-low"), Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if string(result["verified"]) != "false" || result["payout"] != nil || result["payout_withheld"] == nil {
+		t.Errorf("unverified result was paid: %s", resp.Result)
+	}
+}
+
+func TestSunReWorker_PayoutValidation(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	for name, payload := range map[string]string{
+		"unknown type":    `{"type": "lottery", "policy_id": "P"}`,
+		"missing terms":   `{"type": "payout", "policy_id": "P"}`,
+		"bad variable":    `{"type": "payout", "policy_id": "P", "payout": {"variable": "mood", "structure": {"kind": "linear", "exhaustion": "1", "sum_insured": "1"}}}`,
+		"bad structure":   `{"type": "payout", "policy_id": "P", "payout": {"variable": "temperature", "structure": {"kind": "linear", "attachment": "5", "exhaustion": "1", "sum_insured": "1"}}}`,
+		"imprecise curve": `{"type": "payout", "policy_id": "P", "payout": {"variable": "temperature", "structure": {"kind": "linear", "exhaustion": "1.0000001", "sum_insured": "1"}}}`,
+	} {
+		if err := worker.ValidateTask(&performerV1.TaskRequest{TaskId: []byte("t"), Payload: []byte(payload)}); err == nil {
+			t.Errorf("%s: ValidateTask() succeeded", name)
+		}
+	}
+}
+
+func TestTaskBuild_Payout(t *testing.T) {
+	terms := filepath.Join(t.TempDir(), "terms.json")
+	writeFile(t, terms, `{"variable": "wind_speed", "structure": {"kind": "linear", "attachment": "90", "exhaustion": "180", "sum_insured": "1000"}}`)
+
+	var stdout, stderr strings.Builder
+	code := run([]string{"task", "build", "-lat", "25.76", "-lon", "-80.19", "-policy", "POL-WIND", "-payout", terms}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("task build = %d: %s", code, stderr.String())
+	}
+	var req WeatherVerificationRequest
+	if err := json.Unmarshal([]byte(stdout.String()), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Type != TaskTypePayout || req.Payout == nil || req.Payout.Variable != "wind_speed" {
+		t.Errorf("payload = %s", stdout.String())
+	}
+}
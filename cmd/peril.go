@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/energy"
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/metar"
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+// Peril is a plugin for one kind of covered risk. A task selects its peril
+// with "type"; HandleTask only decodes the shared request fields and leaves
+// the peril to say what data it needs, compute its index and encode the
+// result, so a new peril is added by registering it in perils.
+type Peril interface {
+	// Spec describes the peril and its task payload
+	Spec() PerilSpec
+	// Validate checks the peril's own fields of req; the shared fields and
+	// payout terms have been checked already
+	Validate(req *WeatherVerificationRequest) error
+	// Requirements is the data the result for req is computed from
+	Requirements(req *WeatherVerificationRequest) DataRequirements
+	// Evaluate computes the peril's result fields from the fetched data
+	Evaluate(env PerilEnv, req *WeatherVerificationRequest, data *PerilData) (map[string]interface{}, error)
+	// Index reads a payout index variable from the fetched data
+	Index(req *WeatherVerificationRequest, data *PerilData, variable string) (payout.Decimal, error)
+	// Encode returns the canonical bytes of a result. Every operator signs
+	// them, so equal results must encode to equal bytes.
+	Encode(result map[string]interface{}) ([]byte, error)
+}
+
+// PerilSpec describes a peril to integrators
+type PerilSpec struct {
+	// Name is the task type that selects the peril
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Variables are the index variables a payout can be written on
+	Variables []string `json:"variables,omitempty"`
+	// Schema is the JSON Schema of the peril's task payload
+	Schema json.RawMessage `json:"schema"`
+}
+
+// DataRequirements is the data a task's result is computed from
+type DataRequirements struct {
+	// Weather asks for an observation at the task's location and time
+	Weather bool `json:"weather,omitempty"`
+	// Metar asks for a station's METAR reports over a window
+	Metar *MetarRequirement `json:"metar,omitempty"`
+	// Energy asks for hourly irradiance and wind over a window
+	Energy *EnergyRequirement `json:"energy,omitempty"`
+}
+
+// PerilData is the data fetched for a task
+type PerilData struct {
+	Weather *WeatherData
+	Metar   []metar.Report
+	// MetarSource names the source the reports came from
+	MetarSource string
+	Energy      []energy.Sample
+	// EnergySource names the source the samples came from
+	EnergySource string
+}
+
+// sources lists every provider and station the data was derived from
+func (d *PerilData) sources() []string {
+	if d == nil {
+		return nil
+	}
+	var sources []string
+	if d.Weather != nil {
+		sources = auditSources(d.Weather)
+	}
+	if d.MetarSource != "" {
+		sources = append(sources, d.MetarSource)
+	}
+	if d.EnergySource != "" {
+		sources = append(sources, d.EnergySource)
+	}
+	return sources
+}
+
+// PerilEnv is what a peril evaluates a task with besides its data
+type PerilEnv struct {
+	// Timestamp is the task's time, snapped to the time bucket
+	Timestamp  int64
+	Confidence ConfidenceConfig
+}
+
+// perils holds the perils tasks can select
+var perils = NewPerilRegistry(weatherPeril{}, airportPeril{}, energyPeril{})
+
+// PerilRegistry maps task types to the perils that handle them
+type PerilRegistry struct {
+	mu     sync.RWMutex
+	perils map[string]Peril
+}
+
+// NewPerilRegistry returns a registry of perils. It panics if two share a
+// name, as that is a programming error.
+func NewPerilRegistry(perils ...Peril) *PerilRegistry {
+	r := &PerilRegistry{perils: make(map[string]Peril)}
+	for _, p := range perils {
+		if err := r.Register(p); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds p under its name
+func (r *PerilRegistry) Register(p Peril) error {
+	name := p.Spec().Name
+	if name == "" || name == TaskTypePayout {
+		return fmt.Errorf("invalid peril name %q", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.perils[name]; ok {
+		return fmt.Errorf("peril %q is already registered", name)
+	}
+	r.perils[name] = p
+	return nil
+}
+
+// Lookup returns the peril of task type typ. Untyped tasks and payout
+// tasks are weather tasks.
+func (r *PerilRegistry) Lookup(typ string) (Peril, error) {
+	name := typ
+	if name == "" || name == TaskTypePayout {
+		name = weatherPerilName
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.perils[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown task type %q", typ)
+	}
+	return p, nil
+}
+
+// List describes every registered peril, by name
+func (r *PerilRegistry) List() []PerilSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	specs := make([]PerilSpec, 0, len(r.perils))
+	for _, p := range r.perils {
+		specs = append(specs, p.Spec())
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// perilsHandler serves the registered perils and their payload schemas
+func (r *PerilRegistry) perilsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.List())
+}
+
+// peril returns the peril req selects
+func (req *WeatherVerificationRequest) peril() (Peril, error) {
+	return perils.Lookup(req.Type)
+}
+
+// paysOut reports whether req asks for a payout: payout tasks do, and tasks
+// of other perils when they carry payout terms
+func (req *WeatherVerificationRequest) paysOut() bool {
+	switch req.Type {
+	case TaskTypePayout:
+		return true
+	case "", weatherPerilName:
+		return false
+	default:
+		return req.Payout != nil
+	}
+}
+
+// canonicalJSON encodes a result as JSON with object keys sorted
+func canonicalJSON(result map[string]interface{}) ([]byte, error) {
+	return json.Marshal(result)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+)
+
+// quakePeril is a peril needing no fetched data, reading a fixed shaking
+// intensity from the latitude
+type quakePeril struct{}
+
+func (quakePeril) Spec() PerilSpec {
+	return PerilSpec{Name: "quake", Variables: []string{"intensity"}, Schema: json.RawMessage(`{"type": "object"}`)}
+}
+
+func (quakePeril) Validate(req *WeatherVerificationRequest) error {
+	if req.Coverage == nil {
+		return fmt.Errorf("quake tasks need a coverage period")
+	}
+	return nil
+}
+
+func (quakePeril) Requirements(req *WeatherVerificationRequest) DataRequirements {
+	return DataRequirements{}
+}
+
+func (quakePeril) Evaluate(env PerilEnv, req *WeatherVerificationRequest, data *PerilData) (map[string]interface{}, error) {
+	return map[string]interface{}{"intensity": req.Location.Latitude, "verified": req.Location.Latitude >= 7}, nil
+}
+
+func (quakePeril) Index(req *WeatherVerificationRequest, data *PerilData, variable string) (payout.Decimal, error) {
+	return payout.FromFloat(req.Location.Latitude)
+}
+
+func (quakePeril) Encode(result map[string]interface{}) ([]byte, error) {
+	return canonicalJSON(result)
+}
+
+// withPerils registers extra perils beside weather for the test
+func withPerils(t *testing.T, extra ...Peril) {
+	old := perils
+	perils = NewPerilRegistry(append([]Peril{weatherPeril{}}, extra...)...)
+	t.Cleanup(func() { perils = old })
+}
+
+func TestPerilRegistry_Lookup(t *testing.T) {
+	r := NewPerilRegistry(weatherPeril{}, quakePeril{})
+	for _, typ := range []string{"", "weather", "payout"} {
+		if p, err := r.Lookup(typ); err != nil || p.Spec().Name != "weather" {
+			t.Errorf("Lookup(%q) = %v, %v", typ, p, err)
+		}
+	}
+	if p, err := r.Lookup("quake"); err != nil || p.Spec().Name != "quake" {
+		t.Errorf("Lookup(quake) = %v, %v", p, err)
+	}
+	if _, err := r.Lookup("flight"); err == nil || !strings.Contains(err.Error(), "unknown task type") {
+		t.Errorf("Lookup(flight) = %v", err)
+	}
+
+	if err := r.Register(quakePeril{}); err == nil {
+		t.Error("registered a peril twice")
+	}
+	specs := r.List()
+	if len(specs) != 2 || specs[0].Name != "quake" || specs[1].Name != "weather" {
+		t.Errorf("List() = %+v", specs)
+	}
+	for _, spec := range specs {
+		if !json.Valid(spec.Schema) {
+			t.Errorf("%s: invalid schema", spec.Name)
+		}
+	}
+}
+
+func TestPeril_PluggedIntoHandleTask(t *testing.T) {
+	withPerils(t, quakePeril{})
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+
+	payload := `{"type": "quake", "policy_id": "POL-Q", "location": {"latitude": 8, "longitude": 2},
+		"coverage": {"start": 1704067200, "end": 1706745600},
+		"payout": {"variable": "intensity", "structure": {"kind": "linear", "attachment": "6", "exhaustion": "10", "sum_insured": "1000"}}}`
+	task := &performerV1.TaskRequest{TaskId: []byte("task-q"), Payload: []byte(payload)}
+	if err := worker.ValidateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Type      string  `json:"type"`
+		Intensity float64 `json:"intensity"`
+		Verified  bool    `json:"verified"`
+		Weather   *WeatherData
+		Payout    *payout.Result
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != TaskTypePayout || result.Intensity != 8 || !result.Verified || result.Weather != nil ||
+		result.Payout == nil || result.Payout.Payout.String() != "500" {
+		t.Errorf("result = %s", resp.Result)
+	}
+	// The payout settles the period, keyed by the payout variable
+	records := worker.policies.List("POL-Q")
+	if len(records) != 1 || records[0].Peril != "intensity" || records[0].State != PolicySettled {
+		t.Errorf("records = %+v", records)
+	}
+}
+
+func TestPeril_Validate(t *testing.T) {
+	withPerils(t, quakePeril{})
+	for name, c := range map[string]struct {
+		payload, err string
+	}{
+		"unknown type": {`{"type": "flight"}`, "unknown task type"},
+		"peril check":  {`{"type": "quake"}`, "coverage period"},
+		"variable of other peril": {`{"type": "quake", "coverage": {"start": 1, "end": 2},
+			"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "1", "sum_insured": "1"}}}`, "invalid payout index variable"},
+		"weather variable": {`{"type": "payout",
+			"payout": {"variable": "intensity", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "1", "sum_insured": "1"}}}`, "invalid payout index variable"},
+	} {
+		var req WeatherVerificationRequest
+		if err := json.Unmarshal([]byte(c.payload), &req); err != nil {
+			t.Fatal(err)
+		}
+		req.PolicyID = "P"
+		if err := req.Validate(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: Validate() = %v", name, err)
+		}
+	}
+}
+
+func TestPerilsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	perils.perilsHandler(rec, httptest.NewRequest(http.MethodGet, "/perils", nil))
+	var specs []PerilSpec
+	if err := json.Unmarshal(rec.Body.Bytes(), &specs); err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 || specs[0].Name != "airport" || specs[1].Name != "energy" || specs[2].Name != "weather" ||
+		len(specs[2].Variables) != len(weatherVariables) {
+		t.Errorf("GET /perils = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PolicyState is where a policy's coverage period is in its lifecycle
+type PolicyState string
+
+const (
+	// PolicyActive periods have been seen but not triggered
+	PolicyActive PolicyState = "active"
+	// PolicyTriggered periods had a verified claim that has not been paid out
+	PolicyTriggered PolicyState = "triggered"
+	// PolicySettled periods have a signed payout; their result is final
+	PolicySettled PolicyState = "settled"
+	// PolicyExpired periods ended, plus the claim window, without settling
+	PolicyExpired PolicyState = "expired"
+	// PolicyCancelled policies accept no more tasks
+	PolicyCancelled PolicyState = "cancelled"
+)
+
+// policyTransitions lists the states each state may move to
+var policyTransitions = map[PolicyState][]PolicyState{
+	PolicyActive:    {PolicyTriggered, PolicySettled, PolicyExpired, PolicyCancelled},
+	PolicyTriggered: {PolicySettled, PolicyExpired, PolicyCancelled},
+}
+
+// canTransition reports whether a period may move from one state to another
+func canTransition(from, to PolicyState) bool {
+	for _, s := range policyTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrPolicyCancelled is returned for tasks on a cancelled policy
+	ErrPolicyCancelled = errors.New("policy is cancelled")
+	// ErrPolicyExpired is returned for tasks after a period's claim window
+	ErrPolicyExpired = errors.New("policy coverage period has expired")
+)
+
+// PolicyConfig configures policy lifecycle tracking
+type PolicyConfig struct {
+	// Path persists the lifecycle; empty keeps it in memory
+	Path string `json:"path,omitempty"`
+	// ClaimWindow is how long after a coverage period ends claims are accepted
+	ClaimWindow time.Duration `json:"claim_window"`
+}
+
+// Validate checks the policy settings
+func (c PolicyConfig) Validate() error {
+	if c.ClaimWindow <= 0 {
+		return fmt.Errorf("POLICY_CLAIM_WINDOW must be positive")
+	}
+	return nil
+}
+
+// CoveragePeriod is the time a policy covers, as Unix seconds [start, end).
+// It may be declared in the location's civil time instead, with Resolve
+// filling in start and end.
+type CoveragePeriod struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// LocalStart and LocalEnd are wall-clock times, "2006-01-02T15:04[:05]"
+	LocalStart string `json:"local_start,omitempty"`
+	LocalEnd   string `json:"local_end,omitempty"`
+	// TimeZone is the IANA zone local times are read in; empty resolves it
+	// from the location
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// Validate checks that the period is not empty
+func (p *CoveragePeriod) Validate() error {
+	if p.End <= p.Start {
+		return fmt.Errorf("invalid coverage period: end %d is not after start %d", p.End, p.Start)
+	}
+	return nil
+}
+
+// PolicyKey identifies one coverage period of one peril of a policy. Each
+// tenant's policies are a separate namespace.
+type PolicyKey struct {
+	Tenant   string `json:"tenant,omitempty"`
+	PolicyID string `json:"policy_id"`
+	Peril    string `json:"peril"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+}
+
+func (k PolicyKey) String() string {
+	return fmt.Sprintf("%s/%s/%d-%d", k.policy(), k.Peril, k.Start, k.End)
+}
+
+// policy returns the key's policy ID qualified by its tenant
+func (k PolicyKey) policy() string {
+	return tenantPolicy(k.Tenant, k.PolicyID)
+}
+
+// tenantPolicy qualifies policyID with tenant, "tenant/policy", so policies
+// of different tenants never share a lifecycle
+func tenantPolicy(tenant, policyID string) string {
+	if tenant == "" || policyID == "" {
+		return policyID
+	}
+	return tenant + "/" + policyID
+}
+
+// policyKey returns the lifecycle key of req. The peril defaults to the
+// payout index variable, or the task type, and the period to the request's
+// time bucket.
+func (req *WeatherVerificationRequest) policyKey(bucket time.Duration, now time.Time) PolicyKey {
+	key := PolicyKey{PolicyID: req.PolicyID, Peril: req.Peril}
+	if key.Peril == "" {
+		key.Peril = weatherPerilName
+		if req.Type != "" && req.Type != TaskTypePayout {
+			key.Peril = req.Type
+		}
+		if req.Payout != nil && req.Payout.Variable != "" {
+			key.Peril = req.Payout.Variable
+		}
+	}
+	if req.Coverage != nil {
+		key.Start, key.End = req.Coverage.Start, req.Coverage.End
+		return key
+	}
+	timestamp := req.Timestamp
+	if timestamp == 0 {
+		timestamp = now.Unix()
+	}
+	key.Start = canonicalTime(timestamp, bucket)
+	key.End = key.Start + int64(bucket/time.Second)
+	return key
+}
+
+// PolicyTransition is one state change of a period
+type PolicyTransition struct {
+	From   PolicyState `json:"from,omitempty"`
+	To     PolicyState `json:"to"`
+	At     time.Time   `json:"at"`
+	TaskID string      `json:"task_id,omitempty"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// PolicyRecord is the lifecycle of one coverage period
+type PolicyRecord struct {
+	PolicyKey
+	State PolicyState `json:"state"`
+	// ExpiresAt is the end of the claim window; zero periods never expire
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// TaskID and Result are the task that settled the period and its canonical result
+	TaskID  string             `json:"task_id,omitempty"`
+	Result  json.RawMessage    `json:"result,omitempty"`
+	History []PolicyTransition `json:"history"`
+}
+
+// policyFile is the persisted lifecycle
+type policyFile struct {
+	Periods   []*PolicyRecord      `json:"periods"`
+	Cancelled map[string]time.Time `json:"cancelled,omitempty"`
+}
+
+// PolicyBook tracks the lifecycle of every policy period this operator has
+// handled, so a settled period is never paid again
+type PolicyBook struct {
+	cfg       PolicyConfig
+	logger    *zap.Logger
+	mu        sync.Mutex
+	periods   map[PolicyKey]*PolicyRecord
+	cancelled map[string]time.Time
+	now       func() time.Time
+}
+
+// NewPolicyBook loads the lifecycle from cfg.Path, if it exists
+func NewPolicyBook(cfg PolicyConfig, logger *zap.Logger) (*PolicyBook, error) {
+	b := &PolicyBook{
+		cfg:       cfg,
+		logger:    logger,
+		periods:   make(map[PolicyKey]*PolicyRecord),
+		cancelled: make(map[string]time.Time),
+		now:       time.Now,
+	}
+	if cfg.Path == "" {
+		return b, nil
+	}
+	data, err := os.ReadFile(cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy state: %w", err)
+	}
+	var file policyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid policy state file %s: %w", cfg.Path, err)
+	}
+	for _, r := range file.Periods {
+		// The file is indented; results are signed in compact form
+		if r.Result != nil {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, r.Result); err != nil {
+				return nil, fmt.Errorf("invalid result for %s: %w", r.PolicyKey, err)
+			}
+			r.Result = compact.Bytes()
+		}
+		b.periods[r.PolicyKey] = r
+	}
+	for id, at := range file.Cancelled {
+		b.cancelled[id] = at
+	}
+	return b, nil
+}
+
+// transition moves r to state, recording why. Callers hold b.mu.
+func (b *PolicyBook) transition(r *PolicyRecord, to PolicyState, taskID, reason string) {
+	r.History = append(r.History, PolicyTransition{From: r.State, To: to, At: b.now().UTC(), TaskID: taskID, Reason: reason})
+	b.logger.Info("Policy period changed state",
+		zap.String("period", r.PolicyKey.String()),
+		zap.String("from", string(r.State)),
+		zap.String("to", string(to)),
+		zap.String("taskId", taskID),
+	)
+	r.State = to
+}
+
+// record returns the record of key, creating an active one. Callers hold b.mu.
+func (b *PolicyBook) record(key PolicyKey, expiresAt time.Time, taskID string) *PolicyRecord {
+	r, ok := b.periods[key]
+	if !ok {
+		r = &PolicyRecord{PolicyKey: key, ExpiresAt: expiresAt}
+		b.transition(r, PolicyActive, taskID, "first task")
+		b.periods[key] = r
+	}
+	return r
+}
+
+// Begin is called before a task for key is computed. It returns the stored
+// result when the period is settled, and an error when the policy is
+// cancelled or, if the period expires, its claim window has closed. A nil
+// book tracks nothing.
+func (b *PolicyBook) Begin(key PolicyKey, expires bool, taskID string) (json.RawMessage, error) {
+	if b == nil {
+		return nil, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var expiresAt time.Time
+	if expires {
+		expiresAt = time.Unix(key.End, 0).Add(b.cfg.ClaimWindow).UTC()
+	}
+	_, existed := b.periods[key]
+	r := b.record(key, expiresAt, taskID)
+	changed := !existed
+	defer func() {
+		if changed {
+			b.saveLocked()
+		}
+	}()
+
+	if _, ok := b.cancelled[key.policy()]; ok && canTransition(r.State, PolicyCancelled) {
+		b.transition(r, PolicyCancelled, taskID, "policy cancelled")
+		changed = true
+	}
+	if !r.ExpiresAt.IsZero() && !b.now().Before(r.ExpiresAt) && canTransition(r.State, PolicyExpired) {
+		b.transition(r, PolicyExpired, taskID, "claim window closed")
+		changed = true
+	}
+
+	switch r.State {
+	case PolicySettled:
+		return r.Result, nil
+	case PolicyCancelled:
+		return nil, fmt.Errorf("%w: %s", ErrPolicyCancelled, key.policy())
+	case PolicyExpired:
+		return nil, fmt.Errorf("%w: %s", ErrPolicyExpired, key)
+	}
+	return nil, nil
+}
+
+// Peek returns the state a task for key would find and, for a settled
+// period, the task that settled it, without recording anything. Periods not
+// seen yet are active, as is every period of a nil book.
+func (b *PolicyBook) Peek(key PolicyKey, expires bool) (PolicyState, string) {
+	if b == nil {
+		return PolicyActive, ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, expiresAt := PolicyActive, time.Time{}
+	if expires {
+		expiresAt = time.Unix(key.End, 0).Add(b.cfg.ClaimWindow)
+	}
+	var taskID string
+	if r, ok := b.periods[key]; ok {
+		state, expiresAt, taskID = r.State, r.ExpiresAt, r.TaskID
+	}
+	if _, ok := b.cancelled[key.policy()]; ok && canTransition(state, PolicyCancelled) {
+		return PolicyCancelled, ""
+	}
+	if !expiresAt.IsZero() && !b.now().Before(expiresAt) && canTransition(state, PolicyExpired) {
+		return PolicyExpired, ""
+	}
+	if state != PolicySettled {
+		taskID = ""
+	}
+	return state, taskID
+}
+
+// policyOutcome is what a result means for the lifecycle
+type policyOutcome struct {
+	Verified bool   `json:"verified"`
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+}
+
+// observed reports whether the outcome rests on verified observations,
+// the only ground on which a period is settled or triggered
+func (o policyOutcome) observed() bool {
+	return o.Verified && o.Source != fallbackSource
+}
+
+// Complete records a computed result for key and returns the result to
+// answer with: result itself, or the settled result if another task settled
+// the period first. Verified payout results settle the period; verified
+// claims trigger it. A result that is not verified, or rests on fallback
+// data, leaves the period open.
+func (b *PolicyBook) Complete(key PolicyKey, taskID string, result []byte) ([]byte, error) {
+	if b == nil {
+		return result, nil
+	}
+	var outcome policyOutcome
+	if err := json.Unmarshal(result, &outcome); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.record(key, time.Time{}, taskID)
+	switch {
+	case r.State == PolicySettled:
+		return r.Result, nil
+	case !outcome.observed():
+		return result, nil
+	case outcome.Type == TaskTypePayout && canTransition(r.State, PolicySettled):
+		b.transition(r, PolicySettled, taskID, "payout signed")
+		r.TaskID, r.Result = taskID, append(json.RawMessage(nil), result...)
+	case r.State == PolicyActive:
+		b.transition(r, PolicyTriggered, taskID, "claim verified")
+	default:
+		return result, nil
+	}
+	if err := b.saveLocked(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Cancel cancels every open period of the policy and refuses its future
+// tasks, returning the number of periods cancelled. A tenant's policies are
+// named "tenant/policy".
+func (b *PolicyBook) Cancel(policyID string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancelled[policyID] = b.now().UTC()
+	var n int
+	for key, r := range b.periods {
+		if key.policy() == policyID && canTransition(r.State, PolicyCancelled) {
+			b.transition(r, PolicyCancelled, "", "policy cancelled")
+			n++
+		}
+	}
+	return n, b.saveLocked()
+}
+
+// List returns the periods of policyID, named as in Cancel, or of every
+// policy when it is empty, ordered by tenant, policy, peril and start
+func (b *PolicyBook) List(policyID string) []PolicyRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := make([]PolicyRecord, 0, len(b.periods))
+	for key, r := range b.periods {
+		if policyID == "" || key.policy() == policyID {
+			records = append(records, *r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, c := records[i].PolicyKey, records[j].PolicyKey
+		if a.Tenant != c.Tenant {
+			return a.Tenant < c.Tenant
+		}
+		if a.PolicyID != c.PolicyID {
+			return a.PolicyID < c.PolicyID
+		}
+		if a.Peril != c.Peril {
+			return a.Peril < c.Peril
+		}
+		return a.Start < c.Start
+	})
+	return records
+}
+
+// saveLocked persists the lifecycle. Callers hold b.mu.
+func (b *PolicyBook) saveLocked() error {
+	if b.cfg.Path == "" {
+		return nil
+	}
+	file := policyFile{Cancelled: b.cancelled}
+	for _, r := range b.periods {
+		file.Periods = append(file.Periods, r)
+	}
+	sort.Slice(file.Periods, func(i, j int) bool {
+		return file.Periods[i].PolicyKey.String() < file.Periods[j].PolicyKey.String()
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(b.cfg.Path, data); err != nil {
+		b.logger.Error("Failed to save policy state", zap.Error(err))
+		return fmt.Errorf("failed to save policy state: %w", err)
+	}
+	return nil
+}
+
+// Policies endpoint: GET lists periods (optionally ?policy=ID), DELETE ?policy=ID cancels a policy.
+// ?tenant=ID selects a tenant's namespace.
+func (b *PolicyBook) policiesHandler(w http.ResponseWriter, req *http.Request) {
+	tenant := req.URL.Query().Get("tenant")
+	policyID := tenantPolicy(tenant, req.URL.Query().Get("policy"))
+	switch req.Method {
+	case http.MethodGet:
+		records := b.List(policyID)
+		if tenant != "" {
+			kept := records[:0]
+			for _, r := range records {
+				if r.Tenant == tenant {
+					kept = append(kept, r)
+				}
+			}
+			records = kept
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	case http.MethodDelete:
+		if policyID == "" {
+			http.Error(w, "policy is required", http.StatusBadRequest)
+			return
+		}
+		n, err := b.Cancel(policyID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"cancelled": n})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// newPolicyWorker returns a worker tracking policies in path that answers
+// from a static provider
+func newPolicyWorker(t *testing.T, path string) *SunReWorker {
+	t.Helper()
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(&staticProvider{data: WeatherData{Temperature: 21, Source: "static", Timestamp: time.Now()}})
+	var err error
+	if worker.policies, err = NewPolicyBook(PolicyConfig{Path: path, ClaimWindow: 24 * time.Hour}, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	return worker
+}
+
+// payoutTask is a payout task for POL-1 over a fixed coverage period,
+// paying on the temperature
+func payoutTask(taskID, sumInsured string) *performerV1.TaskRequest {
+	payload := fmt.Sprintf(`{"type": "payout", "policy_id": "POL-1", "peril": "flood",
+		"location": {"latitude": 1, "longitude": 2},
+		"coverage": {"start": 1704067200, "end": 1706745600},
+		"payout": {"variable": "temperature", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "100", "sum_insured": %q}}}`, sumInsured)
+	return &performerV1.TaskRequest{TaskId: []byte(taskID), Payload: []byte(payload)}
+}
+
+func TestPolicyBook_SettledPeriodReplaysResult(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	worker := newPolicyWorker(t, path)
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+
+	first, err := worker.HandleTask(payoutTask("task-1", "40"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Different data for the same policy, peril and period gets the original result
+	second, err := worker.HandleTask(payoutTask("task-2", "90"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Result, second.Result) {
+		t.Errorf("settled period was recomputed:\n%s\n%s", first.Result, second.Result)
+	}
+
+	records := worker.policies.List("POL-1")
+	if len(records) != 1 || records[0].State != PolicySettled || records[0].TaskID != "task-1" {
+		t.Fatalf("records = %+v", records)
+	}
+
+	// The settlement survives a restart
+	restarted := newPolicyWorker(t, path)
+	restarted.policies.now = worker.policies.now
+	third, err := restarted.HandleTask(payoutTask("task-3", "10"))
+	if err != nil || !bytes.Equal(first.Result, third.Result) {
+		t.Errorf("after restart: %s, %v", third.Result, err)
+	}
+}
+
+func TestPolicyBook_UnverifiedPayoutLeavesPeriodOpen(t *testing.T) {
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+	worker.weatherClient.SetProviders(&flakyProvider{down: true})
+
+	// Every provider is down, so the result is built from fallback data
+	if _, err := worker.HandleTask(payoutTask("task-1", "40")); err != nil {
+		t.Fatal(err)
+	}
+	if records := worker.policies.List("POL-1"); len(records) != 1 || records[0].State != PolicyActive {
+		t.Fatalf("fallback result settled the period: %+v", records)
+	}
+
+	// Once the data can be verified the period settles on the new task
+	worker.weatherClient.SetProviders(&staticProvider{data: WeatherData{Temperature: 21, Source: "static", Timestamp: time.Now()}})
+	if _, err := worker.HandleTask(payoutTask("task-2", "40")); err != nil {
+		t.Fatal(err)
+	}
+	if records := worker.policies.List("POL-1"); records[0].State != PolicySettled || records[0].TaskID != "task-2" {
+		t.Errorf("records = %+v", records)
+	}
+
+	// A payout result claiming verification from fallback data settles nothing
+	key := PolicyKey{PolicyID: "POL-FB", Peril: "temperature", Start: 0, End: 3600}
+	if _, err := worker.policies.Begin(key, false, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worker.policies.Complete(key, "t1", []byte(`{"type": "payout", "verified": true, "source": "Fallback"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if state := worker.policies.List("POL-FB")[0].State; state != PolicyActive {
+		t.Errorf("fallback payout moved the period to %s", state)
+	}
+}
+
+func TestPolicyBook_Lifecycle(t *testing.T) {
+	book, _ := NewPolicyBook(PolicyConfig{ClaimWindow: time.Hour}, zap.NewNop())
+	now := time.Unix(1000, 0)
+	book.now = func() time.Time { return now }
+	key := PolicyKey{PolicyID: "POL-2", Peril: "weather", Start: 0, End: 3600}
+
+	if res, err := book.Begin(key, true, "t1"); res != nil || err != nil {
+		t.Fatalf("Begin() = %s, %v", res, err)
+	}
+	if _, err := book.Complete(key, "t1", []byte(`{"verified": true}`)); err != nil {
+		t.Fatal(err)
+	}
+	if state := book.List("POL-2")[0].State; state != PolicyTriggered {
+		t.Errorf("after a verified claim: %s, want %s", state, PolicyTriggered)
+	}
+
+	// Past the claim window the period expires instead of settling
+	now = time.Unix(2*3600+1, 0)
+	if _, err := book.Begin(key, true, "t2"); !errors.Is(err, ErrPolicyExpired) {
+		t.Errorf("Begin() after the claim window = %v", err)
+	}
+	history := book.List("POL-2")[0].History
+	var states []string
+	for _, h := range history {
+		states = append(states, string(h.To))
+	}
+	if strings.Join(states, ",") != "active,triggered,expired" {
+		t.Errorf("history = %v", states)
+	}
+
+	// Cancelling refuses new periods of the policy
+	if _, err := book.Cancel("POL-2"); err != nil {
+		t.Fatal(err)
+	}
+	other := PolicyKey{PolicyID: "POL-2", Peril: "weather", Start: 3600, End: 7200}
+	if _, err := book.Begin(other, false, "t3"); !errors.Is(err, ErrPolicyCancelled) {
+		t.Errorf("Begin() on a cancelled policy = %v", err)
+	}
+	if state := book.List("POL-2")[0].State; state != PolicyExpired {
+		t.Errorf("cancelling changed an expired period to %s", state)
+	}
+}
+
+func TestPolicyKey_Defaults(t *testing.T) {
+	req := WeatherVerificationRequest{PolicyID: "P", Timestamp: 7200 + 59}
+	if key := req.policyKey(time.Hour, time.Now()); key != (PolicyKey{PolicyID: "P", Peril: "weather", Start: 7200, End: 10800}) {
+		t.Errorf("verification key = %+v", key)
+	}
+	req.Type, req.Payout = TaskTypePayout, &PayoutTerms{Variable: "precipitation"}
+	if key := req.policyKey(time.Hour, time.Now()); key.Peril != "precipitation" {
+		t.Errorf("payout peril = %q", key.Peril)
+	}
+}
+
+func TestRun_Policy(t *testing.T) {
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+	if _, err := worker.HandleTask(payoutTask("task-1", "40")); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/policies", requireTenantKey(dryRunKey, worker.tenants, worker.policies.policiesHandler, http.MethodGet))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"policy", "list", "-addr", srv.URL, "-api-key", ""}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("policy list without an API key = %d", code)
+	}
+	if code := run([]string{"policy", "list", "-addr", srv.URL, "-api-key", dryRunKey}, &stdout, &stderr); code != exitOK ||
+		!strings.Contains(stdout.String(), "POL-1") || !strings.Contains(stdout.String(), "settled") {
+		t.Errorf("policy list = %d, %q %s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"policy", "cancel", "-addr", srv.URL, "-api-key", "", "-policy", "POL-1"}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("policy cancel without an API key = %d", code)
+	}
+	if code := run([]string{"policy", "cancel", "-addr", srv.URL, "-api-key", dryRunKey, "-policy", "POL-1"}, &stdout, &stderr); code != exitOK {
+		t.Errorf("policy cancel = %d, %s", code, stderr.String())
+	}
+	// Settled periods stay settled, so the original result is still returned
+	if _, err := worker.HandleTask(payoutTask("task-2", "90")); err != nil {
+		t.Errorf("settled period after cancellation: %v", err)
+	}
+	if code := run([]string{"policy", "cancel", "-addr", srv.URL}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("policy cancel without -policy = %d", code)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Proxy modes. ponos's rpcServer only listens on plain TCP and its executor
+// and aggregator clients always dial in plaintext, so the ponos executor and
+// aggregator get TLS through a proxy beside them.
+const (
+	// proxyTerminate accepts TLS (mTLS with a client CA) and forwards in
+	// plaintext, in front of a ponos executor or aggregator rpcServer
+	proxyTerminate = "terminate"
+	// proxyOriginate accepts plaintext and forwards over TLS, for a ponos
+	// client that cannot be given certificates
+	proxyOriginate = "originate"
+)
+
+// tlsProxy forwards gRPC connections between a plaintext and a TLS side.
+// Certificates come from a certReloader, so they rotate without a restart.
+type tlsProxy struct {
+	mode     string
+	upstream string
+	certs    *certReloader
+	timeout  time.Duration
+	logger   *zap.Logger
+	wg       sync.WaitGroup
+}
+
+// h2 is the ALPN protocol gRPC requires on TLS connections
+var h2 = []string{"h2"}
+
+func newTLSProxy(mode, upstream string, certs *certReloader, timeout time.Duration, logger *zap.Logger) (*tlsProxy, error) {
+	if mode != proxyTerminate && mode != proxyOriginate {
+		return nil, fmt.Errorf("unknown proxy mode %q (want %s or %s)", mode, proxyTerminate, proxyOriginate)
+	}
+	if mode == proxyTerminate && certs.cfg.CertFile == "" {
+		return nil, errors.New("terminating TLS requires a certificate and key")
+	}
+	return &tlsProxy{mode: mode, upstream: upstream, certs: certs, timeout: timeout, logger: logger}, nil
+}
+
+// serverTLS is the reloadable server configuration with gRPC's ALPN
+func (p *tlsProxy) serverTLS() *tls.Config {
+	cfg := p.certs.ServerTLS()
+	perClient := cfg.GetConfigForClient
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		c, err := perClient(hello)
+		if c != nil {
+			c.NextProtos = h2
+		}
+		return c, err
+	}
+	return cfg
+}
+
+// Serve accepts connections on lis until ctx is done, then closes the open
+// connections and waits for them
+func (p *tlsProxy) Serve(ctx context.Context, lis net.Listener) error {
+	if p.mode == proxyTerminate {
+		lis = tls.NewListener(lis, p.serverTLS())
+	}
+	go func() {
+		<-ctx.Done()
+		lis.Close()
+	}()
+	defer p.wg.Wait()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.forward(ctx, conn)
+		}()
+	}
+}
+
+func (p *tlsProxy) forward(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	dialCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.HandshakeContext(dialCtx); err != nil {
+			p.logger.Warn("TLS handshake failed", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			return
+		}
+	}
+	up, err := p.dial(dialCtx)
+	if err != nil {
+		p.logger.Warn("Failed to reach upstream", zap.String("upstream", p.upstream), zap.Error(err))
+		return
+	}
+	defer up.Close()
+	stopUp := context.AfterFunc(ctx, func() { up.Close() })
+	defer stopUp()
+	cancel()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(up, conn)
+	go pipe(conn, up)
+	<-done
+	<-done
+}
+
+func (p *tlsProxy) dial(ctx context.Context) (net.Conn, error) {
+	if p.mode == proxyTerminate {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", p.upstream)
+	}
+	cfg := p.certs.ClientTLS()
+	cfg.NextProtos = h2
+	d := tls.Dialer{Config: cfg}
+	return d.DialContext(ctx, "tcp", p.upstream)
+}
+
+func cmdTLSProxy(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tls-proxy", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	mode := fs.String("mode", proxyTerminate, "terminate: accept TLS, forward plaintext; originate: accept plaintext, forward TLS")
+	listen := fs.String("listen", "", "address to listen on")
+	upstream := fs.String("upstream", "", "address to forward to")
+	timeout := fs.Duration("timeout", 10*time.Second, "TLS handshake and upstream dial timeout")
+	tlsCfg := TLSConfig{}
+	fs.StringVar(&tlsCfg.CertFile, "tls-cert", "", "certificate presented to the TLS side (required to terminate, for mTLS to originate)")
+	fs.StringVar(&tlsCfg.KeyFile, "tls-key", "", "key for -tls-cert")
+	fs.StringVar(&tlsCfg.CAFile, "tls-ca", "", "terminate: CA required of client certificates; originate: CA verifying the upstream (default: system roots)")
+	fs.StringVar(&tlsCfg.ServerName, "tls-server-name", "", "name to verify on the upstream certificate (originate)")
+	fs.DurationVar(&tlsCfg.ReloadInterval, "tls-reload", 30*time.Second, "how often the certificate files are checked for changes")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *listen == "" || *upstream == "" {
+		fmt.Fprintln(stderr, "tls-proxy: -listen and -upstream are required")
+		return exitUsage
+	}
+	if (tlsCfg.CertFile == "") != (tlsCfg.KeyFile == "") {
+		fmt.Fprintln(stderr, "tls-proxy: -tls-cert and -tls-key must be set together")
+		return exitUsage
+	}
+	if tlsCfg.ReloadInterval <= 0 || *timeout <= 0 {
+		fmt.Fprintln(stderr, "tls-proxy: -tls-reload and -timeout must be positive")
+		return exitUsage
+	}
+
+	// The proxy runs beside ponos, not the performer, so only the logging
+	// settings are read from the environment
+	logger, err := NewLogger(&Config{Env: os.Getenv("ENV"), LogLevel: os.Getenv("LOG_LEVEL")})
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create logger: %v\n", err)
+		return exitUsage
+	}
+	defer logger.Sync()
+
+	certs, err := newCertReloader(tlsCfg, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "tls-proxy: %v\n", err)
+		return exitUsage
+	}
+	proxy, err := newTLSProxy(*mode, *upstream, certs, *timeout, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "tls-proxy: %v\n", err)
+		return exitUsage
+	}
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(stderr, "tls-proxy: %v\n", err)
+		return exitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	logger.Info("Starting TLS proxy",
+		zap.String("mode", *mode),
+		zap.String("listen", lis.Addr().String()),
+		zap.String("upstream", *upstream),
+		zap.Bool("mtls", (*mode == proxyTerminate && tlsCfg.CAFile != "") || (*mode == proxyOriginate && tlsCfg.CertFile != "")),
+	)
+	if err := proxy.Serve(ctx, lis); err != nil {
+		fmt.Fprintf(stderr, "tls-proxy: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
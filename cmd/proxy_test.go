@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	executorV1 "github.com/Layr-Labs/hourglass-monorepo/ponos/gen/protos/eigenlayer/hourglass/v1/executor"
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// echoExecutor answers SubmitTask with the task's payload, like a ponos
+// executor behind its plaintext rpcServer
+type echoExecutor struct {
+	executorV1.UnimplementedExecutorServiceServer
+}
+
+func (echoExecutor) SubmitTask(ctx context.Context, task *executorV1.TaskSubmission) (*executorV1.TaskResult, error) {
+	return &executorV1.TaskResult{TaskId: task.TaskId, Output: task.Payload}, nil
+}
+
+// startPlaintextExecutor serves echoExecutor without TLS
+func startPlaintextExecutor(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	executorV1.RegisterExecutorServiceServer(srv, echoExecutor{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+// startProxy runs a proxy to upstream until the test ends; the returned
+// channel receives Serve's error after stop
+func startProxy(t *testing.T, mode, upstream string, cfg TLSConfig) (string, context.CancelFunc, chan error) {
+	t.Helper()
+	certs, err := newCertReloader(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, err := newTLSProxy(mode, upstream, certs, 5*time.Second, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- proxy.Serve(ctx, lis) }()
+	t.Cleanup(stop)
+	return lis.Addr().String(), stop, done
+}
+
+func TestTLSProxy_TerminatesMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "operators")
+	caPath := ca.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := ca.issue(t, dir, "executor", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "aggregator", x509.ExtKeyUsageClientAuth)
+	addr, _, _ := startProxy(t, proxyTerminate, startPlaintextExecutor(t),
+		TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: caPath, ReloadInterval: time.Hour})
+
+	submit := func(cfg TLSConfig) (*executorV1.TaskResult, error) {
+		cfg.ReloadInterval = time.Hour
+		certs, err := newCertReloader(cfg, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := NewExecutorClient(addr, certs.ClientTLS())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return client.SubmitTask(ctx, &executorV1.TaskSubmission{TaskId: "0x01", Payload: []byte("payload")})
+	}
+
+	res, err := submit(TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caPath, ServerName: "localhost"})
+	if err != nil {
+		t.Fatalf("SubmitTask over mTLS: %v", err)
+	}
+	if res.TaskId != "0x01" || string(res.Output) != "payload" {
+		t.Errorf("SubmitTask = %+v", res)
+	}
+	if _, err := submit(TLSConfig{CAFile: caPath, ServerName: "localhost"}); err == nil {
+		t.Error("SubmitTask without a client certificate succeeded")
+	}
+}
+
+func TestTLSProxy_OriginatesTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "operators")
+	caPath := ca.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := ca.issue(t, dir, "performer", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "executor", x509.ExtKeyUsageClientAuth)
+	performer, _ := startTLSPerformer(t, TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: caPath, ReloadInterval: time.Hour})
+
+	// A plaintext client, as ponos's executor dials its performer, reaches the
+	// mTLS performer through the proxy
+	addr, stop, done := startProxy(t, proxyOriginate, performer,
+		TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caPath, ServerName: "localhost", ReloadInterval: time.Hour})
+	client, err := NewPerformerClient(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.HealthCheck(ctx, &performerV1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("HealthCheck through the proxy: %v", err)
+	}
+	if resp.Status != performerV1.PerformerStatus_READY_FOR_TASK {
+		t.Errorf("HealthCheck = %v", resp.Status)
+	}
+
+	// Stopping the proxy closes the client's open connection
+	stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return with a connection open")
+	}
+}
+
+func TestNewTLSProxy_Invalid(t *testing.T) {
+	certs, err := newCertReloader(TLSConfig{ReloadInterval: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTLSProxy("passthrough", "localhost:1", certs, time.Second, zap.NewNop()); err == nil {
+		t.Error("unknown mode accepted")
+	}
+	if _, err := newTLSProxy(proxyTerminate, "localhost:1", certs, time.Second, zap.NewNop()); err == nil {
+		t.Error("terminating without a certificate accepted")
+	}
+	if _, err := newTLSProxy(proxyOriginate, "localhost:1", certs, time.Second, zap.NewNop()); err != nil {
+		t.Errorf("originating with system roots: %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// QuoteConfig configures premium quoting
+type QuoteConfig struct {
+	// Years is how many past years the policy is run against
+	Years int `json:"years"`
+	// VaRLevelBps is the quantile the VaR is taken at
+	VaRLevelBps uint32         `json:"var_level_bps"`
+	Loading     payout.Loading `json:"loading"`
+	// PerMinute and MaxConcurrent bound POST /quote, as each quote reads
+	// years of archived data
+	PerMinute     int `json:"per_minute"`
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// DefaultQuoteConfig prices against 20 years at a 95% VaR with no loading,
+// serving 30 quotes a minute and 2 at a time
+func DefaultQuoteConfig() QuoteConfig {
+	return QuoteConfig{Years: 20, VaRLevelBps: 9500, PerMinute: 30, MaxConcurrent: 2}
+}
+
+// Validate checks the quoting settings
+func (c QuoteConfig) Validate() error {
+	if c.Years <= 0 || c.Years > 100 {
+		return fmt.Errorf("QUOTE_YEARS must be in 1-100")
+	}
+	if c.VaRLevelBps == 0 || c.VaRLevelBps > payout.MaxBps {
+		return fmt.Errorf("QUOTE_VAR_LEVEL_BPS must be in 1-%d", payout.MaxBps)
+	}
+	if err := c.Loading.Validate(); err != nil {
+		return fmt.Errorf("invalid quote loading: %w", err)
+	}
+	if c.PerMinute <= 0 {
+		return fmt.Errorf("QUOTE_RATE_PER_MINUTE must be positive")
+	}
+	if c.MaxConcurrent <= 0 {
+		return fmt.Errorf("QUOTE_MAX_CONCURRENT must be positive")
+	}
+	return nil
+}
+
+// QuoteRequest asks for a quote on a proposed payout policy
+type QuoteRequest struct {
+	// Task is the payout task the policy would submit. The performer pays on
+	// the one observation at its timestamp, so that is what is priced.
+	Task WeatherVerificationRequest `json:"task"`
+	// Years, VaRLevelBps and Loading override the configured defaults
+	Years       int             `json:"years,omitempty"`
+	VaRLevelBps uint32          `json:"var_level_bps,omitempty"`
+	Loading     *payout.Loading `json:"loading,omitempty"`
+}
+
+// QuoteYear is the policy's payout in one past year
+type QuoteYear struct {
+	Year int `json:"year"`
+	// Date is the day of the reading the task would have observed
+	Date   string         `json:"date"`
+	Source string         `json:"source"`
+	Result *payout.Result `json:"result"`
+}
+
+// QuoteReport is a burn analysis of a proposed policy
+type QuoteReport struct {
+	PolicyID string `json:"policy_id,omitempty"`
+	Variable string `json:"variable"`
+	// ObservedAt is the task timestamp, in the policy's own year, the index
+	// is observed at
+	ObservedAt int64       `json:"observed_at"`
+	Years      []QuoteYear `json:"years"`
+	// MissingYears had no archived data in the window and are not priced
+	MissingYears []int         `json:"missing_years,omitempty"`
+	Quote        *payout.Quote `json:"quote"`
+}
+
+// Quoter prices proposed policies by running their payout structure
+// against archived data, with the same index and payout code the performer
+// signs with. Like the performer, each past year pays on the one reading at
+// the task's timestamp.
+type Quoter struct {
+	cfg      QuoteConfig
+	archives []HistoricalProvider
+	logger   *zap.Logger
+	now      func() time.Time
+	// limiter and slots bound the quotes served over HTTP
+	limiter *rate.Limiter
+	slots   chan struct{}
+}
+
+// NewQuoter returns a quoter reading archived data from the historical providers among providers
+func NewQuoter(cfg QuoteConfig, providers []WeatherProvider, logger *zap.Logger) *Quoter {
+	q := &Quoter{
+		cfg:     cfg,
+		logger:  logger,
+		now:     time.Now,
+		limiter: rate.NewLimiter(rate.Limit(float64(cfg.PerMinute)/60), cfg.PerMinute),
+		slots:   make(chan struct{}, cfg.MaxConcurrent),
+	}
+	for _, p := range providers {
+		if hp, ok := p.(HistoricalProvider); ok {
+			q.archives = append(q.archives, hp)
+		}
+	}
+	return q
+}
+
+// Quote runs the burn analysis for req
+func (q *Quoter) Quote(ctx context.Context, req *QuoteRequest) (*QuoteReport, error) {
+	task := &req.Task
+	if err := task.Validate(); err != nil {
+		return nil, err
+	}
+	if task.Type != TaskTypePayout || task.Payout.Variable == "" {
+		return nil, errors.New("a quote needs a payout task with an index variable")
+	}
+	// Without a timestamp the performer reads the conditions when the task
+	// runs, which cannot be priced in advance
+	if task.Timestamp == 0 {
+		return nil, errors.New("a quote needs the task's timestamp, when the performer observes the index")
+	}
+	if len(q.archives) == 0 {
+		return nil, errors.New("no archived data configured (GRID_DIR or STATION_CATALOGUE)")
+	}
+	cfg := q.cfg
+	if req.Years != 0 {
+		cfg.Years = req.Years
+	}
+	if req.VaRLevelBps != 0 {
+		cfg.VaRLevelBps = req.VaRLevelBps
+	}
+	if req.Loading != nil {
+		cfg.Loading = *req.Loading
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := q.now()
+	at := time.Unix(task.Timestamp, 0).UTC()
+	report := &QuoteReport{PolicyID: task.PolicyID, Variable: task.Payout.Variable, ObservedAt: task.Timestamp}
+	var periods []*payout.Result
+	for y := 1; y <= cfg.Years; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		yearAt := at.AddDate(-y, 0, 0)
+		if yearAt.After(now) {
+			continue
+		}
+		year, err := q.burnYear(ctx, task.Location, task.Payout, yearAt)
+		if err != nil {
+			return nil, err
+		}
+		if year == nil {
+			report.MissingYears = append(report.MissingYears, yearAt.Year())
+			continue
+		}
+		report.Years = append(report.Years, *year)
+		periods = append(periods, year.Result)
+	}
+	if len(periods) == 0 {
+		return nil, fmt.Errorf("no archived %s data for the location in the last %d years", task.Payout.Variable, cfg.Years)
+	}
+
+	quote, err := payout.Burn(task.Payout.Structure, periods, cfg.VaRLevelBps, cfg.Loading)
+	if err != nil {
+		return nil, err
+	}
+	report.Quote = quote
+	q.logger.Info("Quoted policy",
+		zap.String("policyId", task.PolicyID),
+		zap.Int("years", quote.Periods),
+		zap.Int("triggered", quote.Triggered),
+		zap.String("premium", quote.Premium.String()),
+	)
+	return report, nil
+}
+
+// burnYear returns what the policy would have paid on the reading at at, or
+// nil if the archives hold no such reading
+func (q *Quoter) burnYear(ctx context.Context, location Location, terms *PayoutTerms, at time.Time) (*QuoteYear, error) {
+	data := q.fetchArchived(ctx, location, at)
+	if data == nil {
+		return nil, nil
+	}
+	index, err := weatherIndex(data, terms.Variable)
+	if err != nil {
+		return nil, nil
+	}
+	r, err := payout.Calculate(terms.Structure, index)
+	if err != nil {
+		return nil, err
+	}
+	return &QuoteYear{Year: at.Year(), Date: at.Format("2006-01-02"), Source: data.Source, Result: r}, nil
+}
+
+// fetchArchived returns the first archive's data for day, or nil
+func (q *Quoter) fetchArchived(ctx context.Context, location Location, day time.Time) *WeatherData {
+	for _, archive := range q.archives {
+		data, err := archive.FetchAt(ctx, location, day)
+		if err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// quoteHandler serves POST /quote
+func (q *Quoter) quoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !q.limiter.Allow() {
+		http.Error(w, "quote rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	select {
+	case q.slots <- struct{}{}:
+		defer func() { <-q.slots }()
+	default:
+		http.Error(w, "too many quotes in progress", http.StatusTooManyRequests)
+		return
+	}
+	var req QuoteRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid quote request: %v", err), http.StatusBadRequest)
+		return
+	}
+	report, err := q.Quote(r.Context(), &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func cmdQuote(args []string, stdout, stderr io.Writer) int {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("quote", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	taskPath := fs.String("task", "", "path to the proposed payout task JSON, or - for stdin (required)")
+	fs.IntVar(&cfg.Quote.Years, "years", cfg.Quote.Years, "past years to price against (QUOTE_YEARS)")
+	varLevel := fs.Uint("var-level-bps", uint(cfg.Quote.VaRLevelBps), "VaR quantile in basis points (QUOTE_VAR_LEVEL_BPS)")
+	fs.StringVar(&cfg.Grid.Dir, "grid", cfg.Grid.Dir, "directory of daily precipitation rasters (GRID_DIR)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	cfg.Quote.VaRLevelBps = uint32(*varLevel)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return exitUsage
+	}
+	if *taskPath == "" {
+		fmt.Fprintln(stderr, "quote: -task is required")
+		return exitUsage
+	}
+	payload, err := readPayloadFile(*taskPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "quote: failed to read task: %v\n", err)
+		return exitFailure
+	}
+	var req QuoteRequest
+	if err := json.Unmarshal(payload, &req.Task); err != nil {
+		fmt.Fprintf(stderr, "quote: invalid task payload: %v\n", err)
+		return exitUsage
+	}
+
+	logger, err := NewLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create logger: %v\n", err)
+		return exitFailure
+	}
+	defer logger.Sync()
+	archives, err := archiveProviders(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "quote: %v\n", err)
+		return exitFailure
+	}
+
+	report, err := NewQuoter(cfg.Quote, archives, logger).Quote(context.Background(), &req)
+	if err != nil {
+		fmt.Fprintf(stderr, "quote: %v\n", err)
+		return exitFailure
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "YEAR\tOBSERVED\tINDEX\tPAYOUT\tSOURCE")
+	for _, y := range report.Years {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", y.Year, y.Date, y.Result.Index, y.Result.Payout, y.Source)
+	}
+	tw.Flush()
+	quote := report.Quote
+	fmt.Fprintf(stdout, "\nyears priced:      %d (missing %v)\n", quote.Periods, report.MissingYears)
+	fmt.Fprintf(stdout, "payout frequency:  %d/%d (%d bps)\n", quote.Triggered, quote.Periods, quote.FrequencyBps)
+	fmt.Fprintf(stdout, "expected loss:     %s\n", quote.ExpectedLoss)
+	fmt.Fprintf(stdout, "VaR (%d bps):    %s\n", quote.VaRLevelBps, quote.VaR)
+	fmt.Fprintf(stdout, "tail expectation:  %s\n", quote.TailExpectation)
+	fmt.Fprintf(stdout, "premium:           %s (%d bps rate on line)\n", quote.Premium, quote.RateOnLineBps)
+	return exitOK
+}
+
+// archiveProviders returns the historical providers configured in cfg,
+// stations first as the performer orders them
+func archiveProviders(cfg *Config) ([]WeatherProvider, error) {
+	var providers []WeatherProvider
+	if cfg.Stations.Catalogue != "" {
+		stations, err := NewStationProvider(cfg.Stations)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, stations)
+	}
+	if cfg.Grid.Dir != "" {
+		gridded, err := NewGriddedProvider(cfg.Grid)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, gridded)
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no archived data configured: set GRID_DIR or STATION_CATALOGUE, or pass -grid")
+	}
+	return providers, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// archiveProvider serves daily precipitation from a map keyed by date
+type archiveProvider struct {
+	rain map[string]float64
+}
+
+func (p *archiveProvider) Name() string { return "archive" }
+
+func (p *archiveProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	return nil, fmt.Errorf("no current conditions")
+}
+
+func (p *archiveProvider) FetchAt(ctx context.Context, location Location, at time.Time) (*WeatherData, error) {
+	v, ok := p.rain[at.Format("2006-01-02")]
+	if !ok {
+		return nil, fmt.Errorf("no data for %s", at.Format("2006-01-02"))
+	}
+	return &WeatherData{Precipitation: &v, Source: p.Name(), Timestamp: at}, nil
+}
+
+// quoteRequest is a three-day flood policy paying 0-1000 as daily rain goes from 50 to 150 mm
+const quoteRequest = `{"task": {"type": "payout", "policy_id": "POL-Q", "location": {"latitude": 1, "longitude": 2},
+	"coverage": {"start": 1751328000, "end": 1751587200}, "timestamp": 1751414400,
+	"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "50", "exhaustion": "150", "sum_insured": "1000"}}},
+	"years": 4, "var_level_bps": 7500, "loading": {"expense_bps": 1000}}`
+
+func newTestQuoter() *Quoter {
+	// Around July 2 of each past year; 2022 has no data
+	archive := &archiveProvider{rain: map[string]float64{
+		"2024-07-01": 10, "2024-07-02": 100, "2024-07-03": 140,
+		"2023-07-01": 150, "2023-07-02": 0, "2023-07-03": 20,
+		"2021-07-02": 200,
+	}}
+	q := NewQuoter(DefaultQuoteConfig(), []WeatherProvider{&staticProvider{}, archive}, zap.NewNop())
+	q.now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+	return q
+}
+
+func TestQuoter_Quote(t *testing.T) {
+	var req QuoteRequest
+	if err := json.Unmarshal([]byte(quoteRequest), &req); err != nil {
+		t.Fatal(err)
+	}
+	report, err := newTestQuoter().Quote(context.Background(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var years []string
+	for _, y := range report.Years {
+		years = append(years, fmt.Sprintf("%d:%s:%s", y.Year, y.Date, y.Result.Payout))
+	}
+	// Only the reading on the task's day counts, not the worst of the coverage period
+	if got := strings.Join(years, " "); got != "2024:2024-07-02:500 2023:2023-07-02:0 2021:2021-07-02:1000" {
+		t.Errorf("years = %s", got)
+	}
+	if len(report.MissingYears) != 1 || report.MissingYears[0] != 2022 {
+		t.Errorf("missing years = %v", report.MissingYears)
+	}
+	quote := report.Quote
+	// Payouts 0, 500, 1000: mean 500, VaR at 75% is the 3rd of 3
+	if quote.Periods != 3 || quote.Triggered != 2 || quote.ExpectedLoss.String() != "500" ||
+		quote.VaR.String() != "1000" || quote.Premium.String() != "550" {
+		t.Errorf("quote = %+v", quote)
+	}
+}
+
+func TestQuoter_Rejects(t *testing.T) {
+	q := newTestQuoter()
+	for name, body := range map[string]string{
+		"verification task": `{"task": {"policy_id": "P", "location": {"latitude": 1, "longitude": 2}}}`,
+		"precomputed index": `{"task": {"type": "payout", "policy_id": "P", "location": {"latitude": 1, "longitude": 2},
+			"payout": {"index": "1", "structure": {"kind": "linear", "attachment": "50", "exhaustion": "150", "sum_insured": "1000"}}}}`,
+		"no timestamp": `{"task": {"type": "payout", "policy_id": "P", "location": {"latitude": 1, "longitude": 2},
+			"coverage": {"start": 1751328000, "end": 1751587200},
+			"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "50", "exhaustion": "150", "sum_insured": "1000"}}}}`,
+		"no data": `{"task": {"type": "payout", "policy_id": "P", "location": {"latitude": 1, "longitude": 2}, "timestamp": 1700000000,
+			"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "50", "exhaustion": "150", "sum_insured": "1000"}}}}`,
+	} {
+		var req QuoteRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Quote(context.Background(), &req); err == nil {
+			t.Errorf("%s: Quote() succeeded", name)
+		}
+	}
+
+	if _, err := NewQuoter(DefaultQuoteConfig(), nil, zap.NewNop()).Quote(context.Background(), &QuoteRequest{}); err == nil {
+		t.Error("Quote() without archives succeeded")
+	}
+}
+
+func TestQuoter_Handler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newTestQuoter().quoteHandler))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(quoteRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report QuoteReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /quote = %d, %v", resp.StatusCode, err)
+	}
+	if report.Quote.Premium.String() != "550" {
+		t.Errorf("premium = %s", report.Quote.Premium)
+	}
+
+	if resp, _ := http.Post(srv.URL, "application/json", strings.NewReader(`{"task": {}}`)); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("invalid task = %d", resp.StatusCode)
+	}
+	if resp, _ := http.Get(srv.URL); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET = %d", resp.StatusCode)
+	}
+}
+
+func TestQuoter_HandlerLimits(t *testing.T) {
+	q := newTestQuoter()
+	q.limiter = rate.NewLimiter(rate.Limit(1.0/60), 2)
+	q.slots = make(chan struct{}, 1)
+	worker := newTenantWorker(t)
+	srv := httptest.NewServer(requireTenantKey(dryRunKey, worker.tenants, q.quoteHandler, http.MethodPost))
+	defer srv.Close()
+
+	post := func(key string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(quoteRequest))
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post(""); code != http.StatusUnauthorized {
+		t.Errorf("quote without a key = %d", code)
+	}
+	// A quote in progress fills the only slot
+	q.slots <- struct{}{}
+	if code := post(dryRunKey); code != http.StatusTooManyRequests {
+		t.Errorf("quote with no free slot = %d", code)
+	}
+	<-q.slots
+	if code := post(acmeKey); code != http.StatusOK {
+		t.Errorf("quote with a tenant key = %d", code)
+	}
+	// The two keyed requests used up the burst
+	if code := post(dryRunKey); code != http.StatusTooManyRequests {
+		t.Errorf("quote over the rate limit = %d", code)
+	}
+}
+
+func TestRun_Quote(t *testing.T) {
+	grid := writeGridDir(t)
+	task := filepath.Join(t.TempDir(), "task.json")
+	os.WriteFile(task, []byte(`{"type": "payout", "policy_id": "POL-NBO", "location": {"latitude": -0.875, "longitude": 36.625},
+		"coverage": {"start": 1743465600, "end": 1743638400}, "timestamp": 1743465600,
+		"payout": {"variable": "precipitation", "structure": {"kind": "linear", "attachment": "0", "exhaustion": "100", "sum_insured": "1000"}}}`), 0o644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"quote", "-task", task, "-grid", grid, "-years", "2", "-json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("quote = %d: %s", code, stderr.String())
+	}
+	var report QuoteReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	// 2024-04-01, the task's day a year back, read 12 mm at the point
+	if len(report.Years) != 1 || report.Years[0].Date != "2024-04-01" || report.Quote.ExpectedLoss.String() != "120" {
+		t.Errorf("report = %s", stdout.String())
+	}
+
+	if code := run([]string{"quote", "-task", task}, &stdout, &stderr); code != exitFailure {
+		t.Errorf("quote without archived data = %d", code)
+	}
+}
@@ -0,0 +1,717 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNoGridData is returned when a location or region has no valid cells
+var ErrNoGridData = errors.New("no valid grid data")
+
+// Grid is a single-band raster on a regular latitude/longitude grid.
+// Cell (row, col) is centred on (Lat0 + row*DLat, Lon0 + col*DLon); missing
+// values are NaN.
+type Grid struct {
+	Width  int
+	Height int
+	Lon0   float64
+	Lat0   float64
+	DLon   float64
+	DLat   float64
+	Data   []float64
+}
+
+// BoundingBox is a latitude/longitude rectangle
+type BoundingBox struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+}
+
+// Validate checks the box corners
+func (b *BoundingBox) Validate() error {
+	if b.MinLatitude < -90 || b.MaxLatitude > 90 || b.MinLatitude > b.MaxLatitude {
+		return fmt.Errorf("invalid region latitudes: %f to %f", b.MinLatitude, b.MaxLatitude)
+	}
+	if b.MinLongitude < -180 || b.MaxLongitude > 180 || b.MinLongitude > b.MaxLongitude {
+		return fmt.Errorf("invalid region longitudes: %f to %f", b.MinLongitude, b.MaxLongitude)
+	}
+	return nil
+}
+
+func (g *Grid) at(row, col int) float64 {
+	return g.Data[row*g.Width+col]
+}
+
+// position returns the fractional row and column of a coordinate
+func (g *Grid) position(lat, lon float64) (row, col float64, err error) {
+	row = (lat - g.Lat0) / g.DLat
+	col = (lon - g.Lon0) / g.DLon
+	if row < -0.5 || row > float64(g.Height)-0.5 || col < -0.5 || col > float64(g.Width)-0.5 {
+		return 0, 0, fmt.Errorf("location %.4f,%.4f is outside the grid", lat, lon)
+	}
+	return row, col, nil
+}
+
+// Nearest returns the value of the cell containing a coordinate
+func (g *Grid) Nearest(lat, lon float64) (float64, error) {
+	row, col, err := g.position(lat, lon)
+	if err != nil {
+		return 0, err
+	}
+	r := clampIndex(int(math.Round(row)), g.Height)
+	c := clampIndex(int(math.Round(col)), g.Width)
+	v := g.at(r, c)
+	if math.IsNaN(v) {
+		return 0, ErrNoGridData
+	}
+	return v, nil
+}
+
+// Bilinear interpolates between the four cell centres around a coordinate.
+// Missing corners are dropped and the remaining weights renormalised.
+func (g *Grid) Bilinear(lat, lon float64) (float64, error) {
+	row, col, err := g.position(lat, lon)
+	if err != nil {
+		return 0, err
+	}
+	r0 := clampIndex(int(math.Floor(row)), g.Height)
+	c0 := clampIndex(int(math.Floor(col)), g.Width)
+	r1 := clampIndex(r0+1, g.Height)
+	c1 := clampIndex(c0+1, g.Width)
+	fr := math.Min(math.Max(row-float64(r0), 0), 1)
+	fc := math.Min(math.Max(col-float64(c0), 0), 1)
+
+	corners := []struct {
+		v, w float64
+	}{
+		{g.at(r0, c0), (1 - fr) * (1 - fc)},
+		{g.at(r0, c1), (1 - fr) * fc},
+		{g.at(r1, c0), fr * (1 - fc)},
+		{g.at(r1, c1), fr * fc},
+	}
+	var sum, weight float64
+	for _, corner := range corners {
+		if math.IsNaN(corner.v) || corner.w == 0 {
+			continue
+		}
+		sum += corner.v * corner.w
+		weight += corner.w
+	}
+	if weight == 0 {
+		return 0, ErrNoGridData
+	}
+	return sum / weight, nil
+}
+
+// RegionMean averages the valid cells whose centres fall inside box and
+// returns the mean and the number of cells used
+func (g *Grid) RegionMean(box BoundingBox) (float64, int, error) {
+	var sum float64
+	var n int
+	for row := 0; row < g.Height; row++ {
+		lat := g.Lat0 + float64(row)*g.DLat
+		if lat < box.MinLatitude || lat > box.MaxLatitude {
+			continue
+		}
+		for col := 0; col < g.Width; col++ {
+			lon := g.Lon0 + float64(col)*g.DLon
+			if lon < box.MinLongitude || lon > box.MaxLongitude {
+				continue
+			}
+			if v := g.at(row, col); !math.IsNaN(v) {
+				sum += v
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0, 0, ErrNoGridData
+	}
+	return sum / float64(n), n, nil
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// ReadRaster decodes a GeoTIFF (.tif, .tiff) or classic NetCDF (.nc) file,
+// optionally gzip-compressed (.gz). variable selects the NetCDF variable.
+func ReadRaster(path, variable string) (*Grid, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read raster: %w", err)
+	}
+	name := strings.ToLower(path)
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	var grid *Grid
+	switch {
+	case strings.HasSuffix(name, ".tif"), strings.HasSuffix(name, ".tiff"):
+		grid, err = decodeGeoTIFF(data)
+	case strings.HasSuffix(name, ".nc"):
+		grid, err = decodeNetCDF(data, variable)
+	default:
+		return nil, fmt.Errorf("unsupported raster format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return grid, nil
+}
+
+// TIFF tags used by decodeGeoTIFF
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffPredictor       = 317
+	tiffTileWidth       = 322
+	tiffTileLength      = 323
+	tiffTileOffsets     = 324
+	tiffTileByteCounts  = 325
+	tiffSampleFormat    = 339
+	geoPixelScale       = 33550
+	geoTiepoint         = 33922
+	geoTransformation   = 34264
+	gdalNoData          = 42113
+)
+
+// rasterField is a decoded TIFF tag or NetCDF attribute
+type rasterField struct {
+	nums []float64
+	text string
+}
+
+// decodeGeoTIFF reads the first image of a single-band classic GeoTIFF in
+// geographic coordinates. Uncompressed and deflate strips or tiles are supported.
+func decodeGeoTIFF(data []byte) (*Grid, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+	switch order.Uint16(data[2:4]) {
+	case 42:
+	case 43:
+		return nil, fmt.Errorf("BigTIFF is not supported")
+	default:
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+
+	fields, err := readIFD(data, order, order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+	num := func(tag uint16, def float64) float64 {
+		if f, ok := fields[tag]; ok && len(f.nums) > 0 {
+			return f.nums[0]
+		}
+		return def
+	}
+
+	width, height := int(num(tiffImageWidth, 0)), int(num(tiffImageLength, 0))
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	if spp := num(tiffSamplesPerPixel, 1); spp != 1 {
+		return nil, fmt.Errorf("expected a single band, got %v samples per pixel", spp)
+	}
+	if p := num(tiffPredictor, 1); p != 1 {
+		return nil, fmt.Errorf("predictor %v is not supported", p)
+	}
+	compression := int(num(tiffCompression, 1))
+	if compression != 1 && compression != 8 && compression != 32946 {
+		return nil, fmt.Errorf("compression %d is not supported", compression)
+	}
+	sample, err := sampleDecoder(int(num(tiffBitsPerSample, 8)), int(num(tiffSampleFormat, 1)), order)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := &Grid{Width: width, Height: height, Data: make([]float64, width*height)}
+	if err := georeference(grid, fields); err != nil {
+		return nil, err
+	}
+
+	// Blocks are strips (full-width tiles) or tiles, read in row-major order
+	blockW, blockH := width, int(num(tiffRowsPerStrip, float64(height)))
+	offsets, counts := fields[tiffStripOffsets], fields[tiffStripByteCounts]
+	if _, tiled := fields[tiffTileOffsets]; tiled {
+		blockW, blockH = int(num(tiffTileWidth, 0)), int(num(tiffTileLength, 0))
+		offsets, counts = fields[tiffTileOffsets], fields[tiffTileByteCounts]
+	}
+	if blockW <= 0 || blockH <= 0 || len(offsets.nums) == 0 || len(offsets.nums) != len(counts.nums) {
+		return nil, fmt.Errorf("missing or inconsistent image blocks")
+	}
+	across := (width + blockW - 1) / blockW
+
+	for i, off := range offsets.nums {
+		start, end := int(off), int(off)+int(counts.nums[i])
+		if start < 0 || end > len(data) || start > end {
+			return nil, fmt.Errorf("image block %d is out of range", i)
+		}
+		block := data[start:end]
+		if compression != 1 {
+			zr, err := zlib.NewReader(bytes.NewReader(block))
+			if err != nil {
+				return nil, fmt.Errorf("failed to inflate block %d: %w", i, err)
+			}
+			if block, err = io.ReadAll(zr); err != nil {
+				return nil, fmt.Errorf("failed to inflate block %d: %w", i, err)
+			}
+		}
+		top, left := (i/across)*blockH, (i%across)*blockW
+		for y := 0; y < blockH && top+y < height; y++ {
+			for x := 0; x < blockW && left+x < width; x++ {
+				v, ok := sample(block, y*blockW+x)
+				if !ok {
+					return nil, fmt.Errorf("image block %d is truncated", i)
+				}
+				grid.Data[(top+y)*width+left+x] = v
+			}
+		}
+	}
+
+	if f, ok := fields[gdalNoData]; ok {
+		nodata, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimRight(f.text, "\x00")), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nodata value %q", f.text)
+		}
+		markMissing(grid.Data, nodata)
+	}
+	return grid, nil
+}
+
+// readIFD decodes the entries of the image file directory at offset
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) (map[uint16]rasterField, error) {
+	if int(offset)+2 > len(data) {
+		return nil, fmt.Errorf("image directory is out of range")
+	}
+	n := int(order.Uint16(data[offset:]))
+	fields := make(map[uint16]rasterField, n)
+	for i := 0; i < n; i++ {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(data) {
+			return nil, fmt.Errorf("image directory is truncated")
+		}
+		tag := order.Uint16(data[entry:])
+		typ := order.Uint16(data[entry+2:])
+		count := int(order.Uint32(data[entry+4:]))
+
+		size := map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 6: 1, 8: 2, 9: 4, 11: 4, 12: 8}[typ]
+		if size == 0 {
+			continue
+		}
+		value := data[entry+8 : entry+12]
+		if size*count > 4 {
+			at := int(order.Uint32(value))
+			if at < 0 || at+size*count > len(data) {
+				return nil, fmt.Errorf("tag %d is out of range", tag)
+			}
+			value = data[at : at+size*count]
+		}
+
+		var f rasterField
+		if typ == 2 {
+			f.text = string(value[:count])
+		} else {
+			f.nums = make([]float64, count)
+			for j := range f.nums {
+				b := value[j*size:]
+				switch typ {
+				case 1:
+					f.nums[j] = float64(b[0])
+				case 6:
+					f.nums[j] = float64(int8(b[0]))
+				case 3:
+					f.nums[j] = float64(order.Uint16(b))
+				case 8:
+					f.nums[j] = float64(int16(order.Uint16(b)))
+				case 4:
+					f.nums[j] = float64(order.Uint32(b))
+				case 9:
+					f.nums[j] = float64(int32(order.Uint32(b)))
+				case 11:
+					f.nums[j] = float64(math.Float32frombits(order.Uint32(b)))
+				case 12:
+					f.nums[j] = math.Float64frombits(order.Uint64(b))
+				}
+			}
+		}
+		fields[tag] = f
+	}
+	return fields, nil
+}
+
+// georeference sets the grid's cell-centre origin and steps from the GeoTIFF
+// tie point and pixel scale, or from a non-rotated model transformation
+func georeference(grid *Grid, fields map[uint16]rasterField) error {
+	var originLon, originLat, scaleLon, scaleLat float64
+	if t, ok := fields[geoTransformation]; ok && len(t.nums) >= 8 {
+		if t.nums[1] != 0 || t.nums[4] != 0 {
+			return fmt.Errorf("rotated rasters are not supported")
+		}
+		originLon, scaleLon = t.nums[3], t.nums[0]
+		originLat, scaleLat = t.nums[7], -t.nums[5]
+	} else {
+		scale, tie := fields[geoPixelScale], fields[geoTiepoint]
+		if len(scale.nums) < 2 || len(tie.nums) < 6 {
+			return fmt.Errorf("raster is not georeferenced")
+		}
+		scaleLon, scaleLat = scale.nums[0], scale.nums[1]
+		originLon = tie.nums[3] - tie.nums[0]*scaleLon
+		originLat = tie.nums[4] + tie.nums[1]*scaleLat
+	}
+	if scaleLon <= 0 || scaleLat <= 0 {
+		return fmt.Errorf("invalid pixel scale")
+	}
+	// Tie points refer to the top-left corner of the top-left pixel
+	grid.Lon0 = originLon + scaleLon/2
+	grid.Lat0 = originLat - scaleLat/2
+	grid.DLon = scaleLon
+	grid.DLat = -scaleLat
+	return nil
+}
+
+// sampleDecoder returns a reader for the i-th sample of a block
+func sampleDecoder(bits, format int, order binary.ByteOrder) (func(b []byte, i int) (float64, bool), error) {
+	size := bits / 8
+	var conv func(b []byte) float64
+	switch {
+	case bits == 8 && format == 1:
+		conv = func(b []byte) float64 { return float64(b[0]) }
+	case bits == 8 && format == 2:
+		conv = func(b []byte) float64 { return float64(int8(b[0])) }
+	case bits == 16 && format == 1:
+		conv = func(b []byte) float64 { return float64(order.Uint16(b)) }
+	case bits == 16 && format == 2:
+		conv = func(b []byte) float64 { return float64(int16(order.Uint16(b))) }
+	case bits == 32 && format == 1:
+		conv = func(b []byte) float64 { return float64(order.Uint32(b)) }
+	case bits == 32 && format == 2:
+		conv = func(b []byte) float64 { return float64(int32(order.Uint32(b))) }
+	case bits == 32 && format == 3:
+		conv = func(b []byte) float64 { return float64(math.Float32frombits(order.Uint32(b))) }
+	case bits == 64 && format == 3:
+		conv = func(b []byte) float64 { return math.Float64frombits(order.Uint64(b)) }
+	default:
+		return nil, fmt.Errorf("%d-bit samples of format %d are not supported", bits, format)
+	}
+	return func(b []byte, i int) (float64, bool) {
+		if (i+1)*size > len(b) {
+			return 0, false
+		}
+		return conv(b[i*size:]), true
+	}, nil
+}
+
+// markMissing replaces nodata values with NaN
+func markMissing(values []float64, nodata float64) {
+	for i, v := range values {
+		if v == nodata || (math.IsNaN(nodata) && math.IsNaN(v)) {
+			values[i] = math.NaN()
+		}
+	}
+}
+
+// NetCDF classic format tags and types
+const (
+	ncDimension = 10
+	ncVariable  = 11
+	ncAttribute = 12
+
+	ncByte   = 1
+	ncChar   = 2
+	ncShort  = 3
+	ncInt    = 4
+	ncFloat  = 5
+	ncDouble = 6
+)
+
+var ncTypeSize = map[uint32]int{ncByte: 1, ncChar: 1, ncShort: 2, ncInt: 4, ncFloat: 4, ncDouble: 8}
+
+type ncDim struct {
+	name   string
+	length int
+}
+
+type ncVar struct {
+	name   string
+	dims   []int
+	attrs  map[string]rasterField
+	typ    uint32
+	vsize  int
+	offset int64
+}
+
+// ncReader walks a NetCDF classic header
+type ncReader struct {
+	data    []byte
+	pos     int
+	offset8 bool
+	err     error
+}
+
+func (r *ncReader) u32() uint32 {
+	if r.err != nil || r.pos+4 > len(r.data) {
+		r.err = fmt.Errorf("NetCDF header is truncated")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+func (r *ncReader) u64() uint64 {
+	hi := uint64(r.u32())
+	return hi<<32 | uint64(r.u32())
+}
+
+func (r *ncReader) bytes(n int) []byte {
+	padded := (n + 3) &^ 3
+	if r.err != nil || n < 0 || r.pos+padded > len(r.data) {
+		r.err = fmt.Errorf("NetCDF header is truncated")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += padded
+	return b
+}
+
+func (r *ncReader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+// list reads a tagged list header and returns its length
+func (r *ncReader) list(tag uint32) int {
+	got, n := r.u32(), int(r.u32())
+	if got == 0 && n == 0 {
+		return 0
+	}
+	if got != tag && r.err == nil {
+		r.err = fmt.Errorf("unexpected NetCDF header tag %d", got)
+	}
+	return n
+}
+
+func (r *ncReader) attrs() map[string]rasterField {
+	n := r.list(ncAttribute)
+	attrs := make(map[string]rasterField, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		name := r.name()
+		typ := r.u32()
+		count := int(r.u32())
+		size := ncTypeSize[typ]
+		if size == 0 {
+			r.err = fmt.Errorf("attribute %s has unknown type %d", name, typ)
+			break
+		}
+		raw := r.bytes(count * size)
+		if typ == ncChar {
+			attrs[name] = rasterField{text: string(raw)}
+			continue
+		}
+		attrs[name] = rasterField{nums: ncValues(raw, typ, count)}
+	}
+	return attrs
+}
+
+// ncValues decodes big-endian NetCDF values
+func ncValues(raw []byte, typ uint32, count int) []float64 {
+	out := make([]float64, count)
+	size := ncTypeSize[typ]
+	for i := range out {
+		if (i+1)*size > len(raw) {
+			break
+		}
+		b := raw[i*size:]
+		switch typ {
+		case ncByte:
+			out[i] = float64(int8(b[0]))
+		case ncShort:
+			out[i] = float64(int16(binary.BigEndian.Uint16(b)))
+		case ncInt:
+			out[i] = float64(int32(binary.BigEndian.Uint32(b)))
+		case ncFloat:
+			out[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case ncDouble:
+			out[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	}
+	return out
+}
+
+// decodeNetCDF reads a latitude/longitude variable from a NetCDF classic
+// (CDF-1 or CDF-2) file. The variable may have a leading time dimension, in
+// which case the first time step is used. NetCDF-4/HDF5 files are not supported.
+func decodeNetCDF(data []byte, variable string) (*Grid, error) {
+	if len(data) < 4 || string(data[:3]) != "CDF" {
+		if len(data) >= 4 && string(data[1:4]) == "HDF" {
+			return nil, fmt.Errorf("NetCDF-4/HDF5 files are not supported; convert to NetCDF classic or GeoTIFF")
+		}
+		return nil, fmt.Errorf("not a NetCDF file")
+	}
+	r := &ncReader{data: data, pos: 4}
+	switch data[3] {
+	case 1:
+	case 2:
+		r.offset8 = true
+	default:
+		return nil, fmt.Errorf("NetCDF version %d is not supported", data[3])
+	}
+
+	numRecs := int(r.u32())
+	dims := make([]ncDim, r.list(ncDimension))
+	for i := range dims {
+		dims[i] = ncDim{name: r.name(), length: int(r.u32())}
+	}
+	r.attrs()
+	vars := make(map[string]*ncVar)
+	for i, n := 0, r.list(ncVariable); i < n && r.err == nil; i++ {
+		v := &ncVar{name: r.name()}
+		v.dims = make([]int, r.u32())
+		for j := range v.dims {
+			v.dims[j] = int(r.u32())
+		}
+		v.attrs = r.attrs()
+		v.typ = r.u32()
+		v.vsize = int(r.u32())
+		if r.offset8 {
+			v.offset = int64(r.u64())
+		} else {
+			v.offset = int64(r.u32())
+		}
+		vars[v.name] = v
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, v := range vars {
+		for _, d := range v.dims {
+			if d < 0 || d >= len(dims) {
+				return nil, fmt.Errorf("variable %s has an invalid dimension", v.name)
+			}
+		}
+	}
+
+	v, ok := vars[variable]
+	if !ok {
+		return nil, fmt.Errorf("variable %q not found", variable)
+	}
+	if len(v.dims) < 2 || len(v.dims) > 3 {
+		return nil, fmt.Errorf("variable %q must have (lat, lon) or (time, lat, lon) dimensions", variable)
+	}
+	if len(v.dims) == 3 && dims[v.dims[0]].length == 0 && numRecs == 0 {
+		return nil, fmt.Errorf("variable %q has no records", variable)
+	}
+	latDim, lonDim := dims[v.dims[len(v.dims)-2]], dims[v.dims[len(v.dims)-1]]
+
+	readCoord := func(names ...string) ([]float64, error) {
+		for _, name := range names {
+			if c, ok := vars[name]; ok && len(c.dims) == 1 {
+				n := dims[c.dims[0]].length
+				raw, err := ncSlice(data, c.offset, n*ncTypeSize[c.typ])
+				if err != nil {
+					return nil, err
+				}
+				return ncValues(raw, c.typ, n), nil
+			}
+		}
+		return nil, fmt.Errorf("coordinate variable %s not found", names[0])
+	}
+	lats, err := readCoord(latDim.name, "lat", "latitude")
+	if err != nil {
+		return nil, err
+	}
+	lons, err := readCoord(lonDim.name, "lon", "longitude")
+	if err != nil {
+		return nil, err
+	}
+	if len(lats) != latDim.length || len(lons) != lonDim.length || len(lats) < 2 || len(lons) < 2 {
+		return nil, fmt.Errorf("coordinate variables do not match %q", variable)
+	}
+
+	count := latDim.length * lonDim.length
+	size := ncTypeSize[v.typ]
+	if size == 0 || v.typ == ncChar {
+		return nil, fmt.Errorf("variable %q has unsupported type %d", variable, v.typ)
+	}
+	// The first time step of both fixed and record variables starts at the
+	// variable's offset
+	raw, err := ncSlice(data, v.offset, count*size)
+	if err != nil {
+		return nil, err
+	}
+	values := ncValues(raw, v.typ, count)
+
+	for _, name := range []string{"_FillValue", "missing_value"} {
+		if a, ok := v.attrs[name]; ok && len(a.nums) > 0 {
+			markMissing(values, a.nums[0])
+		}
+	}
+	scale, offset := 1.0, 0.0
+	if a, ok := v.attrs["scale_factor"]; ok && len(a.nums) > 0 {
+		scale = a.nums[0]
+	}
+	if a, ok := v.attrs["add_offset"]; ok && len(a.nums) > 0 {
+		offset = a.nums[0]
+	}
+	for i := range values {
+		values[i] = values[i]*scale + offset
+	}
+
+	return &Grid{
+		Width:  lonDim.length,
+		Height: latDim.length,
+		Lon0:   lons[0],
+		Lat0:   lats[0],
+		DLon:   (lons[len(lons)-1] - lons[0]) / float64(len(lons)-1),
+		DLat:   (lats[len(lats)-1] - lats[0]) / float64(len(lats)-1),
+		Data:   values,
+	}, nil
+}
+
+func ncSlice(data []byte, offset int64, n int) ([]byte, error) {
+	if offset < 0 || offset+int64(n) > int64(len(data)) {
+		return nil, fmt.Errorf("NetCDF data is out of range")
+	}
+	return data[offset : offset+int64(n)], nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testTIFF describes a GeoTIFF written by writeTestGeoTIFF
+type testTIFF struct {
+	order    binary.ByteOrder
+	width    int
+	height   int
+	values   []float32
+	tile     int // tile size; zero writes one strip per row
+	deflate  bool
+	lon, lat float64 // top-left corner
+	scale    float64
+	nodata   string
+}
+
+// writeTestGeoTIFF encodes a single-band float32 GeoTIFF
+func writeTestGeoTIFF(t *testing.T, path string, spec testTIFF) {
+	t.Helper()
+	o := spec.order
+
+	// Image blocks first, after the 8-byte header
+	var blocks [][]byte
+	encode := func(rows, cols, top, left int) {
+		var buf bytes.Buffer
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				v := float32(math.NaN())
+				if top+y < spec.height && left+x < spec.width {
+					v = spec.values[(top+y)*spec.width+left+x]
+				}
+				binary.Write(&buf, o, v)
+			}
+		}
+		block := buf.Bytes()
+		if spec.deflate {
+			var z bytes.Buffer
+			zw := zlib.NewWriter(&z)
+			zw.Write(block)
+			zw.Close()
+			block = z.Bytes()
+		}
+		blocks = append(blocks, block)
+	}
+	if spec.tile > 0 {
+		for top := 0; top < spec.height; top += spec.tile {
+			for left := 0; left < spec.width; left += spec.tile {
+				encode(spec.tile, spec.tile, top, left)
+			}
+		}
+	} else {
+		for row := 0; row < spec.height; row++ {
+			encode(1, spec.width, row, 0)
+		}
+	}
+
+	data := make([]byte, 8)
+	var offsets, counts []uint32
+	for _, b := range blocks {
+		offsets = append(offsets, uint32(len(data)))
+		counts = append(counts, uint32(len(b)))
+		data = append(data, b...)
+	}
+
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		payload  []byte
+	}
+	u16 := func(v ...uint16) []byte {
+		b := make([]byte, 2*len(v))
+		for i, x := range v {
+			o.PutUint16(b[2*i:], x)
+		}
+		return b
+	}
+	u32 := func(v ...uint32) []byte {
+		b := make([]byte, 4*len(v))
+		for i, x := range v {
+			o.PutUint32(b[4*i:], x)
+		}
+		return b
+	}
+	f64 := func(v ...float64) []byte {
+		b := make([]byte, 8*len(v))
+		for i, x := range v {
+			o.PutUint64(b[8*i:], math.Float64bits(x))
+		}
+		return b
+	}
+	compression := uint16(1)
+	if spec.deflate {
+		compression = 8
+	}
+	entries := []entry{
+		{tiffImageWidth, 3, 1, u16(uint16(spec.width))},
+		{tiffImageLength, 3, 1, u16(uint16(spec.height))},
+		{tiffBitsPerSample, 3, 1, u16(32)},
+		{tiffCompression, 3, 1, u16(compression)},
+		{tiffSamplesPerPixel, 3, 1, u16(1)},
+		{tiffSampleFormat, 3, 1, u16(3)},
+		{geoPixelScale, 12, 3, f64(spec.scale, spec.scale, 0)},
+		{geoTiepoint, 12, 6, f64(0, 0, 0, spec.lon, spec.lat, 0)},
+	}
+	if spec.tile > 0 {
+		entries = append(entries,
+			entry{tiffTileWidth, 3, 1, u16(uint16(spec.tile))},
+			entry{tiffTileLength, 3, 1, u16(uint16(spec.tile))},
+			entry{tiffTileOffsets, 4, uint32(len(offsets)), u32(offsets...)},
+			entry{tiffTileByteCounts, 4, uint32(len(counts)), u32(counts...)},
+		)
+	} else {
+		entries = append(entries,
+			entry{tiffStripOffsets, 4, uint32(len(offsets)), u32(offsets...)},
+			entry{tiffRowsPerStrip, 3, 1, u16(1)},
+			entry{tiffStripByteCounts, 4, uint32(len(counts)), u32(counts...)},
+		)
+	}
+	if spec.nodata != "" {
+		entries = append(entries, entry{gdalNoData, 2, uint32(len(spec.nodata) + 1), append([]byte(spec.nodata), 0)})
+	}
+
+	// Out-of-line values, then the directory
+	for i := range entries {
+		if len(entries[i].payload) > 4 {
+			at := uint32(len(data))
+			data = append(data, entries[i].payload...)
+			entries[i].payload = u32(at)
+		}
+	}
+	ifd := uint32(len(data))
+	data = append(data, u16(uint16(len(entries)))...)
+	for _, e := range entries {
+		data = append(data, u16(e.tag, e.typ)...)
+		data = append(data, u32(e.count)...)
+		value := make([]byte, 4)
+		copy(value, e.payload)
+		data = append(data, value...)
+	}
+	data = append(data, u32(0)...)
+
+	if o == binary.LittleEndian {
+		copy(data, "II")
+	} else {
+		copy(data, "MM")
+	}
+	o.PutUint16(data[2:], 42)
+	o.PutUint32(data[4:], ifd)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestNetCDF encodes a CDF-1 file with lat, lon and a (time, lat, lon)
+// short variable packed with scale_factor and _FillValue
+func writeTestNetCDF(t *testing.T, path, variable string, lats, lons []float64, packed []int16, scale float64, fill int16) {
+	t.Helper()
+	var h bytes.Buffer
+	be := binary.BigEndian
+	w32 := func(v uint32) { binary.Write(&h, be, v) }
+	name := func(s string) {
+		w32(uint32(len(s)))
+		h.WriteString(s)
+		for h.Len()%4 != 0 {
+			h.WriteByte(0)
+		}
+	}
+
+	h.WriteString("CDF\x01")
+	w32(1) // numrecs
+	w32(ncDimension)
+	w32(3)
+	name("time")
+	w32(0) // unlimited
+	name("lat")
+	w32(uint32(len(lats)))
+	name("lon")
+	w32(uint32(len(lons)))
+	w32(0) // no global attributes
+	w32(0)
+
+	latSize, lonSize := 8*len(lats), 8*len(lons)
+	varSize := (2*len(packed) + 3) &^ 3
+	var offsetFields []int
+	w32(ncVariable)
+	w32(3)
+	writeVar := func(n string, dims []uint32, typ uint32, vsize int, attrs func()) {
+		name(n)
+		w32(uint32(len(dims)))
+		for _, d := range dims {
+			w32(d)
+		}
+		if attrs == nil {
+			w32(0)
+			w32(0)
+		} else {
+			attrs()
+		}
+		w32(typ)
+		w32(uint32(vsize))
+		offsetFields = append(offsetFields, h.Len())
+		w32(0)
+	}
+	writeVar("lat", []uint32{1}, ncDouble, latSize, nil)
+	writeVar("lon", []uint32{2}, ncDouble, lonSize, nil)
+	writeVar(variable, []uint32{0, 1, 2}, ncShort, varSize, func() {
+		w32(ncAttribute)
+		w32(2)
+		name("scale_factor")
+		w32(ncDouble)
+		w32(1)
+		binary.Write(&h, be, scale)
+		name("_FillValue")
+		w32(ncShort)
+		w32(1)
+		binary.Write(&h, be, fill)
+		h.Write([]byte{0, 0})
+	})
+
+	data := h.Bytes()
+	offsets := []int{len(data), len(data) + latSize, len(data) + latSize + lonSize}
+	for i, at := range offsetFields {
+		be.PutUint32(data[at:], uint32(offsets[i]))
+	}
+	var body bytes.Buffer
+	binary.Write(&body, be, lats)
+	binary.Write(&body, be, lons)
+	binary.Write(&body, be, packed)
+	for body.Len()%4 != 0 {
+		body.WriteByte(0)
+	}
+	if err := os.WriteFile(path, append(data, body.Bytes()...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testGridValues is a 4x4 field whose value is 10*row + col
+func testGridValues() []float32 {
+	values := make([]float32, 16)
+	for i := range values {
+		values[i] = float32(10*(i/4) + i%4)
+	}
+	return values
+}
+
+func TestReadRaster_GeoTIFF(t *testing.T) {
+	dir := t.TempDir()
+	base := testTIFF{width: 4, height: 4, values: testGridValues(), lon: 10, lat: 5, scale: 1}
+
+	tests := []struct {
+		name string
+		spec func(testTIFF) testTIFF
+	}{
+		{name: "little endian strips", spec: func(s testTIFF) testTIFF { s.order = binary.LittleEndian; return s }},
+		{name: "big endian strips", spec: func(s testTIFF) testTIFF { s.order = binary.BigEndian; return s }},
+		{name: "deflate tiles", spec: func(s testTIFF) testTIFF {
+			s.order, s.tile, s.deflate = binary.LittleEndian, 3, true
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "grid.tif")
+			writeTestGeoTIFF(t, path, tt.spec(base))
+			grid, err := ReadRaster(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if grid.Width != 4 || grid.Height != 4 || grid.Lon0 != 10.5 || grid.Lat0 != 4.5 || grid.DLat != -1 {
+				t.Fatalf("unexpected grid geometry: %+v", grid)
+			}
+			// Centre of row 1, col 2
+			if v, err := grid.Nearest(3.5, 12.5); err != nil || v != 12 {
+				t.Errorf("Nearest() = %v, %v, want 12", v, err)
+			}
+			// Halfway between rows 1-2 and cols 1-2
+			if v, err := grid.Bilinear(3, 12); err != nil || math.Abs(v-16.5) > 1e-9 {
+				t.Errorf("Bilinear() = %v, %v, want 16.5", v, err)
+			}
+		})
+	}
+}
+
+func TestGrid_MissingValuesAndRegions(t *testing.T) {
+	values := testGridValues()
+	values[1*4+1] = -9999
+	path := filepath.Join(t.TempDir(), "grid.tif")
+	writeTestGeoTIFF(t, path, testTIFF{order: binary.LittleEndian, width: 4, height: 4, values: values, lon: 10, lat: 5, scale: 1, nodata: "-9999"})
+	grid, err := ReadRaster(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := grid.Nearest(3.5, 11.5); !errors.Is(err, ErrNoGridData) {
+		t.Errorf("Nearest() on a nodata cell error = %v, want ErrNoGridData", err)
+	}
+	// The missing corner (11) is dropped from the 11, 12, 21, 22 neighbourhood
+	if v, err := grid.Bilinear(3, 12); err != nil || math.Abs(v-(12+21+22)/3.0) > 1e-9 {
+		t.Errorf("Bilinear() with a missing corner = %v, %v", v, err)
+	}
+	if _, err := grid.Bilinear(40, 12); err == nil {
+		t.Error("Bilinear() outside the grid succeeded")
+	}
+
+	mean, n, err := grid.RegionMean(BoundingBox{MinLatitude: 2, MaxLatitude: 4, MinLongitude: 10, MaxLongitude: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rows 1-2, cols 0-1 without the missing cell: 10, 20, 21
+	if n != 3 || math.Abs(mean-17) > 1e-9 {
+		t.Errorf("RegionMean() = %v over %d cells, want 17 over 3", mean, n)
+	}
+}
+
+func TestReadRaster_NetCDF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "precip.nc")
+	lats := []float64{-1, 0, 1}
+	lons := []float64{30, 30.5, 31}
+	packed := []int16{0, 10, 20, 30, 40, 50, -1, 70, 80}
+	writeTestNetCDF(t, path, "precip", lats, lons, packed, 0.1, -1)
+
+	grid, err := ReadRaster(path, "precip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grid.Width != 3 || grid.Height != 3 || grid.Lat0 != -1 || grid.DLat != 1 || grid.DLon != 0.5 {
+		t.Fatalf("unexpected grid geometry: %+v", grid)
+	}
+	if v, err := grid.Nearest(0, 30.5); err != nil || math.Abs(v-4) > 1e-9 {
+		t.Errorf("Nearest() = %v, %v, want 4", v, err)
+	}
+	if _, err := grid.Nearest(1, 30); !errors.Is(err, ErrNoGridData) {
+		t.Errorf("fill value not treated as missing: %v", err)
+	}
+	if _, err := ReadRaster(path, "rain"); err == nil {
+		t.Error("ReadRaster() with an unknown variable succeeded")
+	}
+}
+
+func TestReadRaster_Unsupported(t *testing.T) {
+	dir := t.TempDir()
+	hdf := filepath.Join(dir, "imerg.nc")
+	os.WriteFile(hdf, []byte("\x89HDF\r\n\x1a\n"), 0o644)
+	if _, err := ReadRaster(hdf, "precip"); err == nil {
+		t.Error("NetCDF-4 file was accepted")
+	}
+	txt := filepath.Join(dir, "grid.asc")
+	os.WriteFile(txt, []byte("ncols 4"), 0o644)
+	if _, err := ReadRaster(txt, ""); err == nil {
+		t.Error("unsupported format was accepted")
+	}
+}
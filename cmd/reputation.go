@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReputationConfig configures provider reputation tracking
+type ReputationConfig struct {
+	// Path persists the scorecard across restarts; empty keeps it in memory
+	Path string `json:"path,omitempty"`
+	// MinSamples is the number of consensus comparisons before a provider can be dropped
+	MinSamples int `json:"min_samples"`
+	// DropBelow is the weight under which a provider no longer counts
+	// toward the consensus; zero never drops
+	DropBelow float64 `json:"drop_below"`
+}
+
+// DefaultReputationConfig drops providers from the consensus that disagree
+// with it more often than not over at least 20 comparisons
+func DefaultReputationConfig() ReputationConfig {
+	return ReputationConfig{MinSamples: 20, DropBelow: 0.5}
+}
+
+// Validate checks the reputation settings
+func (c ReputationConfig) Validate() error {
+	if c.MinSamples <= 0 {
+		return fmt.Errorf("REPUTATION_MIN_SAMPLES must be positive")
+	}
+	if c.DropBelow < 0 || c.DropBelow >= 1 {
+		return fmt.Errorf("REPUTATION_DROP_BELOW must be at least 0 and below 1")
+	}
+	return nil
+}
+
+// minConsensusProviders is the number of providers that must report the same
+// variable for a consensus to be meaningful
+const minConsensusProviders = 3
+
+// ProviderScore is a provider's record against consensus
+type ProviderScore struct {
+	Provider string `json:"provider"`
+	Samples  uint64 `json:"samples"`
+	// Deviations counts samples further from consensus than the agreement scale
+	Deviations       uint64     `json:"deviations"`
+	MeanAbsDeviation float64    `json:"mean_abs_deviation"`
+	MaxAbsDeviation  float64    `json:"max_abs_deviation"`
+	LastDeviationAt  *time.Time `json:"last_deviation_at,omitempty"`
+	Weight           float64    `json:"weight"`
+	Dropped          bool       `json:"dropped"`
+}
+
+// ReputationTracker remembers how often each provider deviated from the
+// consensus of the providers asked for the same task
+type ReputationTracker struct {
+	cfg    ReputationConfig
+	logger *zap.Logger
+	mu     sync.Mutex
+	scores map[string]*ProviderScore
+	now    func() time.Time
+}
+
+// NewReputationTracker loads the scorecard from cfg.Path, if it exists
+func NewReputationTracker(cfg ReputationConfig, logger *zap.Logger) (*ReputationTracker, error) {
+	r := &ReputationTracker{
+		cfg:    cfg,
+		logger: logger,
+		scores: make(map[string]*ProviderScore),
+		now:    time.Now,
+	}
+	if cfg.Path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reputation file: %w", err)
+	}
+	var scores []ProviderScore
+	if err := json.Unmarshal(data, &scores); err != nil {
+		return nil, fmt.Errorf("invalid reputation file %s: %w", cfg.Path, err)
+	}
+	for i := range scores {
+		r.scores[scores[i].Provider] = &scores[i]
+	}
+	return r, nil
+}
+
+// weight is the share of samples that agreed with consensus, with one
+// agreement and one deviation assumed up front so new providers start at 0.5
+func (s *ProviderScore) weight() float64 {
+	return float64(s.Samples-s.Deviations+1) / float64(s.Samples+2)
+}
+
+func (r *ReputationTracker) dropped(s *ProviderScore) bool {
+	return r.cfg.DropBelow > 0 && s.Samples >= uint64(r.cfg.MinSamples) && s.weight() < r.cfg.DropBelow
+}
+
+// Weight returns the provider's weight in the consensus providers are scored
+// against, zero once it is dropped. It neither chooses the providers asked
+// nor enters the confidence score: each operator keeps its own scorecard,
+// and signed results must not depend on it. A nil tracker weighs every
+// provider equally.
+func (r *ReputationTracker) Weight(provider string) float64 {
+	if r == nil {
+		return 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.weightLocked(provider)
+}
+
+// weightLocked is Weight for callers holding r.mu
+func (r *ReputationTracker) weightLocked(provider string) float64 {
+	s, ok := r.scores[provider]
+	switch {
+	case !ok:
+		return (&ProviderScore{}).weight()
+	case r.dropped(s):
+		return 0
+	}
+	return s.weight()
+}
+
+// Dropped reports whether the provider has been dropped from the consensus
+// for deviating too often
+func (r *ReputationTracker) Dropped(provider string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.scores[provider]
+	return ok && r.dropped(s)
+}
+
+// Record scores the answering provider and its corroborators against their
+// reputation-weighted median. It needs minConsensusProviders values.
+func (r *ReputationTracker) Record(data *WeatherData) {
+	if r == nil || len(data.Corroborating)+1 < minConsensusProviders {
+		return
+	}
+	values := append([]SourceValue{{Source: data.Source, Value: primaryValue(data)}}, data.Corroborating...)
+
+	r.mu.Lock()
+	weights := make([]float64, len(values))
+	var total float64
+	for i, v := range values {
+		weights[i] = r.weightLocked(v.Source)
+		total += weights[i]
+	}
+	if total == 0 {
+		// Every provider is dropped: weigh them equally again
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	consensus := weightedMedian(values, weights)
+	variable := "temperature"
+	if data.Precipitation != nil {
+		variable = "precipitation"
+	}
+	tolerance := agreementScale(variable, consensus)
+
+	now := r.now().UTC()
+	for _, v := range values {
+		s, ok := r.scores[v.Source]
+		if !ok {
+			s = &ProviderScore{Provider: v.Source}
+			r.scores[v.Source] = s
+		}
+		wasDropped := r.dropped(s)
+		deviation := math.Abs(v.Value - consensus)
+		s.Samples++
+		s.MeanAbsDeviation += (deviation - s.MeanAbsDeviation) / float64(s.Samples)
+		s.MaxAbsDeviation = math.Max(s.MaxAbsDeviation, deviation)
+		if deviation > tolerance {
+			s.Deviations++
+			s.LastDeviationAt = &now
+		}
+		if !wasDropped && r.dropped(s) {
+			r.logger.Warn("Dropping weather provider from the consensus it deviates from",
+				zap.String("provider", s.Provider),
+				zap.Uint64("samples", s.Samples),
+				zap.Uint64("deviations", s.Deviations),
+				zap.Float64("weight", s.weight()),
+			)
+		}
+	}
+	r.mu.Unlock()
+
+	if err := r.Save(); err != nil {
+		r.logger.Warn("Failed to save provider reputation", zap.Error(err))
+	}
+}
+
+// weightedMedian returns the value at which the cumulative weight, in value
+// order, first reaches half the total
+func weightedMedian(values []SourceValue, weights []float64) float64 {
+	idx := make([]int, len(values))
+	var total float64
+	for i := range idx {
+		idx[i] = i
+		total += weights[i]
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return values[idx[a]].Value < values[idx[b]].Value })
+	var cum float64
+	for _, i := range idx {
+		cum += weights[i]
+		if cum >= total/2 {
+			return values[i].Value
+		}
+	}
+	return values[idx[len(idx)-1]].Value
+}
+
+// Scorecard returns every provider's score, ordered by provider
+func (r *ReputationTracker) Scorecard() []ProviderScore {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]ProviderScore, 0, len(r.scores))
+	for _, s := range r.scores {
+		score := *s
+		score.Weight = roundTo(s.weight(), 4)
+		score.Dropped = r.dropped(s)
+		out = append(out, score)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+// Reset forgets the provider's record, or every record when provider is
+// empty, and returns the number of records removed
+func (r *ReputationTracker) Reset(provider string) int {
+	r.mu.Lock()
+	var n int
+	if provider == "" {
+		n = len(r.scores)
+		r.scores = make(map[string]*ProviderScore)
+	} else if _, ok := r.scores[provider]; ok {
+		delete(r.scores, provider)
+		n = 1
+	}
+	r.mu.Unlock()
+
+	if err := r.Save(); err != nil {
+		r.logger.Warn("Failed to save provider reputation", zap.Error(err))
+	}
+	return n
+}
+
+// Save writes the scorecard to the configured path, replacing it atomically
+func (r *ReputationTracker) Save() error {
+	if r == nil || r.cfg.Path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.Scorecard(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(r.cfg.Path, data); err != nil {
+		return fmt.Errorf("failed to save reputation: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Reputation endpoint: GET returns the scorecard, DELETE resets it (optionally ?provider=name)
+func (r *ReputationTracker) reputationHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Scorecard())
+	case http.MethodDelete:
+		reset := r.Reset(req.URL.Query().Get("provider"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"reset": reset})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
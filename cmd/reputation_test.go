@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestReputation(t *testing.T, cfg ReputationConfig) *ReputationTracker {
+	t.Helper()
+	r, err := NewReputationTracker(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReputation_Record(t *testing.T) {
+	r := newTestReputation(t, ReputationConfig{MinSamples: 3, DropBelow: 0.5})
+	reading := &WeatherData{Temperature: 30, Source: "bad", Corroborating: []SourceValue{
+		{Source: "a", Value: 20},
+		{Source: "b", Value: 20.5},
+	}}
+
+	// Too few providers for a consensus
+	r.Record(&WeatherData{Temperature: 30, Source: "bad", Corroborating: reading.Corroborating[:1]})
+	if len(r.Scorecard()) != 0 {
+		t.Fatal("two providers were scored against each other")
+	}
+
+	for i := 0; i < 3; i++ {
+		r.Record(reading)
+	}
+	scores := r.Scorecard()
+	if len(scores) != 3 {
+		t.Fatalf("scorecard = %+v", scores)
+	}
+	a, bad := scores[0], scores[2]
+	if a.Provider != "a" || a.Samples != 3 || a.Deviations != 0 || a.Weight != 0.8 || a.Dropped {
+		t.Errorf("a = %+v", a)
+	}
+	if bad.Provider != "bad" || bad.Deviations != 3 || bad.MeanAbsDeviation != 9.5 || bad.LastDeviationAt == nil {
+		t.Errorf("bad = %+v", bad)
+	}
+	if !bad.Dropped || !r.Dropped("bad") || r.Dropped("a") {
+		t.Error("only the provider deviating every time should be dropped")
+	}
+	if r.Weight("bad") != 0 || r.Weight("a") != 0.8 || r.Weight("new") != 0.5 {
+		t.Errorf("weights = %v, %v", r.Weight("bad"), r.Weight("new"))
+	}
+}
+
+func TestWeightedMedian(t *testing.T) {
+	values := []SourceValue{{Value: 30}, {Value: 20}, {Value: 21}}
+	if got := weightedMedian(values, []float64{1, 1, 1}); got != 21 {
+		t.Errorf("equal weights: median = %v, want 21", got)
+	}
+	if got := weightedMedian(values, []float64{5, 1, 1}); got != 30 {
+		t.Errorf("a dominant weight should win: median = %v, want 30", got)
+	}
+}
+
+func TestReputation_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reputation.json")
+	r := newTestReputation(t, ReputationConfig{Path: path, MinSamples: 20, DropBelow: 0.5})
+	r.Record(&WeatherData{Temperature: 20, Source: "a", Corroborating: []SourceValue{
+		{Source: "b", Value: 20},
+		{Source: "c", Value: 25},
+	}})
+
+	reloaded := newTestReputation(t, ReputationConfig{Path: path, MinSamples: 20, DropBelow: 0.5})
+	scores := reloaded.Scorecard()
+	if len(scores) != 3 || scores[2].Provider != "c" || scores[2].Deviations != 1 {
+		t.Fatalf("reloaded scorecard = %+v", scores)
+	}
+
+	if n := reloaded.Reset("c"); n != 1 {
+		t.Errorf("Reset(c) = %d, want 1", n)
+	}
+	if scores := newTestReputation(t, ReputationConfig{Path: path, MinSamples: 20}).Scorecard(); len(scores) != 2 {
+		t.Errorf("reset was not persisted: %+v", scores)
+	}
+}
+
+func TestFetchWeather_DroppedProviderIsStillAsked(t *testing.T) {
+	now := time.Now().UTC()
+	providers := func() (*namedProvider, []WeatherProvider) {
+		bad := &namedProvider{name: "bad", staticProvider: staticProvider{data: WeatherData{Temperature: 30, Source: "bad", Timestamp: now}}}
+		a := &namedProvider{name: "a", staticProvider: staticProvider{data: WeatherData{Temperature: 20, Source: "a", Timestamp: now}}}
+		b := &namedProvider{name: "b", staticProvider: staticProvider{data: WeatherData{Temperature: 20.5, Source: "b", Timestamp: now}}}
+		return bad, []WeatherProvider{bad, a, b}
+	}
+
+	// One operator has scored bad out of its consensus, the other is new
+	bad, scored := providers()
+	veteran := NewWeatherClientWithProviders(zap.NewNop(), scored...)
+	veteran.corroborate = true
+	veteran.reputation = newTestReputation(t, ReputationConfig{MinSamples: 2, DropBelow: 0.5})
+	location := Location{Latitude: 1, Longitude: 2}
+	for i := 0; i < 2; i++ {
+		veteran.PurgeCache("")
+		if _, err := veteran.FetchWeather(context.Background(), location); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !veteran.reputation.Dropped("bad") || veteran.reputation.Weight("bad") != 0 {
+		t.Fatalf("bad not dropped from the consensus: %+v", veteran.reputation.Scorecard())
+	}
+	_, fresh := providers()
+	newcomer := NewWeatherClientWithProviders(zap.NewNop(), fresh...)
+	newcomer.corroborate = true
+	newcomer.reputation = newTestReputation(t, ReputationConfig{MinSamples: 2, DropBelow: 0.5})
+
+	// Both still answer from the same provider, so their results agree
+	veteran.PurgeCache("")
+	got, err := veteran.FetchWeather(context.Background(), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := newcomer.FetchWeather(context.Background(), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Source != "bad" || bad.calls != 3 || got.Source != want.Source || len(got.Corroborating) != len(want.Corroborating) {
+		t.Errorf("veteran answered from %s (%d calls), newcomer from %s", got.Source, bad.calls, want.Source)
+	}
+
+	// With every provider dropped the consensus weighs them equally
+	veteran.reputation.scores["a"].Deviations = veteran.reputation.scores["a"].Samples
+	veteran.reputation.scores["b"].Deviations = veteran.reputation.scores["b"].Samples
+	samples := veteran.reputation.scores["a"].Samples
+	veteran.reputation.Record(got)
+	if veteran.reputation.scores["a"].Samples != samples+1 {
+		t.Error("no consensus with every provider dropped")
+	}
+}
+
+func TestReputationHandler(t *testing.T) {
+	r := newTestReputation(t, DefaultReputationConfig())
+	r.Record(&WeatherData{Temperature: 20, Source: "a", Corroborating: []SourceValue{
+		{Source: "b", Value: 20},
+		{Source: "c", Value: 20},
+	}})
+
+	rec := httptest.NewRecorder()
+	r.reputationHandler(rec, httptest.NewRequest(http.MethodGet, "/reputation", nil))
+	var scores []ProviderScore
+	if err := json.Unmarshal(rec.Body.Bytes(), &scores); err != nil || len(scores) != 3 {
+		t.Fatalf("GET /reputation = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.reputationHandler(rec, httptest.NewRequest(http.MethodDelete, "/reputation", nil))
+	if rec.Body.String() != "{\"reset\":3}\n" || len(r.Scorecard()) != 0 {
+		t.Errorf("DELETE /reputation = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.reputationHandler(rec, httptest.NewRequest(http.MethodPost, "/reputation", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /reputation = %d", rec.Code)
+	}
+}
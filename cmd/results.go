@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrTaskIDReused is returned when a task ID arrives again with a different payload
+var ErrTaskIDReused = errors.New("task ID reused with a different payload")
+
+// ResultStoreConfig configures the task result store
+type ResultStoreConfig struct {
+	// Capacity is the number of results kept; the least recently used go first
+	Capacity int `json:"capacity"`
+	// Path persists results across restarts; empty keeps them in memory
+	Path string `json:"path,omitempty"`
+}
+
+// DefaultResultStoreConfig keeps the last 10000 results in memory
+func DefaultResultStoreConfig() ResultStoreConfig {
+	return ResultStoreConfig{Capacity: 10000}
+}
+
+// Validate checks the result store settings
+func (c ResultStoreConfig) Validate() error {
+	if c.Capacity <= 0 {
+		return fmt.Errorf("RESULT_STORE_SIZE must be positive")
+	}
+	return nil
+}
+
+// storedResult is a computed task result
+type storedResult struct {
+	TaskID      string    `json:"task_id"`
+	PayloadHash string    `json:"payload_hash"`
+	Result      []byte    `json:"result"`
+	StoredAt    time.Time `json:"stored_at"`
+}
+
+// ResultStore remembers task results so retries of a task get identical
+// bytes back, which signature aggregation depends on
+type ResultStore struct {
+	cfg     ResultStoreConfig
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	file    *os.File
+	// lines counts the records in the file, which is compacted once it
+	// holds twice the capacity
+	lines int
+	now   func() time.Time
+}
+
+// newResultStore returns an empty store
+func newResultStore(cfg ResultStoreConfig) *ResultStore {
+	return &ResultStore{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// NewResultStore returns a store, loading persisted results from cfg.Path
+func NewResultStore(cfg ResultStoreConfig) (*ResultStore, error) {
+	s := newResultStore(cfg)
+	if cfg.Path == "" {
+		return s, nil
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the persisted results; a truncated last line from a crash is skipped
+func (s *ResultStore) load() error {
+	f, err := os.Open(s.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read result store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r storedResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		s.add(&r)
+	}
+	return scanner.Err()
+}
+
+// add inserts r as the most recently used result, evicting the least
+// recently used beyond capacity. Callers hold s.mu.
+func (s *ResultStore) add(r *storedResult) {
+	if el, ok := s.entries[r.TaskID]; ok {
+		s.order.Remove(el)
+	}
+	s.entries[r.TaskID] = s.order.PushFront(r)
+	for s.order.Len() > s.cfg.Capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*storedResult).TaskID)
+	}
+}
+
+// Lookup returns the stored result of taskID, nil if there is none, or
+// ErrTaskIDReused if it was computed for a different payload. A nil store
+// remembers nothing.
+func (s *ResultStore) Lookup(taskID, payloadHash string) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[taskID]
+	if !ok {
+		return nil, nil
+	}
+	r := el.Value.(*storedResult)
+	if r.PayloadHash != payloadHash {
+		return nil, fmt.Errorf("%w: %s", ErrTaskIDReused, taskID)
+	}
+	s.order.MoveToFront(el)
+	return r.Result, nil
+}
+
+// Store remembers result and returns the result to answer with: result
+// itself, or the one stored first if a concurrent retry got there before
+func (s *ResultStore) Store(taskID, payloadHash string, result []byte) ([]byte, error) {
+	if s == nil {
+		return result, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[taskID]; ok {
+		r := el.Value.(*storedResult)
+		if r.PayloadHash != payloadHash {
+			return nil, fmt.Errorf("%w: %s", ErrTaskIDReused, taskID)
+		}
+		return r.Result, nil
+	}
+
+	r := &storedResult{TaskID: taskID, PayloadHash: payloadHash, Result: result, StoredAt: s.now().UTC()}
+	if s.cfg.Path != "" {
+		if err := s.append(r); err != nil {
+			return nil, err
+		}
+	}
+	s.add(r)
+	if s.lines >= 2*s.cfg.Capacity {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// append writes r to the file, synced so a retry after a crash still finds
+// it. Callers hold s.mu.
+func (s *ResultStore) append(r *storedResult) error {
+	if s.file == nil {
+		return errors.New("result store is closed")
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to persist task result: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to persist task result: %w", err)
+	}
+	s.lines++
+	return nil
+}
+
+// compact rewrites the file with only the results still held, oldest
+// first, and reopens it for appending. Callers hold s.mu.
+func (s *ResultStore) compact() error {
+	var buf bytes.Buffer
+	for el := s.order.Back(); el != nil; el = el.Prev() {
+		line, err := json.Marshal(el.Value)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := writeFileAtomic(s.cfg.Path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compact result store: %w", err)
+	}
+	f, err := os.OpenFile(s.cfg.Path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open result store: %w", err)
+	}
+	s.file, s.lines = f, s.order.Len()
+	return nil
+}
+
+// Len returns the number of results held
+func (s *ResultStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// Close closes the persisted file
+func (s *ResultStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
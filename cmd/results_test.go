@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+func TestSunReWorker_RetryReturnsStoredResult(t *testing.T) {
+	provider := &staticProvider{data: WeatherData{Temperature: 21, Source: "static", Timestamp: time.Now()}}
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(provider)
+	task := &performerV1.TaskRequest{TaskId: []byte("task-retry"), Payload: []byte(`{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-R"}`)}
+
+	first, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The provider now reports something else, and the cache is gone
+	provider.data.Temperature = 35
+	worker.weatherClient.PurgeCache("")
+	retry, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Result, retry.Result) {
+		t.Errorf("retry returned different bytes:\n%s\n%s", first.Result, retry.Result)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.calls)
+	}
+
+	reused := &performerV1.TaskRequest{TaskId: task.TaskId, Payload: []byte(`{"location": {"latitude": 3, "longitude": 4}, "policy_id": "POL-R"}`)}
+	if err := worker.ValidateTask(reused); !errors.Is(err, ErrTaskIDReused) {
+		t.Errorf("ValidateTask() with a reused ID = %v", err)
+	}
+	if _, err := worker.HandleTask(reused); !errors.Is(err, ErrTaskIDReused) {
+		t.Errorf("HandleTask() with a reused ID = %v", err)
+	}
+}
+
+func TestResultStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	s := newResultStore(ResultStoreConfig{Capacity: 2})
+	for _, id := range []string{"a", "b"} {
+		s.Store(id, "h", []byte(id))
+	}
+	s.Lookup("a", "h")
+	s.Store("c", "h", []byte("c"))
+
+	if res, _ := s.Lookup("b", "h"); res != nil {
+		t.Error("least recently used result was kept")
+	}
+	for _, id := range []string{"a", "c"} {
+		if res, _ := s.Lookup(id, "h"); string(res) != id {
+			t.Errorf("Lookup(%s) = %q", id, res)
+		}
+	}
+}
+
+func TestResultStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	s, err := NewResultStore(ResultStoreConfig{Capacity: 3, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := s.Store(fmt.Sprintf("task-%d", i), "h", []byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	// The file was compacted at twice the capacity and keeps the newest results
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines > 6 {
+		t.Errorf("file has %d lines", lines)
+	}
+	// A record cut short by a crash is skipped
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString(`{"task_id": "task-9", "payl`)
+	f.Close()
+
+	s, err = NewResultStore(ResultStoreConfig{Capacity: 3, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.Len())
+	}
+	if res, err := s.Lookup("task-5", "h"); err != nil || string(res) != `{"n":5}` {
+		t.Errorf("Lookup(task-5) = %s, %v", res, err)
+	}
+	if res, _ := s.Lookup("task-1", "h"); res != nil {
+		t.Error("evicted result was restored")
+	}
+	if _, err := s.Lookup("task-5", "other"); !errors.Is(err, ErrTaskIDReused) {
+		t.Errorf("Lookup() with another payload = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math"
+	"time"
+
+	executorV1 "github.com/Layr-Labs/hourglass-monorepo/ponos/gen/protos/eigenlayer/hourglass/v1/executor"
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// performerService serves the performer API like ponos's PonosPerformer.
+// ponos's rpcServer only listens on plain TCP, so TLS listeners use this.
+// PonosPerformer only stores its timeout; tasks are bounded by the worker's
+// PERFORMER_TIMEOUT on either server.
+type performerService struct {
+	performerV1.UnimplementedPerformerServiceServer
+	worker *SunReWorker
+	logger *zap.Logger
+}
+
+// newTLSRpcServer returns the TLS counterpart of ponos's rpcServer: a gRPC
+// server with reflection that any hourglass service (performer, executor)
+// can be registered on. A TLS handshake gets no longer than timeout.
+func newTLSRpcServer(tlsConfig *tls.Config, timeout time.Duration) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.ConnectionTimeout(timeout),
+	)
+	reflection.Register(srv)
+	return srv
+}
+
+// newTLSPerformerServer returns a gRPC server for the worker using tlsConfig
+func newTLSPerformerServer(worker *SunReWorker, tlsConfig *tls.Config, timeout time.Duration, logger *zap.Logger) *grpc.Server {
+	srv := newTLSRpcServer(tlsConfig, timeout)
+	performerV1.RegisterPerformerServiceServer(srv, &performerService{worker: worker, logger: logger})
+	return srv
+}
+
+// ExecuteTask validates and handles a task
+func (s *performerService) ExecuteTask(ctx context.Context, task *performerV1.TaskRequest) (*performerV1.TaskResponse, error) {
+	if err := s.worker.ValidateTask(task); err != nil {
+		s.logger.Error("Task is invalid", zap.String("taskId", string(task.TaskId)), zap.Error(err))
+		return nil, taskStatus(err, "task is invalid: %s")
+	}
+	res, err := s.worker.HandleTask(task)
+	if err != nil {
+		s.logger.Error("Failed to handle task", zap.String("taskId", string(task.TaskId)), zap.Error(err))
+		return nil, taskStatus(err, "failed to handle task: %s")
+	}
+	return &performerV1.TaskResponse{TaskId: task.TaskId, Result: res.Result}, nil
+}
+
+// taskStatus maps a task error to a gRPC status; tasks refused while
+// draining are Unavailable so callers retry elsewhere
+func taskStatus(err error, format string) error {
+	if errors.Is(err, ErrDraining) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Errorf(codes.Internal, format, err.Error())
+}
+
+// HealthCheck reports the performer ready for tasks, or Unavailable while draining
+func (s *performerService) HealthCheck(ctx context.Context, req *performerV1.HealthCheckRequest) (*performerV1.HealthCheckResponse, error) {
+	if s.worker.gate.Draining() {
+		return nil, status.Error(codes.Unavailable, ErrDraining.Error())
+	}
+	return &performerV1.HealthCheckResponse{Status: performerV1.PerformerStatus_READY_FOR_TASK}, nil
+}
+
+// StartSync is a no-op, as in ponos
+func (s *performerService) StartSync(ctx context.Context, req *performerV1.StartSyncRequest) (*performerV1.StartSyncResponse, error) {
+	return &performerV1.StartSyncResponse{}, nil
+}
+
+// grpcDialOptions are the dial options of ponos's clients, over TLS when
+// tlsConfig is set and in plaintext otherwise. Unlike ponos's NewGrpcClient,
+// the TLS configuration is the caller's, so it can carry client certificates
+// and a private CA.
+func grpcDialOptions(tlsConfig *tls.Config) []grpc.DialOption {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32)),
+		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(math.MaxInt32)),
+	}
+}
+
+// NewPerformerClient returns a performer client, the TLS counterpart of
+// ponos's avsPerformerClient. The ponos executor does not use it: it always
+// dials its performer in plaintext (see tls-proxy).
+func NewPerformerClient(url string, tlsConfig *tls.Config) (performerV1.PerformerServiceClient, error) {
+	conn, err := grpc.NewClient(url, grpcDialOptions(tlsConfig)...)
+	if err != nil {
+		return nil, err
+	}
+	return performerV1.NewPerformerServiceClient(conn), nil
+}
+
+// NewExecutorClient returns an executor client, the TLS counterpart of
+// ponos's executorClient. The ponos aggregator does not use it: it always
+// dials executors in plaintext (see tls-proxy).
+func NewExecutorClient(url string, tlsConfig *tls.Config) (executorV1.ExecutorServiceClient, error) {
+	conn, err := grpc.NewClient(url, grpcDialOptions(tlsConfig)...)
+	if err != nil {
+		return nil, err
+	}
+	return executorV1.NewExecutorServiceClient(conn), nil
+}
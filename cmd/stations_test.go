@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// writeStationFiles writes four stations near Nairobi and their 2024-04-01
+// rainfall; KE004 is outside the default radius and KE003's value failed QC
+func writeStationFiles(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	catalogue := filepath.Join(dir, "stations.csv")
+	writeFile(t, catalogue, `ID,LATITUDE,LONGITUDE,ELEVATION,NAME
+KE001,-1.30,36.80,1661,NAIROBI DAGORETTI
+KE002,-1.20,36.90,1624,NAIROBI JKIA
+KE003,-1.28,36.82,1700,NAIROBI WILSON
+KE004,-0.50,37.50,1800,NYERI
+`)
+	obsDir := filepath.Join(dir, "obs")
+	if err := os.Mkdir(obsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(obsDir, "KE001.csv"), `ID,DATE,ELEMENT,DATA_VALUE,M_FLAG,Q_FLAG,S_FLAG,OBS_TIME
+KE001,20240401,PRCP,120,,,S,
+KE001,20240401,TMAX,254,,,S,
+KE001,20240402,PRCP,-9999,,,S,
+`)
+	writeFile(t, filepath.Join(obsDir, "others.csv"), `KE002,20240401,PRCP,40,,,S,
+KE002,20240402,PRCP,10,,,S,
+KE003,20240401,PRCP,9999,,G,S,
+KE004,20240401,PRCP,300,,,S,
+`)
+	return catalogue, obsDir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestStationProvider(t *testing.T) *StationProvider {
+	t.Helper()
+	catalogue, obs := writeStationFiles(t)
+	p, err := NewStationProvider(StationConfig{
+		Catalogue: catalogue, Observations: obs, Name: "ghcnd",
+		Element: "PRCP", K: 5, MaxRadiusKm: 50, MinStations: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestStationCatalogue_Nearest(t *testing.T) {
+	catalogue, obs := writeStationFiles(t)
+	c, err := LoadStationCatalogue(catalogue, obs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Stations() != 4 {
+		t.Fatalf("Stations() = %d, want 4", c.Stations())
+	}
+
+	at := Location{Latitude: -1.29, Longitude: 36.82}
+	readings := c.Nearest(at, "20240401", "PRCP", 5, 50)
+	if len(readings) != 2 || readings[0].ID != "KE001" || readings[1].ID != "KE002" {
+		t.Fatalf("Nearest() = %+v, want KE001 then KE002", readings)
+	}
+	if readings[0].Value != 12 || readings[1].Value != 4 {
+		t.Errorf("values = %v, %v, want 12 and 4 mm", readings[0].Value, readings[1].Value)
+	}
+	if got := c.Nearest(at, "20240401", "PRCP", 1, 50); len(got) != 1 || got[0].ID != "KE001" {
+		t.Errorf("Nearest(k=1) = %+v", got)
+	}
+	if got := c.Nearest(at, "20240401", "PRCP", 5, 200); len(got) != 3 {
+		t.Errorf("Nearest(200 km) returned %d stations, want 3", len(got))
+	}
+	if got := c.Nearest(at, "20240401", "TMAX", 5, 50); len(got) != 1 || math.Abs(got[0].Value-25.4) > 1e-9 {
+		t.Errorf("Nearest(TMAX) = %+v", got)
+	}
+}
+
+func TestInverseDistanceWeight(t *testing.T) {
+	readings := []StationReading{{ID: "a", DistanceKm: 1, Value: 10}, {ID: "b", DistanceKm: 2, Value: 20}}
+	got := inverseDistanceWeight(readings)
+	if want := (10*1.0 + 20*0.25) / 1.25; math.Abs(got-want) > 1e-9 {
+		t.Errorf("interpolated = %v, want %v", got, want)
+	}
+	if math.Abs(readings[0].Weight-0.8) > 1e-9 || math.Abs(readings[1].Weight-0.2) > 1e-9 {
+		t.Errorf("weights = %v, %v", readings[0].Weight, readings[1].Weight)
+	}
+
+	readings = []StationReading{{ID: "a", DistanceKm: 0.001, Value: 7}, {ID: "b", DistanceKm: 2, Value: 20}}
+	if got := inverseDistanceWeight(readings); got != 7 || readings[0].Weight != 1 || readings[1].Weight != 0 {
+		t.Errorf("co-located station: got %v, weights %+v", got, readings)
+	}
+}
+
+func TestStationProvider_FetchAt(t *testing.T) {
+	p := newTestStationProvider(t)
+	at := Location{Latitude: -1.29, Longitude: 36.82}
+
+	data, err := p.FetchAt(context.Background(), at, time.Date(2024, 4, 1, 15, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Precipitation == nil || *data.Precipitation <= 4 || *data.Precipitation >= 12 {
+		t.Fatalf("precipitation = %v, want between the two station values", data.Precipitation)
+	}
+	if data.Conditions != "Rainy" || data.Source != "ghcnd" || len(data.Stations) != 2 {
+		t.Errorf("unexpected reading: %+v", data)
+	}
+	if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); !data.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want the start of the day", data.Timestamp)
+	}
+	if s := data.Stations[0]; s.ID != "KE001" || s.Weight <= data.Stations[1].Weight {
+		t.Errorf("nearest station should carry the largest weight: %+v", data.Stations)
+	}
+
+	// Only KE002 reported on 2024-04-02
+	_, err = p.FetchAt(context.Background(), at, time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrInsufficientStations) {
+		t.Errorf("FetchAt() with one station = %v, want ErrInsufficientStations", err)
+	}
+}
+
+func TestStationConfig_Validate(t *testing.T) {
+	valid := StationConfig{Name: "ghcnd", Element: "PRCP", K: 5, MaxRadiusKm: 50, MinStations: 2}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	for name, mutate := range map[string]func(*StationConfig){
+		"element":         func(c *StationConfig) { c.Element = "SNOW" },
+		"k":               func(c *StationConfig) { c.K = 0 },
+		"radius":          func(c *StationConfig) { c.MaxRadiusKm = 0 },
+		"min above k":     func(c *StationConfig) { c.MinStations = 6 },
+		"catalogue alone": func(c *StationConfig) { c.Catalogue = "stations.csv" },
+	} {
+		cfg := valid
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate() succeeded", name)
+		}
+	}
+}
+
+func TestHandleTask_StationObservations(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(newTestStationProvider(t))
+
+	payload := `{"location": {"latitude": -1.29, "longitude": 36.82}, "timestamp": 1711983600, "policy_id": "POL-KE-2"}`
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-stations"), Payload: []byte(payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Source  string      `json:"source"`
+		Weather WeatherData `json:"weather"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != "ghcnd" || len(result.Weather.Stations) != 2 || result.Weather.Stations[0].ID != "KE001" {
+		t.Fatalf("unexpected result: %s", resp.Result)
+	}
+}
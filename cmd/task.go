@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/chainPoller"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/clients/ethereum"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/config"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/contractCaller"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/contractCaller/caller"
+	"github.com/Layr-Labs/hourglass-monorepo/ponos/pkg/transactionLogParser/log"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"go.uber.org/zap"
+)
+
+func cmdTaskBuild(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("task build", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	lat := fs.Float64("lat", 0, "latitude of the insured location")
+	lon := fs.Float64("lon", 0, "longitude of the insured location")
+	city := fs.String("city", "", "optional city name")
+	policyID := fs.String("policy", "", "policy ID (required)")
+	timestamp := fs.Int64("timestamp", 0, "verification time as Unix seconds (defaults to now)")
+	payoutPath := fs.String("payout", "", "payout terms JSON file; builds a payout task")
+	requesterKey := fs.String("requester-key", os.Getenv("REQUESTER_KEY"), "hex private key of the creator address; signs the task for its tenant (REQUESTER_KEY)")
+	out := fs.String("o", "", "write the payload to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	var signer *ecdsa.PrivateKey
+	if *requesterKey != "" {
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(*requesterKey, "0x"))
+		if err != nil {
+			fmt.Fprintf(stderr, "task build: invalid requester key: %v\n", err)
+			return exitUsage
+		}
+		signer = key
+	}
+
+	req := WeatherVerificationRequest{
+		Location:  Location{Latitude: *lat, Longitude: *lon, City: *city},
+		Timestamp: *timestamp,
+		PolicyID:  *policyID,
+	}
+	if signer != nil {
+		req.Requester = crypto.PubkeyToAddress(signer.PublicKey).Hex()
+	}
+	if *payoutPath != "" {
+		data, err := os.ReadFile(*payoutPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "task build: %v\n", err)
+			return exitUsage
+		}
+		req.Type, req.Payout = TaskTypePayout, &PayoutTerms{}
+		if err := json.Unmarshal(data, req.Payout); err != nil {
+			fmt.Fprintf(stderr, "task build: invalid payout terms: %v\n", err)
+			return exitUsage
+		}
+	}
+	if req.Timestamp == 0 {
+		req.Timestamp = time.Now().Unix()
+	}
+	if err := req.Validate(); err != nil {
+		fmt.Fprintf(stderr, "task build: %v\n", err)
+		return exitUsage
+	}
+
+	payload, err := json.MarshalIndent(req, "", "  ")
+	if err == nil && signer != nil {
+		payload, err = SignTaskPayload(payload, signer)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "task build: %v\n", err)
+		return exitFailure
+	}
+	payload = append(payload, '\n')
+
+	if *out == "" {
+		stdout.Write(payload)
+		return exitOK
+	}
+	if err := os.WriteFile(*out, payload, 0o644); err != nil {
+		fmt.Fprintf(stderr, "task build: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", *out)
+	return exitOK
+}
+
+func cmdTaskSubmit(args []string, stdout, stderr io.Writer) int {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "config: %v\n", err)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("task submit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	payloadPath := fs.String("payload", "", "path to the task payload JSON, or - for stdin (required)")
+	via := fs.String("via", "events", "submission target: events (aggregator ManualPushChainPoller) or mailbox (on-chain TaskMailbox)")
+	eventsURL := fs.String("events-url", "", "aggregator /events endpoint, e.g. http://localhost:9000/events")
+	avsAddress := fs.String("avs-address", cfg.Chain.AVSAddress, "AVS address (AVS_ADDRESS)")
+	mailboxAddress := fs.String("mailbox-address", cfg.Chain.TaskMailboxAddress, "TaskMailbox address (TASK_MAILBOX_ADDRESS)")
+	registrarAddress := fs.String("registrar-address", cfg.Chain.AVSRegistrarAddress, "TaskAVSRegistrar address (AVS_REGISTRAR_ADDRESS)")
+	rpcURL := fs.String("rpc-url", cfg.Chain.RPCURL, "chain RPC URL (RPC_URL)")
+	operatorSetID := fs.Uint("operator-set-id", 1, "executor operator set ID")
+	deadline := fs.Duration("deadline", time.Minute, "task deadline relative to submission")
+	chainID := fs.Uint("chain-id", uint(config.ChainId_EthereumAnvil), "chain ID stamped on pushed events")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *payloadPath == "" {
+		fmt.Fprintln(stderr, "task submit: -payload is required")
+		return exitUsage
+	}
+	if *avsAddress == "" {
+		fmt.Fprintln(stderr, "task submit: -avs-address is required")
+		return exitUsage
+	}
+
+	payload, err := readPayloadFile(*payloadPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "task submit: failed to read payload: %v\n", err)
+		return exitFailure
+	}
+	var req WeatherVerificationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		fmt.Fprintf(stderr, "task submit: invalid task payload: %v\n", err)
+		return exitUsage
+	}
+	if err := req.Validate(); err != nil {
+		fmt.Fprintf(stderr, "task submit: %v\n", err)
+		return exitUsage
+	}
+
+	switch *via {
+	case "events":
+		if *eventsURL == "" {
+			fmt.Fprintln(stderr, "task submit: -events-url is required with -via events")
+			return exitUsage
+		}
+		taskID, err := newTaskID()
+		if err != nil {
+			fmt.Fprintf(stderr, "task submit: %v\n", err)
+			return exitFailure
+		}
+		event := buildTaskCreatedEvent(taskID, *avsAddress, *mailboxAddress, uint32(*operatorSetID), *deadline, payload, config.ChainId(*chainID))
+		if err := pushTaskEvent(*eventsURL, event); err != nil {
+			fmt.Fprintf(stderr, "task submit: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintf(stdout, "pushed task %s to %s\n", taskID, *eventsURL)
+
+	case "mailbox":
+		if *rpcURL == "" || *mailboxAddress == "" || cfg.Chain.PrivateKey == "" {
+			fmt.Fprintln(stderr, "task submit: -via mailbox requires -rpc-url, -mailbox-address and OPERATOR_KEY")
+			return exitUsage
+		}
+		logger, err := NewLogger(cfg)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to create logger: %v\n", err)
+			return exitFailure
+		}
+		defer logger.Sync()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		cc, closeClient, err := dialContractCaller(ctx, *rpcURL, ChainConfig{
+			PrivateKey:          cfg.Chain.PrivateKey,
+			AVSRegistrarAddress: *registrarAddress,
+			TaskMailboxAddress:  *mailboxAddress,
+		}, logger)
+		if err != nil {
+			fmt.Fprintf(stderr, "task submit: %v\n", err)
+			return exitFailure
+		}
+		defer closeClient()
+		receipt, err := cc.PublishMessageToInbox(ctx, *avsAddress, uint32(*operatorSetID), payload)
+		if err != nil {
+			fmt.Fprintf(stderr, "task submit: failed to publish to mailbox: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintf(stdout, "published task in tx %s (block %d)\n", receipt.TxHash.Hex(), receipt.BlockNumber.Uint64())
+
+	default:
+		fmt.Fprintf(stderr, "task submit: unknown -via %q (want events or mailbox)\n", *via)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// dialContractCaller connects to rpcURL and returns a ponos contract caller
+// signing with chain's private key, and a function closing the connection
+func dialContractCaller(ctx context.Context, rpcURL string, chain ChainConfig, logger *zap.Logger) (contractCaller.IContractCaller, func(), error) {
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to dial %s: %w", rpcURL, err)
+	}
+	cc, err := caller.NewContractCaller(&caller.ContractCallerConfig{
+		PrivateKey:          chain.PrivateKey,
+		AVSRegistrarAddress: chain.AVSRegistrarAddress,
+		TaskMailboxAddress:  chain.TaskMailboxAddress,
+	}, client, logger)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return cc, client.Close, nil
+}
+
+// newTaskID returns a random 32-byte hex task ID for pushed events
+func newTaskID() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate task ID: %w", err)
+	}
+	return "0x" + hex.EncodeToString(b[:]), nil
+}
+
+// buildTaskCreatedEvent builds the decoded TaskCreated log the aggregator expects from its chain poller
+func buildTaskCreatedEvent(
+	taskID string,
+	avsAddress string,
+	mailboxAddress string,
+	operatorSetID uint32,
+	deadline time.Duration,
+	payload []byte,
+	chainID config.ChainId,
+) *chainPoller.LogWithBlock {
+	now := time.Now()
+	return &chainPoller.LogWithBlock{
+		Log: &log.DecodedLog{
+			Address:   strings.ToLower(mailboxAddress),
+			EventName: "TaskCreated",
+			Arguments: []log.Argument{
+				{Name: "creator", Type: "address", Value: strings.ToLower(avsAddress), Indexed: true},
+				{Name: "taskHash", Type: "bytes32", Value: taskID, Indexed: true},
+				{Name: "avs", Type: "address", Value: strings.ToLower(avsAddress), Indexed: true},
+			},
+			OutputData: map[string]interface{}{
+				"executorOperatorSetId": operatorSetID,
+				"taskDeadline":          uint64(deadline.Seconds()),
+				"payload":               payload,
+			},
+		},
+		Block: &ethereum.EthereumBlock{
+			Number:    ethereum.EthereumQuantity(now.Unix()),
+			Hash:      ethereum.EthereumHexString(taskID),
+			Timestamp: ethereum.EthereumQuantity(now.Unix()),
+			ChainId:   chainID,
+		},
+	}
+}
+
+// pushTaskEvent posts a task event to a ManualPushChainPoller /events endpoint
+func pushTaskEvent(eventsURL string, event *chainPoller.LogWithBlock) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode task event: %w", err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(eventsURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to push task event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("aggregator returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
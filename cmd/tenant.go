@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrUnknownRequester is returned for tasks no tenant submitted
+	ErrUnknownRequester = errors.New("unknown requester")
+	// ErrPerilNotAllowed is returned for a peril outside the tenant's plan
+	ErrPerilNotAllowed = errors.New("peril not allowed for tenant")
+	// ErrQuotaExceeded is returned when a tenant has used its task quota
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+	// ErrRequesterSignature is returned for a task its requester did not sign
+	ErrRequesterSignature = errors.New("invalid requester signature")
+)
+
+// requesterSignatureField is the payload member holding the requester's signature
+const requesterSignatureField = "requester_signature"
+
+// TenantQuota bounds the tasks a tenant may submit; zero is unlimited
+type TenantQuota struct {
+	PerMinute int `json:"per_minute,omitempty"`
+	// PerDay resets at midnight UTC
+	PerDay int `json:"per_day,omitempty"`
+}
+
+// Tenant is an insurer submitting tasks through the AVS
+type Tenant struct {
+	// ID names the tenant in metrics, audit records and its policy namespace
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Creators are the on-chain addresses the tenant creates tasks from
+	Creators []string `json:"creators,omitempty"`
+	// APIKeyHashes are the hex SHA-256 of the tenant's dry-run API keys
+	APIKeyHashes []string `json:"api_key_sha256,omitempty"`
+	// Perils lists the perils the tenant may use; empty allows all
+	Perils []string    `json:"perils,omitempty"`
+	Quota  TenantQuota `json:"quota"`
+	// Providers lists the data sources the tenant prefers, in order. Of each
+	// kind of source (weather, METAR, energy) only those named are used;
+	// a kind with none named uses every configured source.
+	Providers []string `json:"providers,omitempty"`
+}
+
+// Label returns the tenant's ID, or "" for a nil tenant
+func (t *Tenant) Label() string {
+	if t == nil {
+		return ""
+	}
+	return t.ID
+}
+
+// Sources returns the tenant's preferred data sources, nil for a nil tenant
+func (t *Tenant) Sources() []string {
+	if t == nil {
+		return nil
+	}
+	return t.Providers
+}
+
+// Allows checks that req is within the tenant's plan. Everything is
+// allowed for a nil tenant.
+func (t *Tenant) Allows(req *WeatherVerificationRequest) error {
+	if t == nil || len(t.Perils) == 0 {
+		return nil
+	}
+	peril, err := req.peril()
+	if err != nil {
+		return err
+	}
+	name := peril.Spec().Name
+	for _, allowed := range t.Perils {
+		if allowed == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s may not use %s", ErrPerilNotAllowed, t.ID, name)
+}
+
+// TenantUsage counts a tenant's tasks
+type TenantUsage struct {
+	Tenant         string `json:"tenant"`
+	TasksProcessed uint64 `json:"tasks_processed"`
+	TasksSucceeded uint64 `json:"tasks_succeeded"`
+	TasksFailed    uint64 `json:"tasks_failed"`
+	// TasksRejected were refused for exceeding the quota
+	TasksRejected uint64    `json:"tasks_rejected"`
+	TasksToday    int       `json:"tasks_today"`
+	LastTaskTime  time.Time `json:"last_task_time,omitempty"`
+}
+
+// tenantState is a tenant with its quota and usage
+type tenantState struct {
+	tenant *Tenant
+	// limiter enforces the per-minute quota; nil when unlimited
+	limiter *rate.Limiter
+	day     string
+	usage   TenantUsage
+}
+
+// TenantRegistry identifies the tenant behind each task and enforces its
+// plan. Results must not differ between operators, so every operator needs
+// the same registry; quotas are counted by each operator on its own. A nil
+// registry serves every requester.
+type TenantRegistry struct {
+	mu        sync.Mutex
+	tenants   map[string]*tenantState
+	byCreator map[string]*tenantState
+	byKeyHash map[string]*tenantState
+	now       func() time.Time
+}
+
+// NewTenantRegistry validates tenants and returns their registry
+func NewTenantRegistry(tenants []Tenant) (*TenantRegistry, error) {
+	r := &TenantRegistry{
+		tenants:   make(map[string]*tenantState),
+		byCreator: make(map[string]*tenantState),
+		byKeyHash: make(map[string]*tenantState),
+		now:       time.Now,
+	}
+	for i := range tenants {
+		t := tenants[i]
+		if t.ID == "" || strings.Contains(t.ID, "/") || r.tenants[t.ID] != nil {
+			return nil, fmt.Errorf("tenant %d: id must be set, unique and contain no /", i)
+		}
+		if len(t.Creators) == 0 && len(t.APIKeyHashes) == 0 {
+			return nil, fmt.Errorf("tenant %s: needs a creator address or an API key", t.ID)
+		}
+		if t.Quota.PerMinute < 0 || t.Quota.PerDay < 0 {
+			return nil, fmt.Errorf("tenant %s: quotas must not be negative", t.ID)
+		}
+		for _, name := range t.Perils {
+			if _, err := perils.Lookup(name); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+			}
+		}
+		s := &tenantState{tenant: &t, usage: TenantUsage{Tenant: t.ID}}
+		if t.Quota.PerMinute > 0 {
+			s.limiter = rate.NewLimiter(rate.Limit(float64(t.Quota.PerMinute)/60), t.Quota.PerMinute)
+		}
+		for j, creator := range t.Creators {
+			if !common.IsHexAddress(creator) {
+				return nil, fmt.Errorf("tenant %s: invalid creator address %q", t.ID, creator)
+			}
+			t.Creators[j] = strings.ToLower(common.HexToAddress(creator).Hex())
+			if owner := r.byCreator[t.Creators[j]]; owner != nil {
+				return nil, fmt.Errorf("tenant %s: creator %s already belongs to %s", t.ID, creator, owner.tenant.ID)
+			}
+			r.byCreator[t.Creators[j]] = s
+		}
+		for j, hash := range t.APIKeyHashes {
+			if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+				return nil, fmt.Errorf("tenant %s: api_key_sha256 %d is not a hex SHA-256", t.ID, j)
+			}
+			t.APIKeyHashes[j] = strings.ToLower(hash)
+			if r.byKeyHash[t.APIKeyHashes[j]] != nil {
+				return nil, fmt.Errorf("tenant %s: API key is already in use", t.ID)
+			}
+			r.byKeyHash[t.APIKeyHashes[j]] = s
+		}
+		r.tenants[t.ID] = s
+	}
+	return r, nil
+}
+
+// LoadTenantRegistry reads a JSON list of tenants from path
+func LoadTenantRegistry(path string) (*TenantRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant registry: %w", err)
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("invalid tenant registry %s: %w", path, err)
+	}
+	return NewTenantRegistry(tenants)
+}
+
+// Authorize returns the tenant that created req, decoded from payload, and
+// checks req is within its plan. Anyone can name a requester, so the payload
+// must carry the requester's signature. The tenant is returned with any plan
+// error, so the refusal can be labeled.
+func (r *TenantRegistry) Authorize(req *WeatherVerificationRequest, payload []byte) (*Tenant, error) {
+	if r == nil {
+		return nil, nil
+	}
+	if req.Requester == "" {
+		return nil, fmt.Errorf("%w: tenants are configured and the task names no requester", ErrUnknownRequester)
+	}
+	s := r.byCreator[strings.ToLower(req.Requester)]
+	if s == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnknownRequester, req.Requester)
+	}
+	if err := verifyRequester(payload, req.Requester, req.RequesterSignature); err != nil {
+		return nil, err
+	}
+	return s.tenant, s.tenant.Allows(req)
+}
+
+// RequesterDigest returns the digest a requester signs: the Keccak-256 of
+// payload without its requester_signature, with the top-level members
+// sorted by name and no whitespace between tokens
+func RequesterDigest(payload []byte) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &members); err != nil {
+		return nil, fmt.Errorf("invalid task payload: %w", err)
+	}
+	delete(members, requesterSignatureField)
+	var canonical bytes.Buffer
+	enc := json.NewEncoder(&canonical)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(members); err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(bytes.TrimSuffix(canonical.Bytes(), []byte("\n"))), nil
+}
+
+// SignTaskPayload signs payload as its requester, which must be key's
+// address. The signature is an EIP-191 personal message signature of
+// RequesterDigest, as wallets make with personal_sign.
+func SignTaskPayload(payload []byte, key *ecdsa.PrivateKey) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &members); err != nil {
+		return nil, fmt.Errorf("invalid task payload: %w", err)
+	}
+	var requester string
+	json.Unmarshal(members["requester"], &requester)
+	if signer := crypto.PubkeyToAddress(key.PublicKey); !strings.EqualFold(requester, signer.Hex()) {
+		return nil, fmt.Errorf("payload requester %q is not the signer %s", requester, signer.Hex())
+	}
+	digest, err := RequesterDigest(payload)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(accounts.TextHash(digest), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	members[requesterSignatureField], _ = json.Marshal(hexutil.Encode(sig))
+	return json.MarshalIndent(members, "", "  ")
+}
+
+// verifyRequester checks that signature is requester's signature of payload
+func verifyRequester(payload []byte, requester, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("%w: the task is not signed by %s", ErrRequesterSignature, requester)
+	}
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("%w: not a 65-byte hex signature", ErrRequesterSignature)
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	digest, err := RequesterDigest(payload)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(digest), sig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRequesterSignature, err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); !strings.EqualFold(signer.Hex(), requester) {
+		return fmt.Errorf("%w: signed by %s, not %s", ErrRequesterSignature, signer.Hex(), requester)
+	}
+	return nil
+}
+
+// ForAPIKey returns the tenant holding key, or nil
+func (r *TenantRegistry) ForAPIKey(key string) *Tenant {
+	if r == nil || key == "" {
+		return nil
+	}
+	if s := r.byKeyHash[sha256Hex([]byte(key))]; s != nil {
+		return s.tenant
+	}
+	return nil
+}
+
+// HasAPIKeys reports whether any tenant has a dry-run API key
+func (r *TenantRegistry) HasAPIKeys() bool {
+	return r != nil && len(r.byKeyHash) > 0
+}
+
+// Owns reports whether requester is one of t's creator addresses
+func (t *Tenant) Owns(requester string) bool {
+	for _, creator := range t.Creators {
+		if creator == strings.ToLower(requester) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollDay starts a new day's count if the UTC day has changed. Callers hold r.mu.
+func (s *tenantState) rollDay(now time.Time) {
+	if day := now.UTC().Format("2006-01-02"); s.day != day {
+		s.day, s.usage.TasksToday = day, 0
+	}
+}
+
+// Admit counts a task against t's quota, refusing it once the quota is used
+func (r *TenantRegistry) Admit(t *Tenant) error {
+	if r == nil || t == nil {
+		return nil
+	}
+	s := r.tenants[t.ID]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	s.rollDay(now)
+	if q := t.Quota.PerDay; q > 0 && s.usage.TasksToday >= q {
+		return fmt.Errorf("%w: %s has used its %d tasks for today", ErrQuotaExceeded, t.ID, q)
+	}
+	if s.limiter != nil && !s.limiter.AllowN(now, 1) {
+		return fmt.Errorf("%w: %s is over %d tasks per minute", ErrQuotaExceeded, t.ID, t.Quota.PerMinute)
+	}
+	s.usage.TasksToday++
+	return nil
+}
+
+// Record counts the outcome of a task of the tenant labeled tenant
+func (r *TenantRegistry) Record(tenant string, taskErr error) {
+	if r == nil {
+		return
+	}
+	s := r.tenants[tenant]
+	if s == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.usage.TasksProcessed++
+	switch {
+	case errors.Is(taskErr, ErrQuotaExceeded):
+		s.usage.TasksRejected++
+	case taskErr != nil:
+		s.usage.TasksFailed++
+	default:
+		s.usage.TasksSucceeded++
+	}
+	s.usage.LastTaskTime = r.now()
+}
+
+// Usage returns every tenant's usage, ordered by tenant
+func (r *TenantRegistry) Usage() []TenantUsage {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	usage := make([]TenantUsage, 0, len(r.tenants))
+	for _, s := range r.tenants {
+		s.rollDay(r.now())
+		usage = append(usage, s.usage)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Tenant < usage[j].Tenant })
+	return usage
+}
+
+// Tenants endpoint: GET lists the tenants and their usage
+func (r *TenantRegistry) tenantsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	type tenantView struct {
+		Tenant
+		Usage TenantUsage `json:"usage"`
+	}
+	only := req.URL.Query().Get("tenant")
+	usage := r.Usage()
+	views := make([]tenantView, 0, len(usage))
+	for _, u := range usage {
+		if only != "" && u.Tenant != only {
+			continue
+		}
+		t := *r.tenants[u.Tenant].tenant
+		t.APIKeyHashes = nil
+		views = append(views, tenantView{Tenant: t, Usage: u})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// preferred returns the items named in names, in that order, or every item
+// when none of them is named
+func preferred[T interface{ Name() string }](items []T, names []string) []T {
+	var out []T
+	for _, name := range names {
+		for _, item := range items {
+			if item.Name() == name {
+				out = append(out, item)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return items
+	}
+	return out
+}
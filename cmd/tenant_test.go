@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+const acmeKey = "acme-key-0123456789"
+
+var (
+	acmeSigner  = testSigner("acme")
+	betaSigner  = testSigner("beta")
+	acmeCreator = crypto.PubkeyToAddress(acmeSigner.PublicKey).Hex()
+	betaCreator = crypto.PubkeyToAddress(betaSigner.PublicKey).Hex()
+)
+
+// testSigner derives a fixed creator key from name
+func testSigner(name string) *ecdsa.PrivateKey {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte(name)))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// signTask signs task as its requester when that is acme or beta
+func signTask(t *testing.T, task *performerV1.TaskRequest) *performerV1.TaskRequest {
+	t.Helper()
+	var req WeatherVerificationRequest
+	if err := json.Unmarshal(task.Payload, &req); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []*ecdsa.PrivateKey{acmeSigner, betaSigner} {
+		if strings.EqualFold(req.Requester, crypto.PubkeyToAddress(key.PublicKey).Hex()) {
+			signed, err := SignTaskPayload(task.Payload, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task.Payload = signed
+		}
+	}
+	return task
+}
+
+// newTenantWorker returns a policy worker serving the acme and beta tenants
+func newTenantWorker(t *testing.T) *SunReWorker {
+	t.Helper()
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+	var err error
+	worker.tenants, err = NewTenantRegistry([]Tenant{
+		{ID: "acme", Creators: []string{acmeCreator}, APIKeyHashes: []string{sha256Hex([]byte(acmeKey))},
+			Perils: []string{"weather"}, Quota: TenantQuota{PerDay: 2}},
+		{ID: "beta", Creators: []string{betaCreator}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return worker
+}
+
+// tenantTask is a payout task on POL-1 created by requester, covering the
+// period starting offset seconds after the usual one
+func tenantTask(t *testing.T, taskID, requester string, offset int64) *performerV1.TaskRequest {
+	t.Helper()
+	task := payoutTask(taskID, "40")
+	payload := strings.Replace(string(task.Payload), `"policy_id"`, fmt.Sprintf(`"requester": %q, "policy_id"`, requester), 1)
+	payload = strings.Replace(payload, `"start": 1704067200`, fmt.Sprintf(`"start": %d`, 1704067200+offset), 1)
+	task.Payload = []byte(payload)
+	return signTask(t, task)
+}
+
+func TestSunReWorker_Tenants(t *testing.T) {
+	worker := newTenantWorker(t)
+	dir := t.TempDir()
+	var err error
+	if worker.audit, err = OpenAuditLog(AuditConfig{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requesters are matched case-insensitively
+	first := tenantTask(t, "acme-1", strings.ToLower(acmeCreator), 0)
+	if _, err := worker.HandleTask(first); err != nil {
+		t.Fatal(err)
+	}
+	// A replay of the settled period uses no quota
+	if _, err := worker.HandleTask(tenantTask(t, "acme-2", acmeCreator, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worker.HandleTask(tenantTask(t, "acme-3", acmeCreator, 1)); err != nil {
+		t.Fatal(err)
+	}
+	// The day's quota is used, but a retry is answered from the results store
+	if _, err := worker.HandleTask(tenantTask(t, "acme-4", acmeCreator, 2)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("over quota = %v", err)
+	}
+	if _, err := worker.HandleTask(first); err != nil {
+		t.Errorf("retry = %v", err)
+	}
+	airport := signTask(t, &performerV1.TaskRequest{TaskId: []byte("acme-airport"), Payload: []byte(fmt.Sprintf(
+		`{"type": "airport", "requester": %q, "policy_id": "POL-2", "location": {"latitude": 1, "longitude": 2}}`, acmeCreator))})
+	if _, err := worker.HandleTask(airport); !errors.Is(err, ErrPerilNotAllowed) {
+		t.Errorf("airport task = %v", err)
+	}
+	for _, requester := range []string{"", "0x00000000000000000000000000000000000000cc"} {
+		task := tenantTask(t, "stranger", requester, 0)
+		if err := worker.ValidateTask(task); !errors.Is(err, ErrUnknownRequester) {
+			t.Errorf("requester %q: ValidateTask() = %v", requester, err)
+		}
+		if _, err := worker.HandleTask(task); !errors.Is(err, ErrUnknownRequester) {
+			t.Errorf("requester %q: HandleTask() = %v", requester, err)
+		}
+	}
+
+	// Naming a tenant's creator is not enough: the task must carry its signature
+	forged := map[string]*performerV1.TaskRequest{
+		"unsigned": {TaskId: []byte("forged"), Payload: []byte(strings.Replace(string(payoutTask("forged", "40").Payload),
+			`"policy_id"`, fmt.Sprintf(`"requester": %q, "policy_id"`, acmeCreator), 1))},
+		"edited": tenantTask(t, "forged", acmeCreator, 3),
+	}
+	forged["edited"].Payload = []byte(strings.Replace(string(forged["edited"].Payload), `"40"`, `"4000"`, 1))
+	for name, task := range forged {
+		if err := worker.ValidateTask(task); !errors.Is(err, ErrRequesterSignature) {
+			t.Errorf("%s: ValidateTask() = %v", name, err)
+		}
+	}
+
+	// The same policy ID is a separate policy for each tenant
+	if _, err := worker.HandleTask(tenantTask(t, "beta-1", betaCreator, 0)); err != nil {
+		t.Fatal(err)
+	}
+	for _, policy := range []string{"acme/POL-1", "beta/POL-1"} {
+		if records := worker.policies.List(policy); len(records) == 0 || records[0].State != PolicySettled {
+			t.Errorf("%s: records = %+v", policy, records)
+		}
+	}
+	if records := worker.policies.List("POL-1"); len(records) != 0 {
+		t.Errorf("unqualified policy has records %+v", records)
+	}
+
+	usage := worker.tenants.Usage()
+	want := []TenantUsage{
+		{Tenant: "acme", TasksProcessed: 6, TasksSucceeded: 4, TasksFailed: 1, TasksRejected: 1, TasksToday: 2},
+		{Tenant: "beta", TasksProcessed: 1, TasksSucceeded: 1, TasksToday: 1},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("usage = %+v", usage)
+	}
+	for i := range want {
+		usage[i].LastTaskTime = time.Time{}
+		if usage[i] != want[i] {
+			t.Errorf("usage[%d] = %+v, want %+v", i, usage[i], want[i])
+		}
+	}
+
+	worker.audit.Close()
+	for taskID, tenant := range map[string]string{"acme-4": "acme", "beta-1": "beta", "stranger": ""} {
+		records, err := FindAuditRecords(dir, taskID)
+		if err != nil || len(records) == 0 || records[0].Tenant != tenant {
+			t.Errorf("%s: audit records %+v, %v", taskID, records, err)
+		}
+	}
+}
+
+func TestRun_TaskBuildSigned(t *testing.T) {
+	t.Setenv("REQUESTER_KEY", "")
+	var stdout, stderr bytes.Buffer
+	key := hex.EncodeToString(crypto.FromECDSA(acmeSigner))
+	if code := run([]string{"task", "build", "-lat", "1", "-lon", "2", "-policy", "POL-1", "-requester-key", key}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("task build = %d, stderr: %s", code, stderr.String())
+	}
+	var req WeatherVerificationRequest
+	if err := json.Unmarshal(stdout.Bytes(), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Requester != acmeCreator {
+		t.Errorf("requester = %q, want %s", req.Requester, acmeCreator)
+	}
+	if err := verifyRequester(stdout.Bytes(), req.Requester, req.RequesterSignature); err != nil {
+		t.Errorf("built task does not verify: %v", err)
+	}
+	if code := run([]string{"task", "build", "-policy", "POL-1", "-requester-key", "zz"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("task build with an invalid key = %d", code)
+	}
+}
+
+func TestTenantRegistry_PerMinuteQuota(t *testing.T) {
+	registry, err := NewTenantRegistry([]Tenant{{ID: "acme", Creators: []string{acmeCreator}, Quota: TenantQuota{PerMinute: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 7, 1, 23, 59, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+	task := tenantTask(t, "acme-1", acmeCreator, 0)
+	var req WeatherVerificationRequest
+	json.Unmarshal(task.Payload, &req)
+	tenant, err := registry.Authorize(&req, task.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := registry.Admit(tenant); err != nil {
+			t.Fatalf("task %d: %v", i, err)
+		}
+	}
+	if err := registry.Admit(tenant); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("third task = %v", err)
+	}
+	// A token comes back every 30 seconds, and the daily count restarts at midnight
+	now = now.Add(30 * time.Second)
+	if err := registry.Admit(tenant); err != nil {
+		t.Errorf("after 30s: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := registry.Admit(tenant); err != nil {
+		t.Errorf("after midnight: %v", err)
+	}
+	if usage := registry.Usage(); usage[0].TasksToday != 1 {
+		t.Errorf("tasks today = %d", usage[0].TasksToday)
+	}
+
+	var unlimited *TenantRegistry
+	if tenant, err := unlimited.Authorize(&WeatherVerificationRequest{}, nil); tenant != nil || err != nil || unlimited.Admit(tenant) != nil {
+		t.Errorf("nil registry refused a task")
+	}
+}
+
+func TestLoadTenantRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	for name, doc := range map[string]string{
+		"missing id":     `[{"creators": ["` + acmeCreator + `"]}]`,
+		"duplicate id":   `[{"id": "a", "creators": ["` + acmeCreator + `"]}, {"id": "a", "creators": ["` + betaCreator + `"]}]`,
+		"slash in id":    `[{"id": "a/b", "creators": ["` + acmeCreator + `"]}]`,
+		"no identity":    `[{"id": "a"}]`,
+		"bad address":    `[{"id": "a", "creators": ["0x12"]}]`,
+		"shared creator": `[{"id": "a", "creators": ["` + acmeCreator + `"]}, {"id": "b", "creators": ["` + strings.ToLower(acmeCreator) + `"]}]`,
+		"unknown peril":  `[{"id": "a", "creators": ["` + acmeCreator + `"], "perils": ["hail"]}]`,
+		"bad key hash":   `[{"id": "a", "api_key_sha256": ["` + acmeKey + `"]}]`,
+		"negative quota": `[{"id": "a", "creators": ["` + acmeCreator + `"], "quota": {"per_day": -1}}]`,
+		"not json":       `tenants`,
+	} {
+		if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTenantRegistry(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"id": "acme", "creators": ["`+acmeCreator+`"], "perils": ["weather", "airport"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := LoadTenantRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := registry.Usage(); len(usage) != 1 || usage[0].Tenant != "acme" {
+		t.Errorf("usage = %+v", usage)
+	}
+	if _, err := LoadTenantRegistry("../examples/tenants.json"); err != nil {
+		t.Errorf("example registry: %v", err)
+	}
+}
+
+func TestFetchWeatherFrom_PreferredProviders(t *testing.T) {
+	now := time.Now().UTC()
+	a := &namedProvider{name: "a", staticProvider: staticProvider{data: WeatherData{Temperature: 20, Source: "a", Timestamp: now}}}
+	b := &namedProvider{name: "b", staticProvider: staticProvider{data: WeatherData{Temperature: 21, Source: "b", Timestamp: now}}}
+	client := NewWeatherClientWithProviders(zap.NewNop(), a, b)
+	location := Location{Latitude: 1, Longitude: 2}
+
+	for _, tc := range []struct {
+		sources []string
+		want    string
+	}{
+		{nil, "a"},
+		// Cached data from another provider is not served
+		{[]string{"b"}, "b"},
+		// but data from any preferred provider is
+		{[]string{"b", "a"}, "a"},
+		// None of a tenant's METAR sources are weather providers
+		{[]string{"metar-archive"}, "a"},
+	} {
+		client.PurgeCache("")
+		if _, err := client.FetchWeather(context.Background(), location); err != nil {
+			t.Fatal(err)
+		}
+		data, err := client.FetchWeatherFrom(context.Background(), location, time.Time{}, tc.sources)
+		if err != nil || data.Source != tc.want {
+			t.Errorf("sources %v: got %+v, %v, want %s", tc.sources, data, err, tc.want)
+		}
+	}
+}
+
+func TestDryRunAPI_TenantKey(t *testing.T) {
+	worker := newTenantWorker(t)
+	srv := newDryRunServer(t, worker)
+	payload := func(requester string) string {
+		return string(tenantTask(t, "dry", requester, 0).Payload)
+	}
+
+	resp, out := dryRunPost(t, srv, "/v1/verify", acmeKey, payload(acmeCreator))
+	if resp.StatusCode != http.StatusOK || out.Diagnostics == nil || !strings.HasPrefix(out.Diagnostics.PolicyPeriod, "acme/POL-1/") {
+		t.Errorf("own task: %d %+v", resp.StatusCode, out)
+	}
+	// A tenant's key does not run another tenant's tasks; the operator's does
+	if resp, out := dryRunPost(t, srv, "/v1/validate", acmeKey, payload(betaCreator)); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("other tenant's task: %d %+v", resp.StatusCode, out)
+	}
+	if resp, out := dryRunPost(t, srv, "/v1/validate", dryRunKey, payload(betaCreator)); resp.StatusCode != http.StatusOK {
+		t.Errorf("operator key: %d %+v", resp.StatusCode, out)
+	}
+	// Dry runs use no quota
+	if usage := worker.tenants.Usage(); usage[0].TasksToday != 0 || usage[0].TasksProcessed != 0 {
+		t.Errorf("dry runs were counted: %+v", usage[0])
+	}
+}
+
+func TestPolicyBook_TenantNamespaces(t *testing.T) {
+	worker := newTenantWorker(t)
+	for _, task := range []*performerV1.TaskRequest{tenantTask(t, "acme-1", acmeCreator, 0), tenantTask(t, "beta-1", betaCreator, 0)} {
+		if _, err := worker.HandleTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/policies", worker.policies.policiesHandler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/policies?tenant=acme&policy=POL-1", nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("cancel: %v", err)
+	}
+	// Settled periods stay settled; acme's next period is refused, beta's is not
+	if _, err := worker.HandleTask(tenantTask(t, "acme-2", acmeCreator, 1)); !errors.Is(err, ErrPolicyCancelled) {
+		t.Errorf("acme after cancel = %v", err)
+	}
+	if _, err := worker.HandleTask(tenantTask(t, "beta-2", betaCreator, 1)); err != nil {
+		t.Errorf("beta after acme cancel = %v", err)
+	}
+}
+
+func TestRequireTenantKey_ScopesReads(t *testing.T) {
+	worker := newTenantWorker(t)
+	for _, task := range []*performerV1.TaskRequest{tenantTask(t, "acme-1", acmeCreator, 0), tenantTask(t, "beta-1", betaCreator, 0)} {
+		if _, err := worker.HandleTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/policies", requireTenantKey(dryRunKey, worker.tenants, worker.policies.policiesHandler, http.MethodGet))
+	mux.HandleFunc("/tenants", requireTenantKey(dryRunKey, worker.tenants, worker.tenants.tenantsHandler, http.MethodGet))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, key string) (int, []string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var rows []struct {
+			Tenant string `json:"tenant"`
+			ID     string `json:"id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&rows)
+		var tenants []string
+		for _, r := range rows {
+			tenants = append(tenants, r.Tenant+r.ID)
+		}
+		return resp.StatusCode, tenants
+	}
+
+	for _, path := range []string{"/policies", "/tenants"} {
+		if code, _ := do(http.MethodGet, path, ""); code != http.StatusUnauthorized {
+			t.Errorf("GET %s without a key = %d", path, code)
+		}
+		if code, got := do(http.MethodGet, path, dryRunKey); code != http.StatusOK || len(got) != 2 {
+			t.Errorf("GET %s with the operator key = %d %v", path, code, got)
+		}
+		// A tenant's key only sees the tenant, whatever it asks for
+		if code, got := do(http.MethodGet, path+"?tenant=beta", acmeKey); code != http.StatusOK || len(got) != 1 || got[0] != "acme" {
+			t.Errorf("GET %s with acme's key = %d %v", path, code, got)
+		}
+	}
+	if code, _ := do(http.MethodDelete, "/policies?tenant=acme&policy=POL-1", acmeKey); code != http.StatusForbidden {
+		t.Errorf("DELETE /policies with a tenant key = %d", code)
+	}
+}
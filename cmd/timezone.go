@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/tzgeo"
+)
+
+// localTimeLayouts are the accepted forms of a coverage period's local times
+var localTimeLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+// parseLocalTime reads a wall-clock time with no zone or offset
+func parseLocalTime(s string) (time.Time, error) {
+	for _, layout := range localTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid local time %q: want YYYY-MM-DDTHH:MM[:SS] with no offset", s)
+}
+
+// Local reports whether the period is declared in civil time
+func (p *CoveragePeriod) Local() bool {
+	return p.LocalStart != "" || p.LocalEnd != ""
+}
+
+// Resolve validates the period. A period declared in local time is
+// converted to UTC in its time zone, resolved from location with the
+// bundled boundaries unless the period names one, and the zone is recorded.
+// Every operator must resolve the same window, which is why the boundaries
+// are compiled in rather than configured. A start skipped by a daylight
+// saving transition resolves to the transition, and a time repeated when
+// clocks fall back starts the window at its first occurrence and ends it at
+// its second, so the window covers every moment whose wall clock is inside
+// it. Start and end, if given alongside local times, must match them.
+func (p *CoveragePeriod) Resolve(location Location) error {
+	if p == nil {
+		return nil
+	}
+	if !p.Local() {
+		if p.TimeZone != "" {
+			return fmt.Errorf("coverage time_zone requires local_start and local_end")
+		}
+		return p.Validate()
+	}
+	if p.LocalStart == "" || p.LocalEnd == "" {
+		return fmt.Errorf("coverage needs both local_start and local_end")
+	}
+	localStart, err := parseLocalTime(p.LocalStart)
+	if err != nil {
+		return fmt.Errorf("invalid coverage local_start: %w", err)
+	}
+	localEnd, err := parseLocalTime(p.LocalEnd)
+	if err != nil {
+		return fmt.Errorf("invalid coverage local_end: %w", err)
+	}
+	if !localEnd.After(localStart) {
+		return fmt.Errorf("invalid coverage period: local_end %s is not after local_start %s", p.LocalEnd, p.LocalStart)
+	}
+	tz := p.TimeZone
+	if tz == "" {
+		if tz, err = tzgeo.Default().Lookup(location.Latitude, location.Longitude); err != nil {
+			return fmt.Errorf("%w; name the zone in coverage time_zone", err)
+		}
+	}
+	// "Local" would read the operator's own clock setting
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		return fmt.Errorf("invalid coverage time_zone %q", tz)
+	}
+
+	start := tzgeo.Resolve(localStart, loc, tzgeo.Earliest).Unix()
+	end := tzgeo.Resolve(localEnd, loc, tzgeo.Latest).Unix()
+	if (p.Start != 0 || p.End != 0) && (p.Start != start || p.End != end) {
+		return fmt.Errorf("coverage start and end [%d, %d) do not match local times in %s [%d, %d)",
+			p.Start, p.End, tz, start, end)
+	}
+	p.Start, p.End, p.TimeZone = start, end, tz
+	return p.Validate()
+}
+
+// CoverageWindow is a local-time coverage period as it was resolved
+type CoverageWindow struct {
+	TimeZone string `json:"time_zone"`
+	// LocalStart and LocalEnd are the window's ends in the zone, with the
+	// UTC offset in force at each, which differ across a DST transition
+	LocalStart string `json:"local_start"`
+	LocalEnd   string `json:"local_end"`
+	// UTCStart and UTCEnd are the window [start, end) in UTC
+	UTCStart string `json:"utc_start"`
+	UTCEnd   string `json:"utc_end"`
+}
+
+// Window returns the resolved window of a period declared in local time,
+// or nil for a UTC period
+func (p *CoveragePeriod) Window() *CoverageWindow {
+	if p == nil || !p.Local() {
+		return nil
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return nil
+	}
+	start, end := time.Unix(p.Start, 0), time.Unix(p.End, 0)
+	return &CoverageWindow{
+		TimeZone:   p.TimeZone,
+		LocalStart: start.In(loc).Format(time.RFC3339),
+		LocalEnd:   end.In(loc).Format(time.RFC3339),
+		UTCStart:   start.UTC().Format(time.RFC3339),
+		UTCEnd:     end.UTC().Format(time.RFC3339),
+	}
+}
+
+// zoneAt returns the time zone at location, or a fixed zone at its mean
+// solar time where the bundled boundaries have none
+func zoneAt(location Location) *time.Location {
+	if tz, err := tzgeo.Default().Lookup(location.Latitude, location.Longitude); err == nil {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	hours := int(math.Round(location.Longitude / 15))
+	return time.FixedZone(fmt.Sprintf("UTC%+d", hours), hours*3600)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+func TestCoveragePeriod_Resolve(t *testing.T) {
+	nyc := Location{Latitude: 40.7128, Longitude: -74.0060}
+	sydney := Location{Latitude: -33.8688, Longitude: 151.2093}
+	ocean := Location{Latitude: 30, Longitude: -40}
+	utc := func(month time.Month, day, hour, min int) int64 {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC).Unix()
+	}
+
+	for _, tc := range []struct {
+		name       string
+		period     CoveragePeriod
+		location   Location
+		start, end int64
+		zone       string
+		err        string
+	}{
+		{
+			name:     "event day in summer",
+			period:   CoveragePeriod{LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00"},
+			location: nyc, start: utc(7, 13, 14, 0), end: utc(7, 13, 22, 0), zone: "America/New_York",
+		},
+		{
+			name:     "southern summer",
+			period:   CoveragePeriod{LocalStart: "2024-12-31T20:00:00", LocalEnd: "2025-01-01T02:00:00"},
+			location: sydney, start: utc(12, 31, 9, 0), end: time.Date(2024, 12, 31, 15, 0, 0, 0, time.UTC).Unix(), zone: "Australia/Sydney",
+		},
+		{
+			// Clocks spring forward at 02:00: the day is 23 hours long
+			name:     "spring forward",
+			period:   CoveragePeriod{LocalStart: "2024-03-10T00:00", LocalEnd: "2024-03-11T00:00"},
+			location: nyc, start: utc(3, 10, 5, 0), end: utc(3, 11, 4, 0), zone: "America/New_York",
+		},
+		{
+			name:     "start in the skipped hour",
+			period:   CoveragePeriod{LocalStart: "2024-03-10T02:30", LocalEnd: "2024-03-10T04:00"},
+			location: nyc, start: utc(3, 10, 7, 0), end: utc(3, 10, 8, 0), zone: "America/New_York",
+		},
+		{
+			// 01:00-02:00 happens twice; the window covers both
+			name:     "fall back",
+			period:   CoveragePeriod{LocalStart: "2024-11-03T01:00", LocalEnd: "2024-11-03T01:59"},
+			location: nyc, start: utc(11, 3, 5, 0), end: utc(11, 3, 6, 59), zone: "America/New_York",
+		},
+		{
+			name:     "named zone",
+			period:   CoveragePeriod{LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00", TimeZone: "Atlantic/Azores"},
+			location: ocean, start: utc(7, 13, 10, 0), end: utc(7, 13, 18, 0), zone: "Atlantic/Azores",
+		},
+		{
+			name:     "matching UTC times",
+			period:   CoveragePeriod{Start: utc(7, 13, 14, 0), End: utc(7, 13, 22, 0), LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00"},
+			location: nyc, start: utc(7, 13, 14, 0), end: utc(7, 13, 22, 0), zone: "America/New_York",
+		},
+		{
+			name:     "UTC period",
+			period:   CoveragePeriod{Start: 100, End: 200},
+			location: nyc, start: 100, end: 200,
+		},
+		{name: "no zone", period: CoveragePeriod{LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00"}, location: ocean, err: "time_zone"},
+		{name: "near a border", period: CoveragePeriod{LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00"}, location: Location{Latitude: 35.9489, Longitude: -85.0269}, err: "too close"},
+		{name: "mismatched UTC times", period: CoveragePeriod{Start: 1, End: 2, LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00"}, location: nyc, err: "do not match"},
+		{name: "offset given", period: CoveragePeriod{LocalStart: "2024-07-13T10:00-04:00", LocalEnd: "2024-07-13T18:00"}, location: nyc, err: "local_start"},
+		{name: "one end", period: CoveragePeriod{LocalStart: "2024-07-13T10:00"}, location: nyc, err: "both"},
+		{name: "backwards", period: CoveragePeriod{LocalStart: "2024-07-13T18:00", LocalEnd: "2024-07-13T10:00"}, location: nyc, err: "not after"},
+		{name: "server zone", period: CoveragePeriod{LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00", TimeZone: "Local"}, location: nyc, err: "time_zone"},
+		{name: "unknown zone", period: CoveragePeriod{LocalStart: "2024-07-13T10:00", LocalEnd: "2024-07-13T18:00", TimeZone: "Mars/Olympus"}, location: nyc, err: "time_zone"},
+		{name: "zone without local times", period: CoveragePeriod{Start: 100, End: 200, TimeZone: "UTC"}, location: nyc, err: "time_zone"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.period
+			err := p.Resolve(tc.location)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Resolve() = %v, want an error mentioning %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Start != tc.start || p.End != tc.end || p.TimeZone != tc.zone {
+				t.Errorf("resolved [%d, %d) in %q, want [%d, %d) in %q", p.Start, p.End, p.TimeZone, tc.start, tc.end, tc.zone)
+			}
+			// A resolved period resolves to itself
+			again := p
+			if err := again.Resolve(tc.location); err != nil || again != p {
+				t.Errorf("re-resolving gave %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestSunReWorker_LocalCoverageWindow(t *testing.T) {
+	worker := NewSunReWorker(zap.NewNop())
+	payload := []byte(`{"type": "payout", "policy_id": "POL-EVENT", "location": {"latitude": 51.5074, "longitude": -0.1278},
+		"coverage": {"local_start": "2024-03-31T00:00", "local_end": "2024-03-31T12:00"},
+		"payout": {"variable": "temperature", "structure": {"kind": "linear", "attachment": "100", "exhaustion": "200", "sum_insured": "1000"}}}`)
+	task := &performerV1.TaskRequest{TaskId: []byte("task-event"), Payload: payload}
+	if err := worker.ValidateTask(task); err != nil {
+		t.Fatalf("ValidateTask() = %v", err)
+	}
+	resp, err := worker.HandleTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// British Summer Time starts at 01:00 on 31 March: the morning is 11 hours long
+	var result struct {
+		Window *CoverageWindow `json:"coverage_window"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	want := CoverageWindow{
+		TimeZone:   "Europe/London",
+		LocalStart: "2024-03-31T00:00:00Z",
+		LocalEnd:   "2024-03-31T12:00:00+01:00",
+		UTCStart:   "2024-03-31T00:00:00Z",
+		UTCEnd:     "2024-03-31T11:00:00Z",
+	}
+	if result.Window == nil || *result.Window != want {
+		t.Errorf("coverage_window = %+v, want %+v", result.Window, want)
+	}
+
+	// Periods declared in UTC get no window
+	utcPayload := strings.Replace(string(payload),
+		`"local_start": "2024-03-31T00:00", "local_end": "2024-03-31T12:00"`, `"start": 1711843200, "end": 1711882800`, 1)
+	resp, err = worker.HandleTask(&performerV1.TaskRequest{TaskId: []byte("task-utc"), Payload: []byte(utcPayload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(resp.Result), "coverage_window") {
+		t.Errorf("UTC period result has a coverage window: %s", resp.Result)
+	}
+}
+
+func TestZoneAt(t *testing.T) {
+	if got := zoneAt(Location{Latitude: 35.6762, Longitude: 139.6503}).String(); got != "Asia/Tokyo" {
+		t.Errorf("Tokyo zone = %q", got)
+	}
+	// Mid-ocean falls back to mean solar time
+	at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC).In(zoneAt(Location{Latitude: 0, Longitude: -150}))
+	if _, offset := at.Zone(); offset != -10*3600 {
+		t.Errorf("mid-Pacific offset = %d", offset)
+	}
+}
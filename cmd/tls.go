@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TLSConfig holds the certificates of one end of a gRPC connection
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM certificate chain and key presented to the peer
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// CAFile verifies the peer: client certificates on a server (mTLS), the
+	// server certificate on a client, where it replaces the system roots
+	CAFile string `json:"ca_file,omitempty"`
+	// ServerName overrides the name verified on the server certificate (clients only)
+	ServerName string `json:"server_name,omitempty"`
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration `json:"reload_interval"`
+}
+
+// Enabled reports whether a server certificate is configured
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Validate checks that the configured files exist and belong together
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("PERFORMER_TLS_CERT and PERFORMER_TLS_KEY must be set together")
+	}
+	if c.CAFile != "" && c.CertFile == "" {
+		return fmt.Errorf("PERFORMER_TLS_CLIENT_CA requires PERFORMER_TLS_CERT")
+	}
+	if c.ReloadInterval <= 0 {
+		return fmt.Errorf("TLS_RELOAD_INTERVAL must be positive")
+	}
+	if c.Enabled() {
+		if _, err := loadTLSFiles(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tlsMaterial is a loaded certificate and CA pool
+type tlsMaterial struct {
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+func loadTLSFiles(c TLSConfig) (*tlsMaterial, error) {
+	m := &tlsMaterial{}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		m.cert = &cert
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA: %w", err)
+		}
+		m.pool = x509.NewCertPool()
+		if !m.pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+	return m, nil
+}
+
+// certReloader serves the current certificate and CA pool, reloading them
+// when their files change so certificates can be rotated without a restart
+type certReloader struct {
+	cfg     TLSConfig
+	logger  *zap.Logger
+	mu      sync.Mutex
+	current *tlsMaterial
+	mtimes  map[string]time.Time
+	checked time.Time
+	now     func() time.Time
+}
+
+func newCertReloader(cfg TLSConfig, logger *zap.Logger) (*certReloader, error) {
+	r := &certReloader{cfg: cfg, logger: logger, now: time.Now}
+	m, err := loadTLSFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.current, r.mtimes, r.checked = m, r.stat(), r.now()
+	return r, nil
+}
+
+func (r *certReloader) stat() map[string]time.Time {
+	mtimes := make(map[string]time.Time)
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			mtimes[path] = info.ModTime()
+		}
+	}
+	return mtimes
+}
+
+// material returns the loaded certificates, reloading them first if the
+// reload interval has passed and a file changed. A failed reload keeps the
+// previous certificates.
+func (r *certReloader) material() *tlsMaterial {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.now().Sub(r.checked) < r.cfg.ReloadInterval {
+		return r.current
+	}
+	r.checked = r.now()
+	mtimes := r.stat()
+	changed := len(mtimes) != len(r.mtimes)
+	for path, mtime := range mtimes {
+		changed = changed || !mtime.Equal(r.mtimes[path])
+	}
+	if !changed {
+		return r.current
+	}
+
+	m, err := loadTLSFiles(r.cfg)
+	if err != nil {
+		r.logger.Warn("Failed to reload TLS certificates, keeping the previous ones", zap.Error(err))
+		return r.current
+	}
+	r.current, r.mtimes = m, mtimes
+	r.logger.Info("Reloaded TLS certificates", zap.String("cert", r.cfg.CertFile), zap.String("ca", r.cfg.CAFile))
+	return r.current
+}
+
+// ServerTLS returns a server configuration that presents the reloadable
+// certificate and, with a CA configured, requires client certificates it signed
+func (r *certReloader) ServerTLS() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			m := r.material()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*m.cert},
+			}
+			if m.pool != nil {
+				cfg.ClientCAs = m.pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientTLS returns a client configuration that presents the reloadable
+// certificate, if any, and verifies the server against the reloadable CA, or
+// the system roots when none is configured
+func (r *certReloader) ClientTLS() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: r.cfg.ServerName}
+	if r.cfg.CertFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.material().cert, nil
+		}
+	}
+	if r.cfg.CAFile != "" {
+		// The CA can change between handshakes, so the chain is verified here
+		// against the current pool instead of a fixed RootCAs
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			opts := x509.VerifyOptions{
+				DNSName:       cs.ServerName,
+				Roots:         r.material().pool,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+	return cfg
+}
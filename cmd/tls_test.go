@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for localhost signed by the CA to dir/name.{crt,key}
+func (ca *testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writeFile(t, certPath, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return certPath, keyPath
+}
+
+func (ca *testCA) write(t *testing.T, path string) string {
+	t.Helper()
+	writeFile(t, path, string(ca.pem))
+	return path
+}
+
+// startTLSPerformer serves a worker answering from a static provider over TLS
+func startTLSPerformer(t *testing.T, cfg TLSConfig) (string, *certReloader) {
+	t.Helper()
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(&staticProvider{data: WeatherData{Temperature: 21, Source: "static", Timestamp: time.Now()}})
+	return startTLSWorker(t, cfg, worker)
+}
+
+// startTLSWorker serves worker over TLS
+func startTLSWorker(t *testing.T, cfg TLSConfig, worker *SunReWorker) (string, *certReloader) {
+	t.Helper()
+	certs, err := newCertReloader(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTLSPerformerServer(worker, certs.ServerTLS(), worker.taskTimeout, zap.NewNop())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), certs
+}
+
+func healthCheck(t *testing.T, addr string, cfg TLSConfig) error {
+	t.Helper()
+	cfg.ReloadInterval = time.Hour
+	certs, err := newCertReloader(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewPerformerClient(addr, certs.ClientTLS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.HealthCheck(ctx, &performerV1.HealthCheckRequest{})
+	return err
+}
+
+func TestTLSPerformer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "operators")
+	caPath := ca.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := ca.issue(t, dir, "performer", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "executor", x509.ExtKeyUsageClientAuth)
+
+	addr, _ := startTLSPerformer(t, TLSConfig{
+		CertFile: serverCert, KeyFile: serverKey, CAFile: caPath, ReloadInterval: time.Hour,
+	})
+
+	trusted := TLSConfig{CAFile: caPath, CertFile: clientCert, KeyFile: clientKey, ServerName: "localhost"}
+	if err := healthCheck(t, addr, trusted); err != nil {
+		t.Fatalf("mTLS health check = %v", err)
+	}
+
+	certs, _ := newCertReloader(TLSConfig{CAFile: caPath, CertFile: clientCert, KeyFile: clientKey, ServerName: "localhost", ReloadInterval: time.Hour}, zap.NewNop())
+	client, err := NewPerformerClient(addr, certs.ClientTLS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-TLS"}`)
+	resp, err := client.ExecuteTask(context.Background(), &performerV1.TaskRequest{TaskId: []byte("task-tls"), Payload: payload})
+	if err != nil || !bytes.Contains(resp.Result, []byte(`"policy_id":"POL-TLS"`)) {
+		t.Fatalf("ExecuteTask over mTLS = %v", err)
+	}
+
+	if err := healthCheck(t, addr, TLSConfig{CAFile: caPath, ServerName: "localhost"}); err == nil {
+		t.Error("a client without a certificate was accepted")
+	}
+	other := newTestCA(t, "someone else")
+	otherPath := other.write(t, filepath.Join(dir, "other.pem"))
+	if err := healthCheck(t, addr, TLSConfig{CAFile: otherPath, CertFile: clientCert, KeyFile: clientKey, ServerName: "localhost"}); err == nil {
+		t.Error("a server certificate from an untrusted CA was accepted")
+	}
+}
+
+// stalledProvider answers only when its context ends
+type stalledProvider struct{}
+
+func (stalledProvider) Name() string { return "stalled" }
+
+func (stalledProvider) FetchCurrent(ctx context.Context, location Location) (*WeatherData, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTLSPerformer_TaskTimeout(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "operators")
+	caPath := ca.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := ca.issue(t, dir, "performer", x509.ExtKeyUsageServerAuth)
+
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(stalledProvider{})
+	worker.taskTimeout = 200 * time.Millisecond
+	addr, _ := startTLSWorker(t, TLSConfig{CertFile: serverCert, KeyFile: serverKey, ReloadInterval: time.Hour}, worker)
+
+	certs, _ := newCertReloader(TLSConfig{CAFile: caPath, ServerName: "localhost", ReloadInterval: time.Hour}, zap.NewNop())
+	client, err := NewPerformerClient(addr, certs.ClientTLS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PERFORMER_TIMEOUT bounds the task as it does behind ponos's server
+	start := time.Now()
+	payload := []byte(`{"location": {"latitude": 1, "longitude": 2}, "policy_id": "POL-SLOW"}`)
+	resp, err := client.ExecuteTask(context.Background(), &performerV1.TaskRequest{TaskId: []byte("task-slow"), Payload: payload})
+	if err != nil || !bytes.Contains(resp.Result, []byte(`"source":"Fallback"`)) {
+		t.Fatalf("ExecuteTask = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("task took %v with a 200ms timeout", elapsed)
+	}
+}
+
+func TestTLSPerformer_ReloadsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	oldCA, newCA := newTestCA(t, "2024"), newTestCA(t, "2025")
+	serverCert, serverKey := oldCA.issue(t, dir, "performer", x509.ExtKeyUsageServerAuth)
+	addr, certs := startTLSPerformer(t, TLSConfig{CertFile: serverCert, KeyFile: serverKey, ReloadInterval: time.Minute})
+
+	oldPath := oldCA.write(t, filepath.Join(dir, "old.pem"))
+	newPath := newCA.write(t, filepath.Join(dir, "new.pem"))
+	if err := healthCheck(t, addr, TLSConfig{CAFile: oldPath, ServerName: "localhost"}); err != nil {
+		t.Fatalf("health check = %v", err)
+	}
+
+	// Rotate the server certificate on disk
+	rotatedCert, rotatedKey := newCA.issue(t, t.TempDir(), "performer", x509.ExtKeyUsageServerAuth)
+	for src, dst := range map[string]string{rotatedCert: serverCert, rotatedKey: serverKey} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, dst, string(data))
+		later := time.Now().Add(time.Minute)
+		os.Chtimes(dst, later, later)
+	}
+
+	// Until the reload interval passes the old certificate is still served
+	if err := healthCheck(t, addr, TLSConfig{CAFile: oldPath, ServerName: "localhost"}); err != nil {
+		t.Errorf("health check before the reload interval = %v", err)
+	}
+	certs.mu.Lock()
+	certs.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	certs.mu.Unlock()
+	if err := healthCheck(t, addr, TLSConfig{CAFile: newPath, ServerName: "localhost"}); err != nil {
+		t.Errorf("health check after rotation = %v", err)
+	}
+
+	// A broken file keeps the last good certificate
+	writeFile(t, serverKey, "not a key")
+	later := time.Now().Add(2 * time.Minute)
+	os.Chtimes(serverKey, later, later)
+	certs.mu.Lock()
+	certs.now = func() time.Time { return time.Now().Add(4 * time.Minute) }
+	certs.mu.Unlock()
+	if err := healthCheck(t, addr, TLSConfig{CAFile: newPath, ServerName: "localhost"}); err != nil {
+		t.Errorf("health check after a failed reload = %v", err)
+	}
+}
+
+func TestRun_PerformerHealthTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "operators")
+	caPath := ca.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := ca.issue(t, dir, "performer", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "cli", x509.ExtKeyUsageClientAuth)
+	addr, _ := startTLSPerformer(t, TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: caPath, ReloadInterval: time.Hour})
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"performer", "health", "-addr", addr,
+		"-tls-ca", caPath, "-tls-cert", clientCert, "-tls-key", clientKey, "-tls-server-name", "localhost"}, &stdout, &stderr)
+	if code != exitOK || strings.TrimSpace(stdout.String()) != "READY_FOR_TASK" {
+		t.Errorf("performer health = %d, %q %s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "operators")
+	cert, key := ca.issue(t, dir, "performer", x509.ExtKeyUsageServerAuth)
+	caPath := ca.write(t, filepath.Join(dir, "ca.pem"))
+
+	valid := TLSConfig{CertFile: cert, KeyFile: key, CAFile: caPath, ReloadInterval: time.Minute}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if err := (TLSConfig{ReloadInterval: time.Minute}).Validate(); err != nil {
+		t.Errorf("TLS disabled: Validate() = %v", err)
+	}
+	for name, cfg := range map[string]TLSConfig{
+		"cert without key": {CertFile: cert, ReloadInterval: time.Minute},
+		"CA without cert":  {CAFile: caPath, ReloadInterval: time.Minute},
+		"CA is not PEM":    {CertFile: cert, KeyFile: key, CAFile: key, ReloadInterval: time.Minute},
+		"key mismatch":     {CertFile: cert, KeyFile: caPath, ReloadInterval: time.Minute},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate() succeeded", name)
+		}
+	}
+}
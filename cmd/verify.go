@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+// Exit codes returned by the verify subcommand
+const (
+	verifyExitMatch    = 0
+	verifyExitMismatch = 1
+	verifyExitError    = 2
+)
+
+// volatileResultFields differ between any two runs and are never compared.
+// latency_ms and operator_id only appear in results from older performers.
+var volatileResultFields = map[string]bool{
+	"latency_ms":        true,
+	"operator_id":       true,
+	"weather.timestamp": true,
+}
+
+// defaultVerifyTolerances are the absolute tolerances applied to numeric fields
+var defaultVerifyTolerances = map[string]float64{
+	"weather.temperature":   0.1,
+	"weather.humidity":      1.0,
+	"weather.wind_speed":    0.5,
+	"weather.pressure":      0.5,
+	"weather.precipitation": 0.1,
+}
+
+// FieldDiff describes the comparison of a single result field
+type FieldDiff struct {
+	Field      string      `json:"field"`
+	Claimed    interface{} `json:"claimed"`
+	Recomputed interface{} `json:"recomputed"`
+	Tolerance  float64     `json:"tolerance,omitempty"`
+	Status     string      `json:"status"`
+}
+
+// Field comparison statuses
+const (
+	diffMatch    = "match"
+	diffMismatch = "mismatch"
+	diffIgnored  = "ignored"
+)
+
+// VerifyReport is the outcome of re-verifying a claimed task result
+type VerifyReport struct {
+	TaskID   string      `json:"task_id"`
+	Replay   string      `json:"replay"`
+	Match    bool        `json:"match"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Fields   []FieldDiff `json:"fields"`
+}
+
+// toleranceFlag collects repeated -tolerance field=value flags
+type toleranceFlag map[string]float64
+
+func (t toleranceFlag) String() string {
+	parts := make([]string, 0, len(t))
+	for k, v := range t {
+		parts = append(parts, fmt.Sprintf("%s=%g", k, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (t toleranceFlag) Set(value string) error {
+	field, raw, ok := strings.Cut(value, "=")
+	if !ok || field == "" {
+		return fmt.Errorf("expected field=value, got %q", value)
+	}
+	tol, err := strconv.ParseFloat(raw, 64)
+	if err != nil || tol < 0 {
+		return fmt.Errorf("invalid tolerance for %s: %q", field, raw)
+	}
+	t[field] = tol
+	return nil
+}
+
+// cassetteInteraction is one recorded weather provider HTTP exchange
+type cassetteInteraction struct {
+	URL    string          `json:"url"`
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// cassetteTransport replays recorded provider responses instead of hitting the network
+type cassetteTransport struct {
+	interactions map[string]cassetteInteraction
+}
+
+// loadCassette reads a JSON array of recorded interactions from path
+func loadCassette(path string) (*cassetteTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var interactions []cassetteInteraction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("invalid cassette: %w", err)
+	}
+	ct := &cassetteTransport{interactions: make(map[string]cassetteInteraction)}
+	for _, i := range interactions {
+		ct.interactions[i.URL] = i
+	}
+	return ct, nil
+}
+
+func (c *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	interaction, ok := c.interactions[req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("no recorded interaction for %s", req.URL)
+	}
+	status := interaction.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(interaction.Body)),
+		Request:    req,
+	}, nil
+}
+
+// loadClaimedResult extracts the raw task output from a result or certificate file.
+// Certificates carry the output as base64 in TaskResponse; anything else is
+// treated as the raw output bytes.
+func loadClaimedResult(data []byte) []byte {
+	var cert struct {
+		TaskResponse []byte `json:"taskResponse"`
+	}
+	if err := json.Unmarshal(data, &cert); err == nil && len(cert.TaskResponse) > 0 {
+		return cert.TaskResponse
+	}
+	return data
+}
+
+// runVerify implements the verify subcommand and returns the process exit code
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	taskPath := fs.String("task", "", "path to the task payload JSON")
+	resultPath := fs.String("result", "", "path to the claimed result (raw output or certificate JSON)")
+	taskID := fs.String("task-id", "", "task ID to recompute with (defaults to the claimed task_id)")
+	cassettePath := fs.String("cassette", "", "path to recorded provider responses to replay")
+	provenancePath := fs.String("provenance", "", "path to a weather provenance record to recompute from")
+	gridDir := fs.String("grid", "", "directory of daily precipitation rasters to recompute from (GRID_* settings apply)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	tolerances := toleranceFlag{}
+	for k, v := range defaultVerifyTolerances {
+		tolerances[k] = v
+	}
+	fs.Var(tolerances, "tolerance", "absolute tolerance for a numeric field, as field=value (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return verifyExitError
+	}
+	if *taskPath == "" || *resultPath == "" {
+		fmt.Fprintln(stderr, "verify: -task and -result are required")
+		fs.Usage()
+		return verifyExitError
+	}
+	sources := 0
+	for _, v := range []string{*cassettePath, *provenancePath, *gridDir} {
+		if v != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		fmt.Fprintln(stderr, "verify: -cassette, -provenance and -grid are mutually exclusive")
+		return verifyExitError
+	}
+
+	payload, err := os.ReadFile(*taskPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: failed to read task payload: %v\n", err)
+		return verifyExitError
+	}
+	resultData, err := os.ReadFile(*resultPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: failed to read claimed result: %v\n", err)
+		return verifyExitError
+	}
+	claimed := loadClaimedResult(resultData)
+
+	report, err := verifyResult(payload, claimed, verifyOptions{
+		TaskID:         *taskID,
+		CassettePath:   *cassettePath,
+		ProvenancePath: *provenancePath,
+		GridDir:        *gridDir,
+		Tolerances:     tolerances,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: %v\n", err)
+		return verifyExitError
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printVerifyReport(stdout, report)
+	}
+
+	if !report.Match {
+		return verifyExitMismatch
+	}
+	return verifyExitMatch
+}
+
+// verifyOptions controls how a claimed result is recomputed
+type verifyOptions struct {
+	TaskID         string
+	CassettePath   string
+	ProvenancePath string
+	GridDir        string
+	Tolerances     map[string]float64
+}
+
+// verifyResult recomputes the result for payload and diffs it against claimed
+func verifyResult(payload, claimed []byte, opts verifyOptions) (*VerifyReport, error) {
+	var claimedDoc map[string]interface{}
+	if err := json.Unmarshal(claimed, &claimedDoc); err != nil {
+		return nil, fmt.Errorf("claimed result is not a JSON object: %w", err)
+	}
+
+	taskID := opts.TaskID
+	if taskID == "" {
+		taskID, _ = claimedDoc["task_id"].(string)
+	}
+	report := &VerifyReport{TaskID: taskID, Replay: "live"}
+
+	worker := NewSunReWorker(zap.NewNop())
+	// Confidence depends on the provider reliability operators are configured with
+	if cfg, err := LoadConfig(); err == nil {
+		worker.confidence = cfg.Confidence
+		worker.metar = metarSources(cfg.Metar)
+		worker.energy = energySources(cfg.Energy)
+	}
+	task := &performerV1.TaskRequest{TaskId: []byte(taskID), Payload: payload}
+	if err := worker.ValidateTask(task); err != nil {
+		return nil, err
+	}
+
+	var recomputed []byte
+	switch {
+	case opts.ProvenancePath != "":
+		report.Replay = "provenance"
+		data, err := os.ReadFile(opts.ProvenancePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read provenance record: %w", err)
+		}
+		var weather WeatherData
+		if err := json.Unmarshal(data, &weather); err != nil {
+			return nil, fmt.Errorf("invalid provenance record: %w", err)
+		}
+		var req WeatherVerificationRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid task payload: %w", err)
+		}
+		if err := req.Coverage.Resolve(req.Location); err != nil {
+			return nil, err
+		}
+		peril, err := req.peril()
+		if err != nil {
+			return nil, err
+		}
+		need := peril.Requirements(&req)
+		provenance := &PerilData{}
+		if need.Weather {
+			provenance.Weather = &weather
+		}
+		// METAR reports and hourly series are not part of the provenance
+		// record; they are read again from the configured sources
+		if need.Metar != nil {
+			if provenance.Metar, provenance.MetarSource, err = worker.fetchMetars(context.Background(), need.Metar, nil); err != nil {
+				return nil, err
+			}
+		}
+		if need.Energy != nil {
+			if provenance.Energy, provenance.EnergySource, err = worker.fetchEnergy(context.Background(), need.Energy, nil); err != nil {
+				return nil, err
+			}
+		}
+		recomputed, err = worker.buildResult(task.TaskId, req, provenance)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		if opts.GridDir != "" {
+			report.Replay = "grid"
+			grid := GridConfig{Name: "gridded", Variable: "precip", Interpolation: "bilinear"}
+			if cfg, err := LoadConfig(); err == nil {
+				grid = cfg.Grid
+			}
+			grid.Dir = opts.GridDir
+			gridded, err := NewGriddedProvider(grid)
+			if err != nil {
+				return nil, err
+			}
+			worker.weatherClient.SetProviders(gridded)
+		}
+		if opts.CassettePath != "" {
+			report.Replay = "cassette"
+			cassette, err := loadCassette(opts.CassettePath)
+			if err != nil {
+				return nil, err
+			}
+			worker.weatherClient.SetProviders(NewOpenMeteoProvider(&http.Client{Transport: cassette}))
+			// Recorded observations are old by the time a dispute is raised;
+			// freshness was checked when the result was signed
+			worker.weatherClient.maxAge = 0
+		} else if opts.GridDir == "" {
+			report.Warnings = append(report.Warnings,
+				"no cassette or provenance given: recomputed from live provider data, which may have moved since the claim")
+		}
+		resp, err := worker.HandleTask(task)
+		if err != nil {
+			return nil, err
+		}
+		recomputed = resp.Result
+	}
+
+	var recomputedDoc map[string]interface{}
+	if err := json.Unmarshal(recomputed, &recomputedDoc); err != nil {
+		return nil, fmt.Errorf("failed to decode recomputed result: %w", err)
+	}
+	if source, _ := recomputedDoc["source"].(string); source == fallbackSource {
+		report.Warnings = append(report.Warnings,
+			"recomputation used fallback weather data; the provider could not be replayed")
+	}
+
+	report.Fields = diffResults(flattenJSON(claimedDoc), flattenJSON(recomputedDoc), opts.Tolerances)
+	report.Match = true
+	for _, f := range report.Fields {
+		if f.Status == diffMismatch {
+			report.Match = false
+			break
+		}
+	}
+	return report, nil
+}
+
+// flattenJSON flattens nested JSON objects into dot-separated field paths
+func flattenJSON(doc map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		if obj, ok := v.(map[string]interface{}); ok && len(obj) > 0 {
+			for k, child := range obj {
+				if prefix == "" {
+					walk(k, child)
+				} else {
+					walk(prefix+"."+k, child)
+				}
+			}
+			return
+		}
+		out[prefix] = v
+	}
+	walk("", doc)
+	return out
+}
+
+// diffResults compares flattened claimed and recomputed results field by field
+func diffResults(claimed, recomputed map[string]interface{}, tolerances map[string]float64) []FieldDiff {
+	fields := make(map[string]bool)
+	for k := range claimed {
+		fields[k] = true
+	}
+	for k := range recomputed {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	diffs := make([]FieldDiff, 0, len(names))
+	for _, name := range names {
+		c, inClaimed := claimed[name]
+		r, inRecomputed := recomputed[name]
+		d := FieldDiff{Field: name, Claimed: c, Recomputed: r, Tolerance: tolerances[name]}
+		switch {
+		case volatileResultFields[name]:
+			d.Status = diffIgnored
+		case !inClaimed || !inRecomputed:
+			d.Status = diffMismatch
+		default:
+			cf, cNum := c.(float64)
+			rf, rNum := r.(float64)
+			if cNum && rNum {
+				if math.Abs(cf-rf) <= d.Tolerance {
+					d.Status = diffMatch
+				} else {
+					d.Status = diffMismatch
+				}
+			} else if fmt.Sprint(c) == fmt.Sprint(r) {
+				d.Status = diffMatch
+			} else {
+				d.Status = diffMismatch
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// printVerifyReport writes a human-readable diff table
+func printVerifyReport(w io.Writer, report *VerifyReport) {
+	fmt.Fprintf(w, "Task:   %s\n", report.TaskID)
+	fmt.Fprintf(w, "Replay: %s\n", report.Replay)
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "WARNING: %s\n", warning)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tFIELD\tCLAIMED\tRECOMPUTED\tTOLERANCE")
+	for _, f := range report.Fields {
+		tol := "-"
+		if f.Tolerance > 0 {
+			tol = strconv.FormatFloat(f.Tolerance, 'g', -1, 64)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%v\t%v\t%s\n", f.Status, f.Field, f.Claimed, f.Recomputed, tol)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if report.Match {
+		fmt.Fprintln(w, "RESULT: MATCH")
+	} else {
+		fmt.Fprintln(w, "RESULT: MISMATCH")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	performerV1 "github.com/Layr-Labs/protocol-apis/gen/protos/eigenlayer/hourglass/v1/performer"
+	"go.uber.org/zap"
+)
+
+const verifyTestPayload = `{
+	"location": {"latitude": 40.7128, "longitude": -74.0060, "city": "New York"},
+	"timestamp": 1704067200,
+	"policy_id": "POL-001"
+}`
+
+// writeVerifyFixtures records a cassette and a claimed result produced from it
+func writeVerifyFixtures(t *testing.T) (dir string, claimed map[string]interface{}) {
+	t.Helper()
+	dir = t.TempDir()
+
+	loc := Location{Latitude: 40.7128, Longitude: -74.0060, City: "New York"}
+	cassette := []cassetteInteraction{{
+		URL:  openMeteoURL(loc),
+		Body: json.RawMessage(`{"current":{"time":"2024-01-01T00:00","temperature_2m":3.4,"relative_humidity_2m":71,"wind_speed_10m":12.2,"surface_pressure":1012.8,"weather_code":61}}`),
+	}}
+	writeJSONFile(t, filepath.Join(dir, "cassette.json"), cassette)
+	if err := os.WriteFile(filepath.Join(dir, "task.json"), []byte(verifyTestPayload), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ct, err := loadCassette(filepath.Join(dir, "cassette.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker := NewSunReWorker(zap.NewNop())
+	worker.weatherClient.SetProviders(NewOpenMeteoProvider(&http.Client{Transport: ct}))
+	worker.weatherClient.now = func() time.Time { return time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC) }
+	resp, err := worker.HandleTask(&performerV1.TaskRequest{
+		TaskId:  []byte("task-verify-1"),
+		Payload: []byte(verifyTestPayload),
+	})
+	if err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	if err := json.Unmarshal(resp.Result, &claimed); err != nil {
+		t.Fatal(err)
+	}
+	return dir, claimed
+}
+
+func writeJSONFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunVerify_Cassette(t *testing.T) {
+	dir, claimed := writeVerifyFixtures(t)
+
+	tests := []struct {
+		name     string
+		mutate   func(doc map[string]interface{})
+		args     []string
+		wantCode int
+	}{
+		{
+			name:     "identical result",
+			mutate:   func(doc map[string]interface{}) {},
+			wantCode: verifyExitMatch,
+		},
+		{
+			name: "volatile fields differ",
+			mutate: func(doc map[string]interface{}) {
+				doc["operator_id"] = "another-operator"
+				doc["latency_ms"] = 900
+			},
+			wantCode: verifyExitMatch,
+		},
+		{
+			name: "temperature within tolerance",
+			mutate: func(doc map[string]interface{}) {
+				doc["weather"].(map[string]interface{})["temperature"] = 3.45
+			},
+			wantCode: verifyExitMatch,
+		},
+		{
+			name: "temperature outside tolerance",
+			mutate: func(doc map[string]interface{}) {
+				doc["weather"].(map[string]interface{})["temperature"] = 9.0
+			},
+			wantCode: verifyExitMismatch,
+		},
+		{
+			name: "temperature within overridden tolerance",
+			mutate: func(doc map[string]interface{}) {
+				doc["weather"].(map[string]interface{})["temperature"] = 9.0
+			},
+			args:     []string{"-tolerance", "weather.temperature=10"},
+			wantCode: verifyExitMatch,
+		},
+		{
+			name: "policy differs",
+			mutate: func(doc map[string]interface{}) {
+				doc["policy_id"] = "POL-999"
+			},
+			wantCode: verifyExitMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			raw, _ := json.Marshal(claimed)
+			json.Unmarshal(raw, &doc)
+			tt.mutate(doc)
+			resultPath := filepath.Join(t.TempDir(), "result.json")
+			writeJSONFile(t, resultPath, doc)
+
+			args := append([]string{
+				"-task", filepath.Join(dir, "task.json"),
+				"-result", resultPath,
+				"-cassette", filepath.Join(dir, "cassette.json"),
+			}, tt.args...)
+			var stdout, stderr bytes.Buffer
+			if code := runVerify(args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("runVerify() = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunVerify_Certificate(t *testing.T) {
+	dir, claimed := writeVerifyFixtures(t)
+
+	output, _ := json.Marshal(claimed)
+	certPath := filepath.Join(dir, "cert.json")
+	writeJSONFile(t, certPath, map[string]interface{}{
+		"TaskId":       []byte("task-verify-1"),
+		"TaskResponse": output,
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := runVerify([]string{
+		"-task", filepath.Join(dir, "task.json"),
+		"-result", certPath,
+		"-cassette", filepath.Join(dir, "cassette.json"),
+		"-json",
+	}, &stdout, &stderr)
+	if code != verifyExitMatch {
+		t.Fatalf("runVerify() = %d, want %d\n%s%s", code, verifyExitMatch, stdout.String(), stderr.String())
+	}
+
+	var report VerifyReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON report: %v", err)
+	}
+	if report.TaskID != "task-verify-1" || report.Replay != "cassette" || !report.Match {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestRunVerify_Provenance(t *testing.T) {
+	dir, claimed := writeVerifyFixtures(t)
+	resultPath := filepath.Join(dir, "result.json")
+	writeJSONFile(t, resultPath, claimed)
+	provenancePath := filepath.Join(dir, "provenance.json")
+	writeJSONFile(t, provenancePath, claimed["weather"])
+
+	var stdout, stderr bytes.Buffer
+	code := runVerify([]string{
+		"-task", filepath.Join(dir, "task.json"),
+		"-result", resultPath,
+		"-provenance", provenancePath,
+	}, &stdout, &stderr)
+	if code != verifyExitMatch {
+		t.Errorf("runVerify() = %d, want %d\n%s%s", code, verifyExitMatch, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunVerify_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runVerify([]string{"-task", "missing.json"}, &stdout, &stderr); code != verifyExitError {
+		t.Errorf("runVerify() = %d, want %d", code, verifyExitError)
+	}
+}
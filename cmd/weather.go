@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Layr-Labs/hourglass-avs-template/pkg/payout"
+)
+
+// weatherPerilName selects weather verification, the default peril
+const weatherPerilName = "weather"
+
+// weatherVariables are the weather fields a payout index can be read from
+var weatherVariables = []string{"precipitation", "temperature", "wind_speed", "humidity", "pressure"}
+
+// weatherSchema is the JSON Schema of weather and payout task payloads
+const weatherSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Weather verification task",
+  "type": "object",
+  "required": ["policy_id", "location"],
+  "properties": {
+    "type": {"enum": ["", "weather", "payout"]},
+    "policy_id": {"type": "string", "minLength": 1},
+    "requester": {"type": "string", "description": "address that created the task; selects its tenant"},
+    "peril": {"type": "string"},
+    "location": {
+      "type": "object",
+      "required": ["latitude", "longitude"],
+      "properties": {
+        "latitude": {"type": "number", "minimum": -90, "maximum": 90},
+        "longitude": {"type": "number", "minimum": -180, "maximum": 180},
+        "city": {"type": "string"},
+        "region": {
+          "type": "object",
+          "required": ["min_latitude", "min_longitude", "max_latitude", "max_longitude"],
+          "properties": {
+            "min_latitude": {"type": "number"}, "min_longitude": {"type": "number"},
+            "max_latitude": {"type": "number"}, "max_longitude": {"type": "number"}
+          }
+        }
+      }
+    },
+    "timestamp": {"type": "integer", "description": "Unix seconds; defaults to now"},
+    "min_confidence": {"type": "number", "minimum": 0, "maximum": 1},
+    "coverage": {
+      "type": "object",
+      "anyOf": [{"required": ["start", "end"]}, {"required": ["local_start", "local_end"]}],
+      "properties": {
+        "start": {"type": "integer"}, "end": {"type": "integer"},
+        "local_start": {"type": "string", "description": "wall-clock time at the location, YYYY-MM-DDTHH:MM[:SS]"},
+        "local_end": {"type": "string"},
+        "time_zone": {"type": "string", "description": "IANA zone of the local times; resolved from the location when empty"}
+      }
+    },
+    "payout": {
+      "type": "object",
+      "required": ["structure", "variable"],
+      "properties": {
+        "structure": {"type": "object"},
+        "variable": {"enum": ["precipitation", "temperature", "wind_speed", "humidity", "pressure"]}
+      }
+    }
+  }
+}`
+
+// weatherPeril verifies a claim against the weather observed at the
+// policy's location, scoring the observation's confidence
+type weatherPeril struct{}
+
+func (weatherPeril) Spec() PerilSpec {
+	return PerilSpec{
+		Name:        weatherPerilName,
+		Description: "Weather observed at a location and time, with a confidence score; payout tasks apply a payout curve to one weather variable",
+		Variables:   weatherVariables,
+		Schema:      json.RawMessage(weatherSchema),
+	}
+}
+
+func (weatherPeril) Validate(req *WeatherVerificationRequest) error {
+	return nil
+}
+
+// Requirements asks for the weather
+func (weatherPeril) Requirements(req *WeatherVerificationRequest) DataRequirements {
+	return DataRequirements{Weather: true}
+}
+
+func (weatherPeril) Evaluate(env PerilEnv, req *WeatherVerificationRequest, data *PerilData) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if data.Weather == nil {
+		return fields, nil
+	}
+	// The data may be shared with the cache, so the score goes on a copy
+	assessed := *data.Weather
+	confidence := env.Confidence.Assess(&assessed, time.Unix(env.Timestamp, 0))
+	assessed.Confidence = confidence.Score
+
+	fields["weather"] = &assessed
+	// Only an observation verifies a claim: fallback data, or data nothing
+	// vouches for, never does, whatever min_confidence allows
+	fields["verified"] = data.Weather.Source != fallbackSource &&
+		confidence.Score > 0 && confidence.Score >= req.MinConfidence
+	fields["observed_at"] = data.Weather.Timestamp.Unix()
+	fields["confidence"] = confidence.Score
+	fields["confidence_inputs"] = confidence
+	fields["source"] = data.Weather.Source
+	return fields, nil
+}
+
+func (weatherPeril) Index(req *WeatherVerificationRequest, data *PerilData, variable string) (payout.Decimal, error) {
+	if data.Weather == nil {
+		return 0, fmt.Errorf("no weather data to read %s from", variable)
+	}
+	return weatherIndex(data.Weather, variable)
+}
+
+func (weatherPeril) Encode(result map[string]interface{}) ([]byte, error) {
+	return canonicalJSON(result)
+}
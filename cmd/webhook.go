@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Webhook event types
+const (
+	// WebhookClaimVerified is sent when a verification returns verified: true
+	WebhookClaimVerified = "claim.verified"
+	// WebhookPayoutTriggered is sent when a payout task's index reaches the curve
+	WebhookPayoutTriggered = "payout.triggered"
+)
+
+// Headers sent with every webhook
+const (
+	WebhookEventHeader     = "X-SunRe-Event"
+	WebhookDeliveryHeader  = "X-SunRe-Delivery"
+	WebhookTimestampHeader = "X-SunRe-Timestamp"
+	WebhookSignatureHeader = "X-SunRe-Signature"
+)
+
+// maxDeadLetters bounds the dead letters kept; the oldest go first
+const maxDeadLetters = 1000
+
+// WebhookConfig configures outbound webhook notifications
+type WebhookConfig struct {
+	// Endpoints is the JSON file listing the endpoints; empty disables webhooks
+	Endpoints string `json:"endpoints,omitempty"`
+	// Outbox persists undelivered and dead-lettered notifications; empty keeps them in memory
+	Outbox string `json:"outbox,omitempty"`
+	// MaxAttempts is the deliveries tried before a notification is dead-lettered
+	MaxAttempts int `json:"max_attempts"`
+	// BaseDelay is the backoff after the first failure; it doubles per attempt up to MaxDelay
+	BaseDelay time.Duration `json:"base_delay"`
+	MaxDelay  time.Duration `json:"max_delay"`
+	// Timeout bounds each delivery
+	Timeout time.Duration `json:"timeout"`
+}
+
+// DefaultWebhookConfig returns the retry settings used by the performer
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Minute, Timeout: 10 * time.Second}
+}
+
+// Validate checks the webhook settings
+func (c WebhookConfig) Validate() error {
+	if c.MaxAttempts <= 0 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	if c.BaseDelay <= 0 || c.MaxDelay < c.BaseDelay {
+		return fmt.Errorf("WEBHOOK_BASE_DELAY must be positive and at most WEBHOOK_MAX_DELAY")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT must be positive")
+	}
+	return nil
+}
+
+// WebhookEndpoint is a receiver of webhooks
+type WebhookEndpoint struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Secret keys the HMAC-SHA256 signature of each delivery
+	Secret string `json:"secret"`
+	// Events and Policies filter what is sent; empty sends everything
+	Events   []string `json:"events,omitempty"`
+	Policies []string `json:"policies,omitempty"`
+}
+
+// matches reports whether the endpoint wants ev
+func (e *WebhookEndpoint) matches(ev *WebhookEvent) bool {
+	return (len(e.Events) == 0 || containsString(e.Events, ev.Type)) &&
+		(len(e.Policies) == 0 || containsString(e.Policies, ev.PolicyID))
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadWebhookEndpoints reads and validates the endpoints in path
+func LoadWebhookEndpoints(path string) ([]WebhookEndpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook endpoints: %w", err)
+	}
+	var endpoints []WebhookEndpoint
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("invalid webhook endpoints %s: %w", path, err)
+	}
+	seen := make(map[string]bool)
+	for i, e := range endpoints {
+		if e.Name == "" || seen[e.Name] {
+			return nil, fmt.Errorf("webhook endpoint %d: name must be set and unique", i)
+		}
+		seen[e.Name] = true
+		if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook endpoint %s: invalid url %q", e.Name, e.URL)
+		}
+		if e.Secret == "" {
+			return nil, fmt.Errorf("webhook endpoint %s: secret is required", e.Name)
+		}
+		for _, ev := range e.Events {
+			if ev != WebhookClaimVerified && ev != WebhookPayoutTriggered {
+				return nil, fmt.Errorf("webhook endpoint %s: unknown event %q", e.Name, ev)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// WebhookEvent is the body of a webhook
+type WebhookEvent struct {
+	// ID is derived from the task and type, so receivers can drop duplicates
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	TaskID    string          `json:"task_id"`
+	PolicyID  string          `json:"policy_id"`
+	Result    json.RawMessage `json:"result"`
+}
+
+// webhookEvents returns the events a task result raises
+func webhookEvents(taskID string, result []byte, now time.Time) ([]*WebhookEvent, error) {
+	var outcome struct {
+		PolicyID string `json:"policy_id"`
+		Verified bool   `json:"verified"`
+		Type     string `json:"type"`
+		Payout   *struct {
+			Triggered bool `json:"triggered"`
+		} `json:"payout"`
+	}
+	if err := json.Unmarshal(result, &outcome); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	var types []string
+	if outcome.Verified {
+		types = append(types, WebhookClaimVerified)
+	}
+	if outcome.Type == TaskTypePayout && outcome.Payout != nil && outcome.Payout.Triggered {
+		types = append(types, WebhookPayoutTriggered)
+	}
+	events := make([]*WebhookEvent, 0, len(types))
+	for _, typ := range types {
+		events = append(events, &WebhookEvent{
+			ID:        sha256Hex([]byte(typ + "\x00" + taskID))[:32],
+			Type:      typ,
+			CreatedAt: now.UTC(),
+			TaskID:    taskID,
+			PolicyID:  outcome.PolicyID,
+			Result:    append(json.RawMessage(nil), result...),
+		})
+	}
+	return events, nil
+}
+
+// SignWebhook returns the signature header value for body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a received webhook's signature, and that its
+// timestamp is within tolerance of now to refuse replays
+func VerifyWebhook(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid webhook timestamp")
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return errors.New("webhook timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(header.Get(WebhookSignatureHeader)), []byte(SignWebhook(secret, ts, body))) {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
+
+// WebhookDelivery is one event on its way to one endpoint
+type WebhookDelivery struct {
+	ID          string       `json:"id"`
+	Endpoint    string       `json:"endpoint"`
+	Event       WebhookEvent `json:"event"`
+	Attempts    int          `json:"attempts"`
+	NextAttempt time.Time    `json:"next_attempt"`
+	LastError   string       `json:"last_error,omitempty"`
+	// DeadAt is set once the delivery is dead-lettered
+	DeadAt *time.Time `json:"dead_at,omitempty"`
+}
+
+// WebhookOutbox is the undelivered and dead-lettered notifications
+type WebhookOutbox struct {
+	Pending []*WebhookDelivery `json:"pending"`
+	Dead    []*WebhookDelivery `json:"dead"`
+}
+
+// Notifier sends HMAC-signed webhooks for triggered policies. Every
+// notification is saved to the outbox before it is attempted and is retried
+// with exponential backoff until it is delivered, or dead-lettered after
+// MaxAttempts or a permanent (4xx) refusal.
+type Notifier struct {
+	cfg       WebhookConfig
+	endpoints map[string]WebhookEndpoint
+	order     []string
+	client    *http.Client
+	logger    *zap.Logger
+	mu        sync.Mutex
+	pending   map[string]*WebhookDelivery
+	dead      []*WebhookDelivery
+	wake      chan struct{}
+	now       func() time.Time
+}
+
+// NewNotifier returns a notifier for endpoints, loading undelivered
+// notifications from cfg.Outbox. Pending deliveries to endpoints no longer
+// configured are dead-lettered.
+func NewNotifier(cfg WebhookConfig, endpoints []WebhookEndpoint, logger *zap.Logger) (*Notifier, error) {
+	n := &Notifier{
+		cfg:       cfg,
+		endpoints: make(map[string]WebhookEndpoint),
+		client:    &http.Client{Timeout: cfg.Timeout},
+		logger:    logger,
+		pending:   make(map[string]*WebhookDelivery),
+		wake:      make(chan struct{}, 1),
+		now:       time.Now,
+	}
+	for _, e := range endpoints {
+		n.endpoints[e.Name] = e
+		n.order = append(n.order, e.Name)
+	}
+	if cfg.Outbox == "" {
+		return n, nil
+	}
+	data, err := os.ReadFile(cfg.Outbox)
+	if errors.Is(err, os.ErrNotExist) {
+		return n, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook outbox: %w", err)
+	}
+	var outbox WebhookOutbox
+	if err := json.Unmarshal(data, &outbox); err != nil {
+		return nil, fmt.Errorf("invalid webhook outbox %s: %w", cfg.Outbox, err)
+	}
+	n.dead = outbox.Dead
+	for _, d := range outbox.Pending {
+		if _, ok := n.endpoints[d.Endpoint]; !ok {
+			n.deadLetterLocked(d, "endpoint no longer configured")
+			continue
+		}
+		n.pending[d.ID] = d
+	}
+	return n, nil
+}
+
+// Notify queues the webhooks a task result raises. A nil notifier sends nothing.
+func (n *Notifier) Notify(taskID string, result []byte) error {
+	if n == nil {
+		return nil
+	}
+	now := n.now()
+	events, err := webhookEvents(taskID, result, now)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	n.mu.Lock()
+	queued := 0
+	for _, ev := range events {
+		for _, name := range n.order {
+			e := n.endpoints[name]
+			id := ev.ID + "/" + name
+			if _, ok := n.pending[id]; ok || !e.matches(ev) {
+				continue
+			}
+			n.pending[id] = &WebhookDelivery{ID: id, Endpoint: name, Event: *ev, NextAttempt: now.UTC()}
+			queued++
+		}
+	}
+	err = n.saveLocked()
+	n.mu.Unlock()
+	if queued > 0 {
+		n.signal()
+	}
+	return err
+}
+
+// signal wakes the delivery loop
+func (n *Notifier) signal() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers queued webhooks until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-n.wake:
+		}
+		next := n.deliverDue(ctx)
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// deliverDue attempts every delivery that is due and returns when the next
+// one falls due, or zero if none is queued
+func (n *Notifier) deliverDue(ctx context.Context) time.Time {
+	n.mu.Lock()
+	now := n.now()
+	var due []*WebhookDelivery
+	for _, d := range n.pending {
+		if !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	n.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+
+	for _, d := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		n.attempt(ctx, d)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var next time.Time
+	for _, d := range n.pending {
+		if next.IsZero() || d.NextAttempt.Before(next) {
+			next = d.NextAttempt
+		}
+	}
+	return next
+}
+
+// attempt sends d once and records the outcome
+func (n *Notifier) attempt(ctx context.Context, d *WebhookDelivery) {
+	n.mu.Lock()
+	endpoint := n.endpoints[d.Endpoint]
+	n.mu.Unlock()
+	permanent, err := n.send(ctx, endpoint, d)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	d.Attempts++
+	fields := []zap.Field{
+		zap.String("delivery", d.ID),
+		zap.String("endpoint", d.Endpoint),
+		zap.String("event", d.Event.Type),
+		zap.Int("attempt", d.Attempts),
+	}
+	switch {
+	case err == nil:
+		delete(n.pending, d.ID)
+		n.logger.Info("Delivered webhook", fields...)
+	case permanent || d.Attempts >= n.cfg.MaxAttempts:
+		delete(n.pending, d.ID)
+		n.deadLetterLocked(d, err.Error())
+		n.logger.Error("Dead-lettered webhook", append(fields, zap.Error(err))...)
+	default:
+		d.LastError = err.Error()
+		d.NextAttempt = n.now().Add(n.backoff(d.Attempts)).UTC()
+		n.logger.Warn("Webhook delivery failed, will retry", append(fields, zap.Error(err), zap.Time("next", d.NextAttempt))...)
+	}
+	if err := n.saveLocked(); err != nil {
+		n.logger.Error("Failed to save webhook outbox", zap.Error(err))
+	}
+}
+
+// send posts d to endpoint, reporting whether a failure is permanent
+func (n *Notifier) send(ctx context.Context, endpoint WebhookEndpoint, d *WebhookDelivery) (bool, error) {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return true, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	ts := n.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.Event.Type)
+	req.Header.Set(WebhookDeliveryHeader, d.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, ts, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("endpoint returned %s", resp.Status)
+	// Timeouts and rate limits are worth retrying; other refusals are not
+	permanent := resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+	return permanent, err
+}
+
+// backoff returns the delay after the attempt-th failed delivery
+func (n *Notifier) backoff(attempt int) time.Duration {
+	delay := n.cfg.BaseDelay << (attempt - 1)
+	if delay > n.cfg.MaxDelay || delay <= 0 {
+		delay = n.cfg.MaxDelay
+	}
+	return delay
+}
+
+// deadLetterLocked moves d to the dead letters. Callers hold n.mu.
+func (n *Notifier) deadLetterLocked(d *WebhookDelivery, reason string) {
+	at := n.now().UTC()
+	d.LastError, d.DeadAt = reason, &at
+	n.dead = append(n.dead, d)
+	if len(n.dead) > maxDeadLetters {
+		n.dead = n.dead[len(n.dead)-maxDeadLetters:]
+	}
+}
+
+// Redeliver moves a dead letter back to the outbox for another round of attempts
+func (n *Notifier) Redeliver(id string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, d := range n.dead {
+		if d.ID != id {
+			continue
+		}
+		if _, ok := n.endpoints[d.Endpoint]; !ok {
+			return fmt.Errorf("endpoint %s is no longer configured", d.Endpoint)
+		}
+		n.dead = append(n.dead[:i], n.dead[i+1:]...)
+		d.Attempts, d.DeadAt, d.NextAttempt = 0, nil, n.now().UTC()
+		n.pending[d.ID] = d
+		n.signal()
+		return n.saveLocked()
+	}
+	return fmt.Errorf("no dead letter %q", id)
+}
+
+// Outbox returns copies of the pending deliveries, oldest first, and the dead letters
+func (n *Notifier) Outbox() WebhookOutbox {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := WebhookOutbox{Pending: make([]*WebhookDelivery, 0, len(n.pending)), Dead: make([]*WebhookDelivery, 0, len(n.dead))}
+	for _, d := range n.pending {
+		c := *d
+		out.Pending = append(out.Pending, &c)
+	}
+	sort.Slice(out.Pending, func(i, j int) bool { return out.Pending[i].NextAttempt.Before(out.Pending[j].NextAttempt) })
+	for _, d := range n.dead {
+		c := *d
+		out.Dead = append(out.Dead, &c)
+	}
+	return out
+}
+
+// saveLocked persists the outbox. Callers hold n.mu.
+func (n *Notifier) saveLocked() error {
+	if n.cfg.Outbox == "" {
+		return nil
+	}
+	outbox := WebhookOutbox{Pending: make([]*WebhookDelivery, 0, len(n.pending)), Dead: n.dead}
+	for _, d := range n.pending {
+		outbox.Pending = append(outbox.Pending, d)
+	}
+	sort.Slice(outbox.Pending, func(i, j int) bool { return outbox.Pending[i].ID < outbox.Pending[j].ID })
+	data, err := json.MarshalIndent(outbox, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(n.cfg.Outbox, data); err != nil {
+		return fmt.Errorf("failed to save webhook outbox: %w", err)
+	}
+	return nil
+}
+
+// Close saves the outbox. A nil notifier has nothing to save.
+func (n *Notifier) Close() error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.saveLocked()
+}
+
+// webhooksHandler serves GET /webhooks (the outbox) and
+// POST /webhooks?redeliver=ID (retry a dead letter)
+func (n *Notifier) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.Outbox())
+	case http.MethodPost:
+		id := r.URL.Query().Get("redeliver")
+		if id == "" {
+			http.Error(w, "redeliver is required", http.StatusBadRequest)
+			return
+		}
+		if err := n.Redeliver(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"redelivering": id})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhookReceiver is a local endpoint answering with the queued status
+// codes, then 200, and recording the events it accepted
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	calls    int
+	events   []WebhookEvent
+	received chan struct{}
+}
+
+func newWebhookReceiver(t *testing.T, secret string, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	r := &webhookReceiver{statuses: statuses, received: make(chan struct{}, 16)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if err := VerifyWebhook(secret, req.Header, body, time.Hour, time.Now()); err != nil {
+			t.Errorf("receiver: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls++
+		if len(r.statuses) > 0 {
+			status := r.statuses[0]
+			r.statuses = r.statuses[1:]
+			w.WriteHeader(status)
+			return
+		}
+		var ev WebhookEvent
+		json.Unmarshal(body, &ev)
+		if req.Header.Get(WebhookEventHeader) != ev.Type {
+			t.Errorf("event header %q for a %s event", req.Header.Get(WebhookEventHeader), ev.Type)
+		}
+		r.events = append(r.events, ev)
+		r.received <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+// webhookTestConfig retries quickly
+func webhookTestConfig(outbox string) WebhookConfig {
+	return WebhookConfig{Outbox: outbox, MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 4 * time.Second, Timeout: 5 * time.Second}
+}
+
+// triggeredPayout is the result of a payout task that triggered
+const triggeredPayout = `{"policy_id": "POL-1", "type": "payout", "payout": {"triggered": true}}`
+
+func TestNotifier_HandleTaskDeliversSignedWebhook(t *testing.T) {
+	receiver, srv := newWebhookReceiver(t, "s3cret")
+	notifier, err := NewNotifier(webhookTestConfig(""), []WebhookEndpoint{{Name: "ops", URL: srv.URL, Secret: "s3cret", Events: []string{WebhookPayoutTriggered}}}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	worker := newPolicyWorker(t, "")
+	worker.policies.now = func() time.Time { return time.Unix(1706745600, 0) }
+	worker.notifier = notifier
+	if _, err := worker.HandleTask(payoutTask("task-1", "40")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-receiver.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	ev := receiver.events[0]
+	if ev.Type != WebhookPayoutTriggered || ev.TaskID != "task-1" || ev.PolicyID != "POL-1" || len(ev.Result) == 0 {
+		t.Errorf("event = %+v", ev)
+	}
+
+	// Delivered notifications leave the outbox
+	for deadline := time.Now().Add(5 * time.Second); len(notifier.Outbox().Pending) != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("delivered webhook still pending")
+		}
+	}
+
+	// A result that did not trigger sends nothing
+	if err := notifier.Notify("task-2", []byte(`{"policy_id": "POL-1", "verified": false}`)); err != nil {
+		t.Fatal(err)
+	}
+	if out := notifier.Outbox(); len(out.Pending) != 0 {
+		t.Errorf("pending = %d", len(out.Pending))
+	}
+}
+
+func TestNotifier_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	receiver, srv := newWebhookReceiver(t, "k", 500, 503, 500)
+	outbox := filepath.Join(t.TempDir(), "outbox.json")
+	notifier, _ := NewNotifier(webhookTestConfig(outbox), []WebhookEndpoint{{Name: "ops", URL: srv.URL, Secret: "k"}}, zap.NewNop())
+	now := time.Now().Truncate(time.Second)
+	notifier.now = func() time.Time { return now }
+
+	if err := notifier.Notify("task-1", []byte(triggeredPayout)); err != nil {
+		t.Fatal(err)
+	}
+	// The backoff doubles after each failure
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		next := notifier.deliverDue(context.Background())
+		if got := next.Sub(now); got != want {
+			t.Errorf("attempt %d: next in %s, want %s", i+1, got, want)
+		}
+		// Nothing is sent before it is due
+		if notifier.deliverDue(context.Background()); receiver.calls != i+1 {
+			t.Errorf("attempt %d: %d calls", i+1, receiver.calls)
+		}
+		now = next
+	}
+
+	// The third failure reaches MaxAttempts
+	if next := notifier.deliverDue(context.Background()); !next.IsZero() {
+		t.Errorf("next = %v after dead-lettering", next)
+	}
+	out := notifier.Outbox()
+	if len(out.Pending) != 0 || len(out.Dead) != 1 || out.Dead[0].Attempts != 3 || out.Dead[0].LastError == "" {
+		t.Fatalf("outbox = %+v", out)
+	}
+
+	// The dead letter survives a restart and can be redelivered
+	restarted, err := NewNotifier(webhookTestConfig(outbox), []WebhookEndpoint{{Name: "ops", URL: srv.URL, Secret: "k"}}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Redeliver(out.Dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	restarted.deliverDue(context.Background())
+	if len(receiver.events) != 1 || len(restarted.Outbox().Dead) != 0 {
+		t.Errorf("after redelivery: %d events, outbox %+v", len(receiver.events), restarted.Outbox())
+	}
+}
+
+func TestNotifier_PermanentFailureDeadLettersAtOnce(t *testing.T) {
+	receiver, srv := newWebhookReceiver(t, "k", http.StatusGone)
+	notifier, _ := NewNotifier(webhookTestConfig(""), []WebhookEndpoint{{Name: "ops", URL: srv.URL, Secret: "k"}}, zap.NewNop())
+	notifier.Notify("task-1", []byte(triggeredPayout))
+	notifier.deliverDue(context.Background())
+	if out := notifier.Outbox(); receiver.calls != 1 || len(out.Dead) != 1 {
+		t.Errorf("calls = %d, outbox = %+v", receiver.calls, out)
+	}
+}
+
+func TestNotifier_OutboxSurvivesRestart(t *testing.T) {
+	outbox := filepath.Join(t.TempDir(), "outbox.json")
+	endpoints := []WebhookEndpoint{
+		{Name: "ops", URL: "http://127.0.0.1:1/hook", Secret: "k"},
+		{Name: "old", URL: "http://127.0.0.1:1/old", Secret: "k"},
+	}
+	notifier, _ := NewNotifier(webhookTestConfig(outbox), endpoints, zap.NewNop())
+	notifier.Notify("task-1", []byte(triggeredPayout))
+
+	// Queued before any attempt; the removed endpoint's delivery is dead-lettered
+	restarted, err := NewNotifier(webhookTestConfig(outbox), endpoints[:1], zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := restarted.Outbox()
+	if len(out.Pending) != 1 || out.Pending[0].Endpoint != "ops" || len(out.Dead) != 1 || out.Dead[0].Endpoint != "old" {
+		t.Errorf("outbox = %+v", out)
+	}
+}
+
+func TestWebhookEndpoint_Filters(t *testing.T) {
+	events, err := webhookEvents("task-1", []byte(`{"policy_id": "POL-1", "verified": true, "type": "payout", "payout": {"triggered": true}}`), time.Now())
+	if err != nil || len(events) != 2 {
+		t.Fatalf("events = %v, %v", events, err)
+	}
+	for _, c := range []struct {
+		endpoint WebhookEndpoint
+		want     int
+	}{
+		{WebhookEndpoint{}, 2},
+		{WebhookEndpoint{Events: []string{WebhookClaimVerified}}, 1},
+		{WebhookEndpoint{Policies: []string{"POL-2"}}, 0},
+		{WebhookEndpoint{Events: []string{WebhookPayoutTriggered}, Policies: []string{"POL-1"}}, 1},
+	} {
+		n := 0
+		for _, ev := range events {
+			if c.endpoint.matches(ev) {
+				n++
+			}
+		}
+		if n != c.want {
+			t.Errorf("%+v matched %d, want %d", c.endpoint, n, c.want)
+		}
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id": "1"}`)
+	now := time.Unix(1700000000, 0)
+	header := http.Header{}
+	header.Set(WebhookTimestampHeader, "1700000000")
+	header.Set(WebhookSignatureHeader, SignWebhook("k", now.Unix(), body))
+
+	if err := VerifyWebhook("k", header, body, time.Minute, now); err != nil {
+		t.Errorf("valid webhook: %v", err)
+	}
+	if err := VerifyWebhook("other", header, body, time.Minute, now); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	if err := VerifyWebhook("k", header, []byte(`{"id": "2"}`), time.Minute, now); err == nil {
+		t.Error("altered body accepted")
+	}
+	if err := VerifyWebhook("k", header, body, time.Minute, now.Add(time.Hour)); err == nil {
+		t.Error("stale webhook accepted")
+	}
+}
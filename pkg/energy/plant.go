@@ -0,0 +1,255 @@
+package energy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Plant converts the weather over an interval into power
+type Plant interface {
+	// CapacityKW is the plant's rated output
+	CapacityKW() float64
+	// PowerKW is the mean output over an interval in the given conditions.
+	// ok is false when the conditions lack the weather the plant needs.
+	PowerKW(c *Conditions) (kw float64, ok bool)
+}
+
+// DefaultTempCoefficientPct is the power change of crystalline silicon
+// modules per °C of cell temperature above 25 °C
+const DefaultTempCoefficientPct = -0.4
+
+// DefaultAlbedo is the reflectance of grass
+const DefaultAlbedo = 0.2
+
+// noct is the nominal operating cell temperature of an open-rack module, °C
+const noct = 45.0
+
+// PVSystem is a photovoltaic plant with fixed-tilt modules. Zero
+// coefficients and albedo use the defaults.
+type PVSystem struct {
+	// CapacityDCKW is the module nameplate at standard test conditions
+	CapacityDCKW float64 `json:"capacity_kw"`
+	// InverterKW caps AC output; zero leaves it uncapped
+	InverterKW float64 `json:"inverter_kw,omitempty"`
+	TiltDeg    float64 `json:"tilt_deg"`
+	// AzimuthDeg is the direction the modules face, clockwise from north
+	AzimuthDeg         float64 `json:"azimuth_deg"`
+	TempCoefficientPct float64 `json:"temperature_coefficient_pct,omitempty"`
+	// LossesPct covers wiring, soiling, mismatch and availability
+	LossesPct float64 `json:"losses_pct,omitempty"`
+	Albedo    float64 `json:"albedo,omitempty"`
+}
+
+// Validate checks the system's parameters
+func (p *PVSystem) Validate() error {
+	switch {
+	case p.CapacityDCKW <= 0:
+		return fmt.Errorf("PV capacity_kw must be positive")
+	case p.InverterKW < 0:
+		return fmt.Errorf("PV inverter_kw must not be negative")
+	case p.TiltDeg < 0 || p.TiltDeg > 90:
+		return fmt.Errorf("PV tilt_deg must be between 0 and 90")
+	case p.AzimuthDeg < 0 || p.AzimuthDeg >= 360:
+		return fmt.Errorf("PV azimuth_deg must be between 0 and 360")
+	case p.LossesPct < 0 || p.LossesPct >= 100:
+		return fmt.Errorf("PV losses_pct must be between 0 and 100")
+	case p.Albedo < 0 || p.Albedo > 1:
+		return fmt.Errorf("PV albedo must be between 0 and 1")
+	case p.TempCoefficientPct < -2 || p.TempCoefficientPct > 0:
+		return fmt.Errorf("PV temperature_coefficient_pct must be between -2 and 0")
+	}
+	return nil
+}
+
+func (p *PVSystem) CapacityKW() float64 {
+	if p.InverterKW > 0 {
+		return math.Min(p.CapacityDCKW, p.InverterKW)
+	}
+	return p.CapacityDCKW
+}
+
+// PowerKW models the system with isotropic sky transposition onto the
+// module plane and a NOCT cell temperature. At night the output is zero
+// whether or not irradiance was measured.
+func (p *PVSystem) PowerKW(c *Conditions) (float64, bool) {
+	if !c.Sun.Up() {
+		return 0, true
+	}
+	if !c.HasIrradiance {
+		return 0, false
+	}
+	return p.power(c.Irradiance, c), true
+}
+
+// ClearSkyKW is the output under a cloudless sky
+func (p *PVSystem) ClearSkyKW(c *Conditions) float64 {
+	if !c.Sun.Up() {
+		return 0
+	}
+	return p.power(c.ClearSky, c)
+}
+
+func (p *PVSystem) power(irr Irradiance, c *Conditions) float64 {
+	albedo := p.Albedo
+	if albedo == 0 {
+		albedo = DefaultAlbedo
+	}
+	coefficient := p.TempCoefficientPct
+	if coefficient == 0 {
+		coefficient = DefaultTempCoefficientPct
+	}
+
+	tilt, zenith := rad(p.TiltDeg), rad(c.Sun.Zenith)
+	cosIncidence := math.Cos(zenith)*math.Cos(tilt) + math.Sin(zenith)*math.Sin(tilt)*math.Cos(rad(c.Sun.Azimuth-p.AzimuthDeg))
+	poa := irr.DNI*math.Max(cosIncidence, 0) +
+		irr.DHI*(1+math.Cos(tilt))/2 +
+		irr.GHI*albedo*(1-math.Cos(tilt))/2
+
+	cell := 25.0
+	if c.TemperatureC != nil {
+		cell = *c.TemperatureC + poa*(noct-20)/800
+	}
+	kw := p.CapacityDCKW * poa / 1000 * (1 + coefficient/100*(cell-25)) * (1 - p.LossesPct/100)
+	if p.InverterKW > 0 {
+		kw = math.Min(kw, p.InverterKW)
+	}
+	return math.Max(kw, 0)
+}
+
+// CurvePoint is one point of a turbine power curve
+type CurvePoint struct {
+	SpeedMS float64 `json:"speed_ms"`
+	PowerKW float64 `json:"power_kw"`
+}
+
+// DefaultShearExponent is the wind profile exponent of open terrain, used
+// when wind is reported at a single height
+const DefaultShearExponent = 1.0 / 7
+
+// standardAirDensity is the density power curves are specified at, kg/m³
+const standardAirDensity = 1.225
+
+// Turbine is a wind plant of identical turbines
+type Turbine struct {
+	HubHeightM float64 `json:"hub_height_m"`
+	// Curve is the power curve at standard air density, by increasing
+	// speed. Power is interpolated linearly between points, and is zero
+	// below the first point and above the last, where the turbine cuts out.
+	Curve []CurvePoint `json:"power_curve"`
+	// Count is the number of turbines; zero means one
+	Count int `json:"count,omitempty"`
+	// LossesPct covers wake, electrical and availability losses
+	LossesPct float64 `json:"losses_pct,omitempty"`
+	// ShearExponent extrapolates wind from a single reported height to the
+	// hub; zero uses DefaultShearExponent
+	ShearExponent float64 `json:"shear_exponent,omitempty"`
+}
+
+// Validate checks the turbine's parameters
+func (t *Turbine) Validate() error {
+	switch {
+	case t.HubHeightM <= 0 || t.HubHeightM > 300:
+		return fmt.Errorf("turbine hub_height_m must be between 0 and 300")
+	case len(t.Curve) < 2:
+		return fmt.Errorf("turbine power_curve needs at least two points")
+	case t.Count < 0:
+		return fmt.Errorf("turbine count must not be negative")
+	case t.LossesPct < 0 || t.LossesPct >= 100:
+		return fmt.Errorf("turbine losses_pct must be between 0 and 100")
+	case t.ShearExponent < 0 || t.ShearExponent > 1:
+		return fmt.Errorf("turbine shear_exponent must be between 0 and 1")
+	}
+	for i, p := range t.Curve {
+		if p.SpeedMS < 0 || p.PowerKW < 0 {
+			return fmt.Errorf("power curve point %d is negative", i)
+		}
+		if i > 0 && p.SpeedMS <= t.Curve[i-1].SpeedMS {
+			return fmt.Errorf("power curve speeds must increase")
+		}
+	}
+	return nil
+}
+
+func (t *Turbine) count() float64 {
+	return float64(max(t.Count, 1))
+}
+
+func (t *Turbine) CapacityKW() float64 {
+	var rated float64
+	for _, p := range t.Curve {
+		rated = math.Max(rated, p.PowerKW)
+	}
+	return rated * t.count()
+}
+
+// PowerKW reads the power curve at the hub-height wind, corrected to
+// standard air density when the temperature is known
+func (t *Turbine) PowerKW(c *Conditions) (float64, bool) {
+	speed, ok := t.HubWindMS(c)
+	if !ok {
+		return 0, false
+	}
+	if c.TemperatureC != nil {
+		pressure := 101325 * math.Exp(-c.ElevationM/8434.5)
+		density := pressure / (287.05 * (*c.TemperatureC + 273.15))
+		speed *= math.Cbrt(density / standardAirDensity)
+	}
+	return t.curve(speed) * t.count() * (1 - t.LossesPct/100), true
+}
+
+// curve interpolates the power curve at speed
+func (t *Turbine) curve(speed float64) float64 {
+	first, last := t.Curve[0], t.Curve[len(t.Curve)-1]
+	if speed < first.SpeedMS || speed > last.SpeedMS {
+		return 0
+	}
+	i := sort.Search(len(t.Curve), func(i int) bool { return t.Curve[i].SpeedMS >= speed })
+	if t.Curve[i].SpeedMS == speed {
+		return t.Curve[i].PowerKW
+	}
+	a, b := t.Curve[i-1], t.Curve[i]
+	return a.PowerKW + (b.PowerKW-a.PowerKW)*(speed-a.SpeedMS)/(b.SpeedMS-a.SpeedMS)
+}
+
+// HubWindMS is the wind speed at hub height. It is extrapolated from the
+// reported height nearest the hub, with the shear between the two nearest
+// heights when there are two, or the turbine's exponent otherwise.
+func (t *Turbine) HubWindMS(c *Conditions) (float64, bool) {
+	if len(c.Wind) == 0 {
+		return 0, false
+	}
+	if v, ok := c.Wind[int(t.HubHeightM)]; ok && float64(int(t.HubHeightM)) == t.HubHeightM {
+		return v, true
+	}
+	heights := make([]int, 0, len(c.Wind))
+	for h := range c.Wind {
+		if h > 0 {
+			heights = append(heights, h)
+		}
+	}
+	if len(heights) == 0 {
+		return 0, false
+	}
+	// Nearest first, on a log scale as the wind profile is
+	distance := func(h int) float64 { return math.Abs(math.Log(float64(h) / t.HubHeightM)) }
+	sort.Slice(heights, func(i, j int) bool {
+		if di, dj := distance(heights[i]), distance(heights[j]); di != dj {
+			return di < dj
+		}
+		return heights[i] < heights[j]
+	})
+
+	alpha := t.ShearExponent
+	if alpha == 0 {
+		alpha = DefaultShearExponent
+	}
+	near := heights[0]
+	if len(heights) > 1 {
+		far := heights[1]
+		if v1, v2 := c.Wind[near], c.Wind[far]; v1 > 0 && v2 > 0 {
+			alpha = clamp(math.Log(v1/v2)/math.Log(float64(near)/float64(far)), 0, 1)
+		}
+	}
+	return c.Wind[near] * math.Pow(t.HubHeightM/float64(near), alpha), true
+}
@@ -0,0 +1,126 @@
+package energy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+var turbine = Turbine{
+	HubHeightM: 100,
+	Curve:      []CurvePoint{{3, 0}, {12, 2000}, {25, 2000}},
+}
+
+func TestTurbine_PowerKW(t *testing.T) {
+	for _, c := range []struct {
+		wind map[int]float64
+		want float64
+	}{
+		{map[int]float64{100: 7.5}, 1000},
+		{map[int]float64{100: 2}, 0},
+		{map[int]float64{100: 18}, 2000},
+		// Past the last point the turbine has cut out
+		{map[int]float64{100: 26}, 0},
+		// Extrapolated from 10 m with the 1/7 power law: 5 m/s becomes 6.95
+		{map[int]float64{10: 5}, 2000 * (5*math.Pow(10, 1.0/7) - 3) / 9},
+	} {
+		if got, ok := turbine.PowerKW(&Conditions{Wind: c.wind}); !ok || !near(got, c.want, 1e-6) {
+			t.Errorf("wind %v: %v kW, want %v", c.wind, got, c.want)
+		}
+	}
+	if _, ok := turbine.PowerKW(&Conditions{}); ok {
+		t.Error("power without wind")
+	}
+
+	farm := turbine
+	farm.Count, farm.LossesPct = 3, 10
+	if got, _ := farm.PowerKW(&Conditions{Wind: map[int]float64{100: 7.5}}); !near(got, 2700, 1e-6) || farm.CapacityKW() != 6000 {
+		t.Errorf("farm = %v kW of %v", got, farm.CapacityKW())
+	}
+	// Thin warm air at altitude delivers less power
+	if got, _ := turbine.PowerKW(&Conditions{Wind: map[int]float64{100: 7.5}, TemperatureC: ptr(30), ElevationM: 1500}); got >= 900 {
+		t.Errorf("at 1500 m and 30 °C = %v kW", got)
+	}
+}
+
+func TestTurbine_HubWindMS(t *testing.T) {
+	// The shear between 10 m and 100 m carries on to 120 m
+	c := &Conditions{Wind: map[int]float64{10: 5, 100: 7}}
+	alpha := math.Log(7.0/5) / math.Log(10)
+	hub := Turbine{HubHeightM: 120}
+	if got, ok := hub.HubWindMS(c); !ok || !near(got, 7*math.Pow(1.2, alpha), 1e-9) {
+		t.Errorf("hub wind = %v", got)
+	}
+}
+
+func TestTurbine_Validate(t *testing.T) {
+	for name, tb := range map[string]Turbine{
+		"no hub":      {Curve: turbine.Curve},
+		"short curve": {HubHeightM: 100, Curve: turbine.Curve[:1]},
+		"unsorted":    {HubHeightM: 100, Curve: []CurvePoint{{12, 2000}, {3, 0}}},
+		"losses":      {HubHeightM: 100, Curve: turbine.Curve, LossesPct: 100},
+		"negative kw": {HubHeightM: 100, Curve: []CurvePoint{{3, -1}, {12, 2000}}},
+	} {
+		if err := tb.Validate(); err == nil {
+			t.Errorf("%s: valid", name)
+		}
+	}
+	if err := turbine.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPVSystem_PowerKW(t *testing.T) {
+	ghi := 900*math.Cos(rad(30)) + 220
+	noon := &Conditions{
+		Time:          time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC),
+		Sun:           Position{Zenith: 30, Azimuth: 180},
+		Irradiance:    Irradiance{GHI: ghi, DNI: 900, DHI: 220},
+		HasIrradiance: true,
+	}
+	flat := PVSystem{CapacityDCKW: 1000}
+	if got, ok := flat.PowerKW(noon); !ok || !near(got, ghi, 1e-9) {
+		t.Errorf("flat modules = %v kW", got)
+	}
+	// Tilting towards the sun catches the beam square on
+	tilted := PVSystem{CapacityDCKW: 1000, TiltDeg: 30, AzimuthDeg: 180}
+	want := 900 + 220*(1+math.Cos(rad(30)))/2 + ghi*0.2*(1-math.Cos(rad(30)))/2
+	if got, _ := tilted.PowerKW(noon); !near(got, want, 1e-9) {
+		t.Errorf("tilted modules = %v kW, want %v", got, want)
+	}
+	// Hot cells, losses and the inverter all cut output
+	hot := *noon
+	hot.TemperatureC = ptr(35)
+	derated := PVSystem{CapacityDCKW: 1000, LossesPct: 14, InverterKW: 700}
+	if got, _ := derated.PowerKW(&hot); got != 700 {
+		t.Errorf("clipped = %v kW", got)
+	}
+	derated.InverterKW = 0
+	if got, _ := derated.PowerKW(&hot); !near(got, ghi*(1-0.004*(35+ghi*25/800-25))*0.86, 1e-9) {
+		t.Errorf("derated = %v kW", got)
+	}
+
+	night := &Conditions{Sun: Position{Zenith: 100}}
+	if got, ok := flat.PowerKW(night); !ok || got != 0 {
+		t.Errorf("night = %v, %v", got, ok)
+	}
+	if _, ok := flat.PowerKW(&Conditions{Sun: Position{Zenith: 30}}); ok {
+		t.Error("daytime power without irradiance")
+	}
+}
+
+func TestPVSystem_Validate(t *testing.T) {
+	for name, p := range map[string]PVSystem{
+		"capacity": {},
+		"tilt":     {CapacityDCKW: 1, TiltDeg: 91},
+		"azimuth":  {CapacityDCKW: 1, AzimuthDeg: 360},
+		"losses":   {CapacityDCKW: 1, LossesPct: -1},
+		"albedo":   {CapacityDCKW: 1, Albedo: 2},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: valid", name)
+		}
+	}
+}
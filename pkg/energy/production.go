@@ -0,0 +1,192 @@
+package energy
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Site is where a plant stands
+type Site struct {
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	ElevationM float64 `json:"elevation_m,omitempty"`
+	// LinkeTurbidity is the haze of a clear sky; zero uses DefaultLinkeTurbidity
+	LinkeTurbidity float64 `json:"linke_turbidity,omitempty"`
+}
+
+// Sample is the mean weather over an interval starting at Time. Fields a
+// source does not report are nil.
+type Sample struct {
+	Time time.Time `json:"time"`
+	// GHI, DNI and DHI are irradiance in W/m²; DNI and DHI are derived
+	// from GHI when missing
+	GHI          *float64 `json:"ghi,omitempty"`
+	DNI          *float64 `json:"dni,omitempty"`
+	DHI          *float64 `json:"dhi,omitempty"`
+	TemperatureC *float64 `json:"temperature,omitempty"`
+	// Wind maps heights above ground in metres to wind speed in m/s
+	Wind map[int]float64 `json:"wind,omitempty"`
+}
+
+// Conditions are a sample's weather resolved at the middle of its interval
+type Conditions struct {
+	Time time.Time
+	Sun  Position
+	// Irradiance is the sample's, completed by decomposition; it is only
+	// set when HasIrradiance
+	Irradiance    Irradiance
+	HasIrradiance bool
+	ClearSky      Irradiance
+	TemperatureC  *float64
+	Wind          map[int]float64
+	ElevationM    float64
+}
+
+// Resolve works out the conditions of s, an interval of length step at site
+func Resolve(site Site, s Sample, step time.Duration) *Conditions {
+	mid := s.Time.Add(step / 2)
+	sun := SolarPosition(mid, site.Latitude, site.Longitude)
+	c := &Conditions{
+		Time:         mid,
+		Sun:          sun,
+		ClearSky:     ClearSky(sun, mid, site.ElevationM, site.LinkeTurbidity),
+		TemperatureC: s.TemperatureC,
+		Wind:         s.Wind,
+		ElevationM:   site.ElevationM,
+	}
+	if s.GHI == nil {
+		return c
+	}
+	ghi := math.Max(*s.GHI, 0)
+	cosZenith := math.Cos(rad(sun.Zenith))
+	switch {
+	case s.DNI != nil && s.DHI != nil:
+		c.Irradiance = Irradiance{GHI: ghi, DNI: math.Max(*s.DNI, 0), DHI: math.Max(*s.DHI, 0)}
+	case s.DNI != nil:
+		dni := math.Max(*s.DNI, 0)
+		c.Irradiance = Irradiance{GHI: ghi, DNI: dni, DHI: math.Max(ghi-dni*math.Max(cosZenith, 0), 0)}
+	case s.DHI != nil && cosZenith >= minCosZenith:
+		dhi := math.Min(math.Max(*s.DHI, 0), ghi)
+		c.Irradiance = Irradiance{GHI: ghi, DNI: (ghi - dhi) / cosZenith, DHI: dhi}
+	default:
+		c.Irradiance = Decompose(ghi, sun, mid)
+	}
+	c.HasIrradiance = true
+	return c
+}
+
+// Production is a plant's expected output over a window. Energy is in MWh
+// and irradiation in kWh/m².
+type Production struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// ExpectedMWh is the output of the covered time, scaled up to the
+	// whole window as if the missing time produced at the same rate
+	ExpectedMWh float64 `json:"expected_mwh"`
+	// ClearSkyMWh is what a PV system would have produced under a cloudless sky
+	ClearSkyMWh    float64 `json:"clear_sky_mwh,omitempty"`
+	PeakKW         float64 `json:"peak_kw"`
+	CapacityKW     float64 `json:"capacity_kw"`
+	CapacityFactor float64 `json:"capacity_factor"`
+	// GHI, DNI and ClearSkyGHI are the irradiation over the covered time
+	GHI         float64 `json:"ghi_kwh_m2"`
+	DNI         float64 `json:"dni_kwh_m2"`
+	ClearSkyGHI float64 `json:"clear_sky_ghi_kwh_m2"`
+	// MeanHubWindMS is the mean hub-height wind of a wind plant
+	MeanHubWindMS  float64 `json:"mean_hub_wind_ms,omitempty"`
+	CoveredSeconds int64   `json:"covered_seconds"`
+	MissingSeconds int64   `json:"missing_seconds"`
+}
+
+// ClearnessIndex is the measured irradiation as a fraction of the
+// clear-sky irradiation, or zero when there was none
+func (p *Production) ClearnessIndex() float64 {
+	if p.ClearSkyGHI <= 0 {
+		return 0
+	}
+	return p.GHI / p.ClearSkyGHI
+}
+
+// Simulate runs the plant through the samples over [start, end). Each
+// sample covers step from its time; of samples at the same time the last
+// given wins. Time no sample covers, or whose sample lacks the weather the
+// plant needs, is missing.
+func Simulate(plant Plant, site Site, samples []Sample, start, end time.Time, step time.Duration) *Production {
+	p := &Production{Start: start.UTC(), End: end.UTC(), CapacityKW: plant.CapacityKW()}
+	pv, _ := plant.(*PVSystem)
+	turbine, _ := plant.(*Turbine)
+
+	var sorted []Sample
+	for _, s := range samples {
+		if s.Time.Before(end) && s.Time.Add(step).After(start) {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	unique := sorted[:0]
+	for _, s := range sorted {
+		if n := len(unique); n > 0 && unique[n-1].Time.Equal(s.Time) {
+			unique[n-1] = s
+			continue
+		}
+		unique = append(unique, s)
+	}
+
+	var kwh, clearKWh, windSeconds, windSum float64
+	for i, s := range unique {
+		from, until := s.Time, s.Time.Add(step)
+		if i+1 < len(unique) && unique[i+1].Time.Before(until) {
+			until = unique[i+1].Time
+		}
+		if from.Before(start) {
+			from = start
+		}
+		if until.After(end) {
+			until = end
+		}
+		seconds := int64(until.Sub(from) / time.Second)
+		if seconds <= 0 {
+			continue
+		}
+		hours := float64(seconds) / 3600
+
+		c := Resolve(site, s, step)
+		if c.HasIrradiance {
+			p.GHI += c.Irradiance.GHI * hours / 1000
+			p.DNI += c.Irradiance.DNI * hours / 1000
+			p.ClearSkyGHI += c.ClearSky.GHI * hours / 1000
+		}
+		if turbine != nil {
+			if v, ok := turbine.HubWindMS(c); ok {
+				windSum += v * float64(seconds)
+				windSeconds += float64(seconds)
+			}
+		}
+		kw, ok := plant.PowerKW(c)
+		if !ok {
+			continue
+		}
+		p.CoveredSeconds += seconds
+		kwh += kw * hours
+		p.PeakKW = math.Max(p.PeakKW, kw)
+		if pv != nil {
+			clearKWh += pv.ClearSkyKW(c) * hours
+		}
+	}
+
+	window := int64(end.Sub(start) / time.Second)
+	p.MissingSeconds = max(window-p.CoveredSeconds, 0)
+	if p.CoveredSeconds > 0 {
+		scale := float64(window) / float64(p.CoveredSeconds)
+		p.ExpectedMWh = kwh * scale / 1000
+		p.ClearSkyMWh = clearKWh * scale / 1000
+	}
+	if windSeconds > 0 {
+		p.MeanHubWindMS = windSum / windSeconds
+	}
+	if p.CapacityKW > 0 && window > 0 {
+		p.CapacityFactor = p.ExpectedMWh * 1000 / (p.CapacityKW * float64(window) / 3600)
+	}
+	return p
+}
@@ -0,0 +1,80 @@
+package energy
+
+import (
+	"testing"
+	"time"
+)
+
+var day = time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)
+
+// hourly returns a sample for each hour of day from fn, skipping nil ones
+func hourly(fn func(at time.Time) *Sample) []Sample {
+	var samples []Sample
+	for h := 0; h < 24; h++ {
+		if s := fn(day.Add(time.Duration(h) * time.Hour)); s != nil {
+			samples = append(samples, *s)
+		}
+	}
+	return samples
+}
+
+func TestSimulate_Wind(t *testing.T) {
+	samples := hourly(func(at time.Time) *Sample {
+		if at.Hour() == 5 || at.Hour() == 6 {
+			return nil
+		}
+		return &Sample{Time: at, Wind: map[int]float64{100: 7.5}}
+	})
+	// A later sample for the same hour replaces the first
+	samples = append(samples, Sample{Time: day, Wind: map[int]float64{100: 12}})
+
+	p := Simulate(&turbine, Site{Latitude: 55, Longitude: 8}, samples, day, day.Add(24*time.Hour), time.Hour)
+	// 21 hours at 1 MW and one at 2 MW, over 22 covered hours
+	if !near(p.ExpectedMWh, 23*24.0/22, 1e-9) || p.MissingSeconds != 7200 || p.CoveredSeconds != 22*3600 {
+		t.Errorf("production = %+v", p)
+	}
+	if p.PeakKW != 2000 || !near(p.MeanHubWindMS, (21*7.5+12)/22, 1e-9) || !near(p.CapacityFactor, p.ExpectedMWh/48, 1e-9) {
+		t.Errorf("production = %+v", p)
+	}
+	if p.ClearSkyMWh != 0 || p.GHI != 0 {
+		t.Errorf("wind plant has solar figures: %+v", p)
+	}
+}
+
+func TestSimulate_Solar(t *testing.T) {
+	site := Site{Latitude: 37.2, Longitude: -2.5, ElevationM: 500}
+	pv := PVSystem{CapacityDCKW: 10000, TiltDeg: 30, AzimuthDeg: 180, LossesPct: 14}
+
+	// A sky that was exactly as clear as the model reports only GHI
+	clear := hourly(func(at time.Time) *Sample {
+		c := Resolve(site, Sample{Time: at}, time.Hour)
+		return &Sample{Time: at, GHI: ptr(c.ClearSky.GHI), TemperatureC: ptr(25)}
+	})
+	p := Simulate(&pv, site, clear, day, day.Add(24*time.Hour), time.Hour)
+	if !near(p.ClearnessIndex(), 1, 1e-9) || p.MissingSeconds != 0 {
+		t.Errorf("clear day = %+v", p)
+	}
+	// A midsummer day in southern Spain yields about 6 kWh per kWp
+	if p.ExpectedMWh < 50 || p.ExpectedMWh > 75 || !near(p.ExpectedMWh, p.ClearSkyMWh, 0.1*p.ClearSkyMWh) {
+		t.Errorf("clear day = %v MWh, clear sky %v MWh", p.ExpectedMWh, p.ClearSkyMWh)
+	}
+
+	// Half the light, with daylight hours missing from 14:00
+	dull := hourly(func(at time.Time) *Sample {
+		if at.Hour() >= 14 && at.Hour() < 16 {
+			return nil
+		}
+		c := Resolve(site, Sample{Time: at}, time.Hour)
+		return &Sample{Time: at, GHI: ptr(c.ClearSky.GHI / 2), TemperatureC: ptr(25)}
+	})
+	q := Simulate(&pv, site, dull, day, day.Add(24*time.Hour), time.Hour)
+	if !near(q.ClearnessIndex(), 0.5, 1e-9) || q.MissingSeconds != 7200 || q.ExpectedMWh >= p.ExpectedMWh*0.7 {
+		t.Errorf("dull day = %+v", q)
+	}
+
+	// Night hours need no measurement
+	night := []Sample{{Time: day}, {Time: day.Add(time.Hour)}}
+	if r := Simulate(&pv, site, night, day, day.Add(2*time.Hour), time.Hour); r.MissingSeconds != 0 || r.ExpectedMWh != 0 {
+		t.Errorf("night = %+v", r)
+	}
+}
@@ -0,0 +1,165 @@
+// Package energy estimates the output of solar and wind plants from
+// weather: solar position, clear-sky irradiance and the split of global
+// irradiance into its direct and diffuse parts, a PV system model and
+// turbine power curves, and the expected production over a window.
+package energy
+
+import (
+	"math"
+	"time"
+)
+
+// SolarConstant is the mean extraterrestrial irradiance in W/m²
+const SolarConstant = 1361.0
+
+// DefaultLinkeTurbidity is a typical turbidity for a rural mid-latitude site
+const DefaultLinkeTurbidity = 3.0
+
+// Position is where the sun is in the sky, in degrees. Azimuth is measured
+// clockwise from north.
+type Position struct {
+	Zenith  float64 `json:"zenith"`
+	Azimuth float64 `json:"azimuth"`
+}
+
+// Elevation is the sun's angle above the horizon
+func (p Position) Elevation() float64 {
+	return 90 - p.Zenith
+}
+
+// Up reports whether the sun is above the horizon
+func (p Position) Up() bool {
+	return p.Zenith < 90
+}
+
+// Irradiance is global horizontal, direct normal and diffuse horizontal
+// irradiance in W/m²
+type Irradiance struct {
+	GHI float64 `json:"ghi"`
+	DNI float64 `json:"dni"`
+	DHI float64 `json:"dhi"`
+}
+
+func rad(deg float64) float64 { return deg * math.Pi / 180 }
+func deg(rad float64) float64 { return rad * 180 / math.Pi }
+
+// clamp limits x to [lo, hi]
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
+
+// SolarPosition returns the geometric position of the sun at t seen from
+// latitude and longitude, following the NOAA solar calculator. It is
+// accurate to about 0.01° between 1800 and 2100; atmospheric refraction is
+// left out.
+func SolarPosition(t time.Time, latitude, longitude float64) Position {
+	t = t.UTC()
+	jd := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	jc := (jd - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	eccent := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(rad(meanAnom))*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(rad(2*meanAnom))*(0.019993-0.000101*jc) +
+		math.Sin(rad(3*meanAnom))*0.000289
+	omega := 125.04 - 1934.136*jc
+	appLong := meanLong + center - 0.00569 - 0.00478*math.Sin(rad(omega))
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*math.Cos(rad(omega))
+	decl := math.Asin(math.Sin(rad(obliq)) * math.Sin(rad(appLong)))
+
+	y := math.Pow(math.Tan(rad(obliq/2)), 2)
+	l, m := rad(meanLong), rad(meanAnom)
+	eqTime := 4 * deg(y*math.Sin(2*l)-2*eccent*math.Sin(m)+4*eccent*y*math.Sin(m)*math.Cos(2*l)-
+		0.5*y*y*math.Sin(4*l)-1.25*eccent*eccent*math.Sin(2*m))
+
+	minutes := float64(t.Hour()*60+t.Minute()) + (float64(t.Second())+float64(t.Nanosecond())/1e9)/60
+	trueSolar := math.Mod(minutes+eqTime+4*longitude, 1440)
+	if trueSolar < 0 {
+		trueSolar += 1440
+	}
+	hourAngle := trueSolar/4 - 180
+
+	lat := rad(latitude)
+	cosZenith := clamp(math.Sin(lat)*math.Sin(decl)+math.Cos(lat)*math.Cos(decl)*math.Cos(rad(hourAngle)), -1, 1)
+	zenith := math.Acos(cosZenith)
+
+	var azimuth float64
+	if denom := math.Cos(lat) * math.Sin(zenith); math.Abs(denom) > 1e-9 {
+		a := deg(math.Acos(clamp((math.Sin(lat)*cosZenith-math.Sin(decl))/denom, -1, 1)))
+		if hourAngle > 0 {
+			azimuth = math.Mod(a+180, 360)
+		} else {
+			azimuth = math.Mod(540-a, 360)
+		}
+	} else if latitude > 0 {
+		// At a pole, or with the sun overhead, face the equator
+		azimuth = 180
+	}
+	return Position{Zenith: deg(zenith), Azimuth: azimuth}
+}
+
+// ExtraterrestrialNormal returns the irradiance on a surface facing the sun
+// above the atmosphere on t's day, which varies with the Earth-Sun distance
+func ExtraterrestrialNormal(t time.Time) float64 {
+	b := 2 * math.Pi * float64(t.UTC().YearDay()-1) / 365
+	return SolarConstant * (1.00011 + 0.034221*math.Cos(b) + 0.00128*math.Sin(b) +
+		0.000719*math.Cos(2*b) + 0.000077*math.Sin(2*b))
+}
+
+// airMass is the relative optical air mass at a zenith angle (Kasten and
+// Young, 1989)
+func airMass(zenith float64) float64 {
+	return 1 / (math.Cos(rad(zenith)) + 0.50572*math.Pow(96.07995-zenith, -1.6364))
+}
+
+// ClearSky returns the irradiance under a cloudless sky for the sun at pos
+// and a site at elevationM, with the Ineichen-Perez model. A turbidity of
+// zero uses DefaultLinkeTurbidity.
+func ClearSky(pos Position, t time.Time, elevationM, linkeTurbidity float64) Irradiance {
+	if !pos.Up() {
+		return Irradiance{}
+	}
+	if linkeTurbidity <= 0 {
+		linkeTurbidity = DefaultLinkeTurbidity
+	}
+	cosZenith := math.Cos(rad(pos.Zenith))
+	i0 := ExtraterrestrialNormal(t)
+	am := airMass(pos.Zenith) * math.Exp(-elevationM/8434.5)
+	fh1, fh2 := math.Exp(-elevationM/8000), math.Exp(-elevationM/1250)
+	cg1, cg2 := 5.09e-5*elevationM+0.868, 3.92e-5*elevationM+0.0387
+
+	ghi := math.Max(cg1*i0*cosZenith*math.Exp(-cg2*am*(fh1+fh2*(linkeTurbidity-1))), 0)
+	b := 0.664 + 0.163/fh1
+	dni := b * i0 * math.Exp(-0.09*am*(linkeTurbidity-1))
+	// The beam can not carry more than the global irradiance allows
+	limit := ghi * math.Max((1-(0.1-0.2*math.Exp(-linkeTurbidity))/(0.1+0.882/fh1))/cosZenith, 0)
+	dni = clamp(dni, 0, limit)
+	return Irradiance{GHI: ghi, DNI: dni, DHI: math.Max(ghi-dni*cosZenith, 0)}
+}
+
+// minCosZenith stops the beam blowing up near the horizon, where a small
+// error in GHI becomes a large one in DNI
+const minCosZenith = 0.065
+
+// Decompose splits global horizontal irradiance into its direct normal and
+// diffuse parts with the Erbs model, for sources that only measure GHI
+func Decompose(ghi float64, pos Position, t time.Time) Irradiance {
+	cosZenith := math.Cos(rad(pos.Zenith))
+	if ghi <= 0 || cosZenith < minCosZenith {
+		return Irradiance{GHI: math.Max(ghi, 0), DHI: math.Max(ghi, 0)}
+	}
+	kt := clamp(ghi/(ExtraterrestrialNormal(t)*cosZenith), 0, 1)
+	var kd float64
+	switch {
+	case kt <= 0.22:
+		kd = 1 - 0.09*kt
+	case kt <= 0.8:
+		kd = 0.9511 - 0.1604*kt + 4.388*kt*kt - 16.638*kt*kt*kt + 12.336*kt*kt*kt*kt
+	default:
+		kd = 0.165
+	}
+	dhi := kd * ghi
+	return Irradiance{GHI: ghi, DNI: (ghi - dhi) / cosZenith, DHI: dhi}
+}
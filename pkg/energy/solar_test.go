@@ -0,0 +1,79 @@
+package energy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestSolarPosition(t *testing.T) {
+	for _, c := range []struct {
+		name                 string
+		at                   time.Time
+		lat, lon             float64
+		zenith, azimuth, tol float64
+	}{
+		// The NOAA calculator gives an elevation of 61.96° just before solar noon
+		{"Greenwich solstice noon", time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC), 51.4779, 0, 28.04, 179.1, 0.05},
+		// The winter sun is due north at noon in Sydney
+		{"Sydney winter noon", time.Date(2024, 6, 21, 2, 0, 0, 0, time.UTC), -33.87, 151.21, 57.31, 359.2, 0.05},
+		{"Greenwich midnight", time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 51.4779, 0, 105.08, 359.6, 0.05},
+	} {
+		pos := SolarPosition(c.at, c.lat, c.lon)
+		if !near(pos.Zenith, c.zenith, c.tol) || !near(pos.Azimuth, c.azimuth, 0.1) {
+			t.Errorf("%s: %+v, want zenith %v azimuth %v", c.name, pos, c.zenith, c.azimuth)
+		}
+	}
+	if pos := SolarPosition(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 51.4779, 0); pos.Up() {
+		t.Errorf("sun up at midnight: %+v", pos)
+	}
+}
+
+func TestExtraterrestrialNormal(t *testing.T) {
+	// Perihelion is in early January, aphelion in early July
+	if got := ExtraterrestrialNormal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)); !near(got, 1408.7, 0.5) {
+		t.Errorf("January = %v", got)
+	}
+	if got := ExtraterrestrialNormal(time.Date(2024, 7, 4, 0, 0, 0, 0, time.UTC)); !near(got, 1315.5, 0.5) {
+		t.Errorf("July = %v", got)
+	}
+}
+
+func TestClearSky(t *testing.T) {
+	equinox := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+	overhead := ClearSky(Position{Zenith: 0}, equinox, 0, 0)
+	if !near(overhead.GHI, 1060, 5) || !near(overhead.DNI, 948, 5) || !near(overhead.GHI, overhead.DNI+overhead.DHI, 1e-9) {
+		t.Errorf("overhead sun = %+v", overhead)
+	}
+	if hazy := ClearSky(Position{Zenith: 0}, equinox, 0, 6); hazy.GHI >= overhead.GHI || hazy.DNI >= overhead.DNI {
+		t.Errorf("hazier sky = %+v, clearer %+v", hazy, overhead)
+	}
+	if high := ClearSky(Position{Zenith: 0}, equinox, 3000, 0); high.GHI <= overhead.GHI {
+		t.Errorf("at 3000 m = %+v, at sea level %+v", high, overhead)
+	}
+	if night := ClearSky(Position{Zenith: 95}, equinox, 0, 0); night != (Irradiance{}) {
+		t.Errorf("night = %+v", night)
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	equinox := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+	sun := Position{Zenith: 30}
+	cosZenith := math.Cos(rad(30))
+
+	clear := Decompose(800, sun, equinox)
+	if !near(clear.DNI*cosZenith+clear.DHI, 800, 1e-9) || !near(clear.DHI, 231.1, 0.5) {
+		t.Errorf("clear = %+v", clear)
+	}
+	// Overcast light is almost all diffuse
+	if overcast := Decompose(100, sun, equinox); overcast.DHI < 0.98*100 {
+		t.Errorf("overcast = %+v", overcast)
+	}
+	if low := Decompose(20, Position{Zenith: 89}, equinox); low.DNI != 0 || low.DHI != 20 {
+		t.Errorf("sun on the horizon = %+v", low)
+	}
+}
@@ -0,0 +1,143 @@
+package metar
+
+import (
+	"sort"
+	"time"
+)
+
+// Minimums are the ceiling and visibility below which conditions count
+type Minimums struct {
+	CeilingFt   int `json:"ceiling_ft"`
+	VisibilityM int `json:"visibility_m"`
+}
+
+// IFRMinimums are the FAA IFR thresholds: a ceiling below 1000 ft or
+// visibility below 3 statute miles
+var IFRMinimums = Minimums{CeilingFt: 1000, VisibilityM: milesToMetres(3)}
+
+// DefaultMaxGap is how long a report stands for when no newer one follows:
+// routine reports are hourly, so a gap longer than this is a missed report
+const DefaultMaxGap = 90 * time.Minute
+
+// SummaryOptions control how reports are summarised
+type SummaryOptions struct {
+	// Minimums define the time below minimums; zero uses IFRMinimums
+	Minimums Minimums
+	// RunwayHeading, in degrees, is the runway crosswinds are measured
+	// against; zero leaves crosswind out
+	RunwayHeading int
+	// MaxGap is how long a report stands for; zero uses DefaultMaxGap
+	MaxGap time.Duration
+}
+
+// Summary is what a station reported over a window. Durations are in whole
+// seconds: each report stands until the next one, or for at most MaxGap,
+// and time no report covers is missing.
+type Summary struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Reports counts the reports made within the window
+	Reports  int      `json:"reports"`
+	Minimums Minimums `json:"minimums"`
+	// BelowMinimumsSeconds is the time the ceiling or visibility was below the minimums
+	BelowMinimumsSeconds int64 `json:"below_minimums_seconds"`
+	// ThunderstormSeconds is the time a thunderstorm was reported present or nearby
+	ThunderstormSeconds int64 `json:"thunderstorm_seconds"`
+	MissingSeconds      int64 `json:"missing_seconds"`
+	// CategorySeconds is the time spent in each flight category
+	CategorySeconds map[Category]int64 `json:"category_seconds"`
+	// Thunderstorm reports whether any report in the window had a
+	// thunderstorm, present or recent
+	Thunderstorm   bool `json:"thunderstorm"`
+	MinVisibilityM *int `json:"min_visibility_m,omitempty"`
+	// MinCeilingFt is the lowest ceiling reported; nil if there was none
+	MinCeilingFt   *int `json:"min_ceiling_ft,omitempty"`
+	MaxWindKt      int  `json:"max_wind_kt"`
+	MaxGustKt      int  `json:"max_gust_kt"`
+	MaxCrosswindKt int  `json:"max_crosswind_kt,omitempty"`
+}
+
+// Summarize summarises the reports over [start, end). Reports made before
+// the window count for the time they stand into it. Of reports made at the
+// same time, the last given wins.
+func Summarize(reports []Report, start, end time.Time, opts SummaryOptions) *Summary {
+	if opts.Minimums == (Minimums{}) {
+		opts.Minimums = IFRMinimums
+	}
+	if opts.MaxGap <= 0 {
+		opts.MaxGap = DefaultMaxGap
+	}
+	s := &Summary{Start: start.UTC(), End: end.UTC(), Minimums: opts.Minimums, CategorySeconds: map[Category]int64{}}
+
+	var sorted []Report
+	for _, r := range reports {
+		if r.Time.Before(end) && r.Time.After(start.Add(-opts.MaxGap)) {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	unique := sorted[:0]
+	for _, r := range sorted {
+		if n := len(unique); n > 0 && unique[n-1].Time.Equal(r.Time) {
+			unique[n-1] = r
+			continue
+		}
+		unique = append(unique, r)
+	}
+
+	var covered int64
+	for i, r := range unique {
+		until := r.Time.Add(opts.MaxGap)
+		if i+1 < len(unique) && unique[i+1].Time.Before(until) {
+			until = unique[i+1].Time
+		}
+		if until.After(end) {
+			until = end
+		}
+		from := r.Time
+		if from.Before(start) {
+			from = start
+		}
+		if seconds := int64(until.Sub(from) / time.Second); seconds > 0 {
+			covered += seconds
+			s.CategorySeconds[r.Category()] += seconds
+			if r.Below(opts.Minimums) {
+				s.BelowMinimumsSeconds += seconds
+			}
+			if r.Conditions.Thunderstorm() {
+				s.ThunderstormSeconds += seconds
+			}
+		}
+		if r.Time.Before(start) {
+			continue
+		}
+
+		s.Reports++
+		if r.Conditions.Thunderstorm() || containsTS(r.Recent) {
+			s.Thunderstorm = true
+		}
+		if r.VisibilityM != nil && (s.MinVisibilityM == nil || *r.VisibilityM < *s.MinVisibilityM) {
+			vis := *r.VisibilityM
+			s.MinVisibilityM = &vis
+		}
+		if ceiling, ok := r.CeilingFt(); ok && (s.MinCeilingFt == nil || ceiling < *s.MinCeilingFt) {
+			s.MinCeilingFt = &ceiling
+		}
+		if r.Wind != nil {
+			s.MaxWindKt = max(s.MaxWindKt, r.Wind.Speed)
+			s.MaxGustKt = max(s.MaxGustKt, r.Wind.Gust)
+		}
+		if opts.RunwayHeading != 0 {
+			s.MaxCrosswindKt = max(s.MaxCrosswindKt, r.CrosswindKt(opts.RunwayHeading))
+		}
+	}
+	if window := int64(end.Sub(start) / time.Second); window > covered {
+		s.MissingSeconds = window - covered
+	}
+	return s
+}
+
+func containsTS(groups []string) bool {
+	c := Conditions{Weather: groups}
+	return c.Thunderstorm()
+}
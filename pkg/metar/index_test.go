@@ -0,0 +1,66 @@
+package metar
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, text string) Report {
+	t.Helper()
+	r, err := Parse(text, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *r
+}
+
+func TestSummarize(t *testing.T) {
+	reports := []Report{
+		// Before the window, standing until the 10:51 report
+		mustParse(t, "KJFK 120951Z 18010KT 10SM BKN040 24/20 A2992"),
+		mustParse(t, "KJFK 121051Z 18012KT 2SM BR OVC008 24/22 A2990"),
+		mustParse(t, "KJFK 121120Z 20018G30KT 1/2SM +TSRA OVC003CB 22/21 A2988"),
+		mustParse(t, "KJFK 121151Z 21010KT 6SM -RA BKN012 22/21 A2990 RETS"),
+		// The 11:51 report stands for 90 minutes, leaving 13:21-14:21 missing
+		mustParse(t, "KJFK 121421Z 21008KT 10SM FEW050 23/20 A2992"),
+	}
+	start := time.Date(2024, 7, 12, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 7, 12, 15, 0, 0, 0, time.UTC)
+	s := Summarize(reports, start, end, SummaryOptions{RunwayHeading: 40})
+
+	// 10:51-11:51 below IFR minimums; the 11:20 thunderstorm lasts 31 minutes
+	if s.Reports != 4 || s.BelowMinimumsSeconds != 3600 || s.ThunderstormSeconds != 31*60 || s.MissingSeconds != 3600 {
+		t.Errorf("summary = %+v", s)
+	}
+	if s.CategorySeconds[VFR] != 51*60+39*60 || s.CategorySeconds[IFR] != 29*60 || s.CategorySeconds[LIFR] != 31*60 ||
+		s.CategorySeconds[MVFR] != 90*60 {
+		t.Errorf("categories = %v", s.CategorySeconds)
+	}
+	if !s.Thunderstorm || *s.MinVisibilityM != 805 || *s.MinCeilingFt != 300 || s.MaxWindKt != 18 || s.MaxGustKt != 30 {
+		t.Errorf("extremes = %+v", s)
+	}
+	// A 30 kt gust from 200 across runway 04
+	if s.MaxCrosswindKt != 10 {
+		t.Errorf("crosswind = %d", s.MaxCrosswindKt)
+	}
+}
+
+func TestSummarize_Minimums(t *testing.T) {
+	reports := []Report{
+		mustParse(t, "KJFK 121000Z 18010KT 1600 BKN004"),
+		// A SPECI at the same time replaces it
+		mustParse(t, "SPECI KJFK 121000Z 18010KT 1600 BKN002"),
+		mustParse(t, "KJFK 121100Z 18010KT 9999 BKN010"),
+	}
+	start := time.Date(2024, 7, 12, 10, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	cat1 := Minimums{CeilingFt: 300, VisibilityM: 800}
+	s := Summarize(reports, start, end, SummaryOptions{Minimums: cat1})
+	if s.Reports != 2 || s.BelowMinimumsSeconds != 3600 || s.Minimums != cat1 || s.Thunderstorm || s.MissingSeconds != 0 {
+		t.Errorf("summary = %+v", s)
+	}
+
+	if s := Summarize(nil, start, end, SummaryOptions{}); s.Reports != 0 || s.MissingSeconds != 7200 || s.Minimums != IFRMinimums {
+		t.Errorf("empty summary = %+v", s)
+	}
+}
@@ -0,0 +1,391 @@
+// Package metar decodes METAR and SPECI aviation weather reports and TAF
+// forecasts, and summarises the reports of a station over a time window
+// into indices such as the hours spent below IFR minimums. Reports only
+// carry the day of the month, so decoding takes a reference time to place
+// them in a month and year.
+package metar
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of an observation report
+type Kind string
+
+const (
+	// KindMETAR is a routine report
+	KindMETAR Kind = "METAR"
+	// KindSPECI is a special report issued when conditions change
+	KindSPECI Kind = "SPECI"
+)
+
+// metresPerMile converts statute miles to metres
+const metresPerMile = 1609.344
+
+// cavokVisibilityM is the visibility CAVOK and "9999" stand for
+const cavokVisibilityM = 10000
+
+// ErrNil is returned for a NIL report, which has no observation
+var ErrNil = errors.New("nil report")
+
+// Wind is a reported or forecast wind
+type Wind struct {
+	// Direction is where the wind blows from, in degrees true. It is zero
+	// when Variable is set.
+	Direction int  `json:"direction"`
+	Variable  bool `json:"variable,omitempty"`
+	// Speed and Gust are in knots
+	Speed int `json:"speed"`
+	Gust  int `json:"gust,omitempty"`
+}
+
+// CloudLayer is one cloud layer
+type CloudLayer struct {
+	// Cover is FEW, SCT, BKN or OVC, or VV for the vertical visibility into an obscured sky
+	Cover string `json:"cover"`
+	// BaseFt is the height of the base above ground in feet; -1 when not reported
+	BaseFt int `json:"base_ft"`
+	// Type is CB or TCU for convective cloud
+	Type string `json:"type,omitempty"`
+}
+
+// Conditions are the weather elements METARs and TAFs share. A TAF change
+// group sets only the elements that change: nil fields are unchanged, and
+// empty Weather or Clouds mean NSW or a clear sky.
+type Conditions struct {
+	Wind *Wind `json:"wind,omitempty"`
+	// VisibilityM is the prevailing visibility in whole metres
+	VisibilityM *int `json:"visibility_m,omitempty"`
+	// CAVOK reports visibility of 10 km or more, no cloud below 5000 ft and
+	// no significant weather
+	CAVOK bool `json:"cavok,omitempty"`
+	// Weather holds the present weather groups, such as "+TSRA" or "BR"
+	Weather []string     `json:"weather,omitempty"`
+	Clouds  []CloudLayer `json:"clouds,omitempty"`
+}
+
+// Report is a decoded METAR or SPECI
+type Report struct {
+	Kind    Kind      `json:"kind"`
+	Station string    `json:"station"`
+	Time    time.Time `json:"time"`
+	// Auto marks a report from an automated station
+	Auto bool `json:"auto,omitempty"`
+	Conditions
+	// Recent holds recent weather groups without the RE prefix, such as "TS"
+	Recent       []string `json:"recent,omitempty"`
+	TemperatureC *int     `json:"temperature_c,omitempty"`
+	DewPointC    *int     `json:"dew_point_c,omitempty"`
+	// AltimeterHPa is the QNH in hectopascals
+	AltimeterHPa *float64 `json:"altimeter_hpa,omitempty"`
+	// Unparsed holds body groups that were not understood
+	Unparsed []string `json:"unparsed,omitempty"`
+	// Raw is the report as decoded, normalised to single spaces
+	Raw string `json:"raw"`
+}
+
+var (
+	stationRe     = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}$`)
+	timeRe        = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	windRe        = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	windVaryRe    = regexp.MustCompile(`^\d{3}V\d{3}$`)
+	visMetresRe   = regexp.MustCompile(`^(\d{4})(?:N|NE|E|SE|S|SW|W|NW|NDV)?$`)
+	visMilesRe    = regexp.MustCompile(`^([MP])?(?:(\d+)|(\d+)/(\d+))SM$`)
+	visWholeRe    = regexp.MustCompile(`^\d$`)
+	visFractionRe = regexp.MustCompile(`^(\d)/(\d{1,2})SM$`)
+	weatherRe     = regexp.MustCompile(`^(?:\+|-|VC)?(?:MI|PR|BC|DR|BL|SH|TS|FZ)?(?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*$`)
+	cloudRe       = regexp.MustCompile(`^(FEW|SCT|BKN|OVC|VV)(\d{3}|///)(CB|TCU|///)?$`)
+	clearRe       = regexp.MustCompile(`^(SKC|CLR|NSC|NCD)$`)
+	tempRe        = regexp.MustCompile(`^(M?\d{2}|//)/(M?\d{2}|//)?$`)
+	altimeterRe   = regexp.MustCompile(`^([AQ])(\d{4})$`)
+	rvrRe         = regexp.MustCompile(`^R\d{2}[LCR]?/`)
+)
+
+// Parse decodes a METAR or SPECI. ref places the report's day of the month:
+// the report is taken to be from the latest matching time no more than two
+// days after ref, so ref is best the time the report was received or the
+// end of the window it was fetched for.
+func Parse(text string, ref time.Time) (*Report, error) {
+	tokens := tokenize(text)
+	r := &Report{Kind: KindMETAR, Raw: strings.Join(tokens, " ")}
+	i := 0
+	if i < len(tokens) && (tokens[i] == string(KindMETAR) || tokens[i] == string(KindSPECI)) {
+		r.Kind = Kind(tokens[i])
+		i++
+	}
+	if i < len(tokens) && tokens[i] == "COR" {
+		i++
+	}
+	if i >= len(tokens) || !stationRe.MatchString(tokens[i]) {
+		return nil, fmt.Errorf("invalid report %q: missing station", r.Raw)
+	}
+	r.Station = tokens[i]
+	i++
+	if i >= len(tokens) {
+		return nil, fmt.Errorf("invalid report %q: missing time", r.Raw)
+	}
+	m := timeRe.FindStringSubmatch(tokens[i])
+	if m == nil {
+		return nil, fmt.Errorf("invalid report %q: invalid time %q", r.Raw, tokens[i])
+	}
+	t, err := resolveTime(atoi(m[1]), atoi(m[2]), atoi(m[3]), ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report %q: %w", r.Raw, err)
+	}
+	r.Time = t
+	i++
+
+	for ; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok == "NIL":
+			return nil, ErrNil
+		case tok == "AUTO":
+			r.Auto = true
+		case tok == "COR", rvrRe.MatchString(tok):
+			// Corrections and runway visual ranges add nothing the indices use
+		case tok == "RMK" || tok == "NOSIG" || tok == "BECMG" || tok == "TEMPO":
+			// Remarks and the trend forecast are not part of the observation
+			return r, nil
+		case strings.HasPrefix(tok, "RE") && len(tok) > 2 && weatherRe.MatchString(tok[2:]):
+			r.Recent = append(r.Recent, tok[2:])
+		case tempRe.MatchString(tok):
+			m := tempRe.FindStringSubmatch(tok)
+			r.TemperatureC, r.DewPointC = parseTemp(m[1]), parseTemp(m[2])
+		case altimeterRe.MatchString(tok):
+			m := altimeterRe.FindStringSubmatch(tok)
+			hpa := float64(atoi(m[2]))
+			if m[1] == "A" {
+				// Inches of mercury in hundredths
+				hpa = math.Round(hpa*33.8639) / 100
+			}
+			r.AltimeterHPa = &hpa
+		default:
+			n := r.Conditions.parse(tokens, i)
+			if n == 0 {
+				r.Unparsed = append(r.Unparsed, tok)
+				continue
+			}
+			i += n - 1
+		}
+	}
+	return r, nil
+}
+
+// parse decodes the condition group at tokens[i], returning how many tokens
+// it took, or zero if it is not one
+func (c *Conditions) parse(tokens []string, i int) int {
+	tok := tokens[i]
+	switch {
+	case tok == "CAVOK":
+		vis := cavokVisibilityM
+		c.CAVOK, c.VisibilityM = true, &vis
+		c.Weather, c.Clouds = []string{}, []CloudLayer{}
+	case tok == "NSW":
+		c.Weather = []string{}
+	case clearRe.MatchString(tok):
+		c.Clouds = []CloudLayer{}
+	case tok == "////":
+		// Visibility not measured
+	case windRe.MatchString(tok):
+		c.Wind = parseWind(windRe.FindStringSubmatch(tok))
+	case windVaryRe.MatchString(tok):
+		// Variation of the direction; the mean is already reported
+	case visMetresRe.MatchString(tok):
+		// A second group is the minimum visibility in one direction
+		if c.VisibilityM == nil {
+			vis := atoi(visMetresRe.FindStringSubmatch(tok)[1])
+			if vis == 9999 {
+				vis = cavokVisibilityM
+			}
+			c.VisibilityM = &vis
+		}
+	case visMilesRe.MatchString(tok):
+		m := visMilesRe.FindStringSubmatch(tok)
+		miles := float64(atoi(m[2]))
+		if m[3] != "" {
+			miles = float64(atoi(m[3])) / float64(atoi(m[4]))
+		}
+		c.setMiles(miles)
+	case visWholeRe.MatchString(tok) && i+1 < len(tokens) && visFractionRe.MatchString(tokens[i+1]):
+		// "1 1/2SM"
+		m := visFractionRe.FindStringSubmatch(tokens[i+1])
+		c.setMiles(float64(atoi(tok)) + float64(atoi(m[1]))/float64(atoi(m[2])))
+		return 2
+	case cloudRe.MatchString(tok):
+		m := cloudRe.FindStringSubmatch(tok)
+		layer := CloudLayer{Cover: m[1], BaseFt: -1}
+		if m[2] != "///" {
+			layer.BaseFt = atoi(m[2]) * 100
+		}
+		if m[3] != "///" {
+			layer.Type = m[3]
+		}
+		c.Clouds = append(c.Clouds, layer)
+	case tok != "" && tok != "+" && tok != "-" && tok != "VC" && weatherRe.MatchString(tok):
+		c.Weather = append(c.Weather, tok)
+	default:
+		return 0
+	}
+	return 1
+}
+
+// setMiles sets the visibility from statute miles
+func (c *Conditions) setMiles(miles float64) {
+	vis := milesToMetres(miles)
+	c.VisibilityM = &vis
+}
+
+// milesToMetres converts statute miles to whole metres
+func milesToMetres(miles float64) int {
+	return int(math.Round(miles * metresPerMile))
+}
+
+// parseWind decodes a matched wind group, converting the speeds to knots
+func parseWind(m []string) *Wind {
+	w := &Wind{Speed: atoi(m[2]), Gust: atoi(m[3])}
+	if m[1] == "VRB" {
+		w.Variable = true
+	} else {
+		w.Direction = atoi(m[1])
+	}
+	switch m[4] {
+	case "MPS":
+		w.Speed, w.Gust = toKnots(w.Speed, 1.943844), toKnots(w.Gust, 1.943844)
+	case "KMH":
+		w.Speed, w.Gust = toKnots(w.Speed, 1/1.852), toKnots(w.Gust, 1/1.852)
+	}
+	return w
+}
+
+func toKnots(v int, factor float64) int {
+	return int(math.Round(float64(v) * factor))
+}
+
+// parseTemp decodes a temperature such as "M05", or nil for "//"
+func parseTemp(s string) *int {
+	if s == "" || s == "//" {
+		return nil
+	}
+	v := atoi(strings.TrimPrefix(s, "M"))
+	if strings.HasPrefix(s, "M") {
+		v = -v
+	}
+	return &v
+}
+
+// CeilingFt is the base of the lowest broken or overcast layer, or the
+// vertical visibility, in feet. ok is false when there is no ceiling.
+func (c *Conditions) CeilingFt() (ft int, ok bool) {
+	for _, l := range c.Clouds {
+		if (l.Cover == "BKN" || l.Cover == "OVC" || l.Cover == "VV") && l.BaseFt >= 0 && (!ok || l.BaseFt < ft) {
+			ft, ok = l.BaseFt, true
+		}
+	}
+	return ft, ok
+}
+
+// Thunderstorm reports whether a thunderstorm is present or in the vicinity
+func (c *Conditions) Thunderstorm() bool {
+	for _, w := range c.Weather {
+		if strings.Contains(w, "TS") {
+			return true
+		}
+	}
+	return false
+}
+
+// CrosswindKt is the crosswind on a runway with the given heading in
+// degrees, in knots, using the gust when one is reported. A variable wind
+// counts in full.
+func (c *Conditions) CrosswindKt(runwayHeading int) int {
+	if c.Wind == nil {
+		return 0
+	}
+	speed := c.Wind.Speed
+	if c.Wind.Gust > speed {
+		speed = c.Wind.Gust
+	}
+	if c.Wind.Variable {
+		return speed
+	}
+	angle := float64(c.Wind.Direction-runwayHeading) * math.Pi / 180
+	return int(math.Round(math.Abs(float64(speed) * math.Sin(angle))))
+}
+
+// Category is a flight category
+type Category string
+
+const (
+	VFR  Category = "VFR"
+	MVFR Category = "MVFR"
+	IFR  Category = "IFR"
+	LIFR Category = "LIFR"
+)
+
+// Category returns the FAA flight category of the conditions. Unreported
+// visibility counts as unrestricted.
+func (c *Conditions) Category() Category {
+	ceiling, hasCeiling := c.CeilingFt()
+	ceilingBelow := func(ft int) bool { return hasCeiling && ceiling < ft }
+	visBelow := func(miles float64) bool { return c.VisibilityM != nil && *c.VisibilityM < milesToMetres(miles) }
+	switch {
+	case ceilingBelow(500) || visBelow(1):
+		return LIFR
+	case ceilingBelow(1000) || visBelow(3):
+		return IFR
+	case ceilingBelow(3001) || (c.VisibilityM != nil && *c.VisibilityM <= milesToMetres(5)):
+		return MVFR
+	default:
+		return VFR
+	}
+}
+
+// Below reports whether the ceiling or visibility is below m
+func (c *Conditions) Below(m Minimums) bool {
+	if ceiling, ok := c.CeilingFt(); ok && ceiling < m.CeilingFt {
+		return true
+	}
+	return c.VisibilityM != nil && *c.VisibilityM < m.VisibilityM
+}
+
+// tokenize upper-cases text and splits it into groups, dropping the "="
+// that ends a report
+func tokenize(text string) []string {
+	text = strings.TrimSpace(strings.ToUpper(text))
+	text = strings.TrimSuffix(text, "=")
+	return strings.Fields(text)
+}
+
+// resolveTime places a day, hour and minute at the latest time no more
+// than two days after ref. Hour 24 is midnight at the end of the day.
+func resolveTime(day, hour, minute int, ref time.Time) (time.Time, error) {
+	if day < 1 || day > 31 || hour > 24 || minute > 59 {
+		return time.Time{}, fmt.Errorf("invalid day and time %02d%02d%02d", day, hour, minute)
+	}
+	ref = ref.UTC()
+	limit := ref.Add(48 * time.Hour)
+	year, month, _ := ref.Date()
+	for delta := 1; delta >= -2; delta-- {
+		first := time.Date(year, month+time.Month(delta), 1, 0, 0, 0, 0, time.UTC)
+		if day > first.AddDate(0, 1, -1).Day() {
+			continue
+		}
+		t := time.Date(first.Year(), first.Month(), day, hour, minute, 0, 0, time.UTC)
+		if !t.After(limit) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("day %d does not fit near %s", day, ref.Format(time.RFC3339))
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
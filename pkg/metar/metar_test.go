@@ -0,0 +1,139 @@
+package metar
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var ref = time.Date(2024, 7, 12, 23, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("METAR KJFK 121751Z 18015G28KT 150V210 1 1/2SM R04R/2200FT +TSRA BR FEW008 BKN025CB OVC040 24/22 A2992 RETS RMK AO2 TSB32=", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Kind != KindMETAR || r.Station != "KJFK" || !r.Time.Equal(time.Date(2024, 7, 12, 17, 51, 0, 0, time.UTC)) {
+		t.Errorf("header = %s %s %s", r.Kind, r.Station, r.Time)
+	}
+	if r.Wind == nil || *r.Wind != (Wind{Direction: 180, Speed: 15, Gust: 28}) {
+		t.Errorf("wind = %+v", r.Wind)
+	}
+	if r.VisibilityM == nil || *r.VisibilityM != 2414 {
+		t.Errorf("visibility = %v", r.VisibilityM)
+	}
+	if !reflect.DeepEqual(r.Weather, []string{"+TSRA", "BR"}) || !reflect.DeepEqual(r.Recent, []string{"TS"}) {
+		t.Errorf("weather = %v, recent = %v", r.Weather, r.Recent)
+	}
+	want := []CloudLayer{{"FEW", 800, ""}, {"BKN", 2500, "CB"}, {"OVC", 4000, ""}}
+	if !reflect.DeepEqual(r.Clouds, want) {
+		t.Errorf("clouds = %+v", r.Clouds)
+	}
+	if ceiling, ok := r.CeilingFt(); !ok || ceiling != 2500 {
+		t.Errorf("ceiling = %d, %v", ceiling, ok)
+	}
+	if *r.TemperatureC != 24 || *r.DewPointC != 22 || *r.AltimeterHPa != 1013.21 {
+		t.Errorf("temperature = %d/%d, altimeter = %v", *r.TemperatureC, *r.DewPointC, *r.AltimeterHPa)
+	}
+	if len(r.Unparsed) != 0 || !r.Thunderstorm() || r.Category() != IFR {
+		t.Errorf("unparsed = %v, thunderstorm = %v, category = %s", r.Unparsed, r.Thunderstorm(), r.Category())
+	}
+}
+
+func TestParse_International(t *testing.T) {
+	r, err := Parse("SPECI EGLL 120920Z AUTO VRB03MPS 0400 R27L/0550N FG VV002 M01/M01 Q1021 NOSIG", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Kind != KindSPECI || !r.Auto || r.Wind == nil || !r.Wind.Variable || r.Wind.Speed != 6 {
+		t.Errorf("report = %+v, wind = %+v", r, r.Wind)
+	}
+	if *r.VisibilityM != 400 || *r.TemperatureC != -1 || *r.AltimeterHPa != 1021 || r.Category() != LIFR {
+		t.Errorf("visibility = %d, temperature = %d, altimeter = %v, category = %s", *r.VisibilityM, *r.TemperatureC, *r.AltimeterHPa, r.Category())
+	}
+	if ceiling, ok := r.CeilingFt(); !ok || ceiling != 200 {
+		t.Errorf("ceiling = %d, %v", ceiling, ok)
+	}
+
+	r, err = Parse("LFPG 121000Z 27010KT CAVOK 18/09 Q1015", ref)
+	if err != nil || !r.CAVOK || *r.VisibilityM != 10000 || r.Category() != VFR {
+		t.Errorf("CAVOK = %+v, %v", r, err)
+	}
+	if _, ok := r.CeilingFt(); ok {
+		t.Error("CAVOK has a ceiling")
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	if _, err := Parse("KJFK 121751Z NIL=", ref); !errors.Is(err, ErrNil) {
+		t.Errorf("NIL report: %v", err)
+	}
+	for _, text := range []string{"", "METAR", "KJFK 1217Z 18015KT", "KJFK 321751Z 18015KT"} {
+		if _, err := Parse(text, ref); err == nil {
+			t.Errorf("Parse(%q) succeeded", text)
+		}
+	}
+}
+
+func TestResolveTime(t *testing.T) {
+	for _, c := range []struct {
+		day, hour int
+		ref, want time.Time
+	}{
+		{12, 17, ref, time.Date(2024, 7, 12, 17, 0, 0, 0, time.UTC)},
+		// Up to two days ahead of the reference is this month
+		{14, 6, ref, time.Date(2024, 7, 14, 6, 0, 0, 0, time.UTC)},
+		{20, 0, ref, time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)},
+		// Across a month and year end
+		{1, 0, time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// June has no 31st
+		{31, 12, time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)},
+	} {
+		if got, err := resolveTime(c.day, c.hour, 0, c.ref); err != nil || !got.Equal(c.want) {
+			t.Errorf("day %d hour %d near %s = %s, %v; want %s", c.day, c.hour, c.ref, got, err, c.want)
+		}
+	}
+}
+
+func TestConditions_Category(t *testing.T) {
+	for text, want := range map[string]Category{
+		"KJFK 121751Z 10SM FEW250":   VFR,
+		"KJFK 121751Z 10SM BKN030":   MVFR,
+		"KJFK 121751Z 5SM SCT100":    MVFR,
+		"KJFK 121751Z 6SM OVC035":    VFR,
+		"KJFK 121751Z 3SM BR":        MVFR,
+		"KJFK 121751Z 2 1/2SM BR":    IFR,
+		"KJFK 121751Z 10SM OVC009":   IFR,
+		"KJFK 121751Z 1SM BR":        IFR,
+		"KJFK 121751Z M1/4SM FG":     LIFR,
+		"KJFK 121751Z 10SM OVC004":   LIFR,
+		"KJFK 121751Z 10SM BKN///":   VFR,
+		"KJFK 121751Z 9999 SCT008CB": VFR,
+	} {
+		r, err := Parse(text, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Category(); got != want {
+			t.Errorf("%s: %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestConditions_CrosswindKt(t *testing.T) {
+	for wind, want := range map[string]int{
+		"31010KT":    0,
+		"04010KT":    10,
+		"36020G30KT": 23,
+		"VRB05KT":    5,
+	} {
+		r, err := Parse("KJFK 121751Z "+wind, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.CrosswindKt(310); got != want {
+			t.Errorf("%s on runway 31: %d kt, want %d", wind, got, want)
+		}
+	}
+}
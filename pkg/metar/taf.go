@@ -0,0 +1,218 @@
+package metar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Change is the kind of a TAF change group
+type Change string
+
+const (
+	// ChangeBase is the forecast's opening conditions
+	ChangeBase Change = ""
+	// ChangeFrom replaces every element from its start
+	ChangeFrom Change = "FM"
+	// ChangeBecoming changes some elements over its period
+	ChangeBecoming Change = "BECMG"
+	// ChangeTemporary are fluctuations lasting under an hour at a time
+	ChangeTemporary Change = "TEMPO"
+	// ChangeProbable are conditions with a probability of 30 or 40 percent
+	ChangeProbable Change = "PROB"
+)
+
+// ForecastPeriod is the base forecast or one change group
+type ForecastPeriod struct {
+	Change Change `json:"change"`
+	// Probability is 30 or 40 for PROB groups
+	Probability int `json:"probability,omitempty"`
+	// Temporary marks a PROB TEMPO group
+	Temporary bool      `json:"temporary,omitempty"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Conditions
+}
+
+// Forecast is a decoded TAF
+type Forecast struct {
+	Station   string    `json:"station"`
+	Issued    time.Time `json:"issued"`
+	Amended   bool      `json:"amended,omitempty"`
+	ValidFrom time.Time `json:"valid_from"`
+	ValidTo   time.Time `json:"valid_to"`
+	// Periods starts with the base forecast, followed by the change groups in order
+	Periods []ForecastPeriod `json:"periods"`
+	// Unparsed holds groups that were not understood
+	Unparsed []string `json:"unparsed,omitempty"`
+	Raw      string   `json:"raw"`
+}
+
+var (
+	validityRe = regexp.MustCompile(`^(\d{2})(\d{2})/(\d{2})(\d{2})$`)
+	fromRe     = regexp.MustCompile(`^FM(\d{2})(\d{2})(\d{2})$`)
+	probRe     = regexp.MustCompile(`^PROB(30|40)$`)
+	extremeRe  = regexp.MustCompile(`^T[XN]M?\d{2}/\d{4}Z$`)
+)
+
+// ParseTAF decodes a TAF. ref places the issue time as in Parse; the
+// validity and change groups are placed after the issue time.
+func ParseTAF(text string, ref time.Time) (*Forecast, error) {
+	tokens := tokenize(text)
+	f := &Forecast{Raw: strings.Join(tokens, " ")}
+	i := 0
+	if i < len(tokens) && tokens[i] == "TAF" {
+		i++
+	}
+	for i < len(tokens) && (tokens[i] == "AMD" || tokens[i] == "COR") {
+		f.Amended = f.Amended || tokens[i] == "AMD"
+		i++
+	}
+	if i >= len(tokens) || !stationRe.MatchString(tokens[i]) {
+		return nil, fmt.Errorf("invalid TAF %q: missing station", f.Raw)
+	}
+	f.Station = tokens[i]
+	i++
+	if i >= len(tokens) {
+		return nil, fmt.Errorf("invalid TAF %q: missing issue time", f.Raw)
+	}
+	m := timeRe.FindStringSubmatch(tokens[i])
+	if m == nil {
+		return nil, fmt.Errorf("invalid TAF %q: invalid issue time %q", f.Raw, tokens[i])
+	}
+	issued, err := resolveTime(atoi(m[1]), atoi(m[2]), atoi(m[3]), ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TAF %q: %w", f.Raw, err)
+	}
+	f.Issued = issued
+	i++
+	if i < len(tokens) && (tokens[i] == "NIL" || tokens[i] == "CNL") {
+		return nil, ErrNil
+	}
+	if i >= len(tokens) {
+		return nil, fmt.Errorf("invalid TAF %q: missing validity", f.Raw)
+	}
+	if f.ValidFrom, f.ValidTo, err = parseValidity(tokens[i], issued); err != nil {
+		return nil, fmt.Errorf("invalid TAF %q: %w", f.Raw, err)
+	}
+	i++
+
+	period := &ForecastPeriod{Change: ChangeBase, From: f.ValidFrom, To: f.ValidTo}
+	for ; i < len(tokens); i++ {
+		tok := tokens[i]
+		next := &ForecastPeriod{To: f.ValidTo}
+		switch {
+		case tok == "RMK":
+			i = len(tokens)
+			continue
+		case tok == "CNL":
+			return nil, ErrNil
+		case extremeRe.MatchString(tok):
+			continue
+		case fromRe.MatchString(tok):
+			m := fromRe.FindStringSubmatch(tok)
+			next.Change = ChangeFrom
+			if next.From, err = resolveTime(atoi(m[1]), atoi(m[2]), atoi(m[3]), issued); err != nil {
+				return nil, fmt.Errorf("invalid TAF %q: %w", f.Raw, err)
+			}
+		case tok == "BECMG" || tok == "TEMPO" || probRe.MatchString(tok):
+			next.Change = Change(tok)
+			if m := probRe.FindStringSubmatch(tok); m != nil {
+				next.Change, next.Probability = ChangeProbable, atoi(m[1])
+				if i+1 < len(tokens) && tokens[i+1] == "TEMPO" {
+					next.Temporary = true
+					i++
+				}
+			}
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("invalid TAF %q: %s without a period", f.Raw, tok)
+			}
+			i++
+			if next.From, next.To, err = parseValidity(tokens[i], issued); err != nil {
+				return nil, fmt.Errorf("invalid TAF %q: %w", f.Raw, err)
+			}
+		default:
+			if n := period.Conditions.parse(tokens, i); n > 0 {
+				i += n - 1
+			} else {
+				f.Unparsed = append(f.Unparsed, tok)
+			}
+			continue
+		}
+		f.Periods = append(f.Periods, *period)
+		period = next
+	}
+	f.Periods = append(f.Periods, *period)
+
+	// A FM group lasts until the next one
+	for j := range f.Periods {
+		if f.Periods[j].Change != ChangeFrom {
+			continue
+		}
+		for k := j + 1; k < len(f.Periods); k++ {
+			if f.Periods[k].Change == ChangeFrom {
+				f.Periods[j].To = f.Periods[k].From
+				break
+			}
+		}
+	}
+	return f, nil
+}
+
+// parseValidity decodes a "DDHH/DDHH" period starting after issued
+func parseValidity(tok string, issued time.Time) (from, to time.Time, err error) {
+	m := validityRe.FindStringSubmatch(tok)
+	if m == nil {
+		return from, to, fmt.Errorf("invalid period %q", tok)
+	}
+	if from, err = resolveTime(atoi(m[1]), atoi(m[2]), 0, issued); err != nil {
+		return from, to, err
+	}
+	if to, err = resolveTime(atoi(m[3]), atoi(m[4]), 0, from); err != nil {
+		return from, to, err
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("period %q ends before it starts", tok)
+	}
+	return from, to, nil
+}
+
+// Prevailing returns the conditions forecast to prevail at t: the base
+// forecast with the FM groups that have started and the BECMG groups that
+// have completed. TEMPO and PROB groups are left out. ok is false outside
+// the forecast's validity.
+func (f *Forecast) Prevailing(t time.Time) (c Conditions, ok bool) {
+	if t.Before(f.ValidFrom) || !t.Before(f.ValidTo) {
+		return c, false
+	}
+	for _, p := range f.Periods {
+		switch {
+		case p.Change == ChangeBase:
+			c = p.Conditions
+		case p.Change == ChangeFrom && !t.Before(p.From):
+			c = p.Conditions
+		case p.Change == ChangeBecoming && !t.Before(p.To):
+			c = c.apply(p.Conditions)
+		}
+	}
+	return c, true
+}
+
+// apply returns c with the elements change sets replaced
+func (c Conditions) apply(change Conditions) Conditions {
+	if change.Wind != nil {
+		c.Wind = change.Wind
+	}
+	if change.VisibilityM != nil {
+		c.VisibilityM = change.VisibilityM
+		c.CAVOK = change.CAVOK
+	}
+	if change.Weather != nil {
+		c.Weather = change.Weather
+	}
+	if change.Clouds != nil {
+		c.Clouds = change.Clouds
+	}
+	return c
+}
@@ -0,0 +1,93 @@
+package metar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const jfkTAF = `TAF AMD KJFK 121130Z 1212/1318 18010KT P6SM SCT050
+  FM121800 20015G25KT 5SM -TSRA BKN020CB
+  TEMPO 1220/1224 2SM +TSRA OVC008CB
+  FM130200 27008KT P6SM SKC
+  BECMG 1306/1308 31012KT
+  PROB30 TEMPO 1312/1316 3SM -SHRA BKN015 TX28/1219Z=`
+
+func TestParseTAF(t *testing.T) {
+	f, err := ParseTAF(jfkTAF, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour int) time.Time { return time.Date(2024, 7, day, hour, 0, 0, 0, time.UTC) }
+	if f.Station != "KJFK" || !f.Amended || !f.Issued.Equal(time.Date(2024, 7, 12, 11, 30, 0, 0, time.UTC)) ||
+		!f.ValidFrom.Equal(at(12, 12)) || !f.ValidTo.Equal(at(13, 18)) {
+		t.Errorf("header = %+v", f)
+	}
+	if len(f.Periods) != 6 || len(f.Unparsed) != 0 {
+		t.Fatalf("periods = %+v, unparsed = %v", f.Periods, f.Unparsed)
+	}
+	for i, want := range []struct {
+		change   Change
+		from, to time.Time
+	}{
+		{ChangeBase, at(12, 12), at(13, 18)},
+		{ChangeFrom, at(12, 18), at(13, 2)},
+		{ChangeTemporary, at(12, 20), at(13, 0)},
+		{ChangeFrom, at(13, 2), at(13, 18)},
+		{ChangeBecoming, at(13, 6), at(13, 8)},
+		{ChangeProbable, at(13, 12), at(13, 16)},
+	} {
+		p := f.Periods[i]
+		if p.Change != want.change || !p.From.Equal(want.from) || !p.To.Equal(want.to) {
+			t.Errorf("period %d = %s %s-%s, want %s %s-%s", i, p.Change, p.From, p.To, want.change, want.from, want.to)
+		}
+	}
+	if p := f.Periods[5]; p.Probability != 30 || !p.Temporary {
+		t.Errorf("PROB30 TEMPO = %+v", p)
+	}
+	if !f.Periods[1].Thunderstorm() || f.Periods[1].Category() != MVFR || f.Periods[2].Category() != IFR {
+		t.Errorf("FM1800 = %+v", f.Periods[1].Conditions)
+	}
+}
+
+func TestForecast_Prevailing(t *testing.T) {
+	f, err := ParseTAF(jfkTAF, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		day, hour    int
+		direction    int
+		thunderstorm bool
+	}{
+		{12, 12, 180, false},
+		// TEMPO groups do not prevail
+		{12, 21, 200, true},
+		{13, 3, 270, false},
+		// BECMG applies once complete, keeping the rest of the FM group
+		{13, 7, 270, false},
+		{13, 9, 310, false},
+	} {
+		cond, ok := f.Prevailing(time.Date(2024, 7, c.day, c.hour, 0, 0, 0, time.UTC))
+		if !ok || cond.Wind.Direction != c.direction || cond.Thunderstorm() != c.thunderstorm {
+			t.Errorf("%02d%02dZ: %+v, %v", c.day, c.hour, cond, ok)
+		}
+		if c.day == 13 && (cond.Clouds == nil || len(cond.Clouds) != 0) {
+			t.Errorf("%02d%02dZ: clouds = %v, want a clear sky", c.day, c.hour, cond.Clouds)
+		}
+	}
+	if _, ok := f.Prevailing(time.Date(2024, 7, 13, 18, 0, 0, 0, time.UTC)); ok {
+		t.Error("prevailing conditions after the validity")
+	}
+}
+
+func TestParseTAF_Rejects(t *testing.T) {
+	if _, err := ParseTAF("TAF KJFK 121130Z NIL=", ref); !errors.Is(err, ErrNil) {
+		t.Errorf("NIL TAF: %v", err)
+	}
+	for _, text := range []string{"TAF", "TAF KJFK 121130Z 18010KT", "TAF KJFK 121130Z 1212/1318 TEMPO", "TAF KJFK 121130Z 1218/1212 18010KT"} {
+		if _, err := ParseTAF(text, ref); err == nil {
+			t.Errorf("ParseTAF(%q) succeeded", text)
+		}
+	}
+}
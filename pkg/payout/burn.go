@@ -0,0 +1,113 @@
+package payout
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Loading turns the expected loss of a structure into a premium
+type Loading struct {
+	// ExpenseBps is added on top of the expected loss
+	ExpenseBps uint32 `json:"expense_bps"`
+	// RiskBps charges for the tail: this share of the tail expectation in
+	// excess of the expected loss is added
+	RiskBps uint32 `json:"risk_bps"`
+	// MinRateBps is the lowest premium as a share of the sum insured
+	MinRateBps uint32 `json:"min_rate_bps"`
+}
+
+// Validate checks the loading
+func (l Loading) Validate() error {
+	if l.MinRateBps > MaxBps {
+		return fmt.Errorf("min_rate_bps must not exceed %d", MaxBps)
+	}
+	return nil
+}
+
+// Quote is a burn analysis of a structure over historical periods
+type Quote struct {
+	// Periods is the number of historical periods analysed
+	Periods int `json:"periods"`
+	// Triggered counts the periods that paid out, and FrequencyBps their share
+	Triggered    int    `json:"triggered"`
+	FrequencyBps uint32 `json:"frequency_bps"`
+	// ExpectedLoss is the mean payout per period
+	ExpectedLoss Amount `json:"expected_loss"`
+	// VaR is the payout not exceeded in VaRLevelBps of periods, and
+	// TailExpectation the mean payout of the periods at or beyond it
+	VaRLevelBps     uint32 `json:"var_level_bps"`
+	VaR             Amount `json:"var"`
+	TailExpectation Amount `json:"tail_expectation"`
+	MaxPayout       Amount `json:"max_payout"`
+	// Premium is the indicative premium, and RateOnLineBps its share of the
+	// sum insured
+	Premium       Amount  `json:"premium"`
+	RateOnLineBps uint32  `json:"rate_on_line_bps"`
+	Loading       Loading `json:"loading"`
+}
+
+// Burn prices s from the payouts it would have made in each historical
+// period. The VaR is the empirical quantile at varLevelBps; the premium is
+// the expected loss plus the expense loading, plus the risk loading on the
+// tail expectation above the expected loss, and at least the minimum rate.
+func Burn(s Structure, periods []*Result, varLevelBps uint32, loading Loading) (*Quote, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := loading.Validate(); err != nil {
+		return nil, err
+	}
+	if len(periods) == 0 {
+		return nil, fmt.Errorf("no historical periods to analyse")
+	}
+	if varLevelBps == 0 || varLevelBps > MaxBps {
+		return nil, fmt.Errorf("VaR level must be in 1-%d bps", MaxBps)
+	}
+
+	payouts := make([]*big.Int, len(periods))
+	total := new(big.Int)
+	q := &Quote{Periods: len(periods), VaRLevelBps: varLevelBps, Loading: loading}
+	for i, r := range periods {
+		payouts[i] = r.Payout.Int()
+		total.Add(total, payouts[i])
+		if r.Triggered && !r.Payout.IsZero() {
+			q.Triggered++
+		}
+	}
+	n := big.NewInt(int64(len(payouts)))
+	q.FrequencyBps = uint32(q.Triggered * MaxBps / len(payouts))
+	q.ExpectedLoss = Amount{v: new(big.Int).Quo(total, n)}
+
+	sort.Slice(payouts, func(i, j int) bool { return payouts[i].Cmp(payouts[j]) < 0 })
+	// The smallest payout at or above the level, by rank ceil(n * level)
+	k := (len(payouts)*int(varLevelBps) + MaxBps - 1) / MaxBps
+	if k < 1 {
+		k = 1
+	}
+	tail := payouts[k-1:]
+	q.VaR = Amount{v: new(big.Int).Set(tail[0])}
+	tailTotal := new(big.Int)
+	for _, p := range tail {
+		tailTotal.Add(tailTotal, p)
+	}
+	q.TailExpectation = Amount{v: tailTotal.Quo(tailTotal, big.NewInt(int64(len(tail))))}
+	q.MaxPayout = Amount{v: new(big.Int).Set(payouts[len(payouts)-1])}
+
+	premium := q.ExpectedLoss.Int()
+	premium.Add(premium, bpsOf(q.ExpectedLoss, loading.ExpenseBps).Int())
+	excess := new(big.Int).Sub(q.TailExpectation.Int(), q.ExpectedLoss.Int())
+	premium.Add(premium, bpsOf(Amount{v: excess}, loading.RiskBps).Int())
+	if floor := bpsOf(s.SumInsured, loading.MinRateBps).Int(); premium.Cmp(floor) < 0 {
+		premium = floor
+	}
+	q.Premium = Amount{v: premium}
+
+	rate := new(big.Int).Mul(premium, big.NewInt(MaxBps))
+	rate.Quo(rate, s.SumInsured.Int())
+	if !rate.IsUint64() || rate.Uint64() > 1<<32-1 {
+		return nil, fmt.Errorf("premium %s is out of range for sum insured %s", premium, s.SumInsured)
+	}
+	q.RateOnLineBps = uint32(rate.Uint64())
+	return q, nil
+}
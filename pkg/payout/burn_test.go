@@ -0,0 +1,81 @@
+package payout
+
+import "testing"
+
+// burnPeriods returns the payouts of s at each index
+func burnPeriods(t *testing.T, s Structure, indices ...string) []*Result {
+	t.Helper()
+	var periods []*Result
+	for _, index := range indices {
+		r, err := Calculate(s, MustDecimal(index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		periods = append(periods, r)
+	}
+	return periods
+}
+
+func TestBurn(t *testing.T) {
+	s := Structure{Kind: Linear, Attachment: MustDecimal("0"), Exhaustion: MustDecimal("100"), SumInsured: NewAmount(1000)}
+	// Seven dry years, then payouts of 200, 500 and 1000
+	periods := burnPeriods(t, s, "0", "0", "0", "0", "0", "0", "0", "20", "50", "100")
+
+	q, err := Burn(s, periods, 9000, Loading{ExpenseBps: 2000, RiskBps: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Periods != 10 || q.Triggered != 3 || q.FrequencyBps != 3000 {
+		t.Errorf("frequency = %d/%d, %d bps", q.Triggered, q.Periods, q.FrequencyBps)
+	}
+	for _, c := range []struct {
+		name string
+		got  Amount
+		want string
+	}{
+		{"expected loss", q.ExpectedLoss, "170"},
+		{"VaR", q.VaR, "500"},
+		{"tail expectation", q.TailExpectation, "750"},
+		{"max payout", q.MaxPayout, "1000"},
+		// 170 + 20% of 170 + 10% of (750 - 170)
+		{"premium", q.Premium, "262"},
+	} {
+		if c.got.String() != c.want {
+			t.Errorf("%s = %s, want %s", c.name, c.got, c.want)
+		}
+	}
+	if q.RateOnLineBps != 2620 {
+		t.Errorf("RateOnLineBps = %d, want 2620", q.RateOnLineBps)
+	}
+
+	// The minimum rate sets a floor under the premium
+	q, err = Burn(s, periods, 9000, Loading{MinRateBps: 5000})
+	if err != nil || q.Premium.String() != "500" || q.RateOnLineBps != 5000 {
+		t.Errorf("with a minimum rate: %+v, %v", q, err)
+	}
+}
+
+func TestBurn_NoPayouts(t *testing.T) {
+	s := Structure{Kind: Linear, Attachment: MustDecimal("0"), Exhaustion: MustDecimal("100"), SumInsured: NewAmount(1000)}
+	q, err := Burn(s, burnPeriods(t, s, "0", "0"), MaxBps, Loading{ExpenseBps: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Triggered != 0 || !q.ExpectedLoss.IsZero() || !q.VaR.IsZero() || !q.Premium.IsZero() {
+		t.Errorf("quote = %+v", q)
+	}
+}
+
+func TestBurn_Invalid(t *testing.T) {
+	s := Structure{Kind: Linear, Attachment: MustDecimal("0"), Exhaustion: MustDecimal("100"), SumInsured: NewAmount(1000)}
+	periods := burnPeriods(t, s, "50")
+	if _, err := Burn(s, nil, 9500, Loading{}); err == nil {
+		t.Error("Burn() without periods succeeded")
+	}
+	if _, err := Burn(s, periods, 0, Loading{}); err == nil {
+		t.Error("Burn() with a zero VaR level succeeded")
+	}
+	if _, err := Burn(s, periods, 9500, Loading{MinRateBps: MaxBps + 1}); err == nil {
+		t.Error("Burn() with an invalid minimum rate succeeded")
+	}
+}
@@ -0,0 +1,168 @@
+package payout
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Scale is the number of fixed-point units in one index unit
+const Scale = 1_000_000
+
+// scaleDigits is the number of decimal places a Decimal holds
+const scaleDigits = 6
+
+// Decimal is an index value in fixed point with six decimal places. It
+// marshals to a JSON string and unmarshals from a string or number without
+// going through floating point.
+type Decimal int64
+
+// ParseDecimal parses a decimal such as "12.5", "-3" or "1e2". Values with
+// more than six decimal places are rejected rather than rounded.
+func ParseDecimal(s string) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt64(Scale))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("decimal %q has more than %d decimal places", s, scaleDigits)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("decimal %q is out of range", s)
+	}
+	return Decimal(r.Num().Int64()), nil
+}
+
+// MustDecimal is ParseDecimal for constants; it panics on invalid input
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// FromFloat converts a measured value, rounding half away from zero to six
+// decimal places. IEEE 754 makes the conversion identical on every operator.
+func FromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid index value %v", f)
+	}
+	scaled := math.Round(f * Scale)
+	if scaled >= math.MaxInt64 || scaled <= math.MinInt64 {
+		return 0, fmt.Errorf("index value %v is out of range", f)
+	}
+	return Decimal(scaled), nil
+}
+
+// String formats d without trailing zeros
+func (d Decimal) String() string {
+	sign, u := "", uint64(d)
+	if d < 0 {
+		sign, u = "-", uint64(-d)
+	}
+	s := fmt.Sprintf("%s%d", sign, u/Scale)
+	if frac := u % Scale; frac != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%0*d", scaleDigits, frac), "0")
+	}
+	return s
+}
+
+// MarshalJSON encodes d as a JSON string
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes a JSON string or number
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Amount is a non-negative token amount in base units (wei). It marshals to
+// a JSON string so clients without big integers keep every digit.
+type Amount struct {
+	v *big.Int
+}
+
+// NewAmount returns an amount of n base units
+func NewAmount(n int64) Amount {
+	return Amount{v: big.NewInt(n)}
+}
+
+// ParseAmount parses a base-unit amount written as a decimal integer
+func ParseAmount(s string) (Amount, error) {
+	v, ok := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if v.Sign() < 0 {
+		return Amount{}, fmt.Errorf("amount %q is negative", s)
+	}
+	return Amount{v: v}, nil
+}
+
+// Int returns a copy of the amount
+func (a Amount) Int() *big.Int {
+	if a.v == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(a.v)
+}
+
+// IsZero reports whether the amount is zero
+func (a Amount) IsZero() bool {
+	return a.v == nil || a.v.Sign() == 0
+}
+
+// Cmp compares a and b like big.Int.Cmp
+func (a Amount) Cmp(b Amount) int {
+	return a.Int().Cmp(b.Int())
+}
+
+// String formats the amount in base units
+func (a Amount) String() string {
+	return a.Int().String()
+}
+
+// MarshalJSON encodes the amount as a JSON string
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON decodes a JSON string or integer number
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// bpsOf returns floor(a * bps / MaxBps)
+func bpsOf(a Amount, bps uint32) Amount {
+	v := a.Int()
+	v.Mul(v, big.NewInt(int64(bps)))
+	v.Quo(v, big.NewInt(MaxBps))
+	return Amount{v: v}
+}
@@ -0,0 +1,78 @@
+package payout
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for in, want := range map[string]Decimal{
+		"12.5":      12_500_000,
+		"-3":        -3_000_000,
+		"0.000001":  1,
+		"1e2":       100_000_000,
+		" 7.250000": 7_250_000,
+	} {
+		got, err := ParseDecimal(in)
+		if err != nil || got != want {
+			t.Errorf("ParseDecimal(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "0.0000001", "1e30"} {
+		if _, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) succeeded", in)
+		}
+	}
+}
+
+func TestDecimal_StringAndJSON(t *testing.T) {
+	for d, want := range map[Decimal]string{
+		12_500_000: "12.5",
+		-1:         "-0.000001",
+		0:          "0",
+		42_000_000: "42",
+	} {
+		if got := d.String(); got != want {
+			t.Errorf("Decimal(%d).String() = %q, want %q", int64(d), got, want)
+		}
+	}
+
+	var v struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": 0.1, "b": "-2.25"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 100_000 || v.B != -2_250_000 {
+		t.Errorf("decoded %+v", v)
+	}
+	out, _ := json.Marshal(v)
+	if string(out) != `{"a":"0.1","b":"-2.25"}` {
+		t.Errorf("encoded %s", out)
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	if d, err := FromFloat(12.3456785); err != nil || d != 12_345_679 {
+		t.Errorf("FromFloat(12.3456785) = %d, %v", d, err)
+	}
+	if d, _ := FromFloat(-0.0000005); d != -1 {
+		t.Errorf("FromFloat rounds half away from zero, got %d", d)
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var a Amount
+	if err := json.Unmarshal([]byte(`"1000000000000000000000"`), &a); err != nil || a.String() != "1000000000000000000000" {
+		t.Fatalf("Unmarshal = %s, %v", a, err)
+	}
+	if err := json.Unmarshal([]byte(`25`), &a); err != nil || a.String() != "25" {
+		t.Errorf("Unmarshal(number) = %s, %v", a, err)
+	}
+	for _, in := range []string{`"-1"`, `"1.5"`, `"x"`} {
+		if err := json.Unmarshal([]byte(in), &a); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded", in)
+		}
+	}
+}
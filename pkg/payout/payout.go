@@ -0,0 +1,186 @@
+// Package payout computes parametric insurance payouts from an index value
+// and a policy's payout structure. Every step is integer arithmetic on
+// fixed-point index values and base-unit token amounts, rounding down, so
+// operators computing the same payout agree to the wei.
+package payout
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// MaxBps is a full payout in basis points
+const MaxBps = 10_000
+
+// Kind selects the payout curve
+type Kind string
+
+const (
+	// Linear pays nothing at the attachment point, rising linearly to the
+	// full sum insured at the exhaustion point
+	Linear Kind = "linear"
+	// Step pays the share of the highest tier the index reaches
+	Step Kind = "step"
+)
+
+// Direction says which side of the thresholds is adverse
+type Direction string
+
+const (
+	// Above pays as the index rises, e.g. excess rainfall or wind speed
+	Above Direction = "above"
+	// Below pays as the index falls, e.g. drought or frost
+	Below Direction = "below"
+)
+
+// Tier is one step of a step curve
+type Tier struct {
+	Threshold Decimal `json:"threshold"`
+	PayoutBps uint32  `json:"payout_bps"`
+}
+
+// Structure is a policy's payout structure
+type Structure struct {
+	Kind Kind `json:"kind"`
+	// Direction defaults to Above
+	Direction  Direction `json:"direction,omitempty"`
+	Attachment Decimal   `json:"attachment,omitempty"`
+	Exhaustion Decimal   `json:"exhaustion,omitempty"`
+	// Tiers are ordered from least to most severe
+	Tiers      []Tier `json:"tiers,omitempty"`
+	SumInsured Amount `json:"sum_insured"`
+	// DeductibleBps of the sum insured is subtracted from the gross payout
+	DeductibleBps uint32 `json:"deductible_bps,omitempty"`
+	// PeriodLimit caps the payouts of a period; it defaults to the sum insured
+	PeriodLimit *Amount `json:"period_limit,omitempty"`
+	// PaidInPeriod is what the policy already paid this period
+	PaidInPeriod *Amount `json:"paid_in_period,omitempty"`
+}
+
+// direction returns the direction, defaulting to Above
+func (s *Structure) direction() Direction {
+	if s.Direction == "" {
+		return Above
+	}
+	return s.Direction
+}
+
+// Worse reports whether index a is more severe than b
+func (s *Structure) Worse(a, b Decimal) bool {
+	if s.direction() == Below {
+		return a < b
+	}
+	return a > b
+}
+
+// Validate checks that the structure describes a payout curve
+func (s *Structure) Validate() error {
+	if d := s.direction(); d != Above && d != Below {
+		return fmt.Errorf("invalid direction %q", s.Direction)
+	}
+	switch s.Kind {
+	case Linear:
+		if !s.Worse(s.Exhaustion, s.Attachment) {
+			return fmt.Errorf("exhaustion %s must be %s attachment %s", s.Exhaustion, s.direction(), s.Attachment)
+		}
+	case Step:
+		if len(s.Tiers) == 0 {
+			return fmt.Errorf("step payout needs at least one tier")
+		}
+		for i, tier := range s.Tiers {
+			if tier.PayoutBps == 0 || tier.PayoutBps > MaxBps {
+				return fmt.Errorf("tier %d: payout_bps must be in 1-%d", i, MaxBps)
+			}
+			if i > 0 && (!s.Worse(tier.Threshold, s.Tiers[i-1].Threshold) || tier.PayoutBps <= s.Tiers[i-1].PayoutBps) {
+				return fmt.Errorf("tier %d: tiers must grow more severe and pay more", i)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid payout kind %q", s.Kind)
+	}
+	if s.SumInsured.IsZero() {
+		return fmt.Errorf("sum_insured must be positive")
+	}
+	if s.DeductibleBps > MaxBps {
+		return fmt.Errorf("deductible_bps must not exceed %d", MaxBps)
+	}
+	return nil
+}
+
+// Result is the payout for one index value
+type Result struct {
+	Index Decimal `json:"index"`
+	// Triggered reports whether the index reached the curve
+	Triggered bool `json:"triggered"`
+	// GrossBps is the curve's share of the sum insured before deductible and limit
+	GrossBps    uint32 `json:"gross_bps"`
+	GrossAmount Amount `json:"gross_amount"`
+	Deductible  Amount `json:"deductible"`
+	// Payout is what is paid, and PayoutBps its share of the sum insured
+	Payout    Amount `json:"payout"`
+	PayoutBps uint32 `json:"payout_bps"`
+	// LimitApplied reports whether the period limit reduced the payout
+	LimitApplied bool `json:"limit_applied,omitempty"`
+}
+
+// Calculate returns the payout under s for index
+func Calculate(s Structure, index Decimal) (*Result, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Result{Index: index, GrossBps: s.grossBps(index)}
+	r.Triggered = r.GrossBps > 0
+	r.GrossAmount = bpsOf(s.SumInsured, r.GrossBps)
+	r.Deductible = bpsOf(s.SumInsured, s.DeductibleBps)
+
+	net := r.GrossAmount.Int()
+	net.Sub(net, r.Deductible.Int())
+	if net.Sign() < 0 {
+		net.SetInt64(0)
+	}
+
+	remaining := s.SumInsured.Int()
+	if s.PeriodLimit != nil {
+		remaining = s.PeriodLimit.Int()
+	}
+	if s.PaidInPeriod != nil {
+		remaining.Sub(remaining, s.PaidInPeriod.Int())
+	}
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	if net.Cmp(remaining) > 0 {
+		net, r.LimitApplied = remaining, true
+	}
+	r.Payout = Amount{v: net}
+
+	bps := new(big.Int).Mul(net, big.NewInt(MaxBps))
+	bps.Quo(bps, s.SumInsured.Int())
+	r.PayoutBps = uint32(bps.Uint64())
+	return r, nil
+}
+
+// grossBps returns the curve's share at index
+func (s *Structure) grossBps(index Decimal) uint32 {
+	if s.Kind == Step {
+		var bps uint32
+		for _, tier := range s.Tiers {
+			if index == tier.Threshold || s.Worse(index, tier.Threshold) {
+				bps = tier.PayoutBps
+			}
+		}
+		return bps
+	}
+
+	if !s.Worse(index, s.Attachment) {
+		return 0
+	}
+	if index == s.Exhaustion || s.Worse(index, s.Exhaustion) {
+		return MaxBps
+	}
+	// (index - attachment) / (exhaustion - attachment), both negative below
+	num := new(big.Int).Sub(big.NewInt(int64(index)), big.NewInt(int64(s.Attachment)))
+	den := new(big.Int).Sub(big.NewInt(int64(s.Exhaustion)), big.NewInt(int64(s.Attachment)))
+	num.Abs(num).Mul(num, big.NewInt(MaxBps))
+	return uint32(num.Quo(num, den.Abs(den)).Uint64())
+}
@@ -0,0 +1,115 @@
+package payout
+
+import "testing"
+
+// oneEther is 10^18 wei
+var oneEther, _ = ParseAmount("1000000000000000000")
+
+func TestCalculate_Linear(t *testing.T) {
+	s := Structure{Kind: Linear, Attachment: MustDecimal("50"), Exhaustion: MustDecimal("150"), SumInsured: oneEther}
+	for index, want := range map[string]struct {
+		bps    uint32
+		payout string
+	}{
+		"40":        {0, "0"},
+		"50":        {0, "0"},
+		"75":        {2500, "250000000000000000"},
+		"100.00003": {5000, "500000000000000000"},
+		"150":       {10000, "1000000000000000000"},
+		"400":       {10000, "1000000000000000000"},
+	} {
+		r, err := Calculate(s, MustDecimal(index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.GrossBps != want.bps || r.PayoutBps != want.bps || r.Payout.String() != want.payout {
+			t.Errorf("index %s: %d bps, %s; want %d bps, %s", index, r.GrossBps, r.Payout, want.bps, want.payout)
+		}
+		if r.Triggered != (want.bps > 0) {
+			t.Errorf("index %s: Triggered = %v", index, r.Triggered)
+		}
+	}
+}
+
+func TestCalculate_LinearBelow(t *testing.T) {
+	// Drought cover: pays as rainfall falls from 30 mm to 10 mm
+	s := Structure{Kind: Linear, Direction: Below, Attachment: MustDecimal("30"), Exhaustion: MustDecimal("10"), SumInsured: NewAmount(1_000_000)}
+	for index, want := range map[string]uint32{"35": 0, "25": 2500, "10": 10000, "0": 10000} {
+		r, err := Calculate(s, MustDecimal(index))
+		if err != nil || r.GrossBps != want {
+			t.Errorf("index %s: %v, %v; want %d bps", index, r, err, want)
+		}
+	}
+}
+
+func TestCalculate_Step(t *testing.T) {
+	s := Structure{Kind: Step, SumInsured: NewAmount(1000), Tiers: []Tier{
+		{Threshold: MustDecimal("100"), PayoutBps: 2500},
+		{Threshold: MustDecimal("150"), PayoutBps: 5000},
+		{Threshold: MustDecimal("200"), PayoutBps: 10000},
+	}}
+	for index, want := range map[string]uint32{"99.999999": 0, "100": 2500, "180": 5000, "250": 10000} {
+		r, err := Calculate(s, MustDecimal(index))
+		if err != nil || r.GrossBps != want {
+			t.Errorf("index %s: %+v, %v; want %d bps", index, r, err, want)
+		}
+	}
+}
+
+func TestCalculate_DeductibleAndLimit(t *testing.T) {
+	limit, paid := NewAmount(600), NewAmount(250)
+	s := Structure{
+		Kind: Linear, Attachment: 0, Exhaustion: MustDecimal("100"), SumInsured: NewAmount(1000),
+		DeductibleBps: 1000, PeriodLimit: &limit, PaidInPeriod: &paid,
+	}
+
+	r, err := Calculate(s, MustDecimal("30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3000 bps gross = 300, less a 100 deductible
+	if r.GrossAmount.String() != "300" || r.Payout.String() != "200" || r.PayoutBps != 2000 || r.LimitApplied {
+		t.Errorf("under the limit: %+v", r)
+	}
+
+	r, _ = Calculate(s, MustDecimal("80"))
+	// 800 - 100 = 700, but only 600 - 250 is left this period
+	if r.Payout.String() != "350" || r.PayoutBps != 3500 || !r.LimitApplied {
+		t.Errorf("over the limit: payout %s, %d bps, limited %v", r.Payout, r.PayoutBps, r.LimitApplied)
+	}
+
+	r, _ = Calculate(s, MustDecimal("5"))
+	if !r.Triggered || !r.Payout.IsZero() {
+		t.Errorf("within the deductible: %+v", r)
+	}
+}
+
+func TestCalculate_RoundsDown(t *testing.T) {
+	// 1/3 of the way pays 3333 bps of an odd wei amount, rounded down
+	s := Structure{Kind: Linear, Attachment: 0, Exhaustion: MustDecimal("3"), SumInsured: NewAmount(1_000_000_000_000_000_001)}
+	r, err := Calculate(s, MustDecimal("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GrossBps != 3333 || r.Payout.String() != "333300000000000000" {
+		t.Errorf("got %d bps, %s", r.GrossBps, r.Payout)
+	}
+}
+
+func TestStructure_Validate(t *testing.T) {
+	for name, s := range map[string]Structure{
+		"unknown kind":        {Kind: "curve", SumInsured: NewAmount(1)},
+		"exhaustion reversed": {Kind: Linear, Attachment: 10, Exhaustion: 5, SumInsured: NewAmount(1)},
+		"below reversed":      {Kind: Linear, Direction: Below, Attachment: 5, Exhaustion: 10, SumInsured: NewAmount(1)},
+		"no tiers":            {Kind: Step, SumInsured: NewAmount(1)},
+		"tiers out of order":  {Kind: Step, SumInsured: NewAmount(1), Tiers: []Tier{{10, 5000}, {5, 6000}}},
+		"tier over 100%":      {Kind: Step, SumInsured: NewAmount(1), Tiers: []Tier{{10, 10001}}},
+		"no sum insured":      {Kind: Linear, Attachment: 0, Exhaustion: 1},
+		"deductible over":     {Kind: Linear, Exhaustion: 1, SumInsured: NewAmount(1), DeductibleBps: 10001},
+		"bad direction":       {Kind: Linear, Exhaustion: 1, SumInsured: NewAmount(1), Direction: "sideways"},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: Validate() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package tzgeo resolves IANA time zones from coordinates with time zone
+// boundary polygons, and turns civil times in a zone into instants across
+// daylight saving transitions. The zone rules are compiled in, so neither
+// needs the host's zoneinfo or a network.
+package tzgeo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// ErrNoZone is returned for a location no boundary contains
+var ErrNoZone = errors.New("no time zone boundary contains the location")
+
+// ErrNearBorder is returned for a location within the margin of a boundary
+// edge, where the outline cannot tell which side it is on
+var ErrNearBorder = errors.New("location is too close to a time zone boundary")
+
+// DefaultMargin is the margin, in kilometres, of the boundaries Parse
+// returns. The bundled outlines are never more than a few kilometres off
+// on the side of the zone they outline.
+const DefaultMargin = 10.0
+
+// kmPerDegree is the length of a degree of latitude
+const kmPerDegree = 111.195
+
+// bundled is a coarse outline of the zones of the main insured markets, in
+// the GeoJSON format of timezone-boundary-builder releases. Coasts follow
+// the shore, but a land border with a zone that keeps a different clock is
+// drawn 10 to 30 km inside each side, so the towns along it fall outside
+// every outline.
+//
+//go:embed zones.geojson
+var bundled []byte
+
+// point is a longitude, latitude pair, the GeoJSON order
+type point [2]float64
+
+// zone is the boundary of one time zone: polygons of rings, the first ring
+// of each its outline and the rest holes
+type zone struct {
+	tzid     string
+	polygons [][][]point
+	// minLon, minLat, maxLon, maxLat bound every polygon
+	minLon, minLat, maxLon, maxLat float64
+}
+
+// Boundaries maps coordinates to time zones
+type Boundaries struct {
+	zones []zone
+	// Margin is how close, in kilometres, a location may come to an edge,
+	// coast or border, before Lookup refuses it
+	Margin float64
+}
+
+var (
+	defaultOnce       sync.Once
+	defaultBoundaries *Boundaries
+)
+
+// Default returns the bundled boundaries. They only outline the interiors
+// of the zones of the main insured markets, coarsely, so Lookup refuses
+// locations within DefaultMargin of an edge, and locations near a border or
+// outside those markets should name their zone instead.
+func Default() *Boundaries {
+	defaultOnce.Do(func() {
+		b, err := Parse(bundled)
+		if err != nil {
+			panic(fmt.Sprintf("tzgeo: invalid bundled boundaries: %v", err))
+		}
+		defaultBoundaries = b
+	})
+	return defaultBoundaries
+}
+
+// Load reads boundaries from a GeoJSON feature collection whose features
+// have a "tzid" property and a Polygon or MultiPolygon geometry
+func Load(r io.Reader) (*Boundaries, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes boundaries from GeoJSON, as Load does
+func Parse(data []byte) (*Boundaries, error) {
+	var collection struct {
+		Features []struct {
+			Properties struct {
+				TZID string `json:"tzid"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("invalid boundaries: %w", err)
+	}
+	b := &Boundaries{Margin: DefaultMargin}
+	for i, f := range collection.Features {
+		tzid := f.Properties.TZID
+		if _, err := time.LoadLocation(tzid); tzid == "" || err != nil {
+			return nil, fmt.Errorf("feature %d: unknown time zone %q", i, tzid)
+		}
+		z := zone{tzid: tzid}
+		var err error
+		switch f.Geometry.Type {
+		case "Polygon":
+			var polygon [][]point
+			err = json.Unmarshal(f.Geometry.Coordinates, &polygon)
+			z.polygons = [][][]point{polygon}
+		case "MultiPolygon":
+			err = json.Unmarshal(f.Geometry.Coordinates, &z.polygons)
+		default:
+			err = fmt.Errorf("unsupported geometry %q", f.Geometry.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("feature %d (%s): %w", i, tzid, err)
+		}
+		if err := z.bound(); err != nil {
+			return nil, fmt.Errorf("feature %d (%s): %w", i, tzid, err)
+		}
+		b.zones = append(b.zones, z)
+	}
+	return b, nil
+}
+
+// bound checks the rings and computes the zone's bounding box
+func (z *zone) bound() error {
+	z.minLon, z.minLat, z.maxLon, z.maxLat = 180, 90, -180, -90
+	for _, polygon := range z.polygons {
+		if len(polygon) == 0 {
+			return errors.New("polygon has no rings")
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 {
+				return errors.New("ring has fewer than 4 points")
+			}
+			for _, p := range ring {
+				z.minLon, z.maxLon = min(z.minLon, p[0]), max(z.maxLon, p[0])
+				z.minLat, z.maxLat = min(z.minLat, p[1]), max(z.maxLat, p[1])
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the IANA name of the zone containing lat, lon. Where
+// boundaries overlap the first zone listed wins, so the answer never
+// depends on map order. A location within the margin of any edge fails
+// with ErrNearBorder.
+func (b *Boundaries) Lookup(lat, lon float64) (string, error) {
+	p := point{lon, lat}
+	if d := b.edgeDistance(p); d < b.Margin {
+		return "", fmt.Errorf("%w: %.4f,%.4f is %.1f km from an edge", ErrNearBorder, lat, lon, d)
+	}
+	for _, z := range b.zones {
+		if lon < z.minLon || lon > z.maxLon || lat < z.minLat || lat > z.maxLat {
+			continue
+		}
+		for _, polygon := range z.polygons {
+			if polygonContains(polygon, p) {
+				return z.tzid, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %.4f,%.4f", ErrNoZone, lat, lon)
+}
+
+// Zones lists the zones with a boundary, in file order
+func (b *Boundaries) Zones() []string {
+	names := make([]string, 0, len(b.zones))
+	for _, z := range b.zones {
+		names = append(names, z.tzid)
+	}
+	return names
+}
+
+// edgeDistance returns the distance in kilometres from p to the nearest
+// edge of a zone whose bounding box is within the margin, or +Inf
+func (b *Boundaries) edgeDistance(p point) float64 {
+	nearest := math.Inf(1)
+	if b.Margin <= 0 {
+		return nearest
+	}
+	// Distances are measured on a plane tangent at p, which is accurate
+	// to well under a kilometre across the margin
+	scale := math.Cos(p[1] * math.Pi / 180)
+	latMargin := b.Margin / kmPerDegree
+	lonMargin := latMargin / max(scale, 0.01)
+	for _, z := range b.zones {
+		if p[0] < z.minLon-lonMargin || p[0] > z.maxLon+lonMargin ||
+			p[1] < z.minLat-latMargin || p[1] > z.maxLat+latMargin {
+			continue
+		}
+		for _, polygon := range z.polygons {
+			for _, ring := range polygon {
+				for i := 1; i < len(ring); i++ {
+					nearest = min(nearest, segmentDistance(ring[i-1], ring[i], p, scale))
+				}
+			}
+		}
+	}
+	return nearest
+}
+
+// segmentDistance returns the distance in kilometres from p to the segment
+// a, c, with longitudes shrunk by scale
+func segmentDistance(a, c, p point, scale float64) float64 {
+	ax, ay := (a[0]-p[0])*scale, a[1]-p[1]
+	cx, cy := (c[0]-p[0])*scale, c[1]-p[1]
+	dx, dy := cx-ax, cy-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy) * kmPerDegree
+}
+
+// polygonContains reports whether p is inside the outline and outside every hole
+func polygonContains(polygon [][]point, p point) bool {
+	if !ringContains(polygon[0], p) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains casts a ray east from p and counts the edges it crosses
+func ringContains(ring []point, p point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, c := ring[i], ring[j]
+		if (a[1] > p[1]) != (c[1] > p[1]) &&
+			p[0] < (c[0]-a[0])*(p[1]-a[1])/(c[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
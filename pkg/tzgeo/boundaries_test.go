@@ -0,0 +1,143 @@
+package tzgeo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultLookup(t *testing.T) {
+	b := Default()
+	for _, tc := range []struct {
+		name     string
+		lat, lon float64
+		want     string
+	}{
+		{"New York", 40.7128, -74.0060, "America/New_York"},
+		{"JFK", 40.6413, -73.7781, "America/New_York"},
+		{"Miami", 25.7617, -80.1918, "America/New_York"},
+		{"Indianapolis", 39.7684, -86.1581, "America/New_York"},
+		{"Chicago", 41.8781, -87.6298, "America/Chicago"},
+		{"Nashville", 36.1627, -86.7816, "America/Chicago"},
+		{"Denver", 39.7392, -104.9903, "America/Denver"},
+		{"Albuquerque", 35.0844, -106.6504, "America/Denver"},
+		{"Phoenix", 33.4484, -112.0740, "America/Phoenix"},
+		{"Flagstaff", 35.1983, -111.6513, "America/Phoenix"},
+		// The Navajo Nation keeps DST inside Arizona; the Hopi Reservation within it does not
+		{"Chinle", 36.1544, -109.5526, "America/Denver"},
+		{"Kayenta", 36.7278, -110.2546, "America/Denver"},
+		{"Second Mesa", 35.7989, -110.5040, "America/Phoenix"},
+		{"Los Angeles", 34.0522, -118.2437, "America/Los_Angeles"},
+		{"London", 51.5074, -0.1278, "Europe/London"},
+		{"Belfast", 54.5973, -5.9301, "Europe/London"},
+		{"Dublin", 53.3498, -6.2603, "Europe/Dublin"},
+		{"Lisbon", 38.7223, -9.1393, "Europe/Lisbon"},
+		{"Tabernas", 37.2, -2.5, "Europe/Madrid"},
+		{"Paris", 48.8566, 2.3522, "Europe/Paris"},
+		{"Berlin", 52.52, 13.405, "Europe/Berlin"},
+		{"Tokyo", 35.6762, 139.6503, "Asia/Tokyo"},
+		{"Sydney", -33.8688, 151.2093, "Australia/Sydney"},
+	} {
+		got, err := b.Lookup(tc.lat, tc.lon)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: Lookup = %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	// Busan is across the strait from Japan; mid-Atlantic has no zone
+	for _, p := range [][2]float64{{35.18, 129.07}, {30, -40}} {
+		if got, err := b.Lookup(p[0], p[1]); !errors.Is(err, ErrNoZone) {
+			t.Errorf("Lookup(%v) = %q, %v, want ErrNoZone", p, got, err)
+		}
+	}
+}
+
+func TestLookupNearBorder(t *testing.T) {
+	b := Default()
+	for name, p := range map[string][2]float64{
+		"Crossville":    {35.9489, -85.0269},
+		"Springerville": {34.1333, -109.2856},
+		"Troy":          {48.4597, -115.8890},
+		"off Sydney":    {-33.9, 151.55},
+	} {
+		if got, err := b.Lookup(p[0], p[1]); !errors.Is(err, ErrNearBorder) {
+			t.Errorf("%s: Lookup = %q, %v, want ErrNearBorder", name, got, err)
+		}
+	}
+
+	// Without a margin the outline alone decides
+	exact, err := Parse(bundled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exact.Margin = 0
+	if got, err := exact.Lookup(34.0, -109.35); err != nil || got != "America/Phoenix" {
+		t.Errorf("west of the New Mexico line without a margin = %q, %v", got, err)
+	}
+}
+
+func TestDefaultLookupBorderBands(t *testing.T) {
+	// Towns across or beside a border with a zone that keeps a different
+	// clock. The outlines stop short of those borders, so these must be
+	// refused rather than resolved to the zone next door.
+	b := Default()
+	for name, p := range map[string][2]float64{
+		"Crossville TN (Central)":        {35.9489, -85.0269},
+		"Columbia KY (Central)":          {37.1028, -85.3063},
+		"Stanthorpe QLD (Brisbane)":      {-28.6545, 151.9330},
+		"Goondiwindi QLD (Brisbane)":     {-28.5470, 150.3070},
+		"Broken Hill NSW (Adelaide)":     {-31.9530, 141.4530},
+		"Woodstock NB (Atlantic)":        {46.1500, -67.5700},
+		"Ontonagon MI (Eastern)":         {46.8711, -89.3140},
+		"Washington Island WI (Central)": {45.3800, -86.9000},
+		"Williston ND (Central)":         {48.1470, -103.6180},
+		"Vale OR (Mountain)":             {43.9821, -117.2382},
+		"Page AZ (no DST)":               {36.9147, -111.4558},
+		"Temple Bar AZ (no DST)":         {36.0200, -114.3300},
+		"Boquillas MX":                   {29.1800, -102.9500},
+		"El Paso":                        {31.7619, -106.4850},
+		"Navajo Nation":                  {35.25, -110.2},
+		"Barrancos PT":                   {38.1300, -6.9800},
+	} {
+		if got, err := b.Lookup(p[0], p[1]); !errors.Is(err, ErrNoZone) && !errors.Is(err, ErrNearBorder) {
+			t.Errorf("%s: Lookup = %q, %v, want a refusal", name, got, err)
+		}
+	}
+}
+
+func TestLookupHoles(t *testing.T) {
+	// A square zone with a square hole holding another zone
+	b, err := Load(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"tzid": "Europe/Rome"}, "geometry": {"type": "Polygon", "coordinates": [
+			[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+			[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]]}},
+		{"type": "Feature", "properties": {"tzid": "Europe/Vatican"}, "geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]]]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Lookup(2, 2); got != "Europe/Rome" {
+		t.Errorf("outside the hole = %q", got)
+	}
+	if got, _ := b.Lookup(5, 5); got != "Europe/Vatican" {
+		t.Errorf("inside the hole = %q", got)
+	}
+	if zones := b.Zones(); len(zones) != 2 || zones[0] != "Europe/Rome" {
+		t.Errorf("Zones = %v", zones)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"unknown zone": `{"features": [{"properties": {"tzid": "Mars/Olympus"}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,0]]]}}]}`,
+		"no zone":      `{"features": [{"properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,0]]]}}]}`,
+		"point":        `{"features": [{"properties": {"tzid": "UTC"}, "geometry": {"type": "Point", "coordinates": [0, 0]}}]}`,
+		"short ring":   `{"features": [{"properties": {"tzid": "UTC"}, "geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[0,0]]]}}]}`,
+		"not json":     `zones`,
+	} {
+		if _, err := Load(strings.NewReader(doc)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
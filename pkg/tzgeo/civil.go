@@ -0,0 +1,67 @@
+package tzgeo
+
+import (
+	"sort"
+	"time"
+)
+
+// Bound picks the instant a civil time resolves to when clocks read it
+// twice, or never
+type Bound int
+
+const (
+	// Earliest resolves a repeated time to its first occurrence, for the
+	// start of a window
+	Earliest Bound = iota
+	// Latest resolves a repeated time to its second occurrence, for the
+	// end of a window
+	Latest
+)
+
+// At returns the instants at which clocks in loc read wall's date and
+// time of day; wall's own location is ignored. There are none when clocks
+// skip the time springing forward, two when they repeat it falling back,
+// and one otherwise.
+func At(wall time.Time, loc *time.Location) []time.Time {
+	naive := time.Date(wall.Year(), wall.Month(), wall.Day(),
+		wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+	// The instant is within a day of naive, so its offset is one of these
+	var instants []time.Time
+	for _, probe := range []time.Time{naive.Add(-24 * time.Hour), naive, naive.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		at := naive.Add(-time.Duration(offset) * time.Second)
+		if _, actual := at.In(loc).Zone(); actual != offset {
+			continue
+		}
+		instants = append(instants, at.In(loc))
+	}
+	sort.Slice(instants, func(i, j int) bool { return instants[i].Before(instants[j]) })
+	unique := instants[:0]
+	for _, at := range instants {
+		if len(unique) == 0 || !unique[len(unique)-1].Equal(at) {
+			unique = append(unique, at)
+		}
+	}
+	return unique
+}
+
+// Resolve returns the instant clocks in loc read wall's date and time of
+// day. A time skipped springing forward resolves to the transition, when
+// clocks jump past it; a repeated time to its occurrence picked by bound.
+func Resolve(wall time.Time, loc *time.Location, bound Bound) time.Time {
+	instants := At(wall, loc)
+	switch {
+	case len(instants) == 0:
+		// Read with the offset before the transition, the time falls just
+		// after it, in the zone period the transition starts
+		naive := time.Date(wall.Year(), wall.Month(), wall.Day(),
+			wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+		_, before := naive.Add(-24 * time.Hour).In(loc).Zone()
+		start, _ := naive.Add(-time.Duration(before) * time.Second).In(loc).ZoneBounds()
+		return start
+	case bound == Latest:
+		return instants[len(instants)-1]
+	default:
+		return instants[0]
+	}
+}
@@ -0,0 +1,65 @@
+package tzgeo
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestAt(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	wall := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	if got := At(wall(7, 13, 10, 0), ny); len(got) != 1 || got[0].UTC() != time.Date(2024, 7, 13, 14, 0, 0, 0, time.UTC) {
+		t.Errorf("summer = %v", got)
+	}
+	if got := At(wall(1, 13, 10, 0), ny); len(got) != 1 || got[0].UTC() != time.Date(2024, 1, 13, 15, 0, 0, 0, time.UTC) {
+		t.Errorf("winter = %v", got)
+	}
+	// Clocks jump from 02:00 to 03:00 on 10 March
+	if got := At(wall(3, 10, 2, 30), ny); len(got) != 0 {
+		t.Errorf("skipped = %v", got)
+	}
+	// and go back from 02:00 to 01:00 on 3 November
+	got := At(wall(11, 3, 1, 30), ny)
+	if len(got) != 2 ||
+		got[0].UTC() != time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC) ||
+		got[1].UTC() != time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC) {
+		t.Errorf("repeated = %v", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	sydney := mustLoad(t, "Australia/Sydney")
+	for _, tc := range []struct {
+		name  string
+		wall  time.Time
+		loc   *time.Location
+		bound Bound
+		want  time.Time
+	}{
+		{"skipped start", time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC), ny, Earliest, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)},
+		{"skipped end", time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC), ny, Latest, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC)},
+		{"repeated start", time.Date(2024, 11, 3, 1, 30, 0, 0, time.UTC), ny, Earliest, time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC)},
+		{"repeated end", time.Date(2024, 11, 3, 1, 30, 0, 0, time.UTC), ny, Latest, time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC)},
+		// Southern hemisphere: clocks spring forward on 6 October
+		{"sydney skipped", time.Date(2024, 10, 6, 2, 15, 0, 0, time.UTC), sydney, Earliest, time.Date(2024, 10, 5, 16, 0, 0, 0, time.UTC)},
+		{"sydney summer", time.Date(2024, 12, 25, 10, 0, 0, 0, time.UTC), sydney, Earliest, time.Date(2024, 12, 24, 23, 0, 0, 0, time.UTC)},
+		{"no transitions", time.Date(2024, 7, 1, 0, 0, 0, 0, time.FixedZone("X", 3600)), time.UTC, Latest, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if got := Resolve(tc.wall, tc.loc, tc.bound); !got.Equal(tc.want) {
+			t.Errorf("%s: Resolve = %v, want %v", tc.name, got.UTC(), tc.want)
+		}
+	}
+}
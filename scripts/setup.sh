@@ -59,7 +59,7 @@ echo "  Building contracts..."
 cd contracts && forge build && cd ..
 
 echo "  Building performer..."
-go build -o bin/sunre-avs cmd/main.go
+go build -o bin/sunre-avs ./cmd
 
 # Setup complete
 echo -e "\n${GREEN}================================================${NC}"
@@ -40,7 +40,7 @@ FAILED=0
 
 case $TEST_TYPE in
     unit)
-        run_test "Go unit tests" "go test ./cmd/... -v" || FAILED=1
+        run_test "Go unit tests" "go test ./cmd/... ./pkg/... -v" || FAILED=1
         ;;
         
     contracts)
@@ -54,7 +54,7 @@ case $TEST_TYPE in
         
     all)
         # Run all tests
-        run_test "Go unit tests" "go test ./cmd/... -v" || FAILED=1
+        run_test "Go unit tests" "go test ./cmd/... ./pkg/... -v" || FAILED=1
         run_test "Contract compilation" "cd contracts && forge build" || FAILED=1
         run_test "Contract tests" "cd contracts && forge test 2>/dev/null" || true  # No tests yet
         